@@ -2,8 +2,11 @@ package attestation
 
 import (
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
 
+	"mainstay/clients"
 	confpkg "mainstay/config"
 	"mainstay/crypto"
 
@@ -11,17 +14,22 @@ import (
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
-	"github.com/btcsuite/btcd/rpcclient"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
 
+// error consts
+const (
+	ERROR_SIGS_MISSING          = "Missing signatures for multisig attestation - required"
+	ERROR_INPUT_MISSING_FOR_FEE = "Missing input transaction output for attestation fee"
+)
+
 // AttestClient structure
 // Maintains RPC connections to main chain client
 // Handles generating staychain next address and next transaction
 // and verifying that the correct chain of transactions is maintained
 type AttestClient struct {
-	MainClient   *rpcclient.Client
+	MainClient   clients.MainChainClient
 	MainChainCfg *chaincfg.Params
 	pk0          string
 	txid0        string
@@ -34,7 +42,13 @@ type AttestClient struct {
 // NewAttestClient returns a pointer to a new AttestClient instance
 // Initially locates the genesis transaction in the main chain wallet
 // and verifies that the corresponding private key is in the wallet
-func NewAttestClient(config *confpkg.Config) *AttestClient {
+// A custom main chain client can optionally replace the config client
+func NewAttestClient(config *confpkg.Config, customMainClient ...clients.MainChainClient) *AttestClient {
+	var mainClient clients.MainChainClient = config.MainClient()
+	if len(customMainClient) > 0 {
+		mainClient = customMainClient[0]
+	}
+
 	// Get initial private key from initial funding transaction of main client
 	pk := config.InitPK()
 	pkWif, errPkWif := crypto.GetWalletPrivKey(pk)
@@ -42,7 +56,7 @@ func NewAttestClient(config *confpkg.Config) *AttestClient {
 		log.Printf("Invalid private key %s\n", pk)
 		log.Fatal(errPkWif)
 	}
-	importErr := config.MainClient().ImportPrivKeyRescan(pkWif, "init", false)
+	importErr := mainClient.ImportPrivKeyRescan(pkWif, "init", false)
 	if importErr != nil {
 		log.Printf("Could not import initial private key %s\n", pk)
 		log.Fatal(importErr)
@@ -63,9 +77,9 @@ func NewAttestClient(config *confpkg.Config) *AttestClient {
 			log.Fatal("Client address missing from multisig script")
 		}
 
-		return &AttestClient{config.MainClient(), config.MainChainCfg(), pk, config.InitTX(), multisig, pubkeys, numOfSigs, pkWif}
+		return &AttestClient{mainClient, config.MainChainCfg(), pk, config.InitTX(), multisig, pubkeys, numOfSigs, pkWif}
 	}
-	return &AttestClient{config.MainClient(), config.MainChainCfg(), pk, config.InitTX(), multisig, []*btcec.PublicKey{}, 1, pkWif}
+	return &AttestClient{mainClient, config.MainChainCfg(), pk, config.InitTX(), multisig, []*btcec.PublicKey{}, 1, pkWif}
 }
 
 // Get next attestation key by tweaking with latest hash
@@ -108,6 +122,20 @@ func (w *AttestClient) GetNextAttestationAddr(key *btcutil.WIF, hash chainhash.H
 	return myAddr, ""
 }
 
+// Get base multisig script or base pubkey of the staychain that
+// attestation addresses are generated from by tweaking with each hash
+// Only the script is returned for multisig attestations
+func (w *AttestClient) GetBaseScriptAndPubkey() ([]byte, []byte, error) {
+	if w.script0 != "" {
+		script, errScript := hex.DecodeString(w.script0)
+		if errScript != nil {
+			return nil, nil, errScript
+		}
+		return script, nil, nil
+	}
+	return nil, w.WalletPriv.SerializePubKey(), nil
+}
+
 // Method to import address to client and report import error
 func (w *AttestClient) ImportAttestationAddr(addr btcutil.Address) error {
 	importErr := w.MainClient.ImportAddress(addr.String())
@@ -140,6 +168,22 @@ func (w *AttestClient) createAttestation(paytoaddr btcutil.Address, txunspent bt
 	return msgtx, nil
 }
 
+// Calculate fee of an attestation transaction from the value of the output it spends
+func (w *AttestClient) getAttestationFee(msgtx *wire.MsgTx) (int64, error) {
+	if len(msgtx.TxIn) == 0 || len(msgtx.TxOut) == 0 {
+		return 0, nil
+	}
+	prevOut := msgtx.TxIn[0].PreviousOutPoint
+	prevTx, errPrev := w.MainClient.GetRawTransaction(&prevOut.Hash)
+	if errPrev != nil {
+		return 0, errPrev
+	}
+	if int(prevOut.Index) >= len(prevTx.MsgTx().TxOut) {
+		return 0, errors.New(ERROR_INPUT_MISSING_FOR_FEE)
+	}
+	return prevTx.MsgTx().TxOut[prevOut.Index].Value - msgtx.TxOut[0].Value, nil
+}
+
 // Given a hash return the corresponding client private key and redeemscript
 func (w *AttestClient) GetKeyAndScriptFromHash(hash chainhash.Hash) (btcutil.WIF, string) {
 	var key btcutil.WIF
@@ -195,6 +239,9 @@ func (w *AttestClient) signAttestation(msgtx *wire.MsgTx, sigs [][]byte, hash ch
 		mySigs, script := crypto.ParseScriptSig(signedMsgTx.TxIn[0].SignatureScript)
 		if hex.EncodeToString(script) == redeemScript {
 			combinedSigs := append(mySigs, sigs...)
+			if len(combinedSigs) < w.numOfSigs {
+				return nil, errors.New(fmt.Sprintf("%s %d got %d", ERROR_SIGS_MISSING, w.numOfSigs, len(combinedSigs)))
+			}
 
 			// take only numOfSigs required
 			combinedScriptSig := crypto.CreateScriptSig(combinedSigs[:w.numOfSigs], script)
@@ -0,0 +1,42 @@
+package attestation
+
+import (
+	"time"
+)
+
+// AttestClock interface
+// Provides the current time to the attestation service so
+// that waiting times can be controlled in simulations
+type AttestClock interface {
+	Now() time.Time
+}
+
+// AttestClockReal structure
+// Implements AttestClock using the system time
+type AttestClockReal struct{}
+
+// Return system time
+func (AttestClockReal) Now() time.Time {
+	return time.Now()
+}
+
+// AttestClockFake structure
+// Implements AttestClock for unit-testing with a manually advanced time
+type AttestClockFake struct {
+	now time.Time
+}
+
+// NewAttestClockFake returns a pointer to an AttestClockFake set to the time provided
+func NewAttestClockFake(now time.Time) *AttestClockFake {
+	return &AttestClockFake{now}
+}
+
+// Return fake time
+func (c *AttestClockFake) Now() time.Time {
+	return c.now
+}
+
+// Advance fake time by the duration provided
+func (c *AttestClockFake) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
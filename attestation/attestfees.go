@@ -19,6 +19,9 @@ const FEE_API_URL = "https://bitcoinfees.earn.com/api/v1/fees/recommended"
 // options: fastestFee, halfHourFee, hourFee
 const BEST_FEE_TYPE = "hourFee"
 
+// fee API url used for requests - can be replaced for testing
+var feeApiUrl = FEE_API_URL
+
 // GetFee returns the best fee based on the parameters provided
 func GetFee(defaultFee bool, customFeeType ...string) int {
 	if defaultFee {
@@ -42,7 +45,7 @@ func GetFee(defaultFee bool, customFeeType ...string) int {
 
 // GetFeeFromAPI attempts to get the best bitcoinfee from the fee API specified
 func GetFeeFromAPI(feeType string) int {
-	resp, getErr := http.Get(FEE_API_URL)
+	resp, getErr := http.Get(feeApiUrl)
 	if getErr != nil {
 		log.Printf("*Fees* API request failed - Using default fee value: %d\n", FEE_PER_BYTE)
 		return FEE_PER_BYTE
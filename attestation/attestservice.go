@@ -5,17 +5,16 @@ import (
 	"context"
 	"errors"
 	"log"
+	"os"
 	"sync"
 	"time"
 
 	confpkg "mainstay/config"
-	"mainstay/messengers"
 	"mainstay/models"
 	"mainstay/server"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
-	zmq "github.com/pebbe/zmq4"
 )
 
 // Attestation Service is the main processes that handles generating
@@ -69,19 +68,18 @@ type AttestService struct {
 	config      *confpkg.Config
 	attester    *AttestClient
 	server      *server.Server
-	publisher   *messengers.PublisherZmq
-	subscribers []*messengers.SubscriberZmq
+	signer      AttestSigner
+	clock       AttestClock
 	state       AttestationState
 	attestation *models.Attestation
 	errorState  error
 	isRegtest   bool
+	pendingTx   *wire.MsgTx // latest attestation tx awaiting confirmation
 }
 
 var attestDelay time.Duration // delay between states
 var confirmTime time.Time     // delay untill confirmation
 
-var poller *zmq.Poller // poller to add all subscriber/publisher sockets
-
 // NewAttestService returns a pointer to an AttestService instance
 // Initiates Attest Client and Attest Server
 func NewAttestService(ctx context.Context, wg *sync.WaitGroup, server *server.Server, config *confpkg.Config, isRegtest bool) *AttestService {
@@ -94,17 +92,10 @@ func NewAttestService(ctx context.Context, wg *sync.WaitGroup, server *server.Se
 	// initiate attestation client
 	attester := NewAttestClient(config)
 
-	// Initialise publisher for sending new hashes and txs
-	// and subscribers to receive sig responses
-	poller = zmq.NewPoller()
-	publisher := messengers.NewPublisherZmq(confpkg.MAIN_PUBLISHER_PORT, poller)
-	var subscribers []*messengers.SubscriberZmq
-	subtopics := []string{confpkg.TOPIC_SIGS}
-	for _, nodeaddr := range config.MultisigNodes() {
-		subscribers = append(subscribers, messengers.NewSubscriberZmq(nodeaddr, subtopics, poller))
-	}
+	// initiate zmq signer communication
+	signer := NewAttestSignerZmq(config)
 
-	return &AttestService{ctx, wg, config, attester, server, publisher, subscribers, ASTATE_INIT, models.NewAttestationDefault(), nil, isRegtest}
+	return &AttestService{ctx, wg, config, attester, server, signer, AttestClockReal{}, ASTATE_INIT, models.NewAttestationDefault(), nil, isRegtest, nil}
 }
 
 // Run Attest Service
@@ -156,8 +147,12 @@ func (s *AttestService) doStateInit() {
 			return // will rebound to init
 		}
 		s.attestation = models.NewAttestation(unconfirmedTxid, &commitment) // initialise attestation
-		rawTx, _ := s.config.MainClient().GetRawTransaction(&unconfirmedTxid)
+		rawTx, _ := s.attester.MainClient.GetRawTransaction(&unconfirmedTxid)
 		s.attestation.Tx = *rawTx.MsgTx() // set msgTx
+		if s.setFailure(s.updateFee()) {
+			return // will rebound to init
+		}
+		s.pendingTx = s.attestation.Tx.Copy()
 
 		s.state = ASTATE_AWAIT_CONFIRMATION // update attestation state
 	} else {
@@ -173,10 +168,17 @@ func (s *AttestService) doStateInit() {
 				s.attestation = models.NewAttestation(*unspentTxid, &commitment)
 				// update server with latest confirmed attestation
 				s.attestation.Confirmed = true
-				rawTx, _ := s.config.MainClient().GetRawTransaction(unspentTxid)
-				walletTx, _ := s.config.MainClient().GetTransaction(unspentTxid)
+				rawTx, _ := s.attester.MainClient.GetRawTransaction(unspentTxid)
+				walletTx, _ := s.attester.MainClient.GetTransaction(unspentTxid)
 				s.attestation.Tx = *rawTx.MsgTx()  // set msgTx
 				s.attestation.UpdateInfo(walletTx) // set tx info
+				if s.setFailure(s.updateFee()) {
+					return // will rebound to init
+				}
+				errBlock := s.updateBlockInfo(walletTx.BlockHash)
+				if s.setFailure(errBlock) {
+					return // will rebound to init
+				}
 
 				errUpdate := s.server.UpdateLatestAttestation(*s.attestation)
 				if s.setFailure(errUpdate) {
@@ -186,7 +188,7 @@ func (s *AttestService) doStateInit() {
 				s.attestation = models.NewAttestationDefault()
 			}
 			confirmedHash := s.attestation.CommitmentHash()
-			s.publisher.SendMessage((&confirmedHash).CloneBytes(), confpkg.TOPIC_CONFIRMED_HASH) // update clients
+			s.signer.SendConfirmedHash((&confirmedHash).CloneBytes()) // update clients
 
 			s.state = ASTATE_NEXT_COMMITMENT // update attestation state
 		} else {
@@ -201,8 +203,9 @@ func (s *AttestService) doStateInit() {
 				return // will rebound to init
 			}
 			s.attestation = models.NewAttestation(lastCommitmentHash, &commitment) // initialise attestation
-			rawTx, _ := s.config.MainClient().GetRawTransaction(&unconfirmedTxid)
+			rawTx, _ := s.attester.MainClient.GetRawTransaction(&unconfirmedTxid)
 			s.attestation.Tx = *rawTx.MsgTx() // set msgTx
+			s.pendingTx = s.attestation.Tx.Copy()
 
 			s.state = ASTATE_AWAIT_CONFIRMATION // update attestation state
 		}
@@ -226,17 +229,26 @@ func (s *AttestService) doStateNextCommitment() {
 
 	// check if commitment has already been attested
 	log.Printf("********** received commitment hash: %s\n", latestCommitmentHash.String())
+	if emptySlots := latestCommitment.GetEmptySlots(); len(emptySlots) > 0 {
+		log.Printf("********** empty slots: %v\n", emptySlots)
+	}
 	if latestCommitmentHash == s.attestation.CommitmentHash() {
 		log.Printf("********** Skipping attestation - Client commitment already attested")
 		return // will remain at the same state
 	}
 
+	// archive commitment list for rebuilding the db from the staychain
+	if s.setFailure(s.archiveCommitment(latestCommitment)) {
+		return // will rebound to init
+	}
+
 	// publish new commitment hash to clients
-	s.publisher.SendMessage((&latestCommitmentHash).CloneBytes(), confpkg.TOPIC_NEW_HASH)
+	s.signer.SendNewHash((&latestCommitmentHash).CloneBytes())
 
 	// initialise new attestation with commitment
 	s.attestation = models.NewAttestationDefault()
 	s.attestation.SetCommitment(&latestCommitment)
+	s.server.PublishEvent(s.newEvent(models.EVENT_ROUND_STARTED))
 
 	s.state = ASTATE_NEW_ATTESTATION // update attestation state
 }
@@ -269,17 +281,20 @@ func (s *AttestService) doStateNewAttestation() {
 		var createErr error
 		var newTx *wire.MsgTx
 		newTx, createErr = s.attester.createAttestation(paytoaddr, txunspent, false)
-		s.attestation.Tx = *newTx
 		if s.setFailure(createErr) {
 			return // will rebound to init
 		}
+		s.attestation.Tx = *newTx
+		if s.setFailure(s.updateFee()) {
+			return // will rebound to init
+		}
 
 		log.Printf("********** pre-sign txid: %s\n", s.attestation.Tx.TxHash().String())
 
 		// publish pre signed transaction
 		var txbytes bytes.Buffer
 		s.attestation.Tx.Serialize(&txbytes)
-		s.publisher.SendMessage(txbytes.Bytes(), confpkg.TOPIC_NEW_TX)
+		s.signer.SendNewTx(txbytes.Bytes())
 
 		s.state = ASTATE_SIGN_ATTESTATION // update attestation state
 		attestDelay = ATIME_SIGS          // add sigs waiting time
@@ -296,17 +311,8 @@ func (s *AttestService) doStateNewAttestation() {
 func (s *AttestService) doStateSignAttestation() {
 	log.Println("*AttestService* SIGN ATTESTATION")
 
-	// Read sigs using subscribers
-	var sigs [][]byte
-	sockets, _ := poller.Poll(-1)
-	for _, socket := range sockets {
-		for _, sub := range s.subscribers {
-			if sub.Socket() == socket.Socket {
-				_, msg := sub.ReadMessage()
-				sigs = append(sigs, msg)
-			}
-		}
-	}
+	// Read sigs from signers
+	sigs := s.signer.GetSigs()
 	log.Printf("********** received %d signatures\n", len(sigs))
 
 	// get last confirmed commitment from server
@@ -348,10 +354,20 @@ func (s *AttestService) doStateSendAttestation() {
 	s.attestation.Txid = txid
 	log.Printf("********** attestation transaction committed with txid: (%s)\n", txid)
 
+	// attestation spending the same output as the pending attestation replaces it
+	if s.pendingTx != nil && s.pendingTx.TxHash() != txid && spendsSameOutput(s.pendingTx, &s.attestation.Tx) {
+		replacedEvent := s.newEvent(models.EVENT_ATTESTATION_REPLACED)
+		replacedEvent.Txid = s.pendingTx.TxHash()
+		replacedEvent.ReplacementTxid = txid
+		s.server.PublishEvent(replacedEvent)
+	}
+	s.pendingTx = s.attestation.Tx.Copy()
+	s.server.PublishEvent(s.newEvent(models.EVENT_ATTESTATION_BROADCAST))
+
 	s.state = ASTATE_AWAIT_CONFIRMATION // update attestation state
 	attestDelay = ATIME_CONFIRMATION    // add confirmation waiting time
 	log.Printf("********** sleeping for: %s ...\n", attestDelay.String())
-	confirmTime = time.Now() // set time for awaiting confirmation
+	confirmTime = s.clock.Now() // set time for awaiting confirmation
 }
 
 // ASTATE_AWAIT_CONFIRMATION
@@ -364,12 +380,12 @@ func (s *AttestService) doStateAwaitConfirmation() {
 
 	// if attestation has been unconfirmed for too long
 	// set to handle unconfirmed state
-	if time.Since(confirmTime) > ATIME_HANDLE_UNCONFIRMED {
+	if s.clock.Now().Sub(confirmTime) > ATIME_HANDLE_UNCONFIRMED {
 		s.state = ASTATE_HANDLE_UNCONFIRMED
 		return
 	}
 
-	newTx, err := s.config.MainClient().GetTransaction(&s.attestation.Txid)
+	newTx, err := s.attester.MainClient.GetTransaction(&s.attestation.Txid)
 	if s.setFailure(err) {
 		return // will rebound to init
 	}
@@ -380,17 +396,30 @@ func (s *AttestService) doStateAwaitConfirmation() {
 		// update server with latest confirmed attestation
 		s.attestation.Confirmed = true
 		s.attestation.UpdateInfo(newTx)
+		errBlock := s.updateBlockInfo(newTx.BlockHash)
+		if s.setFailure(errBlock) {
+			return // will rebound to init
+		}
 		errUpdate := s.server.UpdateLatestAttestation(*s.attestation)
 		if s.setFailure(errUpdate) {
 			return // will rebound to init
 		}
 
 		confirmedHash := s.attestation.CommitmentHash()
-		s.publisher.SendMessage((&confirmedHash).CloneBytes(), confpkg.TOPIC_CONFIRMED_HASH) //update clients
+		s.signer.SendConfirmedHash((&confirmedHash).CloneBytes()) //update clients
+		if errPublish := s.publishConfirmed(); errPublish != nil {
+			log.Printf("********** failed publishing confirmed attestation: %v\n", errPublish)
+		}
+
+		s.pendingTx = nil
+		confirmedEvent := s.newEvent(models.EVENT_ATTESTATION_CONFIRMED)
+		blockhash, _ := chainhash.NewHashFromStr(newTx.BlockHash)
+		confirmedEvent.Blockhash = *blockhash
+		s.server.PublishEvent(confirmedEvent)
 
 		s.state = ASTATE_NEXT_COMMITMENT // update attestation state
 
-		attestDelay = ATIME_NEW_ATTESTATION - time.Since(confirmTime) // add new attestation waiting time - subtract waiting time
+		attestDelay = ATIME_NEW_ATTESTATION - s.clock.Now().Sub(confirmTime) // add new attestation waiting time - subtract waiting time
 	} else {
 		attestDelay = ATIME_CONFIRMATION // add confirmation waiting time
 	}
@@ -449,3 +478,118 @@ func (s *AttestService) setFailure(err error) bool {
 	}
 	return false
 }
+
+// Update attestation fee from the attestation transaction
+func (s *AttestService) updateFee() error {
+	fee, errFee := s.attester.getAttestationFee(&s.attestation.Tx)
+	if errFee != nil {
+		return errFee
+	}
+	s.attestation.Fee = fee
+	return nil
+}
+
+// Fetch the block of the confirmed attestation and update
+// attestation info with the block height and transaction SPV proof
+func (s *AttestService) updateBlockInfo(blockhash string) error {
+	hash, errHash := chainhash.NewHashFromStr(blockhash)
+	if errHash != nil {
+		return errHash
+	}
+	header, errHeader := s.attester.MainClient.GetBlockHeaderVerbose(hash)
+	if errHeader != nil {
+		return errHeader
+	}
+	s.attestation.Info.Height = int64(header.Height)
+	block, errBlock := s.attester.MainClient.GetBlock(hash)
+	if errBlock != nil {
+		return errBlock
+	}
+	return s.attestation.UpdateSpvInfo(block)
+}
+
+// Publish confirmed attestation message and the proof bundle of
+// each client slot with a non zero commitment to subscribed clients
+func (s *AttestService) publishConfirmed() error {
+	msg, errMsg := models.NewAttestationMessage(*s.attestation).Serialize()
+	if errMsg != nil {
+		return errMsg
+	}
+	s.signer.SendConfirmedAttestation(msg)
+
+	commitment, errCommitment := s.attestation.Commitment()
+	if errCommitment != nil {
+		return errCommitment
+	}
+	spvProof, errSpv := s.attestation.Info.SpvProof()
+	if errSpv != nil {
+		return errSpv
+	}
+	blockhash, errHash := chainhash.NewHashFromStr(s.attestation.Info.Blockhash)
+	if errHash != nil {
+		return errHash
+	}
+	script, pubkey, errBase := s.attester.GetBaseScriptAndPubkey()
+	if errBase != nil {
+		return errBase
+	}
+	for _, proof := range s.server.GetCommitmentMerkleProofs(*commitment) {
+		if proof.Commitment == (chainhash.Hash{}) {
+			continue
+		}
+		slotProof := models.SlotProof{Txid: s.attestation.Txid, Blockhash: *blockhash, Confirmed: true,
+			Proof: proof, Spv: spvProof}
+		bundle, errBundle := models.NewProofBundle(slotProof, s.attestation.Tx, script, pubkey)
+		if errBundle != nil {
+			return errBundle
+		}
+		bundleBytes, errExport := bundle.ExportBinary()
+		if errExport != nil {
+			return errExport
+		}
+		s.signer.SendSlotProof(proof.ClientPosition, bundleBytes)
+	}
+	return nil
+}
+
+// Append commitment of a new attestation round to the commitment archive
+// file if set, from which the db can be rebuilt with the staychain
+func (s *AttestService) archiveCommitment(commitment models.Commitment) error {
+	path := s.config.CommitmentArchive()
+	if path == "" {
+		return nil
+	}
+	archive, errOpen := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if errOpen != nil {
+		return errOpen
+	}
+	defer archive.Close()
+
+	record := models.NewCommitmentArchiveRecord(commitment, s.clock.Now())
+	if errWrite := models.WriteCommitmentArchiveRecord(archive, record); errWrite != nil {
+		return errWrite
+	}
+	return archive.Sync()
+}
+
+// Return lifecycle event of the current attestation for the server event feed
+func (s *AttestService) newEvent(eventType string) models.AttestationEvent {
+	event := models.AttestationEvent{Type: eventType, Time: s.clock.Now()}
+	if commitment, errCommitment := s.attestation.Commitment(); errCommitment == nil {
+		event = models.NewAttestationEvent(eventType, *commitment, s.clock.Now())
+	}
+	event.Txid = s.attestation.Txid
+	return event
+}
+
+// Check if two transactions spend the same output
+func spendsSameOutput(tx1 *wire.MsgTx, tx2 *wire.MsgTx) bool {
+	for _, txIn1 := range tx1.TxIn {
+		for _, txIn2 := range tx2.TxIn {
+			if txIn1.PreviousOutPoint == txIn2.PreviousOutPoint {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,10 +1,12 @@
 package attestation
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
 
+	"mainstay/clients"
 	"mainstay/models"
 	"mainstay/server"
 	"mainstay/test"
@@ -24,7 +26,7 @@ func TestAttestService_Regular(t *testing.T) {
 	config := test.Config
 
 	dbFake := server.NewDbFake()
-	server := server.NewServer(dbFake)
+	server := server.NewServer(context.Background(), dbFake)
 	attestService := NewAttestService(nil, nil, server, config, true)
 
 	// Test initial state of attest service
@@ -104,8 +106,6 @@ func TestAttestService_Regular(t *testing.T) {
 
 	// generate new block to confirm attestation
 	config.MainClient().Generate(1)
-	rawTx, _ := config.MainClient().GetRawTransaction(&txid)
-	walletTx, _ := config.MainClient().GetTransaction(&txid)
 	// Test ASTATE_AWAIT_CONFIRMATION -> ASTATE_NEXT_COMMITMENT
 	attestService.doAttestation()
 	assert.Equal(t, ASTATE_NEXT_COMMITMENT, attestService.state)
@@ -113,11 +113,7 @@ func TestAttestService_Regular(t *testing.T) {
 	assert.Equal(t, txid, attestService.attestation.Txid)
 	assert.Equal(t, true, attestDelay < ATIME_NEW_ATTESTATION)
 	assert.Equal(t, true, attestDelay > (ATIME_NEW_ATTESTATION-time.Since(confirmTime)))
-	assert.Equal(t, models.AttestationInfo{
-		Txid:      txid.String(),
-		Blockhash: walletTx.BlockHash,
-		Amount:    rawTx.MsgTx().TxOut[0].Value,
-		Time:      walletTx.Time}, attestService.attestation.Info)
+	assert.Equal(t, expectedAttestationInfo(config.MainClient(), txid), attestService.attestation.Info)
 
 	// Test ASTATE_NEXT_COMMITMENT -> ASTATE_NEXT_COMMITMENT
 	attestService.doAttestation()
@@ -160,8 +156,6 @@ func TestAttestService_Regular(t *testing.T) {
 
 	// generate new block to confirm attestation
 	config.MainClient().Generate(1)
-	rawTx, _ = config.MainClient().GetRawTransaction(&txid)
-	walletTx, _ = config.MainClient().GetTransaction(&txid)
 	// Test ASTATE_AWAIT_CONFIRMATION -> ASTATE_NEXT_COMMITMENT
 	attestService.doAttestation()
 	assert.Equal(t, ASTATE_NEXT_COMMITMENT, attestService.state)
@@ -169,11 +163,7 @@ func TestAttestService_Regular(t *testing.T) {
 	assert.Equal(t, txid, attestService.attestation.Txid)
 	assert.Equal(t, true, attestDelay < ATIME_NEW_ATTESTATION)
 	assert.Equal(t, true, attestDelay > (ATIME_NEW_ATTESTATION-time.Since(confirmTime)))
-	assert.Equal(t, models.AttestationInfo{
-		Txid:      txid.String(),
-		Blockhash: walletTx.BlockHash,
-		Amount:    rawTx.MsgTx().TxOut[0].Value,
-		Time:      walletTx.Time}, attestService.attestation.Info)
+	assert.Equal(t, expectedAttestationInfo(config.MainClient(), txid), attestService.attestation.Info)
 }
 
 // Test Attest Service when Attestation remains unconfirmed
@@ -184,7 +174,7 @@ func TestAttestService_HandleUnconfirmed(t *testing.T) {
 	config := test.Config
 
 	dbFake := server.NewDbFake()
-	server := server.NewServer(dbFake)
+	server := server.NewServer(context.Background(), dbFake)
 	attestService := NewAttestService(nil, nil, server, config, true)
 
 	// Test initial state of attest service
@@ -255,7 +245,7 @@ func TestAttestService_FailureInit(t *testing.T) {
 	config := test.Config
 
 	dbFake := server.NewDbFake()
-	server := server.NewServer(dbFake)
+	server := server.NewServer(context.Background(), dbFake)
 	attestService := NewAttestService(nil, nil, server, config, true)
 
 	// Test initial state of attest service
@@ -302,7 +292,7 @@ func TestAttestService_FailureNextCommitment(t *testing.T) {
 	config := test.Config
 
 	dbFake := server.NewDbFake()
-	server := server.NewServer(dbFake)
+	server := server.NewServer(context.Background(), dbFake)
 	attestService := NewAttestService(nil, nil, server, config, true)
 
 	// Test initial state of attest service
@@ -364,7 +354,7 @@ func TestAttestService_FailureNewAttestation(t *testing.T) {
 	config := test.Config
 
 	dbFake := server.NewDbFake()
-	server := server.NewServer(dbFake)
+	server := server.NewServer(context.Background(), dbFake)
 	attestService := NewAttestService(nil, nil, server, config, true)
 
 	// Test initial state of attest service
@@ -445,7 +435,7 @@ func TestAttestService_FailureSignAttestation(t *testing.T) {
 	config := test.Config
 
 	dbFake := server.NewDbFake()
-	server := server.NewServer(dbFake)
+	server := server.NewServer(context.Background(), dbFake)
 	attestService := NewAttestService(nil, nil, server, config, true)
 
 	// Test initial state of attest service
@@ -536,7 +526,7 @@ func TestAttestService_FailureSendAttestation(t *testing.T) {
 	config := test.Config
 
 	dbFake := server.NewDbFake()
-	server := server.NewServer(dbFake)
+	server := server.NewServer(context.Background(), dbFake)
 	attestService := NewAttestService(nil, nil, server, config, true)
 
 	// Test initial state of attest service
@@ -593,18 +583,12 @@ func TestAttestService_FailureSendAttestation(t *testing.T) {
 
 	// generate new block to confirm attestation
 	config.MainClient().Generate(1)
-	rawTx, _ := config.MainClient().GetRawTransaction(&txid)
-	walletTx, _ := config.MainClient().GetTransaction(&txid)
 	// Test ASTATE_AWAIT_CONFIRMATION -> ASTATE_NEXT_COMMITMENT
 	attestService.doAttestation()
 	assert.Equal(t, ASTATE_NEXT_COMMITMENT, attestService.state)
 	assert.Equal(t, true, attestService.attestation.Confirmed)
 	assert.Equal(t, txid, attestService.attestation.Txid)
-	assert.Equal(t, models.AttestationInfo{
-		Txid:      txid.String(),
-		Blockhash: walletTx.BlockHash,
-		Amount:    rawTx.MsgTx().TxOut[0].Value,
-		Time:      walletTx.Time}, attestService.attestation.Info)
+	assert.Equal(t, expectedAttestationInfo(config.MainClient(), txid), attestService.attestation.Info)
 
 	// failure - re init attestation service from inner state failure
 	attestService.state = ASTATE_INIT
@@ -615,11 +599,7 @@ func TestAttestService_FailureSendAttestation(t *testing.T) {
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
 	assert.Equal(t, txid, attestService.attestation.Txid)
 	assert.Equal(t, true, attestService.attestation.Confirmed)
-	assert.Equal(t, models.AttestationInfo{
-		Txid:      txid.String(),
-		Blockhash: walletTx.BlockHash,
-		Amount:    rawTx.MsgTx().TxOut[0].Value,
-		Time:      walletTx.Time}, attestService.attestation.Info)
+	assert.Equal(t, expectedAttestationInfo(config.MainClient(), txid), attestService.attestation.Info)
 }
 
 // Test Attest Service states
@@ -632,7 +612,7 @@ func TestAttestService_FailureAwaitConfirmation(t *testing.T) {
 	config := test.Config
 
 	dbFake := server.NewDbFake()
-	server := server.NewServer(dbFake)
+	server := server.NewServer(context.Background(), dbFake)
 	attestService := NewAttestService(nil, nil, server, config, true)
 
 	// Test initial state of attest service
@@ -678,18 +658,12 @@ func TestAttestService_FailureAwaitConfirmation(t *testing.T) {
 
 	// generate new block to confirm attestation
 	config.MainClient().Generate(1)
-	rawTx, _ := config.MainClient().GetRawTransaction(&txid)
-	walletTx, _ := config.MainClient().GetTransaction(&txid)
 	// Test ASTATE_AWAIT_CONFIRMATION -> ASTATE_NEXT_COMMITMENT
 	attestService.doAttestation()
 	assert.Equal(t, ASTATE_NEXT_COMMITMENT, attestService.state)
 	assert.Equal(t, true, attestService.attestation.Confirmed)
 	assert.Equal(t, txid, attestService.attestation.Txid)
-	assert.Equal(t, models.AttestationInfo{
-		Txid:      txid.String(),
-		Blockhash: walletTx.BlockHash,
-		Amount:    rawTx.MsgTx().TxOut[0].Value,
-		Time:      walletTx.Time}, attestService.attestation.Info)
+	assert.Equal(t, expectedAttestationInfo(config.MainClient(), txid), attestService.attestation.Info)
 
 	// failure - re init attestation service
 	attestService = NewAttestService(nil, nil, server, config, true)
@@ -700,11 +674,7 @@ func TestAttestService_FailureAwaitConfirmation(t *testing.T) {
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
 	assert.Equal(t, txid, attestService.attestation.Txid)
 	assert.Equal(t, true, attestService.attestation.Confirmed)
-	assert.Equal(t, models.AttestationInfo{
-		Txid:      txid.String(),
-		Blockhash: walletTx.BlockHash,
-		Amount:    rawTx.MsgTx().TxOut[0].Value,
-		Time:      walletTx.Time}, attestService.attestation.Info)
+	assert.Equal(t, expectedAttestationInfo(config.MainClient(), txid), attestService.attestation.Info)
 
 	// failure again and check nothing has changed
 	attestService = NewAttestService(nil, nil, server, config, true)
@@ -715,11 +685,7 @@ func TestAttestService_FailureAwaitConfirmation(t *testing.T) {
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
 	assert.Equal(t, txid, attestService.attestation.Txid)
 	assert.Equal(t, true, attestService.attestation.Confirmed)
-	assert.Equal(t, models.AttestationInfo{
-		Txid:      txid.String(),
-		Blockhash: walletTx.BlockHash,
-		Amount:    rawTx.MsgTx().TxOut[0].Value,
-		Time:      walletTx.Time}, attestService.attestation.Info)
+	assert.Equal(t, expectedAttestationInfo(config.MainClient(), txid), attestService.attestation.Info)
 
 	// failure - re init attestation service from inner state
 	attestService.state = ASTATE_INIT
@@ -730,9 +696,25 @@ func TestAttestService_FailureAwaitConfirmation(t *testing.T) {
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
 	assert.Equal(t, txid, attestService.attestation.Txid)
 	assert.Equal(t, true, attestService.attestation.Confirmed)
-	assert.Equal(t, models.AttestationInfo{
-		Txid:      txid.String(),
-		Blockhash: walletTx.BlockHash,
-		Amount:    rawTx.MsgTx().TxOut[0].Value,
-		Time:      walletTx.Time}, attestService.attestation.Info)
+	assert.Equal(t, expectedAttestationInfo(config.MainClient(), txid), attestService.attestation.Info)
+}
+
+// Return expected info of a confirmed attestation
+// including the block height and the transaction SPV proof
+func expectedAttestationInfo(client clients.MainChainClient, txid chainhash.Hash) models.AttestationInfo {
+	rawTx, _ := client.GetRawTransaction(&txid)
+	walletTx, _ := client.GetTransaction(&txid)
+	blockhash, _ := chainhash.NewHashFromStr(walletTx.BlockHash)
+	header, _ := client.GetBlockHeaderVerbose(blockhash)
+	block, _ := client.GetBlock(blockhash)
+	spvProof, _ := models.NewSpvProof(block, txid)
+	return models.AttestationInfo{
+		Txid:         txid.String(),
+		Blockhash:    walletTx.BlockHash,
+		Height:       int64(header.Height),
+		Amount:       rawTx.MsgTx().TxOut[0].Value,
+		Time:         walletTx.Time,
+		Header:       spvProof.HeaderHex(),
+		TxIndex:      spvProof.TxIndex,
+		MerkleBranch: spvProof.MerkleBranchStrings()}
 }
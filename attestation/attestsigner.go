@@ -0,0 +1,16 @@
+package attestation
+
+// AttestSigner interface
+// Implements communication between the attestation service and
+// the client signers of the multisig attestation transactions
+// Signers are updated with confirmed hashes, new hashes and new
+// unsigned transactions and their signatures are then collected
+// Confirmed attestations and slot proofs are also sent to clients
+type AttestSigner interface {
+	SendConfirmedHash([]byte)
+	SendNewHash([]byte)
+	SendNewTx([]byte)
+	SendConfirmedAttestation([]byte)
+	SendSlotProof(int32, []byte)
+	GetSigs() [][]byte
+}
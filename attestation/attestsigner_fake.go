@@ -0,0 +1,80 @@
+package attestation
+
+import (
+	"bytes"
+
+	"mainstay/crypto"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// AttestSignerFake structure
+// Implements AttestSigner for unit-testing by signing new
+// transactions directly with the AttestClient of each signer
+// in the same way a transaction signing tool would
+type AttestSignerFake struct {
+	signers              []*AttestClient
+	confirmedHash        chainhash.Hash
+	newHash              chainhash.Hash
+	newTx                *wire.MsgTx
+	confirmedAttestation []byte
+	slotProofs           map[int32][]byte
+}
+
+// NewAttestSignerFake returns a pointer to an AttestSignerFake instance
+func NewAttestSignerFake(signers []*AttestClient) *AttestSignerFake {
+	return &AttestSignerFake{signers: signers, slotProofs: make(map[int32][]byte)}
+}
+
+// Store latest confirmed hash
+func (f *AttestSignerFake) SendConfirmedHash(hash []byte) {
+	confirmedHash, _ := chainhash.NewHash(hash)
+	f.confirmedHash = *confirmedHash
+}
+
+// Store new commitment hash
+func (f *AttestSignerFake) SendNewHash(hash []byte) {
+	newHash, _ := chainhash.NewHash(hash)
+	f.newHash = *newHash
+}
+
+// Store new pre signed transaction
+func (f *AttestSignerFake) SendNewTx(tx []byte) {
+	var msgTx wire.MsgTx
+	if errDeserialize := msgTx.Deserialize(bytes.NewReader(tx)); errDeserialize != nil {
+		f.newTx = nil
+		return
+	}
+	f.newTx = &msgTx
+}
+
+// Store latest confirmed attestation message
+func (f *AttestSignerFake) SendConfirmedAttestation(msg []byte) {
+	f.confirmedAttestation = msg
+}
+
+// Store latest slot proof of each position
+func (f *AttestSignerFake) SendSlotProof(position int32, proof []byte) {
+	f.slotProofs[position] = proof
+}
+
+// Sign the latest transaction with each signer and return their sigs
+// Signers that fail to sign the transaction are omitted
+func (f *AttestSignerFake) GetSigs() [][]byte {
+	var sigs [][]byte
+	if f.newTx == nil {
+		return sigs
+	}
+	for _, signer := range f.signers {
+		signedTx, _, errSign := signer.SignTransaction(f.confirmedHash, *f.newTx)
+		if errSign != nil {
+			continue
+		}
+		signerSigs, _ := crypto.ParseScriptSig(signedTx.TxIn[0].SignatureScript)
+		if len(signerSigs) > 0 {
+			sigs = append(sigs, signerSigs[0])
+		}
+	}
+	return sigs
+}
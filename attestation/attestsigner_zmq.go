@@ -0,0 +1,71 @@
+package attestation
+
+import (
+	confpkg "mainstay/config"
+	"mainstay/messengers"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// AttestSignerZmq structure
+// Implements AttestSigner by publishing updates through a zmq
+// publisher and reading signatures from each signer subscriber
+type AttestSignerZmq struct {
+	publisher   *messengers.PublisherZmq
+	subscribers []*messengers.SubscriberZmq
+	poller      *zmq.Poller // poller to add all subscriber/publisher sockets
+}
+
+// NewAttestSignerZmq returns a pointer to an AttestSignerZmq instance
+// Initialise publisher for sending new hashes and txs
+// and subscribers to receive sig responses
+func NewAttestSignerZmq(config *confpkg.Config) *AttestSignerZmq {
+	poller := zmq.NewPoller()
+	publisher := messengers.NewPublisherZmq(confpkg.MAIN_PUBLISHER_PORT, poller)
+	var subscribers []*messengers.SubscriberZmq
+	subtopics := []string{confpkg.TOPIC_SIGS}
+	for _, nodeaddr := range config.MultisigNodes() {
+		subscribers = append(subscribers, messengers.NewSubscriberZmq(nodeaddr, subtopics, poller))
+	}
+	return &AttestSignerZmq{publisher, subscribers, poller}
+}
+
+// Publish latest confirmed hash to signers
+func (z *AttestSignerZmq) SendConfirmedHash(hash []byte) {
+	z.publisher.SendMessage(hash, confpkg.TOPIC_CONFIRMED_HASH)
+}
+
+// Publish new commitment hash to signers
+func (z *AttestSignerZmq) SendNewHash(hash []byte) {
+	z.publisher.SendMessage(hash, confpkg.TOPIC_NEW_HASH)
+}
+
+// Publish new pre signed transaction to signers
+func (z *AttestSignerZmq) SendNewTx(tx []byte) {
+	z.publisher.SendMessage(tx, confpkg.TOPIC_NEW_TX)
+}
+
+// Publish confirmed attestation message to clients
+func (z *AttestSignerZmq) SendConfirmedAttestation(msg []byte) {
+	z.publisher.SendMessage(msg, confpkg.TOPIC_CONFIRMED_ATTESTATION)
+}
+
+// Publish serialized slot proof to clients of the position
+func (z *AttestSignerZmq) SendSlotProof(position int32, proof []byte) {
+	z.publisher.SendMessage(proof, confpkg.SlotProofTopic(position))
+}
+
+// Read sigs from all signer subscribers
+func (z *AttestSignerZmq) GetSigs() [][]byte {
+	var sigs [][]byte
+	sockets, _ := z.poller.Poll(-1)
+	for _, socket := range sockets {
+		for _, sub := range z.subscribers {
+			if sub.Socket() == socket.Socket {
+				_, msg := sub.ReadMessage()
+				sigs = append(sigs, msg)
+			}
+		}
+	}
+	return sigs
+}
@@ -0,0 +1,640 @@
+package attestation
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mainstay/clients"
+	confpkg "mainstay/config"
+	"mainstay/crypto"
+	"mainstay/models"
+	"mainstay/server"
+	"mainstay/staychain"
+	"mainstay/test"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Deterministic simulation of the attestation service
+// Main chain, signers, clock, fee API and db are all faked so that
+// scenarios can be scripted without a bitcoin node or any network
+
+// simulation config - clients are never connected to
+var simConf = []byte(`
+{
+    "main": {
+        "rpcurl": "localhost:18443",
+        "rpcuser": "user",
+        "rpcpass": "pass",
+        "chain": "regtest"
+    },
+    "misc": {
+        "multisignodes": ""
+    },
+    "db": {
+        "user":"",
+        "password":"",
+        "host":"localhost",
+        "port":"27017",
+        "name":"mainstay"
+    }
+}
+`)
+
+// simulation fee returned by the fake fee API
+const SIM_FEE_PER_BYTE = 30
+
+// simulation initial staychain funding
+const SIM_INIT_AMOUNT = btcutil.Amount(100000000)
+
+// simHarness structure
+// Drives an AttestService with fake dependencies and records state sequences
+type simHarness struct {
+	t          *testing.T
+	config     *confpkg.Config
+	mainClient *clients.MainChainClientFake
+	signer     *AttestSignerFake
+	clock      *AttestClockFake
+	dbFake     *server.DbFake
+	server     *server.Server
+	feeApi     *httptest.Server
+	service    *AttestService
+}
+
+// Return new simulation harness for a 2-of-2 multisig staychain
+// between the main service key and a single client signer key
+func newSimHarness(t *testing.T) *simHarness {
+	mainClient := clients.NewMainChainClientFake(confpkg.NewConfig(simConf).MainChainCfg())
+
+	var pubkeys []*btcec.PublicKey
+	for _, priv := range []string{test.PRIV_MAIN, test.PRIV_CLIENT} {
+		wif, _ := btcutil.DecodeWIF(priv)
+		pubkeys = append(pubkeys, wif.PrivKey.PubKey())
+	}
+	config := newSimConfig(test.PRIV_MAIN, pubkeys)
+	multisigAddr, _ := crypto.CreateMultisig(pubkeys, 2, config.MainChainCfg())
+
+	// fund staychain and confirm initial transaction
+	txid0, errFund := mainClient.SendToAddress(multisigAddr, SIM_INIT_AMOUNT)
+	assert.Equal(t, nil, errFund)
+	mainClient.Generate(1)
+	config.SetInitTX(txid0.String())
+
+	signerConfig := newSimConfig(test.PRIV_CLIENT, pubkeys)
+	signerConfig.SetInitTX(txid0.String())
+	signer := NewAttestSignerFake([]*AttestClient{NewAttestClient(signerConfig, mainClient)})
+
+	feeApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"fastestFee": 50, "halfHourFee": 40, "%s": %d}`, BEST_FEE_TYPE, SIM_FEE_PER_BYTE)
+	}))
+	feeApiUrl = feeApi.URL
+
+	dbFake := server.NewDbFake()
+	h := &simHarness{
+		t:          t,
+		config:     config,
+		mainClient: mainClient,
+		signer:     signer,
+		clock:      NewAttestClockFake(time.Unix(clients.FAKE_GENESIS_TIME, 0)),
+		dbFake:     dbFake,
+		server:     server.NewServer(context.Background(), dbFake),
+		feeApi:     feeApi,
+	}
+	h.restart()
+	return h
+}
+
+// Return new config for a multisig staychain and the signer key provided
+func newSimConfig(priv string, pubkeys []*btcec.PublicKey) *confpkg.Config {
+	config := confpkg.NewConfig(simConf)
+	_, script := crypto.CreateMultisig(pubkeys, 2, config.MainChainCfg())
+	config.SetInitPK(priv)
+	config.SetMultisigScript(script)
+	return config
+}
+
+// Close fake fee API and restore fee API url
+func (h *simHarness) close() {
+	h.feeApi.Close()
+	feeApiUrl = FEE_API_URL
+}
+
+// Restart attestation service from ASTATE_INIT keeping all fakes
+func (h *simHarness) restart() {
+	h.service = &AttestService{
+		config:      h.config,
+		attester:    NewAttestClient(h.config, h.mainClient),
+		server:      h.server,
+		signer:      h.signer,
+		clock:       h.clock,
+		state:       ASTATE_INIT,
+		attestation: models.NewAttestationDefault(),
+	}
+}
+
+// Run a single attestation state and wait the delay set by the service
+func (h *simHarness) step() AttestationState {
+	h.service.doAttestation()
+	if attestDelay > 0 {
+		h.clock.Advance(attestDelay)
+	}
+	return h.service.state
+}
+
+// Run attestation states and return the sequence of states reached
+func (h *simHarness) steps(n int) []AttestationState {
+	var states []AttestationState
+	for i := 0; i < n; i++ {
+		states = append(states, h.step())
+	}
+	return states
+}
+
+// Set a single client commitment in the fake db
+func (h *simHarness) commit(hashStr string) chainhash.Hash {
+	hash, _ := chainhash.NewHashFromStr(hashStr)
+	h.dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{Commitment: *hash, ClientPosition: 0}})
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hash})
+	return commitment.GetCommitmentHash()
+}
+
+// Run a full attestation round for the commitment provided until confirmation
+// and return the confirmed attestation txid
+func (h *simHarness) attest(hashStr string) chainhash.Hash {
+	commitmentHash := h.commit(hashStr)
+	assert.Equal(h.t, []AttestationState{
+		ASTATE_NEW_ATTESTATION,
+		ASTATE_SIGN_ATTESTATION,
+		ASTATE_SEND_ATTESTATION,
+		ASTATE_AWAIT_CONFIRMATION}, h.steps(4))
+	txid := h.service.attestation.Txid
+	assert.Equal(h.t, commitmentHash, h.service.attestation.CommitmentHash())
+
+	h.mainClient.Generate(1)
+	assert.Equal(h.t, ASTATE_NEXT_COMMITMENT, h.step())
+	assert.Equal(h.t, true, h.service.attestation.Confirmed)
+	return txid
+}
+
+// Return blockhash of a transaction in the fake main chain
+func (h *simHarness) blockhash(txid chainhash.Hash) string {
+	walletTx, _ := h.mainClient.GetTransaction(&txid)
+	return walletTx.BlockHash
+}
+
+// Return fee per byte paid by attestation transaction
+func (h *simHarness) feePerByte(tx wire.MsgTx, amountIn int64) int64 {
+	unsignedTx := tx.Copy()
+	unsignedTx.TxIn[0].SignatureScript = nil
+	return (amountIn - tx.TxOut[0].Value) / int64(unsignedTx.SerializeSize())
+}
+
+// Test simulation of regular attestation rounds
+func TestAttestSim_Regular(t *testing.T) {
+	h := newSimHarness(t)
+	defer h.close()
+
+	// no commitment yet - remain waiting for client commitment
+	assert.Equal(t, []AttestationState{
+		ASTATE_NEXT_COMMITMENT,
+		ASTATE_ERROR,
+		ASTATE_INIT,
+		ASTATE_NEXT_COMMITMENT}, h.steps(4))
+	assert.Equal(t, errors.New(models.ERROR_COMMITMENT_LIST_EMPTY), h.service.errorState)
+
+	txid1 := h.attest("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// same commitment is not attested again
+	assert.Equal(t, []AttestationState{ASTATE_NEXT_COMMITMENT, ASTATE_NEXT_COMMITMENT}, h.steps(2))
+
+	txid2 := h.attest("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// staychain is formed by the two attestations
+	tx2, _ := h.mainClient.GetRawTransaction(&txid2)
+	assert.Equal(t, txid1, tx2.MsgTx().TxIn[0].PreviousOutPoint.Hash)
+
+	// db contents
+	attestations := h.dbFake.Attestations()
+	assert.Equal(t, 2, len(attestations))
+	assert.Equal(t, txid1, attestations[0].Txid)
+	assert.Equal(t, txid2, attestations[1].Txid)
+	assert.Equal(t, true, attestations[0].Confirmed)
+	assert.Equal(t, true, attestations[1].Confirmed)
+	info := h.dbFake.AttestationsInfo()
+	assert.Equal(t, 2, len(info))
+	assert.Equal(t, h.blockhash(txid1), info[0].Blockhash)
+	assert.Equal(t, h.blockhash(txid2), info[1].Blockhash)
+
+	// block heights and spv proofs of attestation transactions
+	for i, txid := range []chainhash.Hash{txid1, txid2} {
+		blockhash, _ := chainhash.NewHashFromStr(h.blockhash(txid))
+		header, _ := h.mainClient.GetBlockHeaderVerbose(blockhash)
+		assert.Equal(t, int64(header.Height), info[i].Height)
+		spvProof, errSpv := info[i].SpvProof()
+		assert.Equal(t, nil, errSpv)
+		assert.Equal(t, h.blockhash(txid), spvProof.Blockhash().String())
+		assert.Equal(t, true, spvProof.Prove(txid))
+	}
+	assert.Equal(t, true, info[0].Height < info[1].Height)
+
+	// fees taken from fee API
+	assert.Equal(t, int64(SIM_FEE_PER_BYTE), h.feePerByte(attestations[0].Tx, int64(SIM_INIT_AMOUNT)))
+
+	// fees stored with attestations
+	assert.Equal(t, int64(SIM_INIT_AMOUNT)-attestations[0].Tx.TxOut[0].Value, attestations[0].Fee)
+	assert.Equal(t, attestations[0].Tx.TxOut[0].Value-attestations[1].Tx.TxOut[0].Value, attestations[1].Fee)
+}
+
+// Test simulation of client signers dropping out
+func TestAttestSim_SignersDropOut(t *testing.T) {
+	h := newSimHarness(t)
+	defer h.close()
+
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+	h.commit("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// no signatures - attestation cannot be signed
+	signers := h.signer.signers
+	h.signer.signers = nil
+	assert.Equal(t, []AttestationState{
+		ASTATE_NEW_ATTESTATION,
+		ASTATE_SIGN_ATTESTATION,
+		ASTATE_ERROR}, h.steps(3))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d got %d", ERROR_SIGS_MISSING, 2, 1)), h.service.errorState)
+
+	// retried while signers are missing
+	assert.Equal(t, []AttestationState{
+		ASTATE_INIT,
+		ASTATE_NEXT_COMMITMENT,
+		ASTATE_NEW_ATTESTATION,
+		ASTATE_SIGN_ATTESTATION,
+		ASTATE_ERROR}, h.steps(5))
+	mempool, _ := h.mainClient.GetRawMempool()
+	assert.Equal(t, 0, len(mempool))
+	assert.Equal(t, 0, len(h.dbFake.Attestations()))
+
+	// signers return - attestation continues
+	h.signer.signers = signers
+	assert.Equal(t, []AttestationState{
+		ASTATE_INIT,
+		ASTATE_NEXT_COMMITMENT,
+		ASTATE_NEW_ATTESTATION,
+		ASTATE_SIGN_ATTESTATION,
+		ASTATE_SEND_ATTESTATION,
+		ASTATE_AWAIT_CONFIRMATION}, h.steps(6))
+	h.mainClient.Generate(1)
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+	assert.Equal(t, 1, len(h.dbFake.Attestations()))
+	assert.Equal(t, 1, len(h.dbFake.AttestationsInfo()))
+}
+
+// Test simulation of attestation evicted from the mempool
+func TestAttestSim_MempoolEviction(t *testing.T) {
+	h := newSimHarness(t)
+	defer h.close()
+
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+	txid1 := h.attest("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	commitmentHash := h.commit("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	assert.Equal(t, []AttestationState{
+		ASTATE_NEW_ATTESTATION,
+		ASTATE_SIGN_ATTESTATION,
+		ASTATE_SEND_ATTESTATION,
+		ASTATE_AWAIT_CONFIRMATION}, h.steps(4))
+	txid2 := h.service.attestation.Txid
+
+	// evicted attestation is never confirmed
+	h.mainClient.EvictFromMempool(&txid2)
+	h.mainClient.Generate(1)
+	assert.Equal(t, []AttestationState{
+		ASTATE_AWAIT_CONFIRMATION,
+		ASTATE_AWAIT_CONFIRMATION,
+		ASTATE_AWAIT_CONFIRMATION,
+		ASTATE_AWAIT_CONFIRMATION,
+		ASTATE_HANDLE_UNCONFIRMED,
+		ASTATE_HANDLE_UNCONFIRMED}, h.steps(6))
+
+	// unconfirmed attestation stored prior to sending
+	attestations := h.dbFake.Attestations()
+	assert.Equal(t, 2, len(attestations))
+	assert.Equal(t, txid2, attestations[1].Txid)
+	assert.Equal(t, false, attestations[1].Confirmed)
+	assert.Equal(t, 1, len(h.dbFake.AttestationsInfo()))
+
+	// restart resumes from last confirmed attestation and re-attests
+	h.restart()
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+	assert.Equal(t, txid1, h.service.attestation.Txid)
+	assert.Equal(t, []AttestationState{
+		ASTATE_NEW_ATTESTATION,
+		ASTATE_SIGN_ATTESTATION,
+		ASTATE_SEND_ATTESTATION,
+		ASTATE_AWAIT_CONFIRMATION}, h.steps(4))
+	assert.Equal(t, commitmentHash, h.service.attestation.CommitmentHash())
+	h.mainClient.Generate(1)
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+
+	info := h.dbFake.AttestationsInfo()
+	assert.Equal(t, 2, len(info))
+	assert.Equal(t, h.service.attestation.Txid.String(), info[1].Txid)
+	assert.Equal(t, h.blockhash(h.service.attestation.Txid), info[1].Blockhash)
+}
+
+// Test simulation of main chain reorg after attestation confirmation
+func TestAttestSim_ReorgAfterConfirmation(t *testing.T) {
+	h := newSimHarness(t)
+	defer h.close()
+
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+	txid1 := h.attest("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	orphanBlockhash := h.blockhash(txid1)
+
+	// reorg returns attestation to the mempool
+	orphanHash, _ := chainhash.NewHashFromStr(orphanBlockhash)
+	assert.Equal(t, nil, h.mainClient.InvalidateBlock(orphanHash))
+	assert.Equal(t, "", h.blockhash(txid1))
+
+	// next attestation has no confirmed unspent to spend
+	h.commit("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	assert.Equal(t, []AttestationState{
+		ASTATE_NEW_ATTESTATION,
+		ASTATE_ERROR,
+		ASTATE_INIT,
+		ASTATE_AWAIT_CONFIRMATION,
+		ASTATE_HANDLE_UNCONFIRMED}, h.steps(5))
+	assert.Equal(t, errors.New(ERROR_UNSPENT_NOT_FOUND), h.service.errorState)
+	assert.Equal(t, txid1, h.service.attestation.Txid)
+
+	// db still refers to the orphaned block
+	info := h.dbFake.AttestationsInfo()
+	assert.Equal(t, 1, len(info))
+	assert.Equal(t, orphanBlockhash, info[0].Blockhash)
+
+	// attestation confirmed in new chain and db updated on restart
+	h.mainClient.Generate(2)
+	assert.Equal(t, true, h.blockhash(txid1) != "")
+	assert.Equal(t, true, h.blockhash(txid1) != orphanBlockhash)
+	h.restart()
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+	info = h.dbFake.AttestationsInfo()
+	assert.Equal(t, 1, len(info))
+	assert.Equal(t, h.blockhash(txid1), info[0].Blockhash)
+}
+
+// Test simulation of db write failures
+func TestAttestSim_DbFailures(t *testing.T) {
+	h := newSimHarness(t)
+	defer h.close()
+
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+	h.commit("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// attestation not sent if it cannot be stored
+	errDb := errors.New("db write failure")
+	h.dbFake.SetSaveError(errDb)
+	assert.Equal(t, []AttestationState{
+		ASTATE_NEW_ATTESTATION,
+		ASTATE_SIGN_ATTESTATION,
+		ASTATE_SEND_ATTESTATION,
+		ASTATE_ERROR}, h.steps(4))
+	assert.Equal(t, errDb, h.service.errorState)
+	mempool, _ := h.mainClient.GetRawMempool()
+	assert.Equal(t, 0, len(mempool))
+	assert.Equal(t, 0, len(h.dbFake.Attestations()))
+
+	// db restored - attestation sent
+	h.dbFake.SetSaveError(nil)
+	assert.Equal(t, []AttestationState{
+		ASTATE_INIT,
+		ASTATE_NEXT_COMMITMENT,
+		ASTATE_NEW_ATTESTATION,
+		ASTATE_SIGN_ATTESTATION,
+		ASTATE_SEND_ATTESTATION,
+		ASTATE_AWAIT_CONFIRMATION}, h.steps(6))
+	txid := h.service.attestation.Txid
+
+	// confirmation cannot be stored
+	h.dbFake.SetSaveError(errDb)
+	h.mainClient.Generate(1)
+	assert.Equal(t, []AttestationState{
+		ASTATE_ERROR,
+		ASTATE_INIT,
+		ASTATE_ERROR}, h.steps(3))
+	attestations := h.dbFake.Attestations()
+	assert.Equal(t, 1, len(attestations))
+	assert.Equal(t, false, attestations[0].Confirmed)
+	assert.Equal(t, 0, len(h.dbFake.AttestationsInfo()))
+
+	// db restored - confirmation stored on init
+	h.dbFake.SetSaveError(nil)
+	assert.Equal(t, []AttestationState{ASTATE_INIT, ASTATE_NEXT_COMMITMENT}, h.steps(2))
+	attestations = h.dbFake.Attestations()
+	assert.Equal(t, 1, len(attestations))
+	assert.Equal(t, txid, attestations[0].Txid)
+	assert.Equal(t, true, attestations[0].Confirmed)
+	info := h.dbFake.AttestationsInfo()
+	assert.Equal(t, 1, len(info))
+	assert.Equal(t, h.blockhash(txid), info[0].Blockhash)
+}
+
+// Test simulation of fee API outage
+func TestAttestSim_FeeApiOutage(t *testing.T) {
+	h := newSimHarness(t)
+	defer h.close()
+
+	// fee API down - default fee used
+	h.feeApi.Close()
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+	txid1 := h.attest("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	tx1, _ := h.mainClient.GetRawTransaction(&txid1)
+	assert.Equal(t, int64(FEE_PER_BYTE), h.feePerByte(*tx1.MsgTx(), int64(SIM_INIT_AMOUNT)))
+
+	// fee API returns invalid response - default fee used
+	h.feeApi = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"unknownFee": 100}`)
+	}))
+	feeApiUrl = h.feeApi.URL
+	txid2 := h.attest("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	tx2, _ := h.mainClient.GetRawTransaction(&txid2)
+	assert.Equal(t, int64(FEE_PER_BYTE), h.feePerByte(*tx2.MsgTx(), tx1.MsgTx().TxOut[0].Value))
+	assert.Equal(t, 2, len(h.dbFake.AttestationsInfo()))
+}
+
+// Test simulation of rebuilding a lost db from the staychain and commitment archive
+func TestAttestSim_DbRebuild(t *testing.T) {
+	h := newSimHarness(t)
+	defer h.close()
+
+	dir, errDir := ioutil.TempDir("", "mainstay-archive")
+	assert.Equal(t, nil, errDir)
+	defer os.RemoveAll(dir)
+	archivePath := filepath.Join(dir, "commitments.jsonl")
+	h.config.SetCommitmentArchive(archivePath)
+
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+	txid1 := h.attest("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid2 := h.attest("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// round attested without archive cannot be rebuilt
+	h.config.SetCommitmentArchive("")
+	txid3 := h.attest("ccccccc1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// one archive record per round
+	archive, errOpen := os.Open(archivePath)
+	assert.Equal(t, nil, errOpen)
+	defer archive.Close()
+	records, errRead := models.ReadCommitmentArchive(archive)
+	assert.Equal(t, nil, errRead)
+	assert.Equal(t, 2, len(records))
+
+	// rebuild into an empty db
+	ctx := context.Background()
+	txid0, _ := chainhash.NewHashFromStr(h.config.InitTX())
+	script, _ := hex.DecodeString(h.config.MultisigScript())
+	dbRebuilt := server.NewDbFake()
+	rebuilder := staychain.NewDbRebuilder(h.mainClient, dbRebuilt, *txid0, nil, script)
+	result, errRebuild := rebuilder.Rebuild(ctx, records)
+	assert.Equal(t, nil, errRebuild)
+	assert.Equal(t, []chainhash.Hash{txid1, txid2}, result.Rebuilt)
+	assert.Equal(t, []chainhash.Hash{txid3}, result.Unmatched)
+
+	// rebuilt attestations, info, merkle commitments and proofs match the lost db
+	attestations := h.dbFake.Attestations()
+	rebuiltAttestations := dbRebuilt.Attestations()
+	assert.Equal(t, 2, len(rebuiltAttestations))
+	for i, attestation := range attestations[:2] {
+		rebuilt := rebuiltAttestations[i]
+		assert.Equal(t, attestation.Txid, rebuilt.Txid)
+		assert.Equal(t, attestation.Tx, rebuilt.Tx)
+		assert.Equal(t, attestation.Fee, rebuilt.Fee)
+		assert.Equal(t, attestation.Confirmed, rebuilt.Confirmed)
+		assert.Equal(t, attestation.Info, rebuilt.Info)
+		assert.Equal(t, attestation.CommitmentHash(), rebuilt.CommitmentHash())
+		assert.Equal(t, attestation.TreeVersion(), rebuilt.TreeVersion())
+		merkleCommitments, _ := h.dbFake.GetAttestationMerkleCommitments(ctx, attestation.Txid)
+		rebuiltMerkleCommitments, _ := dbRebuilt.GetAttestationMerkleCommitments(ctx, rebuilt.Txid)
+		assert.Equal(t, merkleCommitments, rebuiltMerkleCommitments)
+	}
+	assert.Equal(t, h.dbFake.AttestationsInfo()[:2], dbRebuilt.AttestationsInfo())
+	for _, attestation := range attestations[:2] {
+		proof, _ := h.dbFake.GetMerkleProof(ctx, attestation.CommitmentHash(), 0)
+		rebuiltProof, errProof := dbRebuilt.GetMerkleProof(ctx, attestation.CommitmentHash(), 0)
+		assert.Equal(t, nil, errProof)
+		assert.Equal(t, proof, rebuiltProof)
+	}
+
+	// latest archived client commitments are restored
+	commitment, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	clientCommitments, _ := dbRebuilt.GetClientCommitments(ctx)
+	assert.Equal(t, []models.ClientCommitment{models.ClientCommitment{Commitment: *commitment, ClientPosition: 0}},
+		clientCommitments)
+}
+
+// Test simulation of attestation lifecycle events published to the server
+func TestAttestSim_Events(t *testing.T) {
+	h := newSimHarness(t)
+	defer h.close()
+
+	cursor := h.server.Events().Cursor()
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+	txid1 := h.attest("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment1 := h.service.attestation.CommitmentHash()
+
+	events, _, errEvents := h.server.Events().Since(cursor)
+	assert.Equal(t, nil, errEvents)
+	assert.Equal(t, 3, len(events))
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Type)
+		assert.Equal(t, commitment1, event.MerkleRoot)
+		assert.Equal(t, true, event.HasPosition(0))
+	}
+	assert.Equal(t, []string{models.EVENT_ROUND_STARTED, models.EVENT_ATTESTATION_BROADCAST,
+		models.EVENT_ATTESTATION_CONFIRMED}, types)
+	assert.Equal(t, chainhash.Hash{}, events[0].Txid)
+	assert.Equal(t, txid1, events[1].Txid)
+	assert.Equal(t, txid1, events[2].Txid)
+	assert.Equal(t, h.blockhash(txid1), events[2].Blockhash.String())
+
+	// attestation spending the output of a pending attestation replaces it
+	cursor = h.server.Events().Cursor()
+	h.commit("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	assert.Equal(t, []AttestationState{
+		ASTATE_NEW_ATTESTATION,
+		ASTATE_SIGN_ATTESTATION,
+		ASTATE_SEND_ATTESTATION}, h.steps(3))
+	pendingTx := h.service.attestation.Tx.Copy()
+	pendingTx.TxOut[0].Value++
+	h.service.pendingTx = pendingTx
+	assert.Equal(t, ASTATE_AWAIT_CONFIRMATION, h.step())
+	txid2 := h.service.attestation.Txid
+
+	events, _, _ = h.server.Events().Since(cursor)
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, models.EVENT_ATTESTATION_REPLACED, events[1].Type)
+	assert.Equal(t, pendingTx.TxHash(), events[1].Txid)
+	assert.Equal(t, txid2, events[1].ReplacementTxid)
+	assert.Equal(t, models.EVENT_ATTESTATION_BROADCAST, events[2].Type)
+	assert.Equal(t, txid2, events[2].Txid)
+}
+
+// Test simulation of confirmed attestations and slot proofs published to clients
+func TestAttestSim_ConfirmedProofs(t *testing.T) {
+	h := newSimHarness(t)
+	defer h.close()
+
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashZ, _ := chainhash.NewHashFromStr("ccccccc1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	h.dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{Commitment: *hashX, ClientPosition: 0},
+		models.ClientCommitment{Commitment: *hashZ, ClientPosition: 2}})
+	assert.Equal(t, []AttestationState{
+		ASTATE_NEW_ATTESTATION,
+		ASTATE_SIGN_ATTESTATION,
+		ASTATE_SEND_ATTESTATION,
+		ASTATE_AWAIT_CONFIRMATION}, h.steps(4))
+	txid := h.service.attestation.Txid
+
+	// nothing is published before confirmation
+	assert.Equal(t, []byte(nil), h.signer.confirmedAttestation)
+	assert.Equal(t, 0, len(h.signer.slotProofs))
+	h.mainClient.Generate(1)
+	assert.Equal(t, ASTATE_NEXT_COMMITMENT, h.step())
+
+	// confirmed attestation message with full info
+	message, errMessage := models.DeserializeAttestationMessage(h.signer.confirmedAttestation)
+	assert.Equal(t, nil, errMessage)
+	assert.Equal(t, txid.String(), message.Txid)
+	assert.Equal(t, h.service.attestation.CommitmentHash().String(), message.MerkleRoot)
+	assert.Equal(t, h.blockhash(txid), message.Blockhash)
+	assert.Equal(t, h.service.attestation.Info.Height, message.Height)
+	assert.Equal(t, h.service.attestation.Fee, message.Fee)
+
+	// proof bundles of slots with a commitment only
+	assert.Equal(t, 2, len(h.signer.slotProofs))
+	for position, commitment := range map[int32]chainhash.Hash{0: *hashX, 2: *hashZ} {
+		bundle, errBundle := models.ImportProofBundleBinary(h.signer.slotProofs[position])
+		assert.Equal(t, nil, errBundle)
+		assert.Equal(t, position, bundle.Proof.ClientPosition)
+		assert.Equal(t, commitment, bundle.Proof.Commitment)
+		assert.Equal(t, txid, bundle.Txid)
+		// fake main chain blocks are not mined so only proof of work fails
+		assert.Equal(t, errors.New(fmt.Sprintf("%s %s", staychain.ERROR_PROOF_HEADER_POW, h.blockhash(txid))),
+			staychain.VerifyProofBundle(*bundle))
+	}
+}
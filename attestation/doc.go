@@ -4,5 +4,8 @@ Package attestation implements the MainStay protocol.
 This is implemented by running a service responsible for receiving
 client commitments, generating an attestation transaction, collecting
 signatures, sending and storing this transaction to the server.
+
+The main chain client, signer communication and clock used by the service
+can be replaced by fakes to run deterministic simulations of the service.
 */
 package attestation
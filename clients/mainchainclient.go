@@ -0,0 +1,28 @@
+package clients
+
+import (
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// MainChainClient interface
+// Implements the interface for the mainchain wallet client
+// Current logic includes building, signing and sending attestation
+// transactions and fetching their wallet/mempool status and blocks
+// The btcd rpcclient.Client satisfies this interface directly
+type MainChainClient interface {
+	ImportPrivKeyRescan(*btcutil.WIF, string, bool) error
+	ImportAddress(string) error
+	ListUnspent() ([]btcjson.ListUnspentResult, error)
+	GetRawMempool() ([]*chainhash.Hash, error)
+	GetRawTransaction(*chainhash.Hash) (*btcutil.Tx, error)
+	GetTransaction(*chainhash.Hash) (*btcjson.GetTransactionResult, error)
+	GetBlock(*chainhash.Hash) (*wire.MsgBlock, error)
+	GetBlockHeaderVerbose(*chainhash.Hash) (*btcjson.GetBlockHeaderVerboseResult, error)
+	CreateRawTransaction([]btcjson.TransactionInput, map[btcutil.Address]btcutil.Amount, *int64) (*wire.MsgTx, error)
+	SignRawTransaction3(*wire.MsgTx, []btcjson.RawTxInput, []string) (*wire.MsgTx, bool, error)
+	SendRawTransaction(*wire.MsgTx, bool) (*chainhash.Hash, error)
+	Generate(uint32) ([]*chainhash.Hash, error)
+}
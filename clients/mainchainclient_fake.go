@@ -0,0 +1,488 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// error consts
+const (
+	ERROR_FAKE_TX_NOT_FOUND        = "No such mempool or blockchain transaction"
+	ERROR_FAKE_WALLET_TX_NOT_FOUND = "Invalid or non-wallet transaction id"
+	ERROR_FAKE_MISSING_INPUTS      = "Missing inputs"
+	ERROR_FAKE_MEMPOOL_CONFLICT    = "txn-mempool-conflict"
+	ERROR_FAKE_ALREADY_KNOWN       = "txn-already-known"
+	ERROR_FAKE_BLOCK_NOT_FOUND     = "Block not found"
+	ERROR_FAKE_INVALID_KEY         = "Invalid private key"
+)
+
+// fake block timings
+const (
+	FAKE_GENESIS_TIME    = 1542121293
+	FAKE_BLOCK_INTERVAL  = 10 * time.Minute
+	FAKE_COINBASE_SCRIPT = txscript.OP_TRUE
+)
+
+// MainChainClientFake structure
+// Implements an in-memory fake of MainChainClient for unit-testing
+// Keeps a wallet, a mempool and an active chain of blocks and allows
+// scripting mempool evictions and reorgs for simulating the main chain
+type MainChainClientFake struct {
+	chainCfg *chaincfg.Params
+	txs      map[chainhash.Hash]*wire.MsgTx
+	wallet   map[chainhash.Hash]bool
+	mempool  []chainhash.Hash
+	blocks   []*wire.MsgBlock
+	funded   uint32
+	mined    uint32
+}
+
+// NewMainChainClientFake returns new instance of a fake MainChainClient
+// The fake chain is initiated with a single genesis block
+func NewMainChainClientFake(chainCfg *chaincfg.Params) *MainChainClientFake {
+	f := &MainChainClientFake{
+		chainCfg: chainCfg,
+		txs:      make(map[chainhash.Hash]*wire.MsgTx),
+		wallet:   make(map[chainhash.Hash]bool),
+	}
+	f.Generate(1)
+	return f
+}
+
+// ImportPrivKeyRescan - all fake transactions are wallet transactions - do nothing
+func (f *MainChainClientFake) ImportPrivKeyRescan(key *btcutil.WIF, label string, rescan bool) error {
+	return nil
+}
+
+// ImportAddress - all fake transactions are wallet transactions - do nothing
+func (f *MainChainClientFake) ImportAddress(address string) error {
+	return nil
+}
+
+// SendToAddress creates a wallet transaction funding the address provided
+// The funding input is not tracked and the transaction is added to the mempool
+func (f *MainChainClientFake) SendToAddress(addr btcutil.Address, amount btcutil.Amount) (*chainhash.Hash, error) {
+	pkScript, errScript := txscript.PayToAddrScript(addr)
+	if errScript != nil {
+		return nil, errScript
+	}
+	f.funded++
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, f.funded), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(int64(amount), pkScript))
+
+	txid := tx.TxHash()
+	f.txs[txid] = tx
+	f.wallet[txid] = true
+	f.mempool = append(f.mempool, txid)
+	return &txid, nil
+}
+
+// ListUnspent returns all confirmed wallet outputs not spent in the chain or mempool
+func (f *MainChainClientFake) ListUnspent() ([]btcjson.ListUnspentResult, error) {
+	var unspent []btcjson.ListUnspentResult
+	for height, block := range f.blocks {
+		for _, tx := range block.Transactions {
+			txid := tx.TxHash()
+			if !f.wallet[txid] {
+				continue
+			}
+			for vout, out := range tx.TxOut {
+				if f.isSpent(wire.OutPoint{Hash: txid, Index: uint32(vout)}) {
+					continue
+				}
+				var address string
+				_, addrs, _, errAddr := txscript.ExtractPkScriptAddrs(out.PkScript, f.chainCfg)
+				if errAddr == nil && len(addrs) > 0 {
+					address = addrs[0].EncodeAddress()
+				}
+				unspent = append(unspent, btcjson.ListUnspentResult{
+					TxID:          txid.String(),
+					Vout:          uint32(vout),
+					Address:       address,
+					Amount:        btcutil.Amount(out.Value).ToBTC(),
+					Confirmations: int64(len(f.blocks) - height),
+					Spendable:     true,
+				})
+			}
+		}
+	}
+	return unspent, nil
+}
+
+// GetRawMempool returns the txids of all transactions in the fake mempool
+func (f *MainChainClientFake) GetRawMempool() ([]*chainhash.Hash, error) {
+	var mempool []*chainhash.Hash
+	for i := range f.mempool {
+		txid := f.mempool[i]
+		mempool = append(mempool, &txid)
+	}
+	return mempool, nil
+}
+
+// GetRawTransaction returns any transaction known to the fake client
+func (f *MainChainClientFake) GetRawTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error) {
+	tx, ok := f.txs[*txHash]
+	if !ok {
+		return nil, errors.New(ERROR_FAKE_TX_NOT_FOUND)
+	}
+	return btcutil.NewTx(tx.Copy()), nil
+}
+
+// GetRawTransactionVerbose returns details of any transaction known to the fake client
+// Block details are only set if the transaction is included in the active chain
+// Inputs and outputs are not decoded and only available through the raw tx hex
+func (f *MainChainClientFake) GetRawTransactionVerbose(txHash *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	tx, ok := f.txs[*txHash]
+	if !ok {
+		return nil, errors.New(ERROR_FAKE_TX_NOT_FOUND)
+	}
+	var txBuf bytes.Buffer
+	if errTx := tx.Serialize(&txBuf); errTx != nil {
+		return nil, errTx
+	}
+
+	result := &btcjson.TxRawResult{Hex: hex.EncodeToString(txBuf.Bytes()), Txid: txHash.String(),
+		Hash: tx.WitnessHash().String(), Version: tx.Version, LockTime: tx.LockTime}
+	height, found := f.txHeight(*txHash)
+	if found {
+		block := f.blocks[height]
+		result.BlockHash = block.BlockHash().String()
+		result.Confirmations = uint64(len(f.blocks) - height)
+		result.Time = block.Header.Timestamp.Unix()
+		result.Blocktime = block.Header.Timestamp.Unix()
+	}
+	return result, nil
+}
+
+// GetTransaction returns wallet details of a transaction known to the fake client
+// Blockhash is only set if the transaction is included in the active chain
+func (f *MainChainClientFake) GetTransaction(txHash *chainhash.Hash) (*btcjson.GetTransactionResult, error) {
+	if !f.wallet[*txHash] {
+		return nil, errors.New(ERROR_FAKE_WALLET_TX_NOT_FOUND)
+	}
+
+	result := &btcjson.GetTransactionResult{TxID: txHash.String()}
+	height, found := f.txHeight(*txHash)
+	if found {
+		block := f.blocks[height]
+		result.BlockHash = block.BlockHash().String()
+		result.BlockTime = block.Header.Timestamp.Unix()
+		result.Confirmations = int64(len(f.blocks) - height)
+		result.Time = block.Header.Timestamp.Unix()
+	} else {
+		result.Time = f.blocks[len(f.blocks)-1].Header.Timestamp.Unix()
+	}
+	return result, nil
+}
+
+// CreateRawTransaction creates an unsigned transaction from inputs and amounts
+// Outputs are ordered by address so that the result is deterministic
+func (f *MainChainClientFake) CreateRawTransaction(inputs []btcjson.TransactionInput,
+	amounts map[btcutil.Address]btcutil.Amount, lockTime *int64) (*wire.MsgTx, error) {
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for _, input := range inputs {
+		prevHash, errHash := chainhash.NewHashFromStr(input.Txid)
+		if errHash != nil {
+			return nil, errHash
+		}
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(prevHash, input.Vout), nil, nil))
+	}
+
+	var addrs []btcutil.Address
+	for addr := range amounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].String() < addrs[j].String() })
+	for _, addr := range addrs {
+		pkScript, errScript := txscript.PayToAddrScript(addr)
+		if errScript != nil {
+			return nil, errScript
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(amounts[addr]), pkScript))
+	}
+
+	if lockTime != nil {
+		tx.LockTime = uint32(*lockTime)
+	}
+	return tx, nil
+}
+
+// SignRawTransaction3 signs each input with all keys provided
+// For inputs with a redeem script a multisig scriptSig is generated
+func (f *MainChainClientFake) SignRawTransaction3(tx *wire.MsgTx,
+	inputs []btcjson.RawTxInput, privKeysWIF []string) (*wire.MsgTx, bool, error) {
+
+	signedTx := tx.Copy()
+	for i, txIn := range signedTx.TxIn {
+		for _, input := range inputs {
+			if input.Txid != txIn.PreviousOutPoint.Hash.String() || input.Vout != txIn.PreviousOutPoint.Index {
+				continue
+			}
+			scriptSig, errSign := f.signInput(signedTx, i, input, privKeysWIF)
+			if errSign != nil {
+				return nil, false, errSign
+			}
+			txIn.SignatureScript = scriptSig
+		}
+	}
+	return signedTx, true, nil
+}
+
+// Sign a single transaction input and return the scriptSig
+func (f *MainChainClientFake) signInput(tx *wire.MsgTx, idx int, input btcjson.RawTxInput, privKeysWIF []string) ([]byte, error) {
+	subScript, errScript := decodeScript(input.ScriptPubKey)
+	if errScript != nil {
+		return nil, errScript
+	}
+	redeemScript, errScript := decodeScript(input.RedeemScript)
+	if errScript != nil {
+		return nil, errScript
+	}
+	if len(redeemScript) > 0 {
+		subScript = redeemScript
+	}
+
+	builder := txscript.NewScriptBuilder()
+	if len(redeemScript) > 0 {
+		builder.AddOp(txscript.OP_0)
+	}
+	for _, keyWIF := range privKeysWIF {
+		key, errKey := btcutil.DecodeWIF(keyWIF)
+		if errKey != nil {
+			return nil, errors.New(ERROR_FAKE_INVALID_KEY)
+		}
+		sig, errSig := txscript.RawTxInSignature(tx, idx, subScript, txscript.SigHashAll, key.PrivKey)
+		if errSig != nil {
+			return nil, errSig
+		}
+		builder.AddData(sig)
+		if len(redeemScript) == 0 {
+			builder.AddData(key.SerializePubKey())
+		}
+	}
+	if len(redeemScript) > 0 {
+		builder.AddData(redeemScript)
+	}
+	return builder.Script()
+}
+
+// SendRawTransaction adds a transaction to the fake mempool
+// Transactions with unknown or already spent inputs are rejected
+func (f *MainChainClientFake) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	txid := tx.TxHash()
+	if _, ok := f.txs[txid]; ok && (f.inMempool(txid) || f.inChain(txid)) {
+		return nil, errors.New(ERROR_FAKE_ALREADY_KNOWN)
+	}
+	for _, txIn := range tx.TxIn {
+		prevTx, ok := f.txs[txIn.PreviousOutPoint.Hash]
+		if !ok || int(txIn.PreviousOutPoint.Index) >= len(prevTx.TxOut) {
+			return nil, errors.New(ERROR_FAKE_MISSING_INPUTS)
+		}
+		if f.isSpent(txIn.PreviousOutPoint) {
+			return nil, errors.New(ERROR_FAKE_MEMPOOL_CONFLICT)
+		}
+	}
+
+	f.txs[txid] = tx.Copy()
+	f.wallet[txid] = true
+	f.mempool = append(f.mempool, txid)
+	return &txid, nil
+}
+
+// Generate mines new blocks including all transactions in the mempool
+func (f *MainChainClientFake) Generate(numBlocks uint32) ([]*chainhash.Hash, error) {
+	var hashes []*chainhash.Hash
+	for i := uint32(0); i < numBlocks; i++ {
+		height := len(f.blocks)
+
+		// unique coinbase for each block height
+		coinbase := wire.NewMsgTx(wire.TxVersion)
+		coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex),
+			[]byte{byte(height), byte(height >> 8), byte(height >> 16)}, nil))
+		coinbase.AddTxOut(wire.NewTxOut(0, []byte{FAKE_COINBASE_SCRIPT}))
+
+		txs := []*wire.MsgTx{coinbase}
+		for _, txid := range f.mempool {
+			txs = append(txs, f.txs[txid])
+		}
+		f.mempool = nil
+
+		var prevHash chainhash.Hash
+		if height > 0 {
+			prevHash = f.blocks[height-1].BlockHash()
+		}
+		// unique nonce for each block mined to distinguish reorged blocks
+		f.mined++
+		header := wire.NewBlockHeader(1, &prevHash, calcMerkleRoot(txs), 0x207fffff, f.mined)
+		header.Timestamp = time.Unix(FAKE_GENESIS_TIME, 0).Add(time.Duration(height) * FAKE_BLOCK_INTERVAL)
+
+		block := wire.NewMsgBlock(header)
+		for _, tx := range txs {
+			block.AddTransaction(tx)
+		}
+		f.blocks = append(f.blocks, block)
+
+		blockhash := block.BlockHash()
+		hashes = append(hashes, &blockhash)
+	}
+	return hashes, nil
+}
+
+// GetBlock returns a block of the fake active chain
+func (f *MainChainClientFake) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	for _, block := range f.blocks {
+		if block.BlockHash() == *blockHash {
+			return block, nil
+		}
+	}
+	return nil, errors.New(ERROR_FAKE_BLOCK_NOT_FOUND)
+}
+
+// GetBlockHeaderVerbose returns header details of a block of the fake active chain
+func (f *MainChainClientFake) GetBlockHeaderVerbose(blockHash *chainhash.Hash) (*btcjson.GetBlockHeaderVerboseResult, error) {
+	for height, block := range f.blocks {
+		if block.BlockHash() == *blockHash {
+			result := &btcjson.GetBlockHeaderVerboseResult{
+				Hash:          blockHash.String(),
+				Confirmations: int64(len(f.blocks) - height),
+				Height:        int32(height),
+				MerkleRoot:    block.Header.MerkleRoot.String(),
+				Time:          block.Header.Timestamp.Unix(),
+				PreviousHash:  block.Header.PrevBlock.String(),
+			}
+			if height+1 < len(f.blocks) {
+				result.NextHash = f.blocks[height+1].BlockHash().String()
+			}
+			return result, nil
+		}
+	}
+	return nil, errors.New(ERROR_FAKE_BLOCK_NOT_FOUND)
+}
+
+// GetBlockHash returns the hash of the block at a height of the fake active chain
+func (f *MainChainClientFake) GetBlockHash(height int64) (*chainhash.Hash, error) {
+	if height < 0 || height >= int64(len(f.blocks)) {
+		return nil, errors.New(ERROR_FAKE_BLOCK_NOT_FOUND)
+	}
+	blockhash := f.blocks[height].BlockHash()
+	return &blockhash, nil
+}
+
+// GetBlockCount returns the height of the fake active chain tip
+func (f *MainChainClientFake) GetBlockCount() (int64, error) {
+	return int64(len(f.blocks) - 1), nil
+}
+
+// InvalidateBlock removes a block and all its descendants from the active chain
+// Any non coinbase transactions of the removed blocks are returned to the mempool
+func (f *MainChainClientFake) InvalidateBlock(blockHash *chainhash.Hash) error {
+	for height, block := range f.blocks {
+		if block.BlockHash() == *blockHash {
+			var mempool []chainhash.Hash
+			for _, removed := range f.blocks[height:] {
+				for _, tx := range removed.Transactions[1:] {
+					mempool = append(mempool, tx.TxHash())
+				}
+			}
+			f.blocks = f.blocks[:height]
+			f.mempool = append(mempool, f.mempool...)
+			return nil
+		}
+	}
+	return errors.New(ERROR_FAKE_BLOCK_NOT_FOUND)
+}
+
+// EvictFromMempool drops a transaction from the mempool without mining it
+// The transaction remains known to the wallet as unconfirmed
+func (f *MainChainClientFake) EvictFromMempool(txHash *chainhash.Hash) {
+	for i, txid := range f.mempool {
+		if txid == *txHash {
+			f.mempool = append(f.mempool[:i], f.mempool[i+1:]...)
+			return
+		}
+	}
+}
+
+// Check whether an outpoint is spent in the active chain or the mempool
+func (f *MainChainClientFake) isSpent(outpoint wire.OutPoint) bool {
+	spends := func(tx *wire.MsgTx) bool {
+		for _, txIn := range tx.TxIn {
+			if txIn.PreviousOutPoint == outpoint {
+				return true
+			}
+		}
+		return false
+	}
+	for _, block := range f.blocks {
+		for _, tx := range block.Transactions {
+			if spends(tx) {
+				return true
+			}
+		}
+	}
+	for _, txid := range f.mempool {
+		if spends(f.txs[txid]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the active chain height of the block including a transaction
+func (f *MainChainClientFake) txHeight(txHash chainhash.Hash) (int, bool) {
+	for height, block := range f.blocks {
+		for _, tx := range block.Transactions {
+			if tx.TxHash() == txHash {
+				return height, true
+			}
+		}
+	}
+	return -1, false
+}
+
+// Check whether a transaction is included in the active chain
+func (f *MainChainClientFake) inChain(txHash chainhash.Hash) bool {
+	_, found := f.txHeight(txHash)
+	return found
+}
+
+// Check whether a transaction is in the mempool
+func (f *MainChainClientFake) inMempool(txHash chainhash.Hash) bool {
+	for _, txid := range f.mempool {
+		if txid == txHash {
+			return true
+		}
+	}
+	return false
+}
+
+// Calculate the merkle root of a list of block transactions
+func calcMerkleRoot(txs []*wire.MsgTx) *chainhash.Hash {
+	var utilTxs []*btcutil.Tx
+	for _, tx := range txs {
+		utilTxs = append(utilTxs, btcutil.NewTx(tx))
+	}
+	merkles := blockchain.BuildMerkleTreeStore(utilTxs, false)
+	return merkles[len(merkles)-1]
+}
+
+// Decode a hex encoded script
+func decodeScript(script string) ([]byte, error) {
+	if script == "" {
+		return []byte{}, nil
+	}
+	return hex.DecodeString(script)
+}
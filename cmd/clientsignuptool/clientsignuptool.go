@@ -3,12 +3,14 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
 	"mainstay/config"
-	"mainstay/models"
 	"mainstay/server"
 
 	"github.com/btcsuite/btcutil"
@@ -19,37 +21,82 @@ const CONF_PATH = "/src/mainstay/cmd/clientsignuptool/conf.json"
 
 var (
 	mainConfig *config.Config
-	dbMongo    *server.DbMongo
+	srv        *server.Server
+
+	statusPosition int
+	status         string
+	webhookUrl     string
+	webhookSecret  string
 )
 
 // init
 func init() {
+	flag.IntVar(&statusPosition, "position", -1, "Client position to set the slot status or webhook of instead of signing up a new client")
+	flag.StringVar(&status, "status", "", "Slot status to set for position: active, suspended or revoked")
+	flag.StringVar(&webhookUrl, "webhook", "", "Webhook url to set for position, or \"none\" to remove the webhook")
+	flag.StringVar(&webhookSecret, "webhooksecret", "", "Webhook secret to set for position, generated if not provided")
+	flag.Parse()
+
 	confFile := config.GetConfFile(os.Getenv("GOPATH") + CONF_PATH)
 	mainConfig = config.NewConfig(confFile)
 }
 
 // read client details and get client position
 func clientPosition() int32 {
-	// Read existing clients and get next available client position
+	// Read existing clients and get lowest free client position
 	fmt.Println("existing clients")
-	details, errDb := dbMongo.GetClientDetails()
+	details, errDb := srv.GetClientDetails()
 	if errDb != nil {
 		log.Fatal(errDb)
 	}
-	var maxClientPosition int32
 	if len(details) == 0 {
 		fmt.Println("no existing client positions")
-		return 0
-	} else {
-		for _, client := range details {
-			if client.ClientPosition > maxClientPosition {
-				maxClientPosition = client.ClientPosition
-			}
-			fmt.Printf("client_position: %d pubkey: %s\n", client.ClientPosition, client.Pubkey)
+	}
+	for _, client := range details {
+		fmt.Printf("client_position: %d pubkey: %s status: %s\n", client.ClientPosition, client.Pubkey, client.SlotStatus())
+	}
+	fmt.Println()
+	position, errPosition := srv.NextClientPosition()
+	if errPosition != nil {
+		log.Fatal(errPosition)
+	}
+	return position
+}
+
+// set slot status of existing client position
+func setClientStatus() {
+	details, errStatus := srv.SetClientStatus(int32(statusPosition), status)
+	if errStatus != nil {
+		log.Fatal(errStatus)
+	}
+	fmt.Println("UPDATED CLIENT DETAILS")
+	fmt.Printf("client_position: %d\n", details.ClientPosition)
+	fmt.Printf("pubkey: %s\n", details.Pubkey)
+	fmt.Printf("status: %s\n", details.Status)
+	fmt.Println()
+	clientPosition()
+}
+
+// set webhook url and secret of existing client position
+func setClientWebhook() {
+	if webhookUrl == "none" {
+		webhookUrl = ""
+	} else if webhookSecret == "" {
+		secret := make([]byte, 32)
+		if _, errRand := rand.Read(secret); errRand != nil {
+			log.Fatal(errRand)
 		}
+		webhookSecret = hex.EncodeToString(secret)
 	}
+	details, errWebhook := srv.SetClientWebhook(int32(statusPosition), webhookUrl, webhookSecret)
+	if errWebhook != nil {
+		log.Fatal(errWebhook)
+	}
+	fmt.Println("UPDATED CLIENT DETAILS")
+	fmt.Printf("client_position: %d\n", details.ClientPosition)
+	fmt.Printf("webhook_url: %s\n", details.WebhookUrl)
+	fmt.Printf("webhook_secret: %s\n", details.WebhookSecret)
 	fmt.Println()
-	return maxClientPosition + 1
 }
 
 // main
@@ -57,7 +104,7 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	dbMongo = server.NewDbMongo(ctx, mainConfig.DbConnectivity())
+	srv = server.NewServer(ctx, server.NewDb(ctx, mainConfig.DbConnectivity()))
 
 	fmt.Println()
 	fmt.Println("*********************************************")
@@ -65,6 +112,15 @@ func main() {
 	fmt.Println("*********************************************")
 	fmt.Println()
 
+	if statusPosition >= 0 && webhookUrl != "" {
+		setClientWebhook()
+		return
+	}
+	if statusPosition >= 0 {
+		setClientStatus()
+		return
+	}
+
 	nextClientPosition := clientPosition()
 	fmt.Printf("next available position: %d\n", nextClientPosition)
 	fmt.Println()
@@ -101,10 +157,9 @@ func main() {
 	fmt.Println("*********** Inserting New Client ************")
 	fmt.Println("*********************************************")
 	fmt.Println()
-	newClientDetails := models.ClientDetails{ClientPosition: nextClientPosition, AuthToken: uuid.String(), Pubkey: addr}
-	saveErr := dbMongo.SaveClientDetails(newClientDetails)
-	if saveErr != nil {
-		log.Fatal(saveErr)
+	newClientDetails, signupErr := srv.SignupClient(uuid.String(), addr)
+	if signupErr != nil {
+		log.Fatal(signupErr)
 	}
 	fmt.Println("NEW CLIENT DETAILS")
 	fmt.Printf("client_position: %d\n", newClientDetails.ClientPosition)
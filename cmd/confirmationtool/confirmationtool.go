@@ -3,6 +3,7 @@ package main
 
 import (
 	"flag"
+	"io/ioutil"
 	"log"
 	"os"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"mainstay/clients"
 	"mainstay/config"
 	"mainstay/crypto"
+	"mainstay/models"
 	"mainstay/staychain"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -29,6 +31,9 @@ var (
 	pk          string
 	pkWIF       *btcutil.WIF
 	showDetails bool
+	txidsp      string
+	proofA      string
+	proofB      string
 	mainConfig  *config.Config
 	oceanClient clients.SidechainClient
 )
@@ -38,7 +43,13 @@ func init() {
 	flag.BoolVar(&showDetails, "detailed", false, "Detailed information on attestation transaction")
 	flag.StringVar(&tx, "tx", "", "Tx id from which to start searching the staychain")
 	flag.StringVar(&pk, "pk", "", "Private key for genesis attestation transaction")
+	flag.StringVar(&txidsp, "txidsp", "", "Staychain start point tx id to traverse back to from tx or from proof bundle txs")
+	flag.StringVar(&proofA, "proof1", "", "Proof bundle file for Proof of Immutable State verification")
+	flag.StringVar(&proofB, "proof2", "", "Proof bundle file for Proof of Immutable State verification")
 	flag.Parse()
+	if txidsp != "" && tx == "" && proofA == "" && proofB == "" {
+		log.Fatal("Tx id to traverse back from -tx required with -txidsp")
+	}
 	if tx == "" {
 		tx = FUNDING_TX
 	}
@@ -52,12 +63,26 @@ func init() {
 
 	confFile := config.GetConfFile(os.Getenv("GOPATH") + CONF_PATH)
 	mainConfig = config.NewConfig(confFile)
-	oceanClient = config.NewClientFromConfig(false, confFile)
+	if txidsp == "" && proofA == "" && proofB == "" {
+		oceanClient = config.NewClientFromConfig(false, confFile)
+	}
 }
 
 // main method
 func main() {
 	defer mainConfig.MainClient().Shutdown()
+
+	// Proof of Immutable State mode
+	if proofA != "" || proofB != "" {
+		verifyImmutableState()
+		return
+	}
+
+	// Backward traversal mode
+	if txidsp != "" {
+		traverseToStartPoint()
+		return
+	}
 	defer oceanClient.Close()
 
 	txraw := getRawTxFromHash(tx)
@@ -87,6 +112,70 @@ func main() {
 	}
 }
 
+// Traverse staychain backwards from tx to the start point txidsp
+func traverseToStartPoint() {
+	txhash, errHash := chainhash.NewHashFromStr(tx)
+	if errHash != nil {
+		log.Println("Invalid tx id provided")
+		log.Fatal(errHash)
+	}
+	sphash, errHash := chainhash.NewHashFromStr(txidsp)
+	if errHash != nil {
+		log.Println("Invalid start point tx id provided")
+		log.Fatal(errHash)
+	}
+
+	traverser := staychain.NewChainTraverser(mainConfig.MainClient(), *sphash)
+	height, errTraverse := traverser.Traverse(*txhash)
+	if errTraverse != nil {
+		log.Fatalf("Staychain traversal failed: %v\n", errTraverse)
+	}
+	log.Println("Staychain traversal verified")
+	log.Printf("txid: %s\n", txhash.String())
+	log.Printf("staychain height: %d\n", height)
+}
+
+// Verify Proof of Immutable State for the two proof bundles provided
+func verifyImmutableState() {
+	if proofA == "" || proofB == "" {
+		log.Fatal("Both -proof1 and -proof2 proof bundles are required")
+	}
+	if txidsp == "" {
+		log.Fatal("Staychain start point -txidsp is required")
+	}
+	sphash, errHash := chainhash.NewHashFromStr(txidsp)
+	if errHash != nil {
+		log.Println("Invalid start point tx id provided")
+		log.Fatal(errHash)
+	}
+	bundleA := readProofBundle(proofA)
+	bundleB := readProofBundle(proofB)
+
+	traverser := staychain.NewChainTraverser(mainConfig.MainClient(), *sphash)
+	hops, errState := staychain.VerifyImmutableState(*bundleA, *bundleB, traverser)
+	if errState != nil {
+		log.Fatalf("Proof of Immutable State verification failed: %v\n", errState)
+	}
+	log.Println("Proof of Immutable State verified")
+	log.Printf("position: %d\n", bundleA.Proof.ClientPosition)
+	log.Printf("commitments: %s %s\n", bundleA.Proof.Commitment.String(), bundleB.Proof.Commitment.String())
+	log.Printf("%s txids: %s %s\n", MAIN_NAME, bundleA.Txid.String(), bundleB.Txid.String())
+	log.Printf("staychain hops: %d\n", hops)
+}
+
+// Read proof bundle file in json or binary format
+func readProofBundle(path string) *models.ProofBundle {
+	data, errRead := ioutil.ReadFile(path)
+	if errRead != nil {
+		log.Fatal(errRead)
+	}
+	bundle, errImport := models.ImportProofBundle(data)
+	if errImport != nil {
+		log.Fatal(errImport)
+	}
+	return bundle
+}
+
 // Get raw transaction from a tx string hash using rpc client
 func getRawTxFromHash(hashstr string) staychain.Tx {
 	txhash, errHash := chainhash.NewHashFromStr(hashstr)
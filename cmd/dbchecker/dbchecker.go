@@ -0,0 +1,95 @@
+// Db consistency checker tool
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"log"
+	"os"
+
+	"mainstay/config"
+	"mainstay/server"
+	"mainstay/staychain"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Use staychain package to cross-check the db against the
+// staychain and optionally repair any repairable issues
+
+const CONF_PATH = "/src/mainstay/cmd/dbchecker/conf.json"
+
+var (
+	tx0        string
+	pubkey     string
+	script     string
+	repair     bool
+	txid0      *chainhash.Hash
+	pubkey0    []byte
+	script0    []byte
+	mainConfig *config.Config
+)
+
+// init
+func init() {
+	flag.StringVar(&tx0, "tx", "", "Tx id of the staychain start point transaction")
+	flag.StringVar(&pubkey, "pubkey", "", "Base pubkey of the staychain in hex")
+	flag.StringVar(&script, "script", "", "Base multisig redeem script of the staychain in hex")
+	flag.BoolVar(&repair, "repair", false, "Repair confirmation status, info, commitments and proofs in the db")
+	flag.Parse()
+	if tx0 == "" || (pubkey == "") == (script == "") {
+		flag.PrintDefaults()
+		log.Fatal("Need to provide -tx and one of -pubkey or -script")
+	}
+
+	var errHash error
+	txid0, errHash = chainhash.NewHashFromStr(tx0)
+	if errHash != nil {
+		log.Println("Invalid tx id provided")
+		log.Fatal(errHash)
+	}
+	var errHex error
+	if pubkey != "" {
+		pubkey0, errHex = hex.DecodeString(pubkey)
+	} else {
+		script0, errHex = hex.DecodeString(script)
+	}
+	if errHex != nil {
+		log.Println("Invalid pubkey or script provided")
+		log.Fatal(errHex)
+	}
+
+	confFile := config.GetConfFile(os.Getenv("GOPATH") + CONF_PATH)
+	mainConfig = config.NewConfig(confFile)
+}
+
+// main method
+func main() {
+	defer mainConfig.MainClient().Shutdown()
+
+	ctx := context.Background()
+	db := server.NewDb(ctx, mainConfig.DbConnectivity())
+	checker := staychain.NewDbChecker(mainConfig.MainClient(), db, *txid0, pubkey0, script0)
+	if mainConfig.ProofArchive() != "" {
+		checker.SetProofArchive(server.NewProofArchive(mainConfig.ProofArchive()))
+	}
+
+	issues, errCheck := checker.Check(ctx, repair)
+	if errCheck != nil {
+		log.Fatalf("Db check failed: %v\n", errCheck)
+	}
+
+	var unrepaired int
+	for _, issue := range issues {
+		log.Println(issue.String())
+		if !issue.Repaired {
+			unrepaired++
+		}
+	}
+	log.Printf("issues found: %d\n", len(issues))
+	log.Printf("issues repaired: %d\n", len(issues)-unrepaired)
+	if unrepaired > 0 {
+		os.Exit(1)
+	}
+}
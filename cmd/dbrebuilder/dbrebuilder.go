@@ -0,0 +1,98 @@
+// Db disaster recovery tool
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"log"
+	"os"
+
+	"mainstay/config"
+	"mainstay/models"
+	"mainstay/server"
+	"mainstay/staychain"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Use staychain package to rebuild the db from the
+// staychain and the commitment archive of the service
+
+const CONF_PATH = "/src/mainstay/cmd/dbrebuilder/conf.json"
+
+var (
+	tx0         string
+	pubkey      string
+	script      string
+	archivePath string
+	txid0       *chainhash.Hash
+	pubkey0     []byte
+	script0     []byte
+	mainConfig  *config.Config
+)
+
+// init
+func init() {
+	flag.StringVar(&tx0, "tx", "", "Tx id of the staychain start point transaction")
+	flag.StringVar(&pubkey, "pubkey", "", "Base pubkey of the staychain in hex")
+	flag.StringVar(&script, "script", "", "Base multisig redeem script of the staychain in hex")
+	flag.StringVar(&archivePath, "archive", "", "Commitment archive file written by the attestation service")
+	flag.Parse()
+	if tx0 == "" || archivePath == "" || (pubkey == "") == (script == "") {
+		flag.PrintDefaults()
+		log.Fatal("Need to provide -tx, -archive and one of -pubkey or -script")
+	}
+
+	var errHash error
+	txid0, errHash = chainhash.NewHashFromStr(tx0)
+	if errHash != nil {
+		log.Println("Invalid tx id provided")
+		log.Fatal(errHash)
+	}
+	var errHex error
+	if pubkey != "" {
+		pubkey0, errHex = hex.DecodeString(pubkey)
+	} else {
+		script0, errHex = hex.DecodeString(script)
+	}
+	if errHex != nil {
+		log.Println("Invalid pubkey or script provided")
+		log.Fatal(errHex)
+	}
+
+	confFile := config.GetConfFile(os.Getenv("GOPATH") + CONF_PATH)
+	mainConfig = config.NewConfig(confFile)
+}
+
+// main method
+func main() {
+	defer mainConfig.MainClient().Shutdown()
+
+	archive, errOpen := os.Open(archivePath)
+	if errOpen != nil {
+		log.Fatalf("Commitment archive read failed: %v\n", errOpen)
+	}
+	records, errRead := models.ReadCommitmentArchive(archive)
+	archive.Close()
+	if errRead != nil {
+		log.Fatalf("Commitment archive read failed: %v\n", errRead)
+	}
+
+	ctx := context.Background()
+	db := server.NewDb(ctx, mainConfig.DbConnectivity())
+	rebuilder := staychain.NewDbRebuilder(mainConfig.MainClient(), db, *txid0, pubkey0, script0)
+
+	result, errRebuild := rebuilder.Rebuild(ctx, records)
+	if errRebuild != nil {
+		log.Fatalf("Db rebuild failed: %v\n", errRebuild)
+	}
+	for _, txid := range result.Unmatched {
+		log.Printf("No commitment archive record for staychain tx %s\n", txid.String())
+	}
+	log.Printf("attestations rebuilt: %d\n", len(result.Rebuilt))
+	log.Printf("attestations not rebuilt: %d\n", len(result.Unmatched))
+	if len(result.Unmatched) > 0 {
+		os.Exit(1)
+	}
+}
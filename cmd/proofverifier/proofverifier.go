@@ -0,0 +1,85 @@
+// Proof bundle verification tool
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"mainstay/models"
+	"mainstay/staychain"
+
+	"github.com/btcsuite/btcd/rpcclient"
+)
+
+// Use staychain package to verify a proof bundle offline
+// and optionally confirm its block header with a bitcoin node
+
+var (
+	bundlePath string
+	rpcUrl     string
+	rpcUser    string
+	rpcPass    string
+)
+
+// init
+func init() {
+	flag.StringVar(&bundlePath, "bundle", "", "Path to proof bundle file in json or binary format")
+	flag.StringVar(&rpcUrl, "rpcurl", "", "Bitcoin node rpc url to confirm the block header is in the best chain")
+	flag.StringVar(&rpcUser, "rpcuser", "", "Bitcoin node rpc user")
+	flag.StringVar(&rpcPass, "rpcpass", "", "Bitcoin node rpc password")
+	flag.Parse()
+	if bundlePath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// main method
+func main() {
+	bundle := readBundle(bundlePath)
+
+	if errVerify := staychain.VerifyProofBundle(*bundle); errVerify != nil {
+		log.Fatalf("Proof verification failed: %v\n", errVerify)
+	}
+	log.Println("Proof verified")
+	log.Printf("position: %d\n", bundle.Proof.ClientPosition)
+	log.Printf("commitment: %s\n", bundle.Proof.Commitment.String())
+	log.Printf("merkle root: %s\n", bundle.Proof.MerkleRoot.String())
+	log.Printf("txid: %s\n", bundle.Txid.String())
+	log.Printf("blockhash: %s\n", bundle.Spv.Blockhash().String())
+
+	if rpcUrl != "" {
+		client, errClient := rpcclient.New(&rpcclient.ConnConfig{
+			Host:         rpcUrl,
+			User:         rpcUser,
+			Pass:         rpcPass,
+			HTTPPostMode: true,
+			DisableTLS:   true,
+		}, nil)
+		if errClient != nil {
+			log.Fatalf("Bitcoin node connection failed: %v\n", errClient)
+		}
+		defer client.Shutdown()
+
+		height, errChain := staychain.VerifyProofBundleBestChain(*bundle, client)
+		if errChain != nil {
+			log.Fatalf("Proof verification failed: %v\n", errChain)
+		}
+		log.Printf("Block header in best chain at height: %d\n", height)
+	}
+}
+
+// Read proof bundle file in json or binary format
+func readBundle(path string) *models.ProofBundle {
+	data, errRead := ioutil.ReadFile(path)
+	if errRead != nil {
+		log.Fatalf("Proof bundle read failed: %v\n", errRead)
+	}
+	bundle, errImport := models.ImportProofBundle(data)
+	if errImport != nil {
+		log.Fatalf("Proof bundle decoding failed: %v\n", errImport)
+	}
+	return bundle
+}
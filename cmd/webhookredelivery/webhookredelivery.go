@@ -0,0 +1,75 @@
+// Webhook redelivery tool
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"mainstay/config"
+	"mainstay/server"
+	"mainstay/webhook"
+)
+
+// Use webhook package to list failed client webhook deliveries
+// and redeliver them to the current client webhooks
+
+const CONF_PATH = "/src/mainstay/cmd/webhookredelivery/conf.json"
+
+var (
+	position   int
+	list       bool
+	mainConfig *config.Config
+)
+
+// init
+func init() {
+	flag.IntVar(&position, "position", -1, "Client position to redeliver failed deliveries of, all positions if not set")
+	flag.BoolVar(&list, "list", false, "List failed deliveries without redelivering")
+	flag.Parse()
+
+	confFile := config.GetConfFile(os.Getenv("GOPATH") + CONF_PATH)
+	mainConfig = config.NewConfig(confFile)
+}
+
+// main method
+func main() {
+	ctx := context.Background()
+	srv := server.NewServer(ctx, server.NewDb(ctx, mainConfig.DbConnectivity()))
+
+	if list {
+		deliveries, errDeliveries := srv.GetWebhookDeliveries(false)
+		if errDeliveries != nil {
+			log.Fatal(errDeliveries)
+		}
+		for _, delivery := range deliveries {
+			if !delivery.Pending() && (position < 0 || delivery.ClientPosition == int32(position)) {
+				log.Printf("txid: %s position: %d url: %s attempts: %d failed_at: %s error: %s\n",
+					delivery.Txid.String(), delivery.ClientPosition, delivery.Url, delivery.Attempts,
+					delivery.FailedAt.String(), delivery.LastError)
+			}
+		}
+		return
+	}
+
+	redelivered, errRedeliver := webhook.NewWebhookSender(srv).Redeliver(int32(position))
+	var failed int
+	for _, delivery := range redelivered {
+		if delivery.Delivered {
+			log.Printf("txid: %s position: %d redelivered\n", delivery.Txid.String(), delivery.ClientPosition)
+		} else {
+			log.Printf("txid: %s position: %d failed: %s\n", delivery.Txid.String(), delivery.ClientPosition,
+				delivery.LastError)
+			failed++
+		}
+	}
+	if errRedeliver != nil {
+		log.Fatalf("Redelivery failed: %v\n", errRedeliver)
+	}
+	log.Printf("deliveries redelivered: %d\n", len(redelivered)-failed)
+	log.Printf("deliveries failed: %d\n", failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
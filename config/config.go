@@ -1,8 +1,12 @@
 package config
 
 import (
+	"fmt"
+	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"mainstay/clients"
 
@@ -20,6 +24,14 @@ const TOPIC_NEW_HASH = "H"
 const TOPIC_NEW_TX = "T"
 const TOPIC_CONFIRMED_HASH = "C"
 const TOPIC_SIGS = "S"
+const TOPIC_CONFIRMED_ATTESTATION = "A"
+const TOPIC_SLOT_PROOF = "P"
+
+// Get topic of slot proofs for client position
+// Topics end with a separator as zmq subscriptions match topic prefixes
+func SlotProofTopic(position int32) string {
+	return fmt.Sprintf("%s%d/", TOPIC_SLOT_PROOF, position)
+}
 
 // Config struct
 // Client connections and other parameters required
@@ -28,6 +40,10 @@ type Config struct {
 	mainClient     *rpcclient.Client
 	mainChainCfg   *chaincfg.Params
 	multisigNodes  []string
+	apiHost        string
+	archivePath    string
+	proofArchive   string
+	proofRetention time.Duration
 	initTX         string
 	initPK         string
 	multisigScript string
@@ -49,6 +65,42 @@ func (c *Config) MultisigNodes() []string {
 	return c.multisigNodes
 }
 
+// Get request api host
+func (c *Config) ApiHost() string {
+	return c.apiHost
+}
+
+// Get commitment archive file path
+func (c *Config) CommitmentArchive() string {
+	return c.archivePath
+}
+
+// Set commitment archive file path
+func (c *Config) SetCommitmentArchive(path string) {
+	c.archivePath = path
+}
+
+// Get proof archive directory of archived attestation rounds
+func (c *Config) ProofArchive() string {
+	return c.proofArchive
+}
+
+// Set proof archive directory of archived attestation rounds
+func (c *Config) SetProofArchive(path string) {
+	c.proofArchive = path
+}
+
+// Get retention period of merkle commitments and proofs in the db
+// Zero if rounds are never archived
+func (c *Config) ProofRetention() time.Duration {
+	return c.proofRetention
+}
+
+// Set retention period of merkle commitments and proofs in the db
+func (c *Config) SetProofRetention(retention time.Duration) {
+	c.proofRetention = retention
+}
+
 // Get Tx Signers host names
 func (c *Config) DbConnectivity() DbConnectivity {
 	return c.dbConnectivity
@@ -97,9 +149,21 @@ func NewConfig(customConf ...[]byte) *Config {
 	mainClientCfg := GetChainCfgParams(MAIN_CHAIN_NAME, conf)
 
 	multisignodes := strings.Split(GetEnvFromConf("misc", "multisignodes", conf), ",")
+	apihost := GetEnvFromConf("misc", "apihost", conf)
+	archivePath := GetEnvFromConf("misc", "commitmentarchive", conf)
+	proofArchive := GetEnvFromConf("misc", "proofarchive", conf)
+	var proofRetention time.Duration
+	if retentionDays := GetEnvFromConf("misc", "proofretentiondays", conf); retentionDays != "" {
+		days, errDays := strconv.Atoi(retentionDays)
+		if errDays != nil || days < 1 {
+			log.Fatalf("Invalid proof retention days %s\n", retentionDays)
+		}
+		proofRetention = time.Duration(days) * 24 * time.Hour
+	}
 
 	dbConnectivity := GetDbConnectivity(conf)
-	return &Config{mainClient, mainClientCfg, multisignodes, "", "", "", dbConnectivity}
+	return &Config{mainClient, mainClientCfg, multisignodes, apihost, archivePath, proofArchive, proofRetention,
+		"", "", "", dbConnectivity}
 }
 
 // Return SidechainClient depending on whether unit test config or actual config
@@ -117,23 +181,39 @@ func NewClientFromConfig(isTest bool, customConf ...[]byte) clients.SidechainCli
 	return clients.NewSidechainClientOcean(GetRPC(SIDE_CHAIN_NAME, conf))
 }
 
+// db backend names
+const (
+	DB_BACKEND_MONGO  = "mongo"
+	DB_BACKEND_SQLITE = "sqlite"
+)
+
 // DbDetails struct
 // Database connectivity details
+// Backend is the db backend name, defaulting to mongo if not set
+// Path is the database file path of the sqlite backend
 type DbConnectivity struct {
+	Backend  string
 	User     string
 	Password string
 	Host     string
 	Port     string
 	Name     string
+	Path     string
 }
 
 // Return DbConnectivity from conf options
 func GetDbConnectivity(conf []byte) DbConnectivity {
+	backend := GetEnvFromConf("db", "backend", conf)
+	if backend == "" {
+		backend = DB_BACKEND_MONGO
+	}
 	return DbConnectivity{
+		Backend:  backend,
 		User:     GetEnvFromConf("db", "user", conf),
 		Password: GetEnvFromConf("db", "password", conf),
 		Host:     GetEnvFromConf("db", "host", conf),
 		Port:     GetEnvFromConf("db", "port", conf),
 		Name:     GetEnvFromConf("db", "name", conf),
+		Path:     GetEnvFromConf("db", "path", conf),
 	}
 }
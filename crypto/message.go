@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// Utility functionalities for signing and verifying messages
+// Compatible with the signmessage/verifymessage bitcoin wallet RPC
+
+// Magic prefix of signed bitcoin messages
+const MESSAGE_MAGIC = "Bitcoin Signed Message:\n"
+
+// error consts
+const (
+	ERROR_MESSAGE_SIGNATURE_ENCODING = "Invalid message signature encoding"
+	ERROR_MESSAGE_SIGNATURE_INVALID  = "Invalid message signature"
+	ERROR_MESSAGE_SIGNER_INVALID     = "Invalid message signer pubkey or address"
+)
+
+// Calculate double sha256 hash of a message with the signed message prefix
+func messageHash(message string) []byte {
+	var buf bytes.Buffer
+	wire.WriteVarString(&buf, 0, MESSAGE_MAGIC)
+	wire.WriteVarString(&buf, 0, message)
+	return chainhash.DoubleHashB(buf.Bytes())
+}
+
+// Sign a message with a private key and return the base64 encoded compact signature
+func SignMessage(walletPrivKey *btcutil.WIF, message string) (string, error) {
+	sig, errSign := btcec.SignCompact(btcec.S256(), walletPrivKey.PrivKey, messageHash(message), walletPrivKey.CompressPubKey)
+	if errSign != nil {
+		return "", errSign
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// Verify a base64 encoded compact message signature against a signer
+// The signer can be either a hex encoded pubkey or a pay to pub key hash address
+func VerifyMessage(signer string, message string, signature string) error {
+	sig, errDecode := base64.StdEncoding.DecodeString(signature)
+	if errDecode != nil {
+		return errors.New(ERROR_MESSAGE_SIGNATURE_ENCODING)
+	}
+	pubkey, compressed, errRecover := btcec.RecoverCompact(btcec.S256(), sig, messageHash(message))
+	if errRecover != nil {
+		return errors.New(ERROR_MESSAGE_SIGNATURE_INVALID)
+	}
+	var pubkeyBytes []byte
+	if compressed {
+		pubkeyBytes = pubkey.SerializeCompressed()
+	} else {
+		pubkeyBytes = pubkey.SerializeUncompressed()
+	}
+
+	// signer is a pubkey
+	if signerBytes, errHex := hex.DecodeString(signer); errHex == nil {
+		signerPubkey, errPub := btcec.ParsePubKey(signerBytes, btcec.S256())
+		if errPub != nil {
+			return errors.New(ERROR_MESSAGE_SIGNER_INVALID)
+		}
+		if !signerPubkey.IsEqual(pubkey) {
+			return errors.New(ERROR_MESSAGE_SIGNATURE_INVALID)
+		}
+		return nil
+	}
+
+	// signer is a pay to pub key hash address on any of the supported networks
+	for _, chainCfg := range []*chaincfg.Params{&chaincfg.MainNetParams, &chaincfg.TestNet3Params, &chaincfg.RegressionNetParams} {
+		signerAddr, errAddr := btcutil.DecodeAddress(signer, chainCfg)
+		if errAddr != nil {
+			continue
+		}
+		if _, ok := signerAddr.(*btcutil.AddressPubKeyHash); !ok {
+			continue
+		}
+		if !bytes.Equal(signerAddr.ScriptAddress(), btcutil.Hash160(pubkeyBytes)) {
+			return errors.New(ERROR_MESSAGE_SIGNATURE_INVALID)
+		}
+		return nil
+	}
+	return errors.New(ERROR_MESSAGE_SIGNER_INVALID)
+}
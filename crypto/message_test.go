@@ -0,0 +1,41 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test message signing and verification utility
+func TestMessage(t *testing.T) {
+	message := "1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	privKey, _ := btcutil.DecodeWIF("cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz")
+	pubkey := "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33"
+	addr, _ := GetAddressFromPrivKey(privKey, &chaincfg.RegressionNetParams)
+
+	sig, errSign := SignMessage(privKey, message)
+	assert.Equal(t, nil, errSign)
+
+	// verify with pubkey and address
+	assert.Equal(t, nil, VerifyMessage(pubkey, message, sig))
+	assert.Equal(t, nil, VerifyMessage(addr.String(), message, sig))
+
+	// verify different message
+	assert.Equal(t, errors.New(ERROR_MESSAGE_SIGNATURE_INVALID), VerifyMessage(pubkey, message[1:], sig))
+	assert.Equal(t, errors.New(ERROR_MESSAGE_SIGNATURE_INVALID), VerifyMessage(addr.String(), message[1:], sig))
+
+	// verify different signer
+	otherPubkey := "02f3a78a7bd6cf01c56312e7e828bef74134dfb109e59afd088526212d96518e75"
+	otherAddr := "mgYhSzKCdWzV6c7mBn9EzXkEADVBmPJHmi"
+	assert.Equal(t, errors.New(ERROR_MESSAGE_SIGNATURE_INVALID), VerifyMessage(otherPubkey, message, sig))
+	assert.Equal(t, errors.New(ERROR_MESSAGE_SIGNATURE_INVALID), VerifyMessage(otherAddr, message, sig))
+
+	// invalid signer and signature
+	assert.Equal(t, errors.New(ERROR_MESSAGE_SIGNER_INVALID), VerifyMessage("2MxBi6eodnuoVCw8McGrf1nuoVhastqoBXB", message, sig))
+	assert.Equal(t, errors.New(ERROR_MESSAGE_SIGNER_INVALID), VerifyMessage("03e52c", message, sig))
+	assert.Equal(t, errors.New(ERROR_MESSAGE_SIGNATURE_ENCODING), VerifyMessage(pubkey, message, "!!"))
+	assert.Equal(t, errors.New(ERROR_MESSAGE_SIGNATURE_INVALID), VerifyMessage(pubkey, message, "AAAA"))
+}
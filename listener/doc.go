@@ -0,0 +1,10 @@
+/*
+Package listener implements a client library for the attestation service zmq publisher.
+
+A listener subscribes to the confirmed attestation topic and the slot proof
+topics of the client positions provided, and decodes published messages into
+attestation messages with the full attestation info and proof bundles of the
+client slots. Proof bundles can be verified end to end with the staychain
+package without access to the attestation service.
+*/
+package listener
@@ -0,0 +1,119 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/messengers"
+	"mainstay/models"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// error consts
+const (
+	ERROR_LISTENER_TOPIC    = "Unknown listener message topic"
+	ERROR_LISTENER_POSITION = "Slot proof position does not match topic"
+)
+
+// subscribe to slot proofs of all client positions
+const ALL_POSITIONS = -1
+
+// Message structure
+// Decoded message of the attestation service publisher
+// Only Attestation is set for confirmed attestation messages
+// and only Proof is set for slot proof messages
+type Message struct {
+	Topic       string
+	Attestation *models.AttestationMessage
+	Proof       *models.ProofBundle
+}
+
+// Listener structure
+// Subscribes to the attestation service publisher for confirmed
+// attestations and slot proofs of client positions
+type Listener struct {
+	subscriber *messengers.SubscriberZmq
+	poller     *zmq.Poller
+}
+
+// NewListener returns a pointer to a Listener instance
+// Connect to the publisher address host:port and subscribe to confirmed
+// attestations and slot proofs of the positions provided
+func NewListener(address string, positions []int32) *Listener {
+	poller := zmq.NewPoller()
+	subscriber := messengers.NewSubscriberZmq(address, Topics(positions), poller)
+	return &Listener{subscriber, poller}
+}
+
+// Return topics for confirmed attestations and slot proofs of the positions provided
+func Topics(positions []int32) []string {
+	topics := []string{confpkg.TOPIC_CONFIRMED_ATTESTATION}
+	for _, position := range positions {
+		if position == ALL_POSITIONS {
+			return []string{confpkg.TOPIC_CONFIRMED_ATTESTATION, confpkg.TOPIC_SLOT_PROOF}
+		}
+		topics = append(topics, confpkg.SlotProofTopic(position))
+	}
+	return topics
+}
+
+// Read and decode next message waiting up to timeout, or indefinitely
+// for a negative timeout - nil message is returned on timeout
+func (l *Listener) Read(timeout time.Duration) (*Message, error) {
+	sockets, errPoll := l.poller.Poll(timeout)
+	if errPoll != nil {
+		return nil, errPoll
+	}
+	if len(sockets) == 0 {
+		return nil, nil
+	}
+	topic, msg := l.subscriber.ReadMessage()
+	return DecodeMessage(topic, msg)
+}
+
+// Close underlying zmq subscriber - To be used with defer
+func (l *Listener) Close() {
+	l.subscriber.Close()
+}
+
+// Decode message published with the topic provided
+func DecodeMessage(topic string, msg []byte) (*Message, error) {
+	if topic == confpkg.TOPIC_CONFIRMED_ATTESTATION {
+		attestation, errAttestation := models.DeserializeAttestationMessage(msg)
+		if errAttestation != nil {
+			return nil, errAttestation
+		}
+		return &Message{Topic: topic, Attestation: attestation}, nil
+	}
+
+	position, errPosition := slotProofPosition(topic)
+	if errPosition != nil {
+		return nil, errPosition
+	}
+	bundle, errBundle := models.ImportProofBundleBinary(msg)
+	if errBundle != nil {
+		return nil, errBundle
+	}
+	if bundle.Proof.ClientPosition != position {
+		return nil, errors.New(fmt.Sprintf("%s %d", ERROR_LISTENER_POSITION, bundle.Proof.ClientPosition))
+	}
+	return &Message{Topic: topic, Proof: bundle}, nil
+}
+
+// Parse client position from slot proof topic
+func slotProofPosition(topic string) (int32, error) {
+	if !strings.HasPrefix(topic, confpkg.TOPIC_SLOT_PROOF) || !strings.HasSuffix(topic, "/") {
+		return 0, errors.New(fmt.Sprintf("%s %s", ERROR_LISTENER_TOPIC, topic))
+	}
+	positionStr := strings.TrimSuffix(strings.TrimPrefix(topic, confpkg.TOPIC_SLOT_PROOF), "/")
+	position, errPosition := strconv.ParseInt(positionStr, 10, 32)
+	if errPosition != nil || position < 0 || confpkg.SlotProofTopic(int32(position)) != topic {
+		return 0, errors.New(fmt.Sprintf("%s %s", ERROR_LISTENER_TOPIC, topic))
+	}
+	return int32(position), nil
+}
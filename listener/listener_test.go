@@ -0,0 +1,85 @@
+package listener
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// Return attestation message and binary proof bundle of position 1
+// for a test attestation of three client commitments
+func buildTestMessages(t *testing.T) (models.AttestationMessage, []byte) {
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashZ, _ := chainhash.NewHashFromStr("ccccccc1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY, *hashZ})
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hashX, 0), []byte{0x00}, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0xa9, 0x14}))
+	block := wire.NewMsgBlock(wire.NewBlockHeader(1, &chainhash.Hash{}, &chainhash.Hash{}, 0x207fffff, 1))
+	block.AddTransaction(tx)
+
+	attestation := models.NewAttestation(tx.TxHash(), commitment)
+	attestation.Confirmed = true
+	attestation.Info = models.AttestationInfo{Txid: tx.TxHash().String(), Blockhash: block.BlockHash().String()}
+	assert.Equal(t, nil, attestation.UpdateSpvInfo(block))
+	spvProof, _ := attestation.Info.SpvProof()
+
+	_, pub := btcec.PrivKeyFromBytes(btcec.S256(), bytes.Repeat([]byte{1}, 32))
+	slotProof := models.SlotProof{Txid: tx.TxHash(), Blockhash: block.BlockHash(), Confirmed: true,
+		Proof: commitment.GetMerkleProofs()[1], Spv: spvProof}
+	bundle, errBundle := models.NewProofBundle(slotProof, *tx, nil, pub.SerializeCompressed())
+	assert.Equal(t, nil, errBundle)
+	bundleBytes, errExport := bundle.ExportBinary()
+	assert.Equal(t, nil, errExport)
+	return models.NewAttestationMessage(*attestation), bundleBytes
+}
+
+// Test listener topics of client positions
+func TestTopics(t *testing.T) {
+	assert.Equal(t, []string{confpkg.TOPIC_CONFIRMED_ATTESTATION}, Topics(nil))
+	assert.Equal(t, []string{confpkg.TOPIC_CONFIRMED_ATTESTATION, "P1/", "P10/"}, Topics([]int32{1, 10}))
+	assert.Equal(t, []string{confpkg.TOPIC_CONFIRMED_ATTESTATION, confpkg.TOPIC_SLOT_PROOF},
+		Topics([]int32{1, ALL_POSITIONS}))
+}
+
+// Test decoding of confirmed attestation and slot proof messages
+func TestDecodeMessage(t *testing.T) {
+	attestation, bundleBytes := buildTestMessages(t)
+
+	// confirmed attestation
+	attestationBytes, _ := attestation.Serialize()
+	message, errDecode := DecodeMessage(confpkg.TOPIC_CONFIRMED_ATTESTATION, attestationBytes)
+	assert.Equal(t, nil, errDecode)
+	assert.Equal(t, &Message{Topic: confpkg.TOPIC_CONFIRMED_ATTESTATION, Attestation: &attestation}, message)
+	_, errDecode = DecodeMessage(confpkg.TOPIC_CONFIRMED_ATTESTATION, bundleBytes)
+	assert.NotEqual(t, nil, errDecode)
+
+	// slot proof
+	bundle, _ := models.ImportProofBundleBinary(bundleBytes)
+	message, errDecode = DecodeMessage(confpkg.SlotProofTopic(1), bundleBytes)
+	assert.Equal(t, nil, errDecode)
+	assert.Equal(t, &Message{Topic: "P1/", Proof: bundle}, message)
+	assert.Equal(t, attestation.Txid, message.Proof.Txid.String())
+	assert.Equal(t, attestation.MerkleRoot, message.Proof.Proof.MerkleRoot.String())
+
+	// slot proof of another position
+	_, errDecode = DecodeMessage(confpkg.SlotProofTopic(2), bundleBytes)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_LISTENER_POSITION, 1)), errDecode)
+
+	// invalid topics
+	for _, topic := range []string{"", confpkg.TOPIC_CONFIRMED_HASH, "P1", "P/", "P-1/", "P01/", "Px/"} {
+		_, errDecode = DecodeMessage(topic, bundleBytes)
+		assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_LISTENER_TOPIC, topic)), errDecode)
+	}
+}
@@ -11,8 +11,10 @@ import (
 
 	"mainstay/attestation"
 	"mainstay/config"
+	"mainstay/requestapi"
 	"mainstay/server"
 	"mainstay/test"
+	"mainstay/webhook"
 )
 
 var (
@@ -57,9 +59,13 @@ func main() {
 	wg := &sync.WaitGroup{}
 	ctx, cancel := context.WithCancel(context.Background())
 
-	dbInterface := server.NewDbMongo(ctx, mainConfig.DbConnectivity())
-	server := server.NewServer(dbInterface)
-	attestService := attestation.NewAttestService(ctx, wg, server, mainConfig, isRegtest)
+	dbInterface := server.NewDb(ctx, mainConfig.DbConnectivity())
+	mainServer := server.NewServer(ctx, dbInterface)
+	if mainConfig.ProofArchive() != "" { // serve archived proofs if archive set, before any service starts
+		mainServer.SetProofArchive(server.NewProofArchive(mainConfig.ProofArchive()))
+	}
+	attestService := attestation.NewAttestService(ctx, wg, mainServer, mainConfig, isRegtest)
+	webhookService := webhook.NewWebhookService(ctx, wg, mainServer)
 
 	c := make(chan os.Signal)
 	signal.Notify(c, os.Interrupt)
@@ -79,6 +85,21 @@ func main() {
 	wg.Add(1)
 	go attestService.Run()
 
+	wg.Add(1)
+	go webhookService.Run()
+
+	if mainConfig.ApiHost() != "" { // serve client requests if api host set
+		requestService := requestapi.NewRequestService(ctx, wg, mainServer, mainConfig.ApiHost())
+		wg.Add(1)
+		go requestService.Run()
+	}
+
+	if mainConfig.ProofArchive() != "" && mainConfig.ProofRetention() > 0 { // prune old rounds if archive set
+		retentionService := server.NewRetentionService(ctx, wg, mainServer, mainConfig.ProofRetention())
+		wg.Add(1)
+		go retentionService.Run()
+	}
+
 	if isRegtest { // In regtest demo mode do block generation work
 		wg.Add(1)
 		go test.DoRegtestWork(mainConfig, wg, ctx)
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"bytes"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/btcsuite/btcd/btcjson"
@@ -13,28 +16,45 @@ import (
 // error consts
 const (
 	ERROR_COMMITMENT_NOT_DEFINED = "Commitment not defined"
+	ERROR_COMMITMENT_MERKLE_ROOT = "Commitment does not match attestation merkle root"
 )
 
 // Attestation structure
 // Holds information on the attestation transaction generated
 // and the information on the sidechain hash attested
 // Attestation is unconfirmed until included in a mainchain block
+// Fee is the fee paid by the attestation transaction in satoshis
+// The commitment is only set for attestations created by the attestation
+// service. Attestations loaded from the db hold the merkle root and tree
+// version of their commitment, which is rebuilt from the db merkle commitments
 type Attestation struct {
-	Txid       chainhash.Hash
-	Tx         wire.MsgTx
-	Confirmed  bool
-	Info       AttestationInfo
-	commitment *Commitment
+	Txid        chainhash.Hash
+	Tx          wire.MsgTx
+	Fee         int64
+	Confirmed   bool
+	Info        AttestationInfo
+	commitment  *Commitment
+	merkleRoot  chainhash.Hash
+	treeVersion int32
 }
 
 // Attestation constructor for defaulting some values
 func NewAttestation(txid chainhash.Hash, commitment *Commitment) *Attestation {
-	return &Attestation{txid, wire.MsgTx{}, false, AttestationInfo{}, commitment}
+	attestation := NewAttestationDefault()
+	attestation.Txid = txid
+	attestation.SetCommitment(commitment)
+	return attestation
 }
 
 // Attestation constructor for defaulting all values
 func NewAttestationDefault() *Attestation {
-	return &Attestation{chainhash.Hash{}, wire.MsgTx{}, false, AttestationInfo{}, (*Commitment)(nil)}
+	return &Attestation{chainhash.Hash{}, wire.MsgTx{}, 0, false, AttestationInfo{}, (*Commitment)(nil), chainhash.Hash{}, 0}
+}
+
+// Attestation constructor for attestations without their commitment
+// with the merkle root and tree version of the commitment
+func NewAttestationMerkleRoot(txid chainhash.Hash, merkleRoot chainhash.Hash, treeVersion int32) *Attestation {
+	return &Attestation{txid, wire.MsgTx{}, 0, false, AttestationInfo{}, (*Commitment)(nil), merkleRoot, treeVersion}
 }
 
 // Update info with details from wallet transaction
@@ -51,9 +71,25 @@ func (a *Attestation) UpdateInfo(tx *btcjson.GetTransactionResult) {
 	}
 }
 
-// Set commitment
+// Update info with SPV proof of the transaction in the block provided
+func (a *Attestation) UpdateSpvInfo(block *wire.MsgBlock) error {
+	spvProof, errSpv := NewSpvProof(block, a.Txid)
+	if errSpv != nil {
+		return errSpv
+	}
+	a.Info.Header = spvProof.HeaderHex()
+	a.Info.TxIndex = spvProof.TxIndex
+	a.Info.MerkleBranch = spvProof.MerkleBranchStrings()
+	return nil
+}
+
+// Set commitment along with its merkle root and tree version
 func (a *Attestation) SetCommitment(commitment *Commitment) {
 	a.commitment = commitment
+	if commitment != (*Commitment)(nil) {
+		a.merkleRoot = commitment.GetCommitmentHash()
+		a.treeVersion = commitment.GetTreeVersion()
+	}
 }
 
 // Get commitment
@@ -64,21 +100,43 @@ func (a Attestation) Commitment() (*Commitment, error) {
 	return a.commitment, nil
 }
 
+// Return attestation without its commitment as stored in the db
+func (a Attestation) WithoutCommitment() Attestation {
+	a.commitment = (*Commitment)(nil)
+	return a
+}
+
+// Get commitment merkle tree layout version
+// Zero if no commitment has been set
+func (a Attestation) TreeVersion() int32 {
+	return a.treeVersion
+}
+
 // Get commitment hash
 func (a Attestation) CommitmentHash() chainhash.Hash {
-	if a.commitment == (*Commitment)(nil) {
-		return chainhash.Hash{}
-	}
-	return a.commitment.GetCommitmentHash()
+	return a.merkleRoot
 }
 
 // Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
 func (a Attestation) MarshalBSON() ([]byte, error) {
-	attestationBSON := AttestationBSON{a.Txid.String(), a.CommitmentHash().String(), a.Confirmed, time.Now()}
+	// raw tx hex is empty if no tx has been set yet
+	var txHex string
+	if len(a.Tx.TxIn) > 0 || len(a.Tx.TxOut) > 0 {
+		var txBuf bytes.Buffer
+		if errTx := a.Tx.SerializeNoWitness(&txBuf); errTx != nil {
+			return nil, errTx
+		}
+		txHex = hex.EncodeToString(txBuf.Bytes())
+	}
+
+	attestationBSON := AttestationBSON{a.Txid.String(), a.CommitmentHash().String(), a.Confirmed, time.Now(),
+		txHex, a.Fee, a.TreeVersion()}
 	return bson.Marshal(attestationBSON)
 }
 
 // Implement bson.Unmarshaler UnmarshalJSON() method for use with db_mongo interface
+// Info is stored separately and is not set by this method
+// The commitment is stored as merkle commitments and is not set by this method
 func (a *Attestation) UnmarshalBSON(b []byte) error {
 	var attestationBSON AttestationBSON
 	if err := bson.Unmarshal(b, &attestationBSON); err != nil {
@@ -88,27 +146,63 @@ func (a *Attestation) UnmarshalBSON(b []byte) error {
 	if errHash != nil {
 		return errHash
 	}
+
+	var tx wire.MsgTx
+	if attestationBSON.Tx != "" {
+		txBytes, errHex := hex.DecodeString(attestationBSON.Tx)
+		if errHex != nil {
+			return errHex
+		}
+		if errTx := tx.DeserializeNoWitness(bytes.NewReader(txBytes)); errTx != nil {
+			return errTx
+		}
+	}
+
+	merkleRoot, errRoot := chainhash.NewHashFromStr(attestationBSON.MerkleRoot)
+	if errRoot != nil {
+		return errRoot
+	}
+
+	// attestations stored before tree versions used the legacy layout
+	treeVersion := attestationBSON.TreeVersion
+	if treeVersion == 0 && *merkleRoot != (chainhash.Hash{}) {
+		treeVersion = COMMITMENT_TREE_VERSION_LEGACY
+	} else if treeVersion != 0 && !isCommitmentTreeVersion(treeVersion) {
+		return errors.New(fmt.Sprintf("%s %d", ERROR_COMMITMENT_TREE_VERSION, treeVersion))
+	}
+
 	a.Txid = *txidHash
+	a.Tx = tx
+	a.Fee = attestationBSON.Fee
 	a.Confirmed = attestationBSON.Confirmed
-	// THIS IS INCOMPLETE
-	// in order to get a full Attestation model
-	// we still need to Umarshal the commitment
-	// model and set through SetCommitment()
+	a.commitment = (*Commitment)(nil)
+	a.merkleRoot = *merkleRoot
+	a.treeVersion = treeVersion
 	return nil
 }
 
 // Attestation field names
 const (
-	ATTESTATION_TXID_NAME        = "txid"
-	ATTESTATION_MERKLE_ROOT_NAME = "merkle_root"
-	ATTESTATION_CONFIRMED_NAME   = "confirmed"
-	ATTESTATION_INSERTED_AT_NAME = "inserted_at"
+	ATTESTATION_TXID_NAME         = "txid"
+	ATTESTATION_MERKLE_ROOT_NAME  = "merkle_root"
+	ATTESTATION_CONFIRMED_NAME    = "confirmed"
+	ATTESTATION_INSERTED_AT_NAME  = "inserted_at"
+	ATTESTATION_TX_NAME           = "tx"
+	ATTESTATION_FEE_NAME          = "fee"
+	ATTESTATION_TREE_VERSION_NAME = "tree_version"
+
+	// commitments of attestations stored before these were only
+	// stored as merkle commitments, removed by db migrations
+	ATTESTATION_COMMITMENTS_NAME = "commitments"
 )
 
 // AttestationBSON structure for mongoDb
 type AttestationBSON struct {
-	Txid       string    `bson:"txid"`
-	MerkleRoot string    `bson:"merkle_root"`
-	Confirmed  bool      `bson:"confirmed"`
-	InsertedAt time.Time `bson:"inserted_at"`
+	Txid        string    `bson:"txid"`
+	MerkleRoot  string    `bson:"merkle_root"`
+	Confirmed   bool      `bson:"confirmed"`
+	InsertedAt  time.Time `bson:"inserted_at"`
+	Tx          string    `bson:"tx"`
+	Fee         int64     `bson:"fee"`
+	TreeVersion int32     `bson:"tree_version"`
 }
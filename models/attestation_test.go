@@ -2,10 +2,13 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/mongodb/mongo-go-driver/bson"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,13 +22,14 @@ func TestAttestation(t *testing.T) {
 
 	commitmentHash := attestationDefault.CommitmentHash()
 	assert.Equal(t, chainhash.Hash{}, commitmentHash)
+	assert.Equal(t, int32(0), attestationDefault.TreeVersion())
 
 	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 	root, _ := chainhash.NewHashFromStr("bb088c106b3379b64243c1a4915f72a847d45c7513b152cad583eb3c0a1063c2")
 	commitments := []chainhash.Hash{*hash0, *hash1, *hash2}
-	commitment, errCommitment := NewCommitment(commitments)
+	commitment, errCommitment := NewCommitmentVersion(commitments, COMMITMENT_TREE_VERSION_LEGACY)
 	assert.Equal(t, nil, errCommitment)
 
 	// set commitment to default attestation
@@ -46,6 +50,14 @@ func TestAttestation(t *testing.T) {
 
 	commitmentHash3 := attestation.CommitmentHash()
 	assert.Equal(t, *root, commitmentHash3)
+	assert.Equal(t, COMMITMENT_TREE_VERSION_LEGACY, attestation.TreeVersion())
+
+	// test attestation without commitment
+	attestationRoot := NewAttestationMerkleRoot(*txid, *root, COMMITMENT_TREE_VERSION_LEGACY)
+	_, errCommitment = attestationRoot.Commitment()
+	assert.Equal(t, errors.New(ERROR_COMMITMENT_NOT_DEFINED), errCommitment)
+	assert.Equal(t, *root, attestationRoot.CommitmentHash())
+	assert.Equal(t, COMMITMENT_TREE_VERSION_LEGACY, attestationRoot.TreeVersion())
 
 	// test attestation info
 	txRes := btcjson.GetTransactionResult{
@@ -68,7 +80,7 @@ func TestAttestationBSON(t *testing.T) {
 	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 	root, _ := chainhash.NewHashFromStr("bb088c106b3379b64243c1a4915f72a847d45c7513b152cad583eb3c0a1063c2")
 	commitments := []chainhash.Hash{*hash0, *hash1, *hash2}
-	commitment, _ := NewCommitment(commitments)
+	commitment, _ := NewCommitmentVersion(commitments, COMMITMENT_TREE_VERSION_LEGACY)
 
 	txid, _ := chainhash.NewHashFromStr("4444e34e881d9a1e6cdc3418b54bb57747106bc75e9e84426661f27f98ada3b7")
 	attestation := NewAttestation(*txid, commitment)
@@ -77,24 +89,42 @@ func TestAttestationBSON(t *testing.T) {
 	commitmentHash := attestation.CommitmentHash()
 	assert.Equal(t, *root, commitmentHash)
 
+	// set signed tx and fee
+	attestation.Tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash0, 0), []byte{0x00, 0x47}, nil))
+	attestation.Tx.AddTxOut(wire.NewTxOut(99000, []byte{0xa9, 0x14}))
+	attestation.Fee = int64(1000)
+
 	// test marshal attestation model
 	bytes, errBytes := attestation.MarshalBSON()
 	// can't test bytes exactly as there is a time component
 	// we do test the reverse though below
-	assert.Equal(t, 195, len(bytes))
+	assert.Equal(t, 363, len(bytes))
 	assert.Equal(t, nil, errBytes)
 
 	// test unmarshal attestaion model and verify reverse works
+	// the commitment is not stored with the attestation
+	expected := NewAttestationMerkleRoot(*txid, *root, COMMITMENT_TREE_VERSION_LEGACY)
+	expected.Tx = attestation.Tx
+	expected.Fee = attestation.Fee
 	testAttestation := &Attestation{}
-	testAttestation.UnmarshalBSON(bytes)
+	assert.Equal(t, nil, testAttestation.UnmarshalBSON(bytes))
 	assert.Equal(t, attestation.Txid, testAttestation.Txid)
 	assert.Equal(t, attestation.Confirmed, testAttestation.Confirmed)
+	assert.Equal(t, attestation.Tx, testAttestation.Tx)
+	assert.Equal(t, attestation.Fee, testAttestation.Fee)
+	assert.Equal(t, *root, testAttestation.CommitmentHash())
+	assert.Equal(t, expected, testAttestation)
+	_, errCommitment := testAttestation.Commitment()
+	assert.Equal(t, errors.New(ERROR_COMMITMENT_NOT_DEFINED), errCommitment)
 
 	// test attestation model to document
 	doc, docErr := GetDocumentFromModel(testAttestation)
 	assert.Equal(t, nil, docErr)
 	assert.Equal(t, attestation.Txid.String(), doc.Lookup(ATTESTATION_TXID_NAME).StringValue())
 	assert.Equal(t, attestation.Confirmed, doc.Lookup(ATTESTATION_CONFIRMED_NAME).Boolean())
+	assert.Equal(t, root.String(), doc.Lookup(ATTESTATION_MERKLE_ROOT_NAME).StringValue())
+	_, errLookup := doc.LookupErr(ATTESTATION_COMMITMENTS_NAME)
+	assert.NotEqual(t, nil, errLookup)
 
 	// test reverse document to attestation model
 	testtestCommitment := &Attestation{}
@@ -102,4 +132,43 @@ func TestAttestationBSON(t *testing.T) {
 	assert.Equal(t, nil, docErr)
 	assert.Equal(t, attestation.Txid, testtestCommitment.Txid)
 	assert.Equal(t, attestation.Confirmed, testtestCommitment.Confirmed)
+	assert.Equal(t, expected, testtestCommitment)
+
+	// test attestation with no tx or commitment set
+	defaultAttestation := NewAttestationDefault()
+	bytes, errBytes = defaultAttestation.MarshalBSON()
+	assert.Equal(t, nil, errBytes)
+	testAttestation = &Attestation{}
+	assert.Equal(t, nil, testAttestation.UnmarshalBSON(bytes))
+	assert.Equal(t, defaultAttestation, testAttestation)
+
+	// test tree version is recorded and attestations without it use the legacy layout
+	assert.Equal(t, COMMITMENT_TREE_VERSION_LEGACY, doc.Lookup(ATTESTATION_TREE_VERSION_NAME).Int32())
+	attestationBSON := AttestationBSON{Txid: txid.String(), MerkleRoot: root.String()}
+	bytes, _ = bson.Marshal(attestationBSON)
+	assert.Equal(t, nil, testAttestation.UnmarshalBSON(bytes))
+	assert.Equal(t, COMMITMENT_TREE_VERSION_LEGACY, testAttestation.TreeVersion())
+	assert.Equal(t, *root, testAttestation.CommitmentHash())
+
+	zeroPadCommitment, _ := NewCommitment(commitments)
+	attestation = NewAttestation(*txid, zeroPadCommitment)
+	bytes, _ = attestation.MarshalBSON()
+	testAttestation = &Attestation{}
+	assert.Equal(t, nil, testAttestation.UnmarshalBSON(bytes))
+	assert.Equal(t, COMMITMENT_TREE_VERSION_ZERO_PAD, testAttestation.TreeVersion())
+	assert.Equal(t, zeroPadCommitment.GetCommitmentHash(), testAttestation.CommitmentHash())
+
+	attestationBSON = AttestationBSON{Txid: txid.String(), MerkleRoot: root.String(), TreeVersion: 3}
+	bytes, _ = bson.Marshal(attestationBSON)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_COMMITMENT_TREE_VERSION, 3)), testAttestation.UnmarshalBSON(bytes))
+
+	// test attestations stored with their commitments decode without them
+	legacyBSON := struct {
+		AttestationBSON `bson:",inline"`
+		Commitments     []string `bson:"commitments"`
+	}{AttestationBSON{Txid: txid.String(), MerkleRoot: root.String()}, []string{hash0.String(), hash1.String(), hash2.String()}}
+	bytes, _ = bson.Marshal(legacyBSON)
+	testAttestation = &Attestation{}
+	assert.Equal(t, nil, testAttestation.UnmarshalBSON(bytes))
+	assert.Equal(t, *root, testAttestation.CommitmentHash())
 }
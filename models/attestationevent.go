@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// attestation event types
+const (
+	EVENT_ROUND_STARTED         = "round"
+	EVENT_ATTESTATION_BROADCAST = "broadcast"
+	EVENT_ATTESTATION_CONFIRMED = "confirmed"
+	EVENT_ATTESTATION_REPLACED  = "replaced"
+)
+
+// AttestationEvent structure
+// Lifecycle event of an attestation published by the attestation service
+// Id is assigned by the event feed and is used by clients as a resume cursor
+// Txid is set for all but round events, Blockhash for confirmed events
+// and ReplacementTxid for replaced events with the txid of the replacement
+type AttestationEvent struct {
+	Id              int64
+	Type            string
+	Time            time.Time
+	MerkleRoot      chainhash.Hash
+	Commitments     []chainhash.Hash
+	Txid            chainhash.Hash
+	Blockhash       chainhash.Hash
+	ReplacementTxid chainhash.Hash
+}
+
+// Return new attestation event of type for the commitment provided
+func NewAttestationEvent(eventType string, commitment Commitment, eventTime time.Time) AttestationEvent {
+	event := AttestationEvent{Type: eventType, Time: eventTime, MerkleRoot: commitment.GetCommitmentHash()}
+	for _, merkleCommitment := range commitment.GetMerkleCommitments() {
+		event.Commitments = append(event.Commitments, merkleCommitment.Commitment)
+	}
+	return event
+}
+
+// Check if the event includes a commitment for the client position
+// Empty slots with a zero commitment are not included
+func (e AttestationEvent) HasPosition(position int32) bool {
+	return position >= 0 && int(position) < len(e.Commitments) && e.Commitments[position] != (chainhash.Hash{})
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test AttestationEvent commitments and position filtering
+func TestAttestationEvent(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := NewCommitment([]chainhash.Hash{*hash0, chainhash.Hash{}, *hash2})
+
+	event := NewAttestationEvent(EVENT_ROUND_STARTED, *commitment, time.Unix(1542121293, 0))
+	assert.Equal(t, EVENT_ROUND_STARTED, event.Type)
+	assert.Equal(t, commitment.GetCommitmentHash(), event.MerkleRoot)
+	assert.Equal(t, []chainhash.Hash{*hash0, chainhash.Hash{}, *hash2}, event.Commitments)
+	assert.Equal(t, int64(0), event.Id)
+
+	// empty slots and positions outside the commitment are not included
+	assert.Equal(t, true, event.HasPosition(0))
+	assert.Equal(t, false, event.HasPosition(1))
+	assert.Equal(t, true, event.HasPosition(2))
+	assert.Equal(t, false, event.HasPosition(3))
+	assert.Equal(t, false, event.HasPosition(-1))
+}
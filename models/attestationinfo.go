@@ -1,17 +1,37 @@
 package models
 
 // struct for db AttestationInfo
+// Header, TxIndex and MerkleBranch store the Bitcoin SPV proof
+// of the attestation transaction in the block with Blockhash
+// Height is the main chain height of the block with Blockhash
 type AttestationInfo struct {
-	Txid      string `bson:"txid"`
-	Blockhash string `bson:"blockhash"`
-	Amount    int64  `bson:"amount"`
-	Time      int64  `bson:"time"`
+	Txid         string   `bson:"txid"`
+	Blockhash    string   `bson:"blockhash"`
+	Height       int64    `bson:"height"`
+	Amount       int64    `bson:"amount"`
+	Time         int64    `bson:"time"`
+	Header       string   `bson:"header"`
+	TxIndex      int32    `bson:"tx_index"`
+	MerkleBranch []string `bson:"merkle_branch"`
+}
+
+// Get SPV proof of attestation transaction from info
+// Returns nil if no SPV proof has been stored
+func (i AttestationInfo) SpvProof() (*SpvProof, error) {
+	if i.Header == "" {
+		return nil, nil
+	}
+	return NewSpvProofFromStrings(i.Header, i.TxIndex, i.MerkleBranch)
 }
 
 // AttestationInfo field names
 const (
-	ATTESTATION_INFO_TXID_NAME      = "txid"
-	ATTESTATION_INFO_BLOCKHASH_NAME = "blockhash"
-	ATTESTATION_INFO_AMOUNT_NAME    = "amount"
-	ATTESTATION_INFO_TIME_NAME      = "time"
+	ATTESTATION_INFO_TXID_NAME          = "txid"
+	ATTESTATION_INFO_BLOCKHASH_NAME     = "blockhash"
+	ATTESTATION_INFO_HEIGHT_NAME        = "height"
+	ATTESTATION_INFO_AMOUNT_NAME        = "amount"
+	ATTESTATION_INFO_TIME_NAME          = "time"
+	ATTESTATION_INFO_HEADER_NAME        = "header"
+	ATTESTATION_INFO_TX_INDEX_NAME      = "tx_index"
+	ATTESTATION_INFO_MERKLE_BRANCH_NAME = "merkle_branch"
 )
@@ -1,8 +1,11 @@
 package models
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/mongodb/mongo-go-driver/bson"
 	"github.com/stretchr/testify/assert"
 )
@@ -58,3 +61,39 @@ func AttestationInfoBSON(t *testing.T) {
 	assert.Equal(t, info.Amount, testtestInfo.Amount)
 	assert.Equal(t, info.Time, testtestInfo.Time)
 }
+
+// Test AttestationInfo SPV proof
+func TestAttestationInfoSpvProof(t *testing.T) {
+	// no spv proof stored
+	info := AttestationInfo{Txid: "f123434e881d9a1e6cdc3418b54bb57747106bc75e9e84426661f27f98ada3b7"}
+	spvProof, errSpv := info.SpvProof()
+	assert.Equal(t, nil, errSpv)
+	assert.Equal(t, (*SpvProof)(nil), spvProof)
+
+	// spv proof round trip through info strings
+	block := wire.NewMsgBlock(wire.NewBlockHeader(1, &chainhash.Hash{}, &chainhash.Hash{}, 0x207fffff, 1))
+	for i := 0; i < 3; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxOut(wire.NewTxOut(int64(i), []byte{}))
+		block.AddTransaction(tx)
+	}
+	txid := block.Transactions[2].TxHash()
+	attestation := NewAttestation(txid, nil)
+	assert.Equal(t, nil, attestation.UpdateSpvInfo(block))
+	assert.Equal(t, int32(2), attestation.Info.TxIndex)
+	assert.Equal(t, 2, len(attestation.Info.MerkleBranch))
+
+	spvProof, errSpv = attestation.Info.SpvProof()
+	assert.Equal(t, nil, errSpv)
+	assert.Equal(t, block.Header, spvProof.Header)
+	assert.Equal(t, int32(2), spvProof.TxIndex)
+
+	// invalid header and branch
+	attestation.Info.Header = attestation.Info.Header[2:]
+	_, errSpv = attestation.Info.SpvProof()
+	assert.Equal(t, errors.New(ERROR_SPV_HEADER), errSpv)
+	attestation.Info.Header = spvProof.HeaderHex()
+	attestation.Info.MerkleBranch[0] = "zz"
+	_, errSpv = attestation.Info.SpvProof()
+	assert.Equal(t, errors.New(ERROR_SPV_BRANCH), errSpv)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// error consts
+const (
+	ERROR_ATTESTATION_MESSAGE_ENCODING = "Invalid attestation message encoding"
+	ERROR_ATTESTATION_MESSAGE_FIELD    = "Invalid attestation message field"
+)
+
+// AttestationMessage structure
+// Confirmed attestation with its full info as published to subscribers
+// Header, TxIndex and MerkleBranch are the SPV proof of the attestation
+// transaction in the block with Blockhash
+type AttestationMessage struct {
+	Txid         string   `json:"txid"`
+	MerkleRoot   string   `json:"merkle_root"`
+	TreeVersion  int32    `json:"tree_version"`
+	Fee          int64    `json:"fee"`
+	Blockhash    string   `json:"blockhash"`
+	Height       int64    `json:"height"`
+	Amount       int64    `json:"amount"`
+	Time         int64    `json:"time"`
+	Header       string   `json:"header"`
+	TxIndex      int32    `json:"tx_index"`
+	MerkleBranch []string `json:"merkle_branch"`
+}
+
+// AttestationMessage constructor from a confirmed attestation
+func NewAttestationMessage(attestation Attestation) AttestationMessage {
+	return AttestationMessage{
+		Txid:         attestation.Txid.String(),
+		MerkleRoot:   attestation.CommitmentHash().String(),
+		TreeVersion:  attestation.TreeVersion(),
+		Fee:          attestation.Fee,
+		Blockhash:    attestation.Info.Blockhash,
+		Height:       attestation.Info.Height,
+		Amount:       attestation.Info.Amount,
+		Time:         attestation.Info.Time,
+		Header:       attestation.Info.Header,
+		TxIndex:      attestation.Info.TxIndex,
+		MerkleBranch: attestation.Info.MerkleBranch}
+}
+
+// Serialize attestation message to json format
+func (m AttestationMessage) Serialize() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Get SPV proof of attestation transaction from message
+// Returns nil if the message has no SPV proof
+func (m AttestationMessage) SpvProof() (*SpvProof, error) {
+	if m.Header == "" {
+		return nil, nil
+	}
+	return NewSpvProofFromStrings(m.Header, m.TxIndex, m.MerkleBranch)
+}
+
+// Deserialize attestation message from json format
+// Txid, merkle root and blockhash are required to be valid hashes
+func DeserializeAttestationMessage(data []byte) (*AttestationMessage, error) {
+	var message AttestationMessage
+	if errJson := json.Unmarshal(data, &message); errJson != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_MESSAGE_ENCODING, errJson))
+	}
+	hashes := []struct {
+		name  string
+		value string
+	}{{"txid", message.Txid}, {"merkle_root", message.MerkleRoot}, {"blockhash", message.Blockhash}}
+	for _, hash := range hashes {
+		if _, errHash := chainhash.NewHashFromStr(hash.value); errHash != nil || len(hash.value) != chainhash.MaxHashStringSize {
+			return nil, errors.New(fmt.Sprintf("%s %s", ERROR_ATTESTATION_MESSAGE_FIELD, hash.name))
+		}
+	}
+	if _, errSpv := message.SpvProof(); errSpv != nil {
+		return nil, errors.New(fmt.Sprintf("%s %s", ERROR_ATTESTATION_MESSAGE_FIELD, "header"))
+	}
+	return &message, nil
+}
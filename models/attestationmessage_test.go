@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test AttestationMessage serialization of a confirmed attestation
+func TestAttestationMessage(t *testing.T) {
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := NewCommitment([]chainhash.Hash{*hashX, *hashY})
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hashX, 0), []byte{0x00}, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0xa9, 0x14}))
+	block := wire.NewMsgBlock(wire.NewBlockHeader(1, &chainhash.Hash{}, &chainhash.Hash{}, 0x207fffff, 1))
+	block.AddTransaction(tx)
+
+	attestation := NewAttestation(tx.TxHash(), commitment)
+	attestation.Confirmed = true
+	attestation.Fee = 200
+	attestation.Info = AttestationInfo{
+		Txid:      tx.TxHash().String(),
+		Blockhash: block.BlockHash().String(),
+		Height:    101,
+		Amount:    1000,
+		Time:      1542121293}
+	assert.Equal(t, nil, attestation.UpdateSpvInfo(block))
+
+	message := NewAttestationMessage(*attestation)
+	assert.Equal(t, tx.TxHash().String(), message.Txid)
+	assert.Equal(t, commitment.GetCommitmentHash().String(), message.MerkleRoot)
+	assert.Equal(t, int32(COMMITMENT_TREE_VERSION), message.TreeVersion)
+	assert.Equal(t, int64(200), message.Fee)
+	assert.Equal(t, int64(101), message.Height)
+	spvProof, errSpv := message.SpvProof()
+	assert.Equal(t, nil, errSpv)
+	assert.Equal(t, block.BlockHash(), spvProof.Header.BlockHash())
+
+	// serialize and deserialize
+	data, errSerialize := message.Serialize()
+	assert.Equal(t, nil, errSerialize)
+	deserialized, errDeserialize := DeserializeAttestationMessage(data)
+	assert.Equal(t, nil, errDeserialize)
+	assert.Equal(t, message, *deserialized)
+
+	// invalid encoding and fields
+	_, errDeserialize = DeserializeAttestationMessage([]byte("{"))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_ATTESTATION_MESSAGE_ENCODING,
+		"unexpected end of JSON input")), errDeserialize)
+	invalid := message
+	invalid.MerkleRoot = "aaaa"
+	data, _ = invalid.Serialize()
+	_, errDeserialize = DeserializeAttestationMessage(data)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_ATTESTATION_MESSAGE_FIELD, "merkle_root")), errDeserialize)
+	invalid = message
+	invalid.Header = "00"
+	data, _ = invalid.Serialize()
+	_, errDeserialize = DeserializeAttestationMessage(data)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_ATTESTATION_MESSAGE_FIELD, "header")), errDeserialize)
+}
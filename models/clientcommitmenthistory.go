@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/mongodb/mongo-go-driver/bson"
+)
+
+// struct for db ClientCommitmentHistory
+// Append-only record of a commitment submitted for a client position
+// Submitter is the client details pubkey or address that the signature
+// was verified against. Txid and MerkleRoot are set once the commitment
+// is included in a confirmed attestation and are zero otherwise
+// Sequence is set by the db when the entry is saved and increases with
+// each entry saved, so that entries are ordered strictly
+type ClientCommitmentHistory struct {
+	Sequence       int64
+	Commitment     chainhash.Hash
+	ClientPosition int32
+	SubmittedAt    time.Time
+	Submitter      string
+	Signature      string
+	Txid           chainhash.Hash
+	MerkleRoot     chainhash.Hash
+}
+
+// Return whether the commitment has been included in a confirmed attestation
+func (h ClientCommitmentHistory) Attested() bool {
+	return h.Txid != chainhash.Hash{}
+}
+
+// Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
+// Txid and MerkleRoot are stored as empty strings until attested
+func (h ClientCommitmentHistory) MarshalBSON() ([]byte, error) {
+	historyBSON := ClientCommitmentHistoryBSON{
+		Sequence:       h.Sequence,
+		Commitment:     h.Commitment.String(),
+		ClientPosition: h.ClientPosition,
+		SubmittedAt:    h.SubmittedAt,
+		Submitter:      h.Submitter,
+		Signature:      h.Signature}
+	if h.Attested() {
+		historyBSON.Txid = h.Txid.String()
+		historyBSON.MerkleRoot = h.MerkleRoot.String()
+	}
+	return bson.Marshal(historyBSON)
+}
+
+// Implement bson.Unmarshaler UnmarshalJSON() method for use with db_mongo interface
+func (h *ClientCommitmentHistory) UnmarshalBSON(b []byte) error {
+	var historyBSON ClientCommitmentHistoryBSON
+	if err := bson.Unmarshal(b, &historyBSON); err != nil {
+		return err
+	}
+	commitmentHash, errHash := chainhash.NewHashFromStr(historyBSON.Commitment)
+	if errHash != nil {
+		return errHash
+	}
+	h.Sequence = historyBSON.Sequence
+	h.Commitment = *commitmentHash
+	h.ClientPosition = historyBSON.ClientPosition
+	h.SubmittedAt = historyBSON.SubmittedAt.UTC()
+	h.Submitter = historyBSON.Submitter
+	h.Signature = historyBSON.Signature
+	h.Txid = chainhash.Hash{}
+	h.MerkleRoot = chainhash.Hash{}
+	if historyBSON.Txid != "" {
+		txidHash, errTxid := chainhash.NewHashFromStr(historyBSON.Txid)
+		if errTxid != nil {
+			return errTxid
+		}
+		rootHash, errRoot := chainhash.NewHashFromStr(historyBSON.MerkleRoot)
+		if errRoot != nil {
+			return errRoot
+		}
+		h.Txid = *txidHash
+		h.MerkleRoot = *rootHash
+	}
+	return nil
+}
+
+// ClientCommitmentHistory field names
+const (
+	CLIENT_COMMITMENT_HISTORY_SEQUENCE_NAME        = "sequence"
+	CLIENT_COMMITMENT_HISTORY_COMMITMENT_NAME      = "commitment"
+	CLIENT_COMMITMENT_HISTORY_CLIENT_POSITION_NAME = "client_position"
+	CLIENT_COMMITMENT_HISTORY_SUBMITTED_AT_NAME    = "submitted_at"
+	CLIENT_COMMITMENT_HISTORY_SUBMITTER_NAME       = "submitter"
+	CLIENT_COMMITMENT_HISTORY_SIGNATURE_NAME       = "signature"
+	CLIENT_COMMITMENT_HISTORY_TXID_NAME            = "txid"
+	CLIENT_COMMITMENT_HISTORY_MERKLE_ROOT_NAME     = "merkle_root"
+)
+
+// ClientCommitmentHistoryBSON structure for mongoDB
+type ClientCommitmentHistoryBSON struct {
+	Sequence       int64     `bson:"sequence"`
+	Commitment     string    `bson:"commitment"`
+	ClientPosition int32     `bson:"client_position"`
+	SubmittedAt    time.Time `bson:"submitted_at"`
+	Submitter      string    `bson:"submitter"`
+	Signature      string    `bson:"signature"`
+	Txid           string    `bson:"txid"`
+	MerkleRoot     string    `bson:"merkle_root"`
+}
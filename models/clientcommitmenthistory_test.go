@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test ClientCommitmentHistory BSON interface
+func TestClientCommitmentHistoryBSON(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	root, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	history := ClientCommitmentHistory{
+		Commitment:     *hash0,
+		ClientPosition: int32(5),
+		SubmittedAt:    time.Unix(1542121293, 0).UTC(),
+		Submitter:      "mzDRVsdbMCBfFNqkiBLfYYNLCJcYGtvbGX",
+		Signature:      "H0hz4nNgv+CXA7bqwsbRKPDQfSoRCnawNBR/CMOw4XbXl4JxaowhiN2z/jZXXuBVK9ppmxeu5t6ktu6uOE7pvZE="}
+	assert.Equal(t, false, history.Attested())
+
+	// unattested history entry round trip with empty txid and merkle root
+	doc, docErr := GetDocumentFromModel(history)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, history.Commitment.String(), doc.Lookup(CLIENT_COMMITMENT_HISTORY_COMMITMENT_NAME).StringValue())
+	assert.Equal(t, history.ClientPosition, doc.Lookup(CLIENT_COMMITMENT_HISTORY_CLIENT_POSITION_NAME).Int32())
+	assert.Equal(t, history.Submitter, doc.Lookup(CLIENT_COMMITMENT_HISTORY_SUBMITTER_NAME).StringValue())
+	assert.Equal(t, history.Signature, doc.Lookup(CLIENT_COMMITMENT_HISTORY_SIGNATURE_NAME).StringValue())
+	assert.Equal(t, "", doc.Lookup(CLIENT_COMMITMENT_HISTORY_TXID_NAME).StringValue())
+	assert.Equal(t, "", doc.Lookup(CLIENT_COMMITMENT_HISTORY_MERKLE_ROOT_NAME).StringValue())
+
+	testHistory := &ClientCommitmentHistory{}
+	docErr = GetModelFromDocument(doc, testHistory)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, history, *testHistory)
+
+	// attested history entry round trip
+	history.Txid = *txid
+	history.MerkleRoot = *root
+	assert.Equal(t, true, history.Attested())
+
+	doc, docErr = GetDocumentFromModel(history)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, txid.String(), doc.Lookup(CLIENT_COMMITMENT_HISTORY_TXID_NAME).StringValue())
+	assert.Equal(t, root.String(), doc.Lookup(CLIENT_COMMITMENT_HISTORY_MERKLE_ROOT_NAME).StringValue())
+
+	testHistory = &ClientCommitmentHistory{}
+	docErr = GetModelFromDocument(doc, testHistory)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, history, *testHistory)
+}
@@ -4,16 +4,57 @@ import (
 	_ "github.com/mongodb/mongo-go-driver/bson"
 )
 
+// client slot statuses
+const (
+	CLIENT_STATUS_ACTIVE    = "active"
+	CLIENT_STATUS_SUSPENDED = "suspended"
+	CLIENT_STATUS_REVOKED   = "revoked"
+)
+
 // struct for db ClientDetails
+// Status is the slot lifecycle status with an empty status for client
+// details stored before statuses were introduced treated as active
+// StartedAt and EndedAt are the unix times the slot was assigned to the
+// client and revoked, with EndedAt zero while the slot is not revoked
+// HistorySequence is the sequence of the latest commitment history entry of
+// the position when the slot was assigned to the client, which the history
+// entries of the client follow
+// WebhookUrl is notified of each confirmed attestation of the slot with
+// requests signed by WebhookSecret, with no notifications if not set
 type ClientDetails struct {
-	ClientPosition int32  `bson:"client_position"`
-	AuthToken      string `bson:"auth_token"`
-	Pubkey         string `bson:"pubkey"`
+	ClientPosition  int32  `bson:"client_position"`
+	AuthToken       string `bson:"auth_token"`
+	Pubkey          string `bson:"pubkey"`
+	Status          string `bson:"status,omitempty"`
+	StartedAt       int64  `bson:"started_at,omitempty"`
+	EndedAt         int64  `bson:"ended_at,omitempty"`
+	HistorySequence int64  `bson:"history_sequence,omitempty"`
+	WebhookUrl      string `bson:"webhook_url,omitempty"`
+	WebhookSecret   string `bson:"webhook_secret,omitempty"`
+}
+
+// Return slot lifecycle status of client details
+func (d ClientDetails) SlotStatus() string {
+	if d.Status == "" {
+		return CLIENT_STATUS_ACTIVE
+	}
+	return d.Status
+}
+
+// Return whether the client slot accepts commitments
+func (d ClientDetails) IsActive() bool {
+	return d.SlotStatus() == CLIENT_STATUS_ACTIVE
 }
 
 // ClientDetails field names
 const (
-	CLIENT_DETAILS_CLIENT_POSITION_NAME = "client_position"
-	CLIENT_DETAILS_AUTH_TOKEN_NAME      = "auth_token"
-	CLIENT_DETAILS_PUBKEY_NAME          = "pubkey"
+	CLIENT_DETAILS_CLIENT_POSITION_NAME  = "client_position"
+	CLIENT_DETAILS_AUTH_TOKEN_NAME       = "auth_token"
+	CLIENT_DETAILS_PUBKEY_NAME           = "pubkey"
+	CLIENT_DETAILS_STATUS_NAME           = "status"
+	CLIENT_DETAILS_STARTED_AT_NAME       = "started_at"
+	CLIENT_DETAILS_ENDED_AT_NAME         = "ended_at"
+	CLIENT_DETAILS_HISTORY_SEQUENCE_NAME = "history_sequence"
+	CLIENT_DETAILS_WEBHOOK_URL_NAME      = "webhook_url"
+	CLIENT_DETAILS_WEBHOOK_SECRET_NAME   = "webhook_secret"
 )
@@ -9,15 +9,26 @@ import (
 
 // Test ClientDetails high level interface
 func TestClientDetails(t *testing.T) {
-	clientDetails := ClientDetails{0, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33"}
+	clientDetails := ClientDetails{ClientPosition: 0, AuthToken: "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b",
+		Pubkey: "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33"}
 	assert.Equal(t, int32(0), clientDetails.ClientPosition)
 	assert.Equal(t, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", clientDetails.AuthToken)
 	assert.Equal(t, "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33", clientDetails.Pubkey)
+
+	// client details without status are active
+	assert.Equal(t, CLIENT_STATUS_ACTIVE, clientDetails.SlotStatus())
+	assert.Equal(t, true, clientDetails.IsActive())
+	clientDetails.Status = CLIENT_STATUS_SUSPENDED
+	assert.Equal(t, CLIENT_STATUS_SUSPENDED, clientDetails.SlotStatus())
+	assert.Equal(t, false, clientDetails.IsActive())
+	clientDetails.Status = CLIENT_STATUS_REVOKED
+	assert.Equal(t, false, clientDetails.IsActive())
 }
 
 // Test ClientDetails BSON interface
 func TestClientDetailsBSON(t *testing.T) {
-	clientDetails := ClientDetails{0, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33"}
+	clientDetails := ClientDetails{ClientPosition: 0, AuthToken: "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b",
+		Pubkey: "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33"}
 
 	// test marshal clientDetails model
 	bytes, errBytes := bson.Marshal(clientDetails)
@@ -45,4 +56,32 @@ func TestClientDetailsBSON(t *testing.T) {
 	assert.Equal(t, clientDetails.AuthToken, testtestClientDetails.AuthToken)
 	assert.Equal(t, clientDetails.Pubkey, testtestClientDetails.Pubkey)
 	assert.Equal(t, clientDetails.ClientPosition, testtestClientDetails.ClientPosition)
+
+	assert.Equal(t, "", testtestClientDetails.Status)
+
+	// test slot status and timestamps round trip
+	clientDetails.Status = CLIENT_STATUS_REVOKED
+	clientDetails.StartedAt = int64(1542121293)
+	clientDetails.EndedAt = int64(1542131293)
+	doc, docErr = GetDocumentFromModel(clientDetails)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, CLIENT_STATUS_REVOKED, doc.Lookup(CLIENT_DETAILS_STATUS_NAME).StringValue())
+	assert.Equal(t, int64(1542121293), doc.Lookup(CLIENT_DETAILS_STARTED_AT_NAME).Int64())
+	assert.Equal(t, int64(1542131293), doc.Lookup(CLIENT_DETAILS_ENDED_AT_NAME).Int64())
+	testtestClientDetails = &ClientDetails{}
+	docErr = GetModelFromDocument(doc, testtestClientDetails)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, clientDetails, *testtestClientDetails)
+
+	// test webhook round trip
+	clientDetails.WebhookUrl = "https://client.example.com/webhook"
+	clientDetails.WebhookSecret = "secret0"
+	doc, docErr = GetDocumentFromModel(clientDetails)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, "https://client.example.com/webhook", doc.Lookup(CLIENT_DETAILS_WEBHOOK_URL_NAME).StringValue())
+	assert.Equal(t, "secret0", doc.Lookup(CLIENT_DETAILS_WEBHOOK_SECRET_NAME).StringValue())
+	testtestClientDetails = &ClientDetails{}
+	docErr = GetModelFromDocument(doc, testtestClientDetails)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, clientDetails, *testtestClientDetails)
 }
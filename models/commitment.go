@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/mongodb/mongo-go-driver/bson"
@@ -9,7 +10,8 @@ import (
 
 // error consts
 const (
-	ERROR_COMMITMENT_LIST_EMPTY = "List of commitments is empty"
+	ERROR_COMMITMENT_LIST_EMPTY   = "List of commitments is empty"
+	ERROR_COMMITMENT_TREE_VERSION = "Unknown commitment tree version"
 )
 
 // Commitment structure
@@ -17,13 +19,22 @@ type Commitment struct {
 	tree CommitmentMerkleTree
 }
 
-// Return new Commitment instance
+// Return new Commitment instance with the current tree layout version
 func NewCommitment(commitments []chainhash.Hash) (*Commitment, error) {
+	return NewCommitmentVersion(commitments, COMMITMENT_TREE_VERSION)
+}
+
+// Return new Commitment instance with the tree layout version provided
+// Used to rebuild commitments of attestations made with older layouts
+func NewCommitmentVersion(commitments []chainhash.Hash, version int32) (*Commitment, error) {
 	// check length
 	if len(commitments) == 0 {
 		return nil, errors.New(ERROR_COMMITMENT_LIST_EMPTY)
 	}
-	commitmentTree := NewCommitmentMerkleTree(commitments)
+	if !isCommitmentTreeVersion(version) {
+		return nil, errors.New(fmt.Sprintf("%s %d", ERROR_COMMITMENT_TREE_VERSION, version))
+	}
+	commitmentTree := NewCommitmentMerkleTree(commitments, version)
 	return &Commitment{commitmentTree}, nil
 }
 
@@ -41,6 +52,22 @@ func (c Commitment) GetMerkleCommitments() []CommitmentMerkleCommitment {
 	return commitments
 }
 
+// Get positions of empty slots with a zero commitment
+func (c Commitment) GetEmptySlots() []int32 {
+	emptySlots := []int32{}
+	for pos, commitment := range c.tree.getMerkleCommitments() {
+		if commitment == (chainhash.Hash{}) {
+			emptySlots = append(emptySlots, int32(pos))
+		}
+	}
+	return emptySlots
+}
+
+// Get merkle tree layout version for Commitment
+func (c Commitment) GetTreeVersion() int32 {
+	return c.tree.getVersion()
+}
+
 // Get merkle root hash for Commitment
 func (c Commitment) GetCommitmentHash() chainhash.Hash {
 	return c.tree.getMerkleRoot()
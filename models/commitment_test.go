@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -25,8 +26,9 @@ func TestCommitment(t *testing.T) {
 	_, errCommitmentEmpty := NewCommitment([]chainhash.Hash{})
 	assert.Equal(t, errors.New(ERROR_COMMITMENT_LIST_EMPTY), errCommitmentEmpty)
 
-	commitment, errCommitment := NewCommitment(commitments)
+	commitment, errCommitment := NewCommitmentVersion(commitments, COMMITMENT_TREE_VERSION_LEGACY)
 	assert.Equal(t, nil, errCommitment)
+	assert.Equal(t, COMMITMENT_TREE_VERSION_LEGACY, commitment.GetTreeVersion())
 
 	merkleCommitments := commitment.GetMerkleCommitments()
 	for pos := range merkleCommitments {
@@ -42,6 +44,66 @@ func TestCommitment(t *testing.T) {
 	assert.Equal(t, proofs, merkleProofs)
 }
 
+// Test Commitment empty slots with zero commitments
+func TestCommitmentEmptySlots(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	commitment, _ := NewCommitment([]chainhash.Hash{*hash0, *hash0})
+	assert.Equal(t, []int32{}, commitment.GetEmptySlots())
+
+	commitment, _ = NewCommitment([]chainhash.Hash{chainhash.Hash{}, *hash0, chainhash.Hash{}})
+	assert.Equal(t, []int32{0, 2}, commitment.GetEmptySlots())
+
+	// zero commitments are proven like any other commitment
+	for _, proof := range commitment.GetMerkleProofs() {
+		assert.Equal(t, true, ProveMerkleProof(proof))
+	}
+}
+
+// Test Commitment tree versions and slot proof stability as slots are added
+func TestCommitmentTreeVersion(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	_, errVersion := NewCommitmentVersion([]chainhash.Hash{*hash0}, 0)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_COMMITMENT_TREE_VERSION, 0)), errVersion)
+
+	commitment, errCommitment := NewCommitment([]chainhash.Hash{*hash0, *hash1, *hash2})
+	assert.Equal(t, nil, errCommitment)
+	assert.Equal(t, COMMITMENT_TREE_VERSION, commitment.GetTreeVersion())
+	legacyCommitment, _ := NewCommitmentVersion([]chainhash.Hash{*hash0, *hash1, *hash2}, COMMITMENT_TREE_VERSION_LEGACY)
+	assert.Equal(t, false, commitment.GetCommitmentHash() == legacyCommitment.GetCommitmentHash())
+
+	// padding leaves are not slots
+	assert.Equal(t, 3, len(commitment.GetMerkleProofs()))
+	assert.Equal(t, []int32{}, commitment.GetEmptySlots())
+
+	// adding a slot in place of a padding leaf keeps the proof ops of the other slots
+	// apart from the sibling of the new slot, which changes from zero to its commitment
+	grownCommitment, _ := NewCommitment([]chainhash.Hash{*hash0, *hash1, *hash2, *hash0})
+	proofs := commitment.GetMerkleProofs()
+	grownProofs := grownCommitment.GetMerkleProofs()
+	assert.Equal(t, proofs[0].Ops[0], grownProofs[0].Ops[0])
+	assert.Equal(t, proofs[2].Ops[0].Append, grownProofs[2].Ops[0].Append)
+	assert.Equal(t, chainhash.Hash{}, proofs[2].Ops[0].Commitment)
+	assert.Equal(t, *hash0, grownProofs[2].Ops[0].Commitment)
+
+	// deepening the tree with empty slots only appends ops to existing slot proofs
+	deepCommitment, _ := NewCommitment([]chainhash.Hash{*hash0, *hash1, *hash2, chainhash.Hash{}, chainhash.Hash{}})
+	deepProofs := deepCommitment.GetMerkleProofs()
+	for pos := range proofs {
+		assert.Equal(t, proofs[pos].Ops, deepProofs[pos].Ops[:len(proofs[pos].Ops)])
+		assert.Equal(t, 3, len(deepProofs[pos].Ops))
+		assert.Equal(t, true, deepProofs[pos].Ops[2].Append)
+		assert.Equal(t, true, ProveMerkleProof(deepProofs[pos]))
+	}
+
+	// the legacy layout duplicates the last slot so an empty slot changes its proof
+	legacyDeepCommitment, _ := NewCommitmentVersion([]chainhash.Hash{*hash0, *hash1, *hash2, chainhash.Hash{}}, COMMITMENT_TREE_VERSION_LEGACY)
+	assert.Equal(t, false, legacyCommitment.GetMerkleProofs()[2].Ops[0] == legacyDeepCommitment.GetMerkleProofs()[2].Ops[0])
+}
+
 // Test Commitment BSON interface
 func TestCommitmentBSON(t *testing.T) {
 	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
@@ -49,7 +111,7 @@ func TestCommitmentBSON(t *testing.T) {
 	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 	root, _ := chainhash.NewHashFromStr("bb088c106b3379b64243c1a4915f72a847d45c7513b152cad583eb3c0a1063c2")
 	commitments := []chainhash.Hash{*hash0, *hash1, *hash2}
-	commitment, _ := NewCommitment(commitments)
+	commitment, _ := NewCommitmentVersion(commitments, COMMITMENT_TREE_VERSION_LEGACY)
 
 	merkleCommitments := commitment.GetMerkleCommitments()
 
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// max length of a single commitment archive json line
+const COMMITMENT_ARCHIVE_MAX_LINE = 10000000
+
+// error consts
+const (
+	ERROR_COMMITMENT_ARCHIVE_ENCODING = "Invalid commitment archive record"
+)
+
+// CommitmentArchiveRecord structure
+// Commitment list of a single attestation round as appended to the
+// commitment archive by the attestation service, from which the
+// commitment merkle tree of the round can be rebuilt without the db
+type CommitmentArchiveRecord struct {
+	MerkleRoot  chainhash.Hash
+	TreeVersion int32
+	Commitments []chainhash.Hash
+	ArchivedAt  time.Time
+}
+
+// CommitmentArchiveRecordJSON structure for commitment archive json lines
+type CommitmentArchiveRecordJSON struct {
+	MerkleRoot  string   `json:"merkle_root"`
+	TreeVersion int32    `json:"tree_version"`
+	Commitments []string `json:"commitments"`
+	ArchivedAt  int64    `json:"archived_at"`
+}
+
+// Return new commitment archive record for the commitment provided
+func NewCommitmentArchiveRecord(commitment Commitment, archivedAt time.Time) CommitmentArchiveRecord {
+	return CommitmentArchiveRecord{commitment.GetCommitmentHash(), commitment.GetTreeVersion(),
+		commitment.tree.getMerkleCommitments(), archivedAt}
+}
+
+// Rebuild commitment of the archive record
+// The rebuilt merkle tree has to match the archived merkle root
+func (r CommitmentArchiveRecord) Commitment() (*Commitment, error) {
+	commitment, errCommitment := NewCommitmentVersion(r.Commitments, r.TreeVersion)
+	if errCommitment != nil {
+		return nil, errCommitment
+	}
+	if commitment.GetCommitmentHash() != r.MerkleRoot {
+		return nil, errors.New(fmt.Sprintf("%s %s", ERROR_COMMITMENT_MERKLE_ROOT, r.MerkleRoot.String()))
+	}
+	return commitment, nil
+}
+
+// Append commitment archive record to the archive as a single json line
+func WriteCommitmentArchiveRecord(w io.Writer, record CommitmentArchiveRecord) error {
+	var commitments []string
+	for _, commitment := range record.Commitments {
+		commitments = append(commitments, commitment.String())
+	}
+	line, errJson := json.Marshal(CommitmentArchiveRecordJSON{record.MerkleRoot.String(), record.TreeVersion,
+		commitments, record.ArchivedAt.Unix()})
+	if errJson != nil {
+		return errJson
+	}
+	_, errWrite := w.Write(append(line, '\n'))
+	return errWrite
+}
+
+// Read all commitment archive records from the archive json lines
+// Empty lines are skipped and records are returned in archive order
+func ReadCommitmentArchive(r io.Reader) ([]CommitmentArchiveRecord, error) {
+	var records []CommitmentArchiveRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, COMMITMENT_ARCHIVE_MAX_LINE)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var recordJSON CommitmentArchiveRecordJSON
+		if errJson := json.Unmarshal(line, &recordJSON); errJson != nil {
+			return nil, errors.New(fmt.Sprintf("%s %d: %v", ERROR_COMMITMENT_ARCHIVE_ENCODING, lineNum, errJson))
+		}
+		merkleRoot, errHash := chainhash.NewHashFromStr(recordJSON.MerkleRoot)
+		if errHash != nil {
+			return nil, errors.New(fmt.Sprintf("%s %d: %v", ERROR_COMMITMENT_ARCHIVE_ENCODING, lineNum, errHash))
+		}
+		record := CommitmentArchiveRecord{MerkleRoot: *merkleRoot, TreeVersion: recordJSON.TreeVersion,
+			ArchivedAt: time.Unix(recordJSON.ArchivedAt, 0)}
+		for _, commitmentStr := range recordJSON.Commitments {
+			commitment, errHash := chainhash.NewHashFromStr(commitmentStr)
+			if errHash != nil {
+				return nil, errors.New(fmt.Sprintf("%s %d: %v", ERROR_COMMITMENT_ARCHIVE_ENCODING, lineNum, errHash))
+			}
+			record.Commitments = append(record.Commitments, *commitment)
+		}
+		records = append(records, record)
+	}
+	if errScan := scanner.Err(); errScan != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_COMMITMENT_ARCHIVE_ENCODING, errScan))
+	}
+	return records, nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test CommitmentArchiveRecord writing, reading and rebuilding commitments
+func TestCommitmentArchive(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	legacy, _ := NewCommitmentVersion([]chainhash.Hash{*hash0, *hash1, *hash2}, COMMITMENT_TREE_VERSION_LEGACY)
+	latest, _ := NewCommitment([]chainhash.Hash{*hash2, *hash1, *hash0})
+	records := []CommitmentArchiveRecord{
+		NewCommitmentArchiveRecord(*legacy, time.Unix(1542121293, 0)),
+		NewCommitmentArchiveRecord(*latest, time.Unix(1542121893, 0)),
+	}
+
+	// records are written as json lines and read back in order
+	var archive bytes.Buffer
+	for _, record := range records {
+		assert.Equal(t, nil, WriteCommitmentArchiveRecord(&archive, record))
+	}
+	assert.Equal(t, 2, bytes.Count(archive.Bytes(), []byte("\n")))
+	archive.WriteString("\n")
+	readRecords, errRead := ReadCommitmentArchive(&archive)
+	assert.Equal(t, nil, errRead)
+	assert.Equal(t, records, readRecords)
+
+	// commitments are rebuilt with the archived tree version
+	for i, commitment := range []*Commitment{legacy, latest} {
+		rebuilt, errRebuild := readRecords[i].Commitment()
+		assert.Equal(t, nil, errRebuild)
+		assert.Equal(t, commitment.GetCommitmentHash(), rebuilt.GetCommitmentHash())
+		assert.Equal(t, commitment.GetMerkleProofs(), rebuilt.GetMerkleProofs())
+	}
+
+	// rebuilt commitment has to match the archived merkle root
+	record := records[0]
+	record.TreeVersion = COMMITMENT_TREE_VERSION_ZERO_PAD
+	_, errRebuild := record.Commitment()
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_COMMITMENT_MERKLE_ROOT, record.MerkleRoot.String())), errRebuild)
+
+	// invalid records are rejected with their line number
+	_, errHash := chainhash.NewHashFromStr("zz")
+	_, errRead = ReadCommitmentArchive(bytes.NewBufferString("\n{\"merkle_root\": \"zz\"}\n"))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s 2: %v", ERROR_COMMITMENT_ARCHIVE_ENCODING, errHash)), errRead)
+}
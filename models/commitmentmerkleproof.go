@@ -56,7 +56,7 @@ func buildMerkleProof(position int, tree []*chainhash.Hash) CommitmentMerkleProo
 }
 
 // Prove a commitment using the merkle proof provided
-func proveMerkleProof(proof CommitmentMerkleProof) bool {
+func ProveMerkleProof(proof CommitmentMerkleProof) bool {
 	hash := proof.Commitment
 	for i := range proof.Ops {
 		if proof.Ops[i].Append {
@@ -112,7 +112,28 @@ func (c *CommitmentMerkleProof) UnmarshalBSON(b []byte) error {
 	if err := bson.Unmarshal(b, &proofBSON); err != nil {
 		return err
 	}
-	// TODO : not implemented as not required anywhere at the moment
+	rootHash, errHash := chainhash.NewHashFromStr(proofBSON.MerkleRoot)
+	if errHash != nil {
+		return errHash
+	}
+	commitHash, errHash := chainhash.NewHashFromStr(proofBSON.Commitment)
+	if errHash != nil {
+		return errHash
+	}
+
+	var ops []CommitmentMerkleProofOp
+	for _, opBSON := range proofBSON.Ops {
+		opHash, errHash := chainhash.NewHashFromStr(opBSON.Commitment)
+		if errHash != nil {
+			return errHash
+		}
+		ops = append(ops, CommitmentMerkleProofOp{opBSON.Append, *opHash})
+	}
+
+	c.MerkleRoot = *rootHash
+	c.ClientPosition = proofBSON.ClientPosition
+	c.Commitment = *commitHash
+	c.Ops = ops
 	return nil
 }
 
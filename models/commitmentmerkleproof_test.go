@@ -272,29 +272,29 @@ func TestMerkleProof_ProveCommitment(t *testing.T) {
 
 	// test proving merkle proof with complete ops and partial ops list
 	proof0 := buildMerkleProof(0, merkleTree)
-	assert.Equal(t, true, proveMerkleProof(proof0))
+	assert.Equal(t, true, ProveMerkleProof(proof0))
 	proof0.Ops = proof0.Ops[1:]
-	assert.Equal(t, false, proveMerkleProof(proof0))
+	assert.Equal(t, false, ProveMerkleProof(proof0))
 
 	proof1 := buildMerkleProof(1, merkleTree)
-	assert.Equal(t, true, proveMerkleProof(proof1))
+	assert.Equal(t, true, ProveMerkleProof(proof1))
 	proof0.Ops = proof0.Ops[1:]
-	assert.Equal(t, false, proveMerkleProof(proof0))
+	assert.Equal(t, false, ProveMerkleProof(proof0))
 
 	proof2 := buildMerkleProof(2, merkleTree)
-	assert.Equal(t, true, proveMerkleProof(proof2))
+	assert.Equal(t, true, ProveMerkleProof(proof2))
 	proof2.Ops = proof2.Ops[1:]
-	assert.Equal(t, false, proveMerkleProof(proof2))
+	assert.Equal(t, false, ProveMerkleProof(proof2))
 
 	proof3 := buildMerkleProof(3, merkleTree)
-	assert.Equal(t, true, proveMerkleProof(proof3))
+	assert.Equal(t, true, ProveMerkleProof(proof3))
 	proof3.Ops = proof3.Ops[1:]
-	assert.Equal(t, false, proveMerkleProof(proof3))
+	assert.Equal(t, false, ProveMerkleProof(proof3))
 
 	proof4 := buildMerkleProof(4, merkleTree)
-	assert.Equal(t, true, proveMerkleProof(proof4))
+	assert.Equal(t, true, ProveMerkleProof(proof4))
 	proof4.Ops = proof0.Ops[1:]
-	assert.Equal(t, false, proveMerkleProof(proof4))
+	assert.Equal(t, false, ProveMerkleProof(proof4))
 }
 
 // Test build merkle proof and verify for 3 commitment tree
@@ -317,6 +317,12 @@ func TestMerkleProof_BSON(t *testing.T) {
 	assert.Equal(t, []byte{0x8b, 0x1, 0x0, 0x0, 0x2, 0x6d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x5f, 0x72, 0x6f, 0x6f, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x62, 0x62, 0x30, 0x38, 0x38, 0x63, 0x31, 0x30, 0x36, 0x62, 0x33, 0x33, 0x37, 0x39, 0x62, 0x36, 0x34, 0x32, 0x34, 0x33, 0x63, 0x31, 0x61, 0x34, 0x39, 0x31, 0x35, 0x66, 0x37, 0x32, 0x61, 0x38, 0x34, 0x37, 0x64, 0x34, 0x35, 0x63, 0x37, 0x35, 0x31, 0x33, 0x62, 0x31, 0x35, 0x32, 0x63, 0x61, 0x64, 0x35, 0x38, 0x33, 0x65, 0x62, 0x33, 0x63, 0x30, 0x61, 0x31, 0x30, 0x36, 0x33, 0x63, 0x32, 0x0, 0x10, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x0, 0x0, 0x0, 0x0, 0x0, 0x2, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x31, 0x61, 0x33, 0x39, 0x65, 0x33, 0x34, 0x65, 0x38, 0x38, 0x31, 0x64, 0x39, 0x61, 0x31, 0x65, 0x36, 0x63, 0x64, 0x63, 0x33, 0x34, 0x31, 0x38, 0x62, 0x35, 0x34, 0x61, 0x61, 0x35, 0x37, 0x37, 0x34, 0x37, 0x31, 0x30, 0x36, 0x62, 0x63, 0x37, 0x35, 0x65, 0x39, 0x65, 0x38, 0x34, 0x34, 0x32, 0x36, 0x36, 0x36, 0x31, 0x66, 0x32, 0x37, 0x66, 0x39, 0x38, 0x61, 0x64, 0x61, 0x33, 0x62, 0x37, 0x0, 0x4, 0x6f, 0x70, 0x73, 0x0, 0xc9, 0x0, 0x0, 0x0, 0x3, 0x30, 0x0, 0x5f, 0x0, 0x0, 0x0, 0x8, 0x61, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x0, 0x1, 0x2, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x32, 0x61, 0x33, 0x39, 0x65, 0x33, 0x34, 0x65, 0x38, 0x38, 0x31, 0x64, 0x39, 0x61, 0x31, 0x65, 0x36, 0x63, 0x64, 0x63, 0x33, 0x34, 0x31, 0x38, 0x62, 0x35, 0x34, 0x61, 0x61, 0x35, 0x37, 0x37, 0x34, 0x37, 0x31, 0x30, 0x36, 0x62, 0x63, 0x37, 0x35, 0x65, 0x39, 0x65, 0x38, 0x34, 0x34, 0x32, 0x36, 0x36, 0x36, 0x31, 0x66, 0x32, 0x37, 0x66, 0x39, 0x38, 0x61, 0x64, 0x61, 0x33, 0x62, 0x37, 0x0, 0x0, 0x3, 0x31, 0x0, 0x5f, 0x0, 0x0, 0x0, 0x8, 0x61, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x0, 0x1, 0x2, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x31, 0x61, 0x64, 0x37, 0x32, 0x63, 0x63, 0x32, 0x38, 0x38, 0x37, 0x65, 0x62, 0x34, 0x30, 0x32, 0x64, 0x34, 0x35, 0x34, 0x62, 0x31, 0x39, 0x39, 0x32, 0x64, 0x62, 0x30, 0x61, 0x64, 0x61, 0x36, 0x32, 0x30, 0x61, 0x66, 0x66, 0x64, 0x62, 0x37, 0x37, 0x61, 0x34, 0x36, 0x38, 0x34, 0x35, 0x36, 0x31, 0x37, 0x35, 0x32, 0x33, 0x65, 0x38, 0x34, 0x36, 0x62, 0x62, 0x62, 0x63, 0x39, 0x33, 0x35, 0x0, 0x0, 0x0, 0x0}, bytes)
 	assert.Equal(t, nil, errBytes)
 
+	// test unmarshal proof model
+	testProof := &CommitmentMerkleProof{}
+	errUnmarshal := testProof.UnmarshalBSON(bytes)
+	assert.Equal(t, nil, errUnmarshal)
+	assert.Equal(t, proof0, *testProof)
+
 	// test proof model to document
 	doc, docErr := GetDocumentFromModel(proof0)
 	assert.Equal(t, nil, docErr)
@@ -331,4 +337,10 @@ func TestMerkleProof_BSON(t *testing.T) {
 		assert.Equal(t, proof0.Ops[pos].Append, val.Lookup(PROOF_OP_APPEND_NAME).Boolean())
 		assert.Equal(t, proof0.Ops[pos].Commitment.String(), val.Lookup(PROOF_OP_COMMITMENT_NAME).StringValue())
 	}
+
+	// test document to proof model
+	testProof = &CommitmentMerkleProof{}
+	errModel := GetModelFromDocument(doc, testProof)
+	assert.Equal(t, nil, errModel)
+	assert.Equal(t, proof0, *testProof)
 }
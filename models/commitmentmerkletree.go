@@ -8,6 +8,25 @@ import (
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
+// commitment merkle tree layout versions
+// COMMITMENT_TREE_VERSION_LEGACY pads the leaves to the next power of two by
+// hashing the last node of each level with itself, so the sibling nodes in
+// slot proofs change meaning when slots are added
+// COMMITMENT_TREE_VERSION_ZERO_PAD pads the leaves to the next power of two
+// with zero commitments, so each slot keeps its L/R path as slots are added
+// and a deeper tree only appends ops to existing slot proofs
+const (
+	COMMITMENT_TREE_VERSION_LEGACY   int32 = 1
+	COMMITMENT_TREE_VERSION_ZERO_PAD int32 = 2
+
+	COMMITMENT_TREE_VERSION = COMMITMENT_TREE_VERSION_ZERO_PAD
+)
+
+// Return whether the commitment merkle tree layout version is known
+func isCommitmentTreeVersion(version int32) bool {
+	return version == COMMITMENT_TREE_VERSION_LEGACY || version == COMMITMENT_TREE_VERSION_ZERO_PAD
+}
+
 // Util function to print a merkle tree
 func printMerkleTree(tree []*chainhash.Hash) {
 	num := len(tree)/2 + 1
@@ -65,6 +84,23 @@ func buildMerkleTree(hashes []chainhash.Hash) []*chainhash.Hash {
 	return merkles
 }
 
+// Build merkle tree store from a list of commitments padded with
+// zero commitments to the next power of two
+// e.g. tree template: [hash0, hash1, hash2, zero, hash01, hash2zero, hashRoot]
+func buildZeroPaddedMerkleTree(hashes []chainhash.Hash) []*chainhash.Hash {
+	paddedHashes := make([]chainhash.Hash, nextPow(len(hashes)))
+	copy(paddedHashes, hashes)
+	return buildMerkleTree(paddedHashes)
+}
+
+// Build merkle tree store from a list of commitments using the tree layout version
+func buildVersionMerkleTree(hashes []chainhash.Hash, version int32) []*chainhash.Hash {
+	if version == COMMITMENT_TREE_VERSION_ZERO_PAD {
+		return buildZeroPaddedMerkleTree(hashes)
+	}
+	return buildMerkleTree(hashes)
+}
+
 // Hash the concatenation of two commitment leaves from merkle tree
 func hashLeaves(left chainhash.Hash, right chainhash.Hash) *chainhash.Hash {
 	// Concatenate the left and right nodes.
@@ -94,32 +130,36 @@ func nextPow(n int) int {
 }
 
 // CommitmentMerkleTree structure
+// The zero value version is the legacy tree layout
+// The tree store is not set for commitment snapshots of an
+// IncrementalMerkleTree and is then built when requested
 type CommitmentMerkleTree struct {
 	commitments []chainhash.Hash
 	treeStore   []*chainhash.Hash
 	root        chainhash.Hash
+	version     int32
 }
 
 // New CommitmentMerkleTree instance
-// Takes as input a list of commitments and stores these
-// along with the whole merkle tree in a list
-func NewCommitmentMerkleTree(commitments []chainhash.Hash) CommitmentMerkleTree {
+// Takes as input a list of commitments and the tree layout version
+// and stores these along with the whole merkle tree in a list
+func NewCommitmentMerkleTree(commitments []chainhash.Hash, version int32) CommitmentMerkleTree {
 	leavesSize := len(commitments)
 	myCommitments := make([]chainhash.Hash, leavesSize)
 	copy(myCommitments, commitments)
 
 	treeSize := 2*nextPow(leavesSize) - 1
 	myTreeStore := make([]*chainhash.Hash, treeSize)
-	myTreeStore = buildMerkleTree(myCommitments)
+	myTreeStore = buildVersionMerkleTree(myCommitments, version)
 
 	myRoot := *myTreeStore[treeSize-1]
 
-	return CommitmentMerkleTree{myCommitments, myTreeStore, myRoot}
+	return CommitmentMerkleTree{myCommitments, myTreeStore, myRoot, version}
 }
 
 // Build commitment merkle tree store from commitment hashes
 func (m *CommitmentMerkleTree) updateTreeStore() {
-	m.treeStore = buildMerkleTree(m.commitments)
+	m.treeStore = buildVersionMerkleTree(m.commitments, m.version)
 	m.root = *m.treeStore[len(m.treeStore)-1]
 }
 
@@ -146,15 +186,19 @@ func (m CommitmentMerkleTree) getMerkleCommitments() []chainhash.Hash {
 
 // Return merkle proofs for all commitments in the merkle tree
 func (m CommitmentMerkleTree) getMerkleProofs() []CommitmentMerkleProof {
+	treeStore := m.getMerkleTree()
 	var proofs []CommitmentMerkleProof
 	for i := range m.commitments {
-		proofs = append(proofs, buildMerkleProof(i, m.treeStore))
+		proofs = append(proofs, buildMerkleProof(i, treeStore))
 	}
 	return proofs
 }
 
 // Return the merkle tree store, including all commitments, intermediary tree nodes and root
 func (m CommitmentMerkleTree) getMerkleTree() []*chainhash.Hash {
+	if m.treeStore == nil {
+		return buildVersionMerkleTree(m.commitments, m.version)
+	}
 	return m.treeStore
 }
 
@@ -162,3 +206,11 @@ func (m CommitmentMerkleTree) getMerkleTree() []*chainhash.Hash {
 func (m CommitmentMerkleTree) getMerkleRoot() chainhash.Hash {
 	return m.root
 }
+
+// Get tree layout version
+func (m CommitmentMerkleTree) getVersion() int32 {
+	if m.version == 0 {
+		return COMMITMENT_TREE_VERSION_LEGACY
+	}
+	return m.version
+}
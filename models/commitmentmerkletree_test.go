@@ -134,3 +134,42 @@ func TestMerkleTree_1Commitments(t *testing.T) {
 	partialCommitmentMerkleTree.updateTreeStore()
 	assert.Equal(t, partialCommitmentMerkleTree.getMerkleRoot(), *partialMerkleTree[2])
 }
+
+// Test build zero padded merkle tree for 3 commitment tree
+func TestMerkleTree_ZeroPadded(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	zero := chainhash.Hash{}
+
+	// test partial merkle tree with 3 hashes padded with a zero leaf
+	partialCommitments := []chainhash.Hash{*hash0, *hash1, *hash2}
+	partialMerkleTree := buildZeroPaddedMerkleTree(partialCommitments)
+
+	assert.Equal(t, 7, len(partialMerkleTree))
+	assert.Equal(t, hash0, partialMerkleTree[0])
+	assert.Equal(t, hash1, partialMerkleTree[1])
+	assert.Equal(t, hash2, partialMerkleTree[2])
+	assert.Equal(t, &zero, partialMerkleTree[3])
+	assert.Equal(t, hashLeaves(*hash0, *hash1), partialMerkleTree[4])
+	assert.Equal(t, hashLeaves(*hash2, zero), partialMerkleTree[5])
+	assert.Equal(t, hashLeaves(*hashLeaves(*hash0, *hash1), *hashLeaves(*hash2, zero)), partialMerkleTree[6])
+
+	// test a single hash is padded with a zero leaf
+	singleMerkleTree := buildZeroPaddedMerkleTree([]chainhash.Hash{*hash0})
+	assert.Equal(t, 3, len(singleMerkleTree))
+	assert.Equal(t, hashLeaves(*hash0, zero), singleMerkleTree[2])
+
+	// verify that CommitmentMerkleTree arrives to the same result
+	partialCommitmentMerkleTree := NewCommitmentMerkleTree(partialCommitments, COMMITMENT_TREE_VERSION_ZERO_PAD)
+	assert.Equal(t, partialCommitmentMerkleTree.getMerkleRoot(), *partialMerkleTree[6])
+	assert.Equal(t, COMMITMENT_TREE_VERSION_ZERO_PAD, partialCommitmentMerkleTree.getVersion())
+	assert.Equal(t, partialCommitments, partialCommitmentMerkleTree.getMerkleCommitments())
+
+	// the zero value tree uses the legacy layout
+	legacyCommitmentMerkleTree := CommitmentMerkleTree{}
+	legacyCommitmentMerkleTree.commitments = partialCommitments
+	legacyCommitmentMerkleTree.updateTreeStore()
+	assert.Equal(t, COMMITMENT_TREE_VERSION_LEGACY, legacyCommitmentMerkleTree.getVersion())
+	assert.Equal(t, *buildMerkleTree(partialCommitments)[6], legacyCommitmentMerkleTree.getMerkleRoot())
+}
@@ -0,0 +1,219 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// error consts
+const (
+	ERROR_COMMITMENT_POSITION = "Commitment position out of range"
+)
+
+// IncrementalMerkleTree structure
+// Commitment merkle tree kept across attestation rounds, with the same
+// layout as CommitmentMerkleTree, that only rehashes the paths from the
+// slots whose commitments changed up to the root. Each level only holds
+// the nodes present in the tree store, so for the legacy layout a last
+// node without a right sibling is hashed with itself. Merkle proofs are
+// built from the levels without rebuilding the tree store
+type IncrementalMerkleTree struct {
+	levels         [][]chainhash.Hash
+	numCommitments int
+	version        int32
+}
+
+// New IncrementalMerkleTree instance
+// Takes as input a list of commitments and the tree layout version
+func NewIncrementalMerkleTree(commitments []chainhash.Hash, version int32) (*IncrementalMerkleTree, error) {
+	if len(commitments) == 0 {
+		return nil, errors.New(ERROR_COMMITMENT_LIST_EMPTY)
+	}
+	if !isCommitmentTreeVersion(version) {
+		return nil, errors.New(fmt.Sprintf("%s %d", ERROR_COMMITMENT_TREE_VERSION, version))
+	}
+	tree := &IncrementalMerkleTree{version: version}
+	tree.build(commitments)
+	return tree, nil
+}
+
+// Build all tree levels from the list of commitments
+func (m *IncrementalMerkleTree) build(commitments []chainhash.Hash) {
+	width := nextPow(len(commitments))
+	leaves := make([]chainhash.Hash, len(commitments), width)
+	copy(leaves, commitments)
+	if m.version == COMMITMENT_TREE_VERSION_ZERO_PAD {
+		leaves = leaves[:width]
+	}
+
+	m.numCommitments = len(commitments)
+	m.levels = [][]chainhash.Hash{leaves}
+	for width > 1 {
+		width /= 2
+		level := make([]chainhash.Hash, (len(m.levels[len(m.levels)-1])+1)/2)
+		m.levels = append(m.levels, level)
+		for i := range level {
+			m.hashNode(len(m.levels)-1, i)
+		}
+	}
+}
+
+// Hash node i of a level from its children in the level below
+func (m *IncrementalMerkleTree) hashNode(depth int, i int) {
+	children := m.levels[depth-1]
+	if 2*i+1 < len(children) {
+		m.levels[depth][i] = *hashLeaves(children[2*i], children[2*i+1])
+	} else {
+		m.levels[depth][i] = *hashLeaves(children[2*i], children[2*i])
+	}
+}
+
+// Rehash the paths from the leaf positions provided, in increasing order, to the root
+func (m *IncrementalMerkleTree) updatePaths(positions []int) {
+	for depth := 1; depth < len(m.levels) && len(positions) > 0; depth++ {
+		parents := positions[:0]
+		for _, position := range positions {
+			if len(parents) == 0 || parents[len(parents)-1] != position/2 {
+				parents = append(parents, position/2)
+			}
+		}
+		for _, parent := range parents {
+			m.hashNode(depth, parent)
+		}
+		positions = parents
+	}
+}
+
+// Update commitment of a single slot and rehash its path to the root
+func (m *IncrementalMerkleTree) SetCommitment(position int32, commitment chainhash.Hash) error {
+	if position < 0 || int(position) >= m.numCommitments {
+		return errors.New(fmt.Sprintf("%s %d", ERROR_COMMITMENT_POSITION, position))
+	}
+	if m.levels[0][position] != commitment {
+		m.levels[0][position] = commitment
+		m.updatePaths([]int{int(position)})
+	}
+	return nil
+}
+
+// Update tree to the list of commitments provided
+// Only paths of changed slots are rehashed, unless the number of slots
+// changes the tree width, or any slot is added or removed in the legacy
+// layout, in which case the tree is rebuilt
+// Returns the positions of the slots that changed
+func (m *IncrementalMerkleTree) Update(commitments []chainhash.Hash) ([]int32, error) {
+	if len(commitments) == 0 {
+		return nil, errors.New(ERROR_COMMITMENT_LIST_EMPTY)
+	}
+
+	var changed []int32
+	for i := range commitments {
+		if i >= m.numCommitments || m.levels[0][i] != commitments[i] {
+			changed = append(changed, int32(i))
+		}
+	}
+	if nextPow(len(commitments)) != m.width() ||
+		m.version != COMMITMENT_TREE_VERSION_ZERO_PAD && len(commitments) != m.numCommitments {
+		m.build(commitments)
+		return changed, nil
+	}
+
+	// removed slots of the zero padded layout become zero commitments
+	var positions []int
+	for i := range m.levels[0] {
+		var commitment chainhash.Hash
+		if i < len(commitments) {
+			commitment = commitments[i]
+		}
+		if m.levels[0][i] != commitment {
+			m.levels[0][i] = commitment
+			positions = append(positions, i)
+		}
+	}
+	m.numCommitments = len(commitments)
+	m.updatePaths(positions)
+	return changed, nil
+}
+
+// Return number of leaves of the tree padded to the next power of two
+func (m *IncrementalMerkleTree) width() int {
+	return 1 << uint(len(m.levels)-1)
+}
+
+// Get tree merkle root
+func (m *IncrementalMerkleTree) Root() chainhash.Hash {
+	return m.levels[len(m.levels)-1][0]
+}
+
+// Get tree layout version
+func (m *IncrementalMerkleTree) Version() int32 {
+	return m.version
+}
+
+// Return copy of the list of slot commitments
+func (m *IncrementalMerkleTree) Commitments() []chainhash.Hash {
+	commitments := make([]chainhash.Hash, m.numCommitments)
+	copy(commitments, m.levels[0])
+	return commitments
+}
+
+// Build merkle proof for the commitment of a slot from the tree levels
+func (m *IncrementalMerkleTree) MerkleProof(position int32) (CommitmentMerkleProof, error) {
+	if position < 0 || int(position) >= m.numCommitments {
+		return CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %d", ERROR_COMMITMENT_POSITION, position))
+	}
+	return m.merkleProof(int(position), make([]CommitmentMerkleProofOp, 0, len(m.levels)-1)), nil
+}
+
+// Build merkle proof of a slot appending the proof ops to the slice provided
+// A tree of a single slot has no proof ops
+func (m *IncrementalMerkleTree) merkleProof(position int, ops []CommitmentMerkleProofOp) CommitmentMerkleProof {
+	proof := CommitmentMerkleProof{MerkleRoot: m.Root(), ClientPosition: int32(position),
+		Commitment: m.levels[0][position]}
+	if cap(ops) > 0 {
+		proof.Ops = ops
+	}
+	index := position
+	for _, level := range m.levels[:len(m.levels)-1] {
+		if index%2 == 0 { // left side - if no right sibling append self
+			sibling := index + 1
+			if sibling >= len(level) {
+				sibling = index
+			}
+			proof.Ops = append(proof.Ops, CommitmentMerkleProofOp{true, level[sibling]})
+		} else { // right side
+			proof.Ops = append(proof.Ops, CommitmentMerkleProofOp{false, level[index-1]})
+		}
+		index /= 2
+	}
+	return proof
+}
+
+// Build merkle proofs of all slots of the commitment provided
+// Proofs are built from the tree levels if the commitment has the root,
+// number of slots and layout of the tree, and from the commitment otherwise
+func (m *IncrementalMerkleTree) CommitmentMerkleProofs(commitment Commitment) []CommitmentMerkleProof {
+	if commitment.GetCommitmentHash() != m.Root() || commitment.GetTreeVersion() != m.version ||
+		len(commitment.tree.getMerkleCommitments()) != m.numCommitments {
+		return commitment.GetMerkleProofs()
+	}
+	// proof ops of all slots share a single allocation
+	depth := len(m.levels) - 1
+	ops := make([]CommitmentMerkleProofOp, m.numCommitments*depth)
+	proofs := make([]CommitmentMerkleProof, m.numCommitments)
+	for i := range proofs {
+		proofs[i] = m.merkleProof(i, ops[i*depth:i*depth:(i+1)*depth])
+	}
+	return proofs
+}
+
+// Return Commitment snapshot of the tree
+// Only the slot commitments are copied, so later updates of the tree do not
+// affect the snapshot. The tree store of the snapshot is only built if its
+// merkle proofs are requested, see CommitmentMerkleProofs to build these
+// from the tree instead
+func (m *IncrementalMerkleTree) Commitment() *Commitment {
+	return &Commitment{CommitmentMerkleTree{commitments: m.Commitments(), root: m.Root(), version: m.version}}
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Return list of test commitments for n slots with commitments of round
+func testIncrementalCommitments(n int, round uint32) []chainhash.Hash {
+	commitments := make([]chainhash.Hash, n)
+	var data [8]byte
+	binary.LittleEndian.PutUint32(data[4:], round)
+	for i := range commitments {
+		binary.LittleEndian.PutUint32(data[:4], uint32(i))
+		commitments[i] = chainhash.HashH(data[:])
+	}
+	return commitments
+}
+
+// Verify incremental tree against a commitment built from scratch
+func assertIncrementalTree(t *testing.T, tree *IncrementalMerkleTree, commitments []chainhash.Hash, version int32) {
+	commitment, errCommitment := NewCommitmentVersion(commitments, version)
+	assert.Equal(t, nil, errCommitment)
+	assert.Equal(t, commitment.GetCommitmentHash(), tree.Root())
+	assert.Equal(t, commitments, tree.Commitments())
+
+	proofs := commitment.GetMerkleProofs()
+	for i := range commitments {
+		proof, errProof := tree.MerkleProof(int32(i))
+		assert.Equal(t, nil, errProof)
+		assert.Equal(t, proofs[i], proof)
+		assert.Equal(t, true, ProveMerkleProof(proof))
+	}
+	assert.Equal(t, proofs, tree.CommitmentMerkleProofs(*commitment))
+	snapshot := tree.Commitment()
+	assert.Equal(t, commitment.tree.getMerkleTree(), snapshot.tree.getMerkleTree())
+	assert.Equal(t, proofs, snapshot.GetMerkleProofs())
+	assert.Equal(t, commitment.GetMerkleCommitments(), snapshot.GetMerkleCommitments())
+	assert.Equal(t, version, snapshot.GetTreeVersion())
+}
+
+// Test incremental tree matches commitment merkle trees of both layouts
+func TestIncrementalMerkleTree(t *testing.T) {
+	for _, version := range []int32{COMMITMENT_TREE_VERSION_LEGACY, COMMITMENT_TREE_VERSION_ZERO_PAD} {
+		for _, n := range []int{1, 2, 3, 4, 5, 7, 8, 9, 17} {
+			commitments := testIncrementalCommitments(n, 0)
+			tree, errTree := NewIncrementalMerkleTree(commitments, version)
+			assert.Equal(t, nil, errTree)
+			assertIncrementalTree(t, tree, commitments, version)
+
+			// single slot update
+			commitments[n-1] = chainhash.Hash{}
+			assert.Equal(t, nil, tree.SetCommitment(int32(n-1), chainhash.Hash{}))
+			assertIncrementalTree(t, tree, commitments, version)
+
+			// update of every other slot
+			updated := testIncrementalCommitments(n, 1)
+			var expected []int32
+			for i := 0; i < n; i += 2 {
+				commitments[i] = updated[i]
+				expected = append(expected, int32(i))
+			}
+			changed, errUpdate := tree.Update(commitments)
+			assert.Equal(t, nil, errUpdate)
+			assert.Equal(t, expected, changed)
+			assertIncrementalTree(t, tree, commitments, version)
+		}
+	}
+
+	_, errTree := NewIncrementalMerkleTree(nil, COMMITMENT_TREE_VERSION)
+	assert.Equal(t, errors.New(ERROR_COMMITMENT_LIST_EMPTY), errTree)
+	_, errTree = NewIncrementalMerkleTree(testIncrementalCommitments(1, 0), 3)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_COMMITMENT_TREE_VERSION, 3)), errTree)
+}
+
+// Test incremental tree updates adding and removing slots
+func TestIncrementalMerkleTreeResize(t *testing.T) {
+	for _, version := range []int32{COMMITMENT_TREE_VERSION_LEGACY, COMMITMENT_TREE_VERSION_ZERO_PAD} {
+		commitments := testIncrementalCommitments(5, 0)
+		tree, _ := NewIncrementalMerkleTree(commitments, version)
+
+		// slots added within the tree width
+		commitments = append(commitments, testIncrementalCommitments(7, 1)[5:]...)
+		changed, errUpdate := tree.Update(commitments)
+		assert.Equal(t, nil, errUpdate)
+		assert.Equal(t, []int32{5, 6}, changed)
+		assertIncrementalTree(t, tree, commitments, version)
+
+		// slots added beyond the tree width
+		commitments = append(commitments, testIncrementalCommitments(10, 1)[7:]...)
+		changed, _ = tree.Update(commitments)
+		assert.Equal(t, []int32{7, 8, 9}, changed)
+		assertIncrementalTree(t, tree, commitments, version)
+
+		// slots removed
+		commitments = commitments[:3]
+		changed, _ = tree.Update(commitments)
+		assert.Equal(t, []int32(nil), changed)
+		assertIncrementalTree(t, tree, commitments, version)
+
+		_, errUpdate = tree.Update(nil)
+		assert.Equal(t, errors.New(ERROR_COMMITMENT_LIST_EMPTY), errUpdate)
+		assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_COMMITMENT_POSITION, 3)),
+			tree.SetCommitment(3, chainhash.Hash{}))
+		_, errProof := tree.MerkleProof(-1)
+		assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_COMMITMENT_POSITION, -1)), errProof)
+	}
+}
+
+// Test commitment snapshots are not affected by later tree updates
+func TestIncrementalMerkleTreeSnapshot(t *testing.T) {
+	commitments := testIncrementalCommitments(6, 0)
+	tree, _ := NewIncrementalMerkleTree(commitments, COMMITMENT_TREE_VERSION)
+	snapshot := tree.Commitment()
+	proofs := snapshot.GetMerkleProofs()
+
+	assert.Equal(t, nil, tree.SetCommitment(2, chainhash.Hash{}))
+	assert.NotEqual(t, snapshot.GetCommitmentHash(), tree.Root())
+	assert.Equal(t, proofs, snapshot.GetMerkleProofs())
+	assert.Equal(t, commitments[2], snapshot.GetMerkleCommitments()[2].Commitment)
+
+	// proofs of a commitment other than the tree are built from the commitment
+	assert.Equal(t, proofs, tree.CommitmentMerkleProofs(*snapshot))
+	padded, _ := NewCommitment(append(tree.Commitments(), chainhash.Hash{}, chainhash.Hash{}))
+	assert.Equal(t, tree.Root(), padded.GetCommitmentHash())
+	assert.Equal(t, padded.GetMerkleProofs(), tree.CommitmentMerkleProofs(*padded))
+}
+
+// benchmark slot counts and number of slots changed per round
+var (
+	benchmarkSlots   = []int{10000, 100000, 1000000}
+	benchmarkChanged = 100
+)
+
+// Return commitments of the next round with benchmarkChanged slots spread over the tree
+func benchmarkNextRound(commitments []chainhash.Hash, round uint32) []chainhash.Hash {
+	next := make([]chainhash.Hash, len(commitments))
+	copy(next, commitments)
+	step := len(commitments) / benchmarkChanged
+	for i := 0; i < benchmarkChanged; i++ {
+		next[i*step+int(round)%step] = chainhash.HashH(append(next[i*step][:], byte(round)))
+	}
+	return next
+}
+
+// Benchmark rebuilding the whole tree store for each round
+func BenchmarkBuildMerkleTree(b *testing.B) {
+	for _, n := range benchmarkSlots {
+		commitments := testIncrementalCommitments(n, 0)
+		b.Run(fmt.Sprintf("slots=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				buildZeroPaddedMerkleTree(commitments)
+			}
+		})
+	}
+}
+
+// Benchmark updating the incremental tree with the changed slots of each round
+func BenchmarkIncrementalMerkleTreeUpdate(b *testing.B) {
+	for _, n := range benchmarkSlots {
+		commitments := testIncrementalCommitments(n, 0)
+		tree, _ := NewIncrementalMerkleTree(commitments, COMMITMENT_TREE_VERSION_ZERO_PAD)
+		rounds := [][]chainhash.Hash{benchmarkNextRound(commitments, 1), benchmarkNextRound(commitments, 2)}
+		b.Run(fmt.Sprintf("slots=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tree.Update(rounds[i%2])
+			}
+		})
+	}
+}
+
+// Benchmark building all merkle proofs of a commitment built from scratch
+func BenchmarkCommitmentMerkleProofs(b *testing.B) {
+	for _, n := range benchmarkSlots {
+		commitment, _ := NewCommitment(testIncrementalCommitments(n, 0))
+		b.Run(fmt.Sprintf("slots=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				commitment.GetMerkleProofs()
+			}
+		})
+	}
+}
+
+// Benchmark building all merkle proofs of a round from the tree levels
+func BenchmarkIncrementalMerkleTreeProofs(b *testing.B) {
+	for _, n := range benchmarkSlots {
+		tree, _ := NewIncrementalMerkleTree(testIncrementalCommitments(n, 0), COMMITMENT_TREE_VERSION_ZERO_PAD)
+		commitment := tree.Commitment()
+		b.Run(fmt.Sprintf("slots=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tree.CommitmentMerkleProofs(*commitment)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// proof bundle consts
+const (
+	PROOF_BUNDLE_VERSION      = 1
+	PROOF_BUNDLE_MAGIC        = "MSPB"
+	PROOF_BUNDLE_MAX_OPS      = 32
+	PROOF_BUNDLE_MAX_DATA     = 1000000
+	PROOF_BUNDLE_MAX_BRANCH   = 31
+	PROOF_BUNDLE_MAX_PUBKEY   = 65
+	PROOF_BUNDLE_MAX_REDEEM   = txscript.MaxScriptElementSize
+	PROOF_BUNDLE_PROTOCOL_VER = 0
+)
+
+// error consts
+const (
+	ERROR_PROOF_BUNDLE_ENCODING    = "Invalid proof bundle encoding"
+	ERROR_PROOF_BUNDLE_VERSION     = "Unsupported proof bundle version"
+	ERROR_PROOF_BUNDLE_FIELD       = "Invalid proof bundle field"
+	ERROR_PROOF_BUNDLE_TXID        = "Proof bundle txid does not match raw transaction"
+	ERROR_PROOF_BUNDLE_TWEAK       = "Proof bundle requires exactly one of base script or base pubkey"
+	ERROR_PROOF_BUNDLE_SPV_MISSING = "Slot proof missing SPV proof - confirmed attestation required"
+)
+
+// ProofBundle structure
+// Self-contained proof of a client commitment that can be verified
+// without access to the service, consisting of:
+// - the commitment merkle proof of the client slot
+// - the staychain transaction committing to the merkle root
+// - the base multisig script or pubkey tweaked with the merkle root
+// - the SPV proof of the staychain transaction in a block
+type ProofBundle struct {
+	Version uint32
+	Proof   CommitmentMerkleProof
+	Txid    chainhash.Hash
+	Tx      wire.MsgTx
+	Script  []byte
+	Pubkey  []byte
+	Spv     SpvProof
+}
+
+// ProofBundleOpJSON structure for proof bundle json format
+type ProofBundleOpJSON struct {
+	Append     bool   `json:"append"`
+	Commitment string `json:"commitment"`
+}
+
+// ProofBundleJSON structure for proof bundle json format
+type ProofBundleJSON struct {
+	Version        uint32              `json:"version"`
+	ClientPosition int32               `json:"position"`
+	Commitment     string              `json:"commitment"`
+	MerkleRoot     string              `json:"merkle_root"`
+	Ops            []ProofBundleOpJSON `json:"ops"`
+	Txid           string              `json:"txid"`
+	RawTx          string              `json:"raw_tx"`
+	Script         string              `json:"script,omitempty"`
+	Pubkey         string              `json:"pubkey,omitempty"`
+	Header         string              `json:"header"`
+	TxIndex        int32               `json:"tx_index"`
+	MerkleBranch   []string            `json:"merkle_branch"`
+}
+
+// ProofBundle constructor from a slot proof of a confirmed attestation,
+// the raw staychain transaction and the base script or pubkey
+func NewProofBundle(slotProof SlotProof, tx wire.MsgTx, script []byte, pubkey []byte) (*ProofBundle, error) {
+	if slotProof.Spv == nil {
+		return nil, errors.New(ERROR_PROOF_BUNDLE_SPV_MISSING)
+	}
+	bundle := &ProofBundle{PROOF_BUNDLE_VERSION, slotProof.Proof, slotProof.Txid, tx, script, pubkey, *slotProof.Spv}
+	if errValidate := bundle.Validate(); errValidate != nil {
+		return nil, errValidate
+	}
+	return bundle, nil
+}
+
+// Validate proof bundle structure and consistency of its fields
+// This does not verify the merkle proof, tweaking or SPV proof
+func (b ProofBundle) Validate() error {
+	if b.Version != PROOF_BUNDLE_VERSION {
+		return errors.New(fmt.Sprintf("%s %d", ERROR_PROOF_BUNDLE_VERSION, b.Version))
+	}
+	if b.Proof.ClientPosition < 0 {
+		return errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "position"))
+	}
+	if len(b.Proof.Ops) > PROOF_BUNDLE_MAX_OPS {
+		return errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "ops"))
+	}
+	if b.Tx.TxHash() != b.Txid {
+		return errors.New(ERROR_PROOF_BUNDLE_TXID)
+	}
+	if (len(b.Script) == 0) == (len(b.Pubkey) == 0) {
+		return errors.New(ERROR_PROOF_BUNDLE_TWEAK)
+	}
+	if len(b.Pubkey) > 0 {
+		if _, errPub := btcec.ParsePubKey(b.Pubkey, btcec.S256()); errPub != nil {
+			return errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "pubkey"))
+		}
+	}
+	if len(b.Script) > 0 && txscript.GetScriptClass(b.Script) != txscript.MultiSigTy {
+		return errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "script"))
+	}
+	if b.Spv.TxIndex < 0 || len(b.Spv.MerkleBranch) > PROOF_BUNDLE_MAX_BRANCH ||
+		b.Spv.TxIndex >= 1<<uint(len(b.Spv.MerkleBranch)) {
+		return errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "tx_index"))
+	}
+	return nil
+}
+
+// Export proof bundle to json format
+func (b ProofBundle) ExportJSON() ([]byte, error) {
+	if errValidate := b.Validate(); errValidate != nil {
+		return nil, errValidate
+	}
+	var txBuf bytes.Buffer
+	if errTx := b.Tx.Serialize(&txBuf); errTx != nil {
+		return nil, errTx
+	}
+
+	bundleJSON := ProofBundleJSON{
+		Version:        b.Version,
+		ClientPosition: b.Proof.ClientPosition,
+		Commitment:     b.Proof.Commitment.String(),
+		MerkleRoot:     b.Proof.MerkleRoot.String(),
+		Ops:            []ProofBundleOpJSON{},
+		Txid:           b.Txid.String(),
+		RawTx:          hex.EncodeToString(txBuf.Bytes()),
+		Script:         hex.EncodeToString(b.Script),
+		Pubkey:         hex.EncodeToString(b.Pubkey),
+		Header:         b.Spv.HeaderHex(),
+		TxIndex:        b.Spv.TxIndex,
+		MerkleBranch:   b.Spv.MerkleBranchStrings()}
+	for _, op := range b.Proof.Ops {
+		bundleJSON.Ops = append(bundleJSON.Ops, ProofBundleOpJSON{op.Append, op.Commitment.String()})
+	}
+	return json.MarshalIndent(bundleJSON, "", "    ")
+}
+
+// Import proof bundle from json format
+// Unknown fields, trailing data and invalid fields are rejected
+func ImportProofBundleJSON(data []byte) (*ProofBundle, error) {
+	var bundleJSON ProofBundleJSON
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if errDecode := decoder.Decode(&bundleJSON); errDecode != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_BUNDLE_ENCODING, errDecode))
+	}
+	if _, errTrailing := decoder.Token(); errTrailing != io.EOF {
+		return nil, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_ENCODING, "trailing data"))
+	}
+	if bundleJSON.Version != PROOF_BUNDLE_VERSION {
+		return nil, errors.New(fmt.Sprintf("%s %d", ERROR_PROOF_BUNDLE_VERSION, bundleJSON.Version))
+	}
+
+	var bundle ProofBundle
+	bundle.Version = bundleJSON.Version
+	bundle.Proof.ClientPosition = bundleJSON.ClientPosition
+
+	var errField error
+	if bundle.Proof.Commitment, errField = decodeBundleHash(bundleJSON.Commitment, "commitment"); errField != nil {
+		return nil, errField
+	}
+	if bundle.Proof.MerkleRoot, errField = decodeBundleHash(bundleJSON.MerkleRoot, "merkle_root"); errField != nil {
+		return nil, errField
+	}
+	for _, op := range bundleJSON.Ops {
+		opCommitment, errOp := decodeBundleHash(op.Commitment, "ops")
+		if errOp != nil {
+			return nil, errOp
+		}
+		bundle.Proof.Ops = append(bundle.Proof.Ops, CommitmentMerkleProofOp{op.Append, opCommitment})
+	}
+	if bundle.Txid, errField = decodeBundleHash(bundleJSON.Txid, "txid"); errField != nil {
+		return nil, errField
+	}
+
+	rawTx, errHex := hex.DecodeString(bundleJSON.RawTx)
+	if errHex != nil {
+		return nil, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "raw_tx"))
+	}
+	txReader := bytes.NewReader(rawTx)
+	if errTx := bundle.Tx.Deserialize(txReader); errTx != nil || txReader.Len() > 0 {
+		return nil, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "raw_tx"))
+	}
+	if bundle.Script, errHex = hex.DecodeString(bundleJSON.Script); errHex != nil {
+		return nil, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "script"))
+	}
+	if bundle.Pubkey, errHex = hex.DecodeString(bundleJSON.Pubkey); errHex != nil {
+		return nil, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "pubkey"))
+	}
+
+	spvProof, errSpv := NewSpvProofFromStrings(bundleJSON.Header, bundleJSON.TxIndex, bundleJSON.MerkleBranch)
+	if errSpv != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_BUNDLE_FIELD, errSpv))
+	}
+	bundle.Spv = *spvProof
+
+	return validateImportedProofBundle(&bundle)
+}
+
+// Import proof bundle from binary format if data starts
+// with the proof bundle magic bytes or json format otherwise
+func ImportProofBundle(data []byte) (*ProofBundle, error) {
+	if bytes.HasPrefix(data, []byte(PROOF_BUNDLE_MAGIC)) {
+		return ImportProofBundleBinary(data)
+	}
+	return ImportProofBundleJSON(data)
+}
+
+// Export proof bundle to compact binary format
+// Magic and version are followed by the bundle fields in order
+// with variable length fields prefixed by their varint length
+func (b ProofBundle) ExportBinary() ([]byte, error) {
+	if errValidate := b.Validate(); errValidate != nil {
+		return nil, errValidate
+	}
+	var buf bytes.Buffer
+	buf.WriteString(PROOF_BUNDLE_MAGIC)
+	binary.Write(&buf, binary.LittleEndian, b.Version)
+	binary.Write(&buf, binary.LittleEndian, b.Proof.ClientPosition)
+	buf.Write(b.Proof.Commitment[:])
+	buf.Write(b.Proof.MerkleRoot[:])
+	wire.WriteVarInt(&buf, PROOF_BUNDLE_PROTOCOL_VER, uint64(len(b.Proof.Ops)))
+	for _, op := range b.Proof.Ops {
+		if op.Append {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		buf.Write(op.Commitment[:])
+	}
+	buf.Write(b.Txid[:])
+
+	var txBuf bytes.Buffer
+	if errTx := b.Tx.Serialize(&txBuf); errTx != nil {
+		return nil, errTx
+	}
+	wire.WriteVarBytes(&buf, PROOF_BUNDLE_PROTOCOL_VER, txBuf.Bytes())
+	wire.WriteVarBytes(&buf, PROOF_BUNDLE_PROTOCOL_VER, b.Script)
+	wire.WriteVarBytes(&buf, PROOF_BUNDLE_PROTOCOL_VER, b.Pubkey)
+
+	if errHeader := b.Spv.Header.Serialize(&buf); errHeader != nil {
+		return nil, errHeader
+	}
+	binary.Write(&buf, binary.LittleEndian, b.Spv.TxIndex)
+	wire.WriteVarInt(&buf, PROOF_BUNDLE_PROTOCOL_VER, uint64(len(b.Spv.MerkleBranch)))
+	for _, hash := range b.Spv.MerkleBranch {
+		buf.Write(hash[:])
+	}
+	return buf.Bytes(), nil
+}
+
+// Import proof bundle from compact binary format
+// Invalid magic, unsupported versions and trailing data are rejected
+func ImportProofBundleBinary(data []byte) (*ProofBundle, error) {
+	r := bytes.NewReader(data)
+
+	magic := make([]byte, len(PROOF_BUNDLE_MAGIC))
+	if _, errMagic := io.ReadFull(r, magic); errMagic != nil || string(magic) != PROOF_BUNDLE_MAGIC {
+		return nil, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_ENCODING, "magic"))
+	}
+	var bundle ProofBundle
+	if errVersion := binary.Read(r, binary.LittleEndian, &bundle.Version); errVersion != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_BUNDLE_ENCODING, errVersion))
+	}
+	if bundle.Version != PROOF_BUNDLE_VERSION {
+		return nil, errors.New(fmt.Sprintf("%s %d", ERROR_PROOF_BUNDLE_VERSION, bundle.Version))
+	}
+
+	errRead := readProofBundleBinary(r, &bundle)
+	if errRead != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_BUNDLE_ENCODING, errRead))
+	}
+	if r.Len() > 0 {
+		return nil, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_ENCODING, "trailing data"))
+	}
+
+	return validateImportedProofBundle(&bundle)
+}
+
+// Read proof bundle fields following magic and version from binary format
+func readProofBundleBinary(r *bytes.Reader, bundle *ProofBundle) error {
+	if err := binary.Read(r, binary.LittleEndian, &bundle.Proof.ClientPosition); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, bundle.Proof.Commitment[:]); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, bundle.Proof.MerkleRoot[:]); err != nil {
+		return err
+	}
+	numOfOps, err := wire.ReadVarInt(r, PROOF_BUNDLE_PROTOCOL_VER)
+	if err != nil {
+		return err
+	} else if numOfOps > PROOF_BUNDLE_MAX_OPS {
+		return errors.New("too many ops")
+	}
+	for i := uint64(0); i < numOfOps; i++ {
+		var op CommitmentMerkleProofOp
+		appendByte, errByte := r.ReadByte()
+		if errByte != nil {
+			return errByte
+		} else if appendByte > 1 {
+			return errors.New("invalid op append flag")
+		}
+		op.Append = appendByte == 1
+		if _, err := io.ReadFull(r, op.Commitment[:]); err != nil {
+			return err
+		}
+		bundle.Proof.Ops = append(bundle.Proof.Ops, op)
+	}
+	if _, err := io.ReadFull(r, bundle.Txid[:]); err != nil {
+		return err
+	}
+
+	rawTx, err := wire.ReadVarBytes(r, PROOF_BUNDLE_PROTOCOL_VER, PROOF_BUNDLE_MAX_DATA, "raw_tx")
+	if err != nil {
+		return err
+	}
+	txReader := bytes.NewReader(rawTx)
+	if err := bundle.Tx.Deserialize(txReader); err != nil {
+		return err
+	} else if txReader.Len() > 0 {
+		return errors.New("trailing raw_tx data")
+	}
+	if bundle.Script, err = wire.ReadVarBytes(r, PROOF_BUNDLE_PROTOCOL_VER, PROOF_BUNDLE_MAX_REDEEM, "script"); err != nil {
+		return err
+	}
+	if bundle.Pubkey, err = wire.ReadVarBytes(r, PROOF_BUNDLE_PROTOCOL_VER, PROOF_BUNDLE_MAX_PUBKEY, "pubkey"); err != nil {
+		return err
+	}
+
+	if err := bundle.Spv.Header.Deserialize(r); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &bundle.Spv.TxIndex); err != nil {
+		return err
+	}
+	branchLen, err := wire.ReadVarInt(r, PROOF_BUNDLE_PROTOCOL_VER)
+	if err != nil {
+		return err
+	} else if branchLen > PROOF_BUNDLE_MAX_BRANCH {
+		return errors.New("merkle branch too long")
+	}
+	for i := uint64(0); i < branchLen; i++ {
+		var hash chainhash.Hash
+		if _, err := io.ReadFull(r, hash[:]); err != nil {
+			return err
+		}
+		bundle.Spv.MerkleBranch = append(bundle.Spv.MerkleBranch, hash)
+	}
+	return nil
+}
+
+// Normalise empty fields of imported proof bundle and validate
+func validateImportedProofBundle(bundle *ProofBundle) (*ProofBundle, error) {
+	if len(bundle.Script) == 0 {
+		bundle.Script = nil
+	}
+	if len(bundle.Pubkey) == 0 {
+		bundle.Pubkey = nil
+	}
+	if errValidate := bundle.Validate(); errValidate != nil {
+		return nil, errValidate
+	}
+	return bundle, nil
+}
+
+// Decode 32 byte hex hash string of proof bundle field
+func decodeBundleHash(hashStr string, field string) (chainhash.Hash, error) {
+	hash, errHash := chainhash.NewHashFromStr(hashStr)
+	if errHash != nil || len(hashStr) != chainhash.MaxHashStringSize {
+		return chainhash.Hash{}, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, field))
+	}
+	return *hash, nil
+}
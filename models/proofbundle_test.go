@@ -0,0 +1,148 @@
+package models
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// Build test proof bundle for a 1-of-2 multisig base script
+func buildTestProofBundle(t *testing.T) *ProofBundle {
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashZ, _ := chainhash.NewHashFromStr("ccccccc1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := NewCommitment([]chainhash.Hash{*hashX, *hashY, *hashZ})
+
+	_, pub0 := btcec.PrivKeyFromBytes(btcec.S256(), bytes.Repeat([]byte{1}, 32))
+	_, pub1 := btcec.PrivKeyFromBytes(btcec.S256(), bytes.Repeat([]byte{2}, 32))
+	script := []byte{0x51, 0x21}
+	script = append(script, pub0.SerializeCompressed()...)
+	script = append(script, 0x21)
+	script = append(script, pub1.SerializeCompressed()...)
+	script = append(script, 0x52, 0xae)
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hashX, 0), []byte{0x00}, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0xa9, 0x14}))
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxOut(wire.NewTxOut(0, []byte{0x51}))
+	block := wire.NewMsgBlock(wire.NewBlockHeader(1, &chainhash.Hash{}, &chainhash.Hash{}, 0x207fffff, 1))
+	block.AddTransaction(coinbase)
+	block.AddTransaction(tx)
+	spvProof, _ := NewSpvProof(block, tx.TxHash())
+
+	slotProof := SlotProof{Txid: tx.TxHash(), Confirmed: true, Proof: commitment.GetMerkleProofs()[1], Spv: spvProof}
+	bundle, errBundle := NewProofBundle(slotProof, *tx, script, nil)
+	assert.Equal(t, nil, errBundle)
+	return bundle
+}
+
+// Test ProofBundle construction and validation
+func TestProofBundle(t *testing.T) {
+	bundle := buildTestProofBundle(t)
+	assert.Equal(t, uint32(PROOF_BUNDLE_VERSION), bundle.Version)
+	assert.Equal(t, nil, bundle.Validate())
+
+	// missing spv proof
+	_, errBundle := NewProofBundle(SlotProof{Txid: bundle.Txid, Proof: bundle.Proof}, bundle.Tx, bundle.Script, nil)
+	assert.Equal(t, errors.New(ERROR_PROOF_BUNDLE_SPV_MISSING), errBundle)
+
+	// invalid fields
+	invalid := *bundle
+	invalid.Version = 2
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_PROOF_BUNDLE_VERSION, 2)), invalid.Validate())
+	invalid = *bundle
+	invalid.Txid = chainhash.Hash{}
+	assert.Equal(t, errors.New(ERROR_PROOF_BUNDLE_TXID), invalid.Validate())
+	invalid = *bundle
+	invalid.Proof.ClientPosition = -1
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "position")), invalid.Validate())
+	invalid = *bundle
+	invalid.Proof.Ops = make([]CommitmentMerkleProofOp, PROOF_BUNDLE_MAX_OPS+1)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "ops")), invalid.Validate())
+	invalid = *bundle
+	invalid.Pubkey = []byte{2, 3}
+	assert.Equal(t, errors.New(ERROR_PROOF_BUNDLE_TWEAK), invalid.Validate())
+	invalid.Script = nil
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "pubkey")), invalid.Validate())
+	invalid = *bundle
+	invalid.Script = []byte{0x51}
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "script")), invalid.Validate())
+	invalid = *bundle
+	invalid.Spv.TxIndex = 2
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "tx_index")), invalid.Validate())
+}
+
+// Test ProofBundle json format export and strict import
+func TestProofBundleJSON(t *testing.T) {
+	bundle := buildTestProofBundle(t)
+
+	data, errExport := bundle.ExportJSON()
+	assert.Equal(t, nil, errExport)
+	testBundle, errImport := ImportProofBundleJSON(data)
+	assert.Equal(t, nil, errImport)
+	assert.Equal(t, bundle, testBundle)
+
+	// unknown field, trailing data and unsupported version
+	_, errImport = ImportProofBundleJSON([]byte(strings.Replace(string(data), "\"txid\"", "\"tx\"", 1)))
+	assert.Equal(t, true, strings.HasPrefix(errImport.Error(), ERROR_PROOF_BUNDLE_ENCODING))
+	_, errImport = ImportProofBundleJSON(append(data, []byte("{}")...))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_ENCODING, "trailing data")), errImport)
+	_, errImport = ImportProofBundleJSON([]byte(strings.Replace(string(data), "\"version\": 1", "\"version\": 9", 1)))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_PROOF_BUNDLE_VERSION, 9)), errImport)
+
+	// invalid hash and raw tx fields
+	root := bundle.Proof.MerkleRoot.String()
+	_, errImport = ImportProofBundleJSON([]byte(strings.Replace(string(data), root, root[2:], 1)))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "merkle_root")), errImport)
+	var txBuf bytes.Buffer
+	bundle.Tx.Serialize(&txBuf)
+	rawTx := hex.EncodeToString(txBuf.Bytes())
+	_, errImport = ImportProofBundleJSON([]byte(strings.Replace(string(data), rawTx, rawTx+"00", 1)))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_FIELD, "raw_tx")), errImport)
+}
+
+// Test ProofBundle binary format export and strict import
+func TestProofBundleBinary(t *testing.T) {
+	bundle := buildTestProofBundle(t)
+
+	data, errExport := bundle.ExportBinary()
+	assert.Equal(t, nil, errExport)
+	assert.Equal(t, PROOF_BUNDLE_MAGIC, string(data[:4]))
+	testBundle, errImport := ImportProofBundleBinary(data)
+	assert.Equal(t, nil, errImport)
+	assert.Equal(t, bundle, testBundle)
+
+	// invalid magic, version, truncated and trailing data
+	_, errImport = ImportProofBundleBinary(append([]byte("XXXX"), data[4:]...))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_ENCODING, "magic")), errImport)
+	badVersion := append([]byte{}, data...)
+	badVersion[4] = 9
+	_, errImport = ImportProofBundleBinary(badVersion)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_PROOF_BUNDLE_VERSION, 9)), errImport)
+	_, errImport = ImportProofBundleBinary(data[:len(data)-1])
+	assert.Equal(t, true, strings.HasPrefix(errImport.Error(), ERROR_PROOF_BUNDLE_ENCODING))
+	_, errImport = ImportProofBundleBinary(append(data, 0))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_BUNDLE_ENCODING, "trailing data")), errImport)
+
+	// json and binary formats hold the same bundle
+	jsonData, _ := bundle.ExportJSON()
+	jsonBundle, _ := ImportProofBundleJSON(jsonData)
+	assert.Equal(t, testBundle, jsonBundle)
+
+	// format detected on import
+	testBundle, errImport = ImportProofBundle(data)
+	assert.Equal(t, nil, errImport)
+	assert.Equal(t, bundle, testBundle)
+	testBundle, errImport = ImportProofBundle(jsonData)
+	assert.Equal(t, nil, errImport)
+	assert.Equal(t, bundle, testBundle)
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// SlotProof structure
+// Merkle proof of a client commitment in a commitment merkle tree
+// along with details of the attestation that committed the merkle root
+// Blockhash and Spv are only set for confirmed attestations
+type SlotProof struct {
+	Txid      chainhash.Hash
+	Blockhash chainhash.Hash
+	Confirmed bool
+	Proof     CommitmentMerkleProof
+	Spv       *SpvProof
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// error consts
+const (
+	ERROR_SPV_TX_MISSING = "Transaction missing from block"
+	ERROR_SPV_HEADER     = "Invalid block header"
+	ERROR_SPV_BRANCH     = "Invalid transaction merkle branch"
+)
+
+// SpvProof structure
+// Bitcoin SPV proof of inclusion of a transaction in a block
+// Consists of the block header, the transaction index in the
+// block and the merkle branch from the transaction to the
+// merkle root of the block header
+type SpvProof struct {
+	Header       wire.BlockHeader
+	TxIndex      int32
+	MerkleBranch []chainhash.Hash
+}
+
+// Build SPV proof for transaction with txid in the block provided
+func NewSpvProof(block *wire.MsgBlock, txid chainhash.Hash) (*SpvProof, error) {
+	var hashes []chainhash.Hash
+	index := -1
+	for i, tx := range block.Transactions {
+		hashes = append(hashes, tx.TxHash())
+		if hashes[i] == txid {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, errors.New(fmt.Sprintf("%s %s", ERROR_SPV_TX_MISSING, txid.String()))
+	}
+
+	// iterate through each tree height adding the sibling
+	// of the current node to the branch - odd number of
+	// nodes at a height are padded by duplicating the last
+	var branch []chainhash.Hash
+	position := index
+	for len(hashes) > 1 {
+		if len(hashes)%2 == 1 {
+			hashes = append(hashes, hashes[len(hashes)-1])
+		}
+		branch = append(branch, hashes[position^1])
+
+		var next []chainhash.Hash
+		for i := 0; i < len(hashes); i += 2 {
+			next = append(next, *hashLeaves(hashes[i], hashes[i+1]))
+		}
+		hashes = next
+		position /= 2
+	}
+	return &SpvProof{block.Header, int32(index), branch}, nil
+}
+
+// Get block hash of the SPV proof header
+func (p SpvProof) Blockhash() chainhash.Hash {
+	return p.Header.BlockHash()
+}
+
+// Prove transaction with txid is included in the block of the SPV proof header
+func (p SpvProof) Prove(txid chainhash.Hash) bool {
+	if p.TxIndex < 0 || len(p.MerkleBranch) > 31 || p.TxIndex >= 1<<uint(len(p.MerkleBranch)) {
+		return false
+	}
+	hash := txid
+	position := p.TxIndex
+	for _, sibling := range p.MerkleBranch {
+		if position%2 == 0 {
+			hash = *hashLeaves(hash, sibling)
+		} else {
+			hash = *hashLeaves(sibling, hash)
+		}
+		position /= 2
+	}
+	return hash == p.Header.MerkleRoot
+}
+
+// Get serialized block header hex string
+func (p SpvProof) HeaderHex() string {
+	var buf bytes.Buffer
+	p.Header.Serialize(&buf)
+	return hex.EncodeToString(buf.Bytes())
+}
+
+// Get merkle branch hash strings
+func (p SpvProof) MerkleBranchStrings() []string {
+	branch := []string{}
+	for _, hash := range p.MerkleBranch {
+		branch = append(branch, hash.String())
+	}
+	return branch
+}
+
+// Build SPV proof from serialized header hex and merkle branch hash strings
+func NewSpvProofFromStrings(headerHex string, txIndex int32, branch []string) (*SpvProof, error) {
+	headerBytes, errHex := hex.DecodeString(headerHex)
+	if errHex != nil || len(headerBytes) != wire.MaxBlockHeaderPayload {
+		return nil, errors.New(ERROR_SPV_HEADER)
+	}
+	var header wire.BlockHeader
+	if errHeader := header.Deserialize(bytes.NewReader(headerBytes)); errHeader != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_SPV_HEADER, errHeader))
+	}
+
+	var merkleBranch []chainhash.Hash
+	for _, hashStr := range branch {
+		hash, errHash := chainhash.NewHashFromStr(hashStr)
+		if errHash != nil || len(hashStr) != chainhash.MaxHashStringSize {
+			return nil, errors.New(ERROR_SPV_BRANCH)
+		}
+		merkleBranch = append(merkleBranch, *hash)
+	}
+	return &SpvProof{header, txIndex, merkleBranch}, nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Build test block with the number of transactions provided
+func buildSpvTestBlock(numOfTxs int) *wire.MsgBlock {
+	var txs []*btcutil.Tx
+	for i := 0; i < numOfTxs; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxOut(wire.NewTxOut(int64(i), []byte{}))
+		txs = append(txs, btcutil.NewTx(tx))
+	}
+	store := blockchain.BuildMerkleTreeStore(txs, false)
+	block := wire.NewMsgBlock(wire.NewBlockHeader(1, &chainhash.Hash{}, store[len(store)-1], 0x207fffff, 1))
+	for _, tx := range txs {
+		block.AddTransaction(tx.MsgTx())
+	}
+	return block
+}
+
+// Test SpvProof build and prove for all transactions of blocks of various sizes
+func TestSpvProof(t *testing.T) {
+	for numOfTxs := 1; numOfTxs <= 9; numOfTxs++ {
+		block := buildSpvTestBlock(numOfTxs)
+		for i, tx := range block.Transactions {
+			txid := tx.TxHash()
+			spvProof, errSpv := NewSpvProof(block, txid)
+			assert.Equal(t, nil, errSpv)
+			assert.Equal(t, int32(i), spvProof.TxIndex)
+			assert.Equal(t, block.BlockHash(), spvProof.Blockhash())
+			assert.Equal(t, true, spvProof.Prove(txid), fmt.Sprintf("%d txs, index %d", numOfTxs, i))
+
+			// proof fails for other txid or index
+			assert.Equal(t, false, spvProof.Prove(chainhash.Hash{}))
+			if numOfTxs > 1 {
+				wrongIndex := *spvProof
+				wrongIndex.TxIndex = int32((i + 1) % numOfTxs)
+				assert.Equal(t, false, wrongIndex.Prove(txid))
+			}
+		}
+	}
+
+	// transaction missing from block
+	block := buildSpvTestBlock(3)
+	_, errSpv := NewSpvProof(block, chainhash.Hash{})
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_SPV_TX_MISSING, chainhash.Hash{}.String())), errSpv)
+
+	// serialization round trip
+	txid := block.Transactions[1].TxHash()
+	spvProof, _ := NewSpvProof(block, txid)
+	testProof, errStrings := NewSpvProofFromStrings(spvProof.HeaderHex(), spvProof.TxIndex, spvProof.MerkleBranchStrings())
+	assert.Equal(t, nil, errStrings)
+	assert.Equal(t, spvProof, testProof)
+	assert.Equal(t, true, testProof.Prove(txid))
+}
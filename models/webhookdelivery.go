@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/mongodb/mongo-go-driver/bson"
+)
+
+// struct for db WebhookDelivery
+// Record of a client webhook notification of a confirmed attestation,
+// saved pending along with the confirmed attestation and kept as a dead
+// letter if all attempts fail. Pubkey identifies the client notified, so
+// that a reused position is not sent the notifications of a revoked client.
+// Attempts counts all failed attempts including redeliveries, with the
+// error of the latest attempt. Delivered is set once delivered or
+// redelivered and the record is then kept
+type WebhookDelivery struct {
+	Txid           chainhash.Hash
+	ClientPosition int32
+	Pubkey         string
+	Url            string
+	Attempts       int32
+	LastError      string
+	FailedAt       time.Time
+	Delivered      bool
+}
+
+// Check if the delivery is pending, i.e. neither delivered nor failed
+func (w WebhookDelivery) Pending() bool {
+	return !w.Delivered && w.FailedAt.IsZero()
+}
+
+// Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
+func (w WebhookDelivery) MarshalBSON() ([]byte, error) {
+	deliveryBSON := WebhookDeliveryBSON{
+		Txid:           w.Txid.String(),
+		ClientPosition: w.ClientPosition,
+		Pubkey:         w.Pubkey,
+		Url:            w.Url,
+		Attempts:       w.Attempts,
+		LastError:      w.LastError,
+		FailedAt:       w.FailedAt,
+		Delivered:      w.Delivered}
+	return bson.Marshal(deliveryBSON)
+}
+
+// Implement bson.Unmarshaler UnmarshalJSON() method for use with db_mongo interface
+func (w *WebhookDelivery) UnmarshalBSON(b []byte) error {
+	var deliveryBSON WebhookDeliveryBSON
+	if err := bson.Unmarshal(b, &deliveryBSON); err != nil {
+		return err
+	}
+	txidHash, errHash := chainhash.NewHashFromStr(deliveryBSON.Txid)
+	if errHash != nil {
+		return errHash
+	}
+	w.Txid = *txidHash
+	w.ClientPosition = deliveryBSON.ClientPosition
+	w.Pubkey = deliveryBSON.Pubkey
+	w.Url = deliveryBSON.Url
+	w.Attempts = deliveryBSON.Attempts
+	w.LastError = deliveryBSON.LastError
+	w.FailedAt = deliveryBSON.FailedAt.UTC()
+	w.Delivered = deliveryBSON.Delivered
+	return nil
+}
+
+// WebhookDelivery field names
+const (
+	WEBHOOK_DELIVERY_TXID_NAME            = "txid"
+	WEBHOOK_DELIVERY_CLIENT_POSITION_NAME = "client_position"
+	WEBHOOK_DELIVERY_PUBKEY_NAME          = "pubkey"
+	WEBHOOK_DELIVERY_URL_NAME             = "url"
+	WEBHOOK_DELIVERY_ATTEMPTS_NAME        = "attempts"
+	WEBHOOK_DELIVERY_LAST_ERROR_NAME      = "last_error"
+	WEBHOOK_DELIVERY_FAILED_AT_NAME       = "failed_at"
+	WEBHOOK_DELIVERY_DELIVERED_NAME       = "delivered"
+)
+
+// WebhookDeliveryBSON structure for mongoDB
+type WebhookDeliveryBSON struct {
+	Txid           string    `bson:"txid"`
+	ClientPosition int32     `bson:"client_position"`
+	Pubkey         string    `bson:"pubkey"`
+	Url            string    `bson:"url"`
+	Attempts       int32     `bson:"attempts"`
+	LastError      string    `bson:"last_error"`
+	FailedAt       time.Time `bson:"failed_at"`
+	Delivered      bool      `bson:"delivered"`
+}
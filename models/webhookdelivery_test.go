@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test WebhookDelivery BSON interface
+func TestWebhookDeliveryBSON(t *testing.T) {
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	delivery := WebhookDelivery{
+		Txid:           *txid,
+		ClientPosition: int32(3),
+		Pubkey:         "pubkey3",
+		Url:            "https://client.example.com/webhook",
+		Attempts:       int32(5),
+		LastError:      "webhook response status 500",
+		FailedAt:       time.Unix(1542121293, 0).UTC()}
+
+	doc, docErr := GetDocumentFromModel(delivery)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, txid.String(), doc.Lookup(WEBHOOK_DELIVERY_TXID_NAME).StringValue())
+	assert.Equal(t, delivery.ClientPosition, doc.Lookup(WEBHOOK_DELIVERY_CLIENT_POSITION_NAME).Int32())
+	assert.Equal(t, delivery.Pubkey, doc.Lookup(WEBHOOK_DELIVERY_PUBKEY_NAME).StringValue())
+	assert.Equal(t, delivery.Url, doc.Lookup(WEBHOOK_DELIVERY_URL_NAME).StringValue())
+	assert.Equal(t, delivery.Attempts, doc.Lookup(WEBHOOK_DELIVERY_ATTEMPTS_NAME).Int32())
+	assert.Equal(t, delivery.LastError, doc.Lookup(WEBHOOK_DELIVERY_LAST_ERROR_NAME).StringValue())
+	assert.Equal(t, false, doc.Lookup(WEBHOOK_DELIVERY_DELIVERED_NAME).Boolean())
+
+	testDelivery := &WebhookDelivery{}
+	docErr = GetModelFromDocument(doc, testDelivery)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, delivery, *testDelivery)
+
+	// delivered round trip
+	delivery.Delivered = true
+	doc, _ = GetDocumentFromModel(delivery)
+	testDelivery = &WebhookDelivery{}
+	docErr = GetModelFromDocument(doc, testDelivery)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, delivery, *testDelivery)
+}
@@ -0,0 +1,7 @@
+/*
+Package requestapi implements the request api service that handles client requests.
+
+Clients submit signed commitments to their slot through the api, which are
+verified and stored in the server for inclusion in the next attestation.
+*/
+package requestapi
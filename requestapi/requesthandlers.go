@@ -0,0 +1,432 @@
+package requestapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"mainstay/models"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/gorilla/mux"
+)
+
+// Http handlers for service requests
+
+// request headers
+const (
+	HEADER_AUTH_TOKEN    = "AUTH-TOKEN"
+	HEADER_LAST_EVENT_ID = "Last-Event-ID"
+)
+
+// error consts
+const (
+	ERROR_REQUEST_BODY       = "Invalid request body"
+	ERROR_REQUEST_COMMITMENT = "Invalid commitment - 32 byte hex string required"
+	ERROR_REQUEST_SIGNATURE  = "Missing commitment signature"
+	ERROR_REQUEST_POSITION   = "Invalid client position"
+	ERROR_REQUEST_TXID       = "Invalid txid - 32 byte hex string required"
+	ERROR_REQUEST_ROOT       = "Invalid merkle root - 32 byte hex string required"
+	ERROR_REQUEST_QUERY      = "Invalid query parameter"
+	ERROR_REQUEST_STREAM     = "Streaming not supported"
+	ERROR_REQUEST_STARTED_AT = "Invalid client start time"
+)
+
+// attestations query parameters
+const (
+	QUERY_PAGE        = "page"
+	QUERY_LIMIT       = "limit"
+	QUERY_CONFIRMED   = "confirmed"
+	QUERY_MERKLE_ROOT = "merkle_root"
+	QUERY_FROM_TIME   = "from_time"
+	QUERY_TO_TIME     = "to_time"
+	QUERY_FROM_HEIGHT = "from_height"
+	QUERY_TO_HEIGHT   = "to_height"
+)
+
+// event stream query parameters
+const (
+	QUERY_CLIENT_POSITION = "client_position"
+	QUERY_CURSOR          = "cursor"
+)
+
+// event stream timings
+const (
+	// max duration of a single event stream response, kept below the
+	// request service write timeout after which the stream is cut
+	EVENT_STREAM_DURATION = 10 * time.Second
+
+	// client reconnection delay in milliseconds after a stream ends
+	EVENT_STREAM_RETRY = 1000
+)
+
+// event stream duration - can be replaced for testing
+var eventStreamDuration = EVENT_STREAM_DURATION
+
+// CommitmentSendRequest for ROUTE_COMMITMENT_SEND
+// Commitment is the hex string of the 32 byte commitment and
+// Signature the base64 signature of the commitment hex string
+type CommitmentSendRequest struct {
+	ClientPosition int32  `json:"position"`
+	Commitment     string `json:"commitment"`
+	Signature      string `json:"signature"`
+}
+
+// Index request handler
+func HandleIndex(w http.ResponseWriter, r *http.Request, server *server.Server) {
+	fmt.Fprintln(w, "Request Service for Mainstay Attestations!")
+}
+
+// Commitment Send request handler
+// Verify client auth token and commitment signature and store commitment
+func HandleCommitmentSend(w http.ResponseWriter, r *http.Request, srv *server.Server) {
+	var request CommitmentSendRequest
+	if errDecode := json.NewDecoder(r.Body).Decode(&request); errDecode != nil {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_BODY)
+		return
+	}
+	if len(request.Commitment) != chainhash.MaxHashStringSize {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_COMMITMENT)
+		return
+	}
+	commitmentHash, errHash := chainhash.NewHashFromStr(request.Commitment)
+	if errHash != nil {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_COMMITMENT)
+		return
+	}
+	if request.Signature == "" {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_SIGNATURE)
+		return
+	}
+
+	commitment := models.ClientCommitment{Commitment: *commitmentHash, ClientPosition: request.ClientPosition}
+	errUpdate := srv.UpdateClientCommitment(commitment, r.Header.Get(HEADER_AUTH_TOKEN), request.Signature)
+	if errUpdate != nil {
+		writeError(w, updateErrorStatus(errUpdate), errUpdate.Error())
+		return
+	}
+	writeResponse(w, http.StatusOK, CommitmentSendResponse{
+		ClientPosition: commitment.ClientPosition,
+		Commitment:     commitment.Commitment.String()})
+}
+
+// Return http status for client commitment update errors
+func updateErrorStatus(err error) int {
+	switch {
+	case strings.HasPrefix(err.Error(), server.ERROR_CLIENT_DETAILS_MISSING):
+		return http.StatusNotFound
+	case strings.HasPrefix(err.Error(), server.ERROR_CLIENT_AUTH_TOKEN):
+		return http.StatusUnauthorized
+	case strings.HasPrefix(err.Error(), server.ERROR_CLIENT_SIGNATURE),
+		strings.HasPrefix(err.Error(), server.ERROR_CLIENT_NOT_ACTIVE):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// Latest Proof request handler
+// Return slot proof for position in the latest confirmed attestation
+func HandleProofLatest(w http.ResponseWriter, r *http.Request, srv *server.Server) {
+	position, errPosition := requestPosition(r)
+	if errPosition != nil {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_POSITION)
+		return
+	}
+	slotProof, errProof := srv.GetLatestSlotProof(position)
+	writeSlotProof(w, slotProof, errProof)
+}
+
+// Txid Proof request handler
+// Return slot proof for position in the attestation with the requested txid
+func HandleProofTxid(w http.ResponseWriter, r *http.Request, srv *server.Server) {
+	position, errPosition := requestPosition(r)
+	if errPosition != nil {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_POSITION)
+		return
+	}
+	txid, errTxid := requestHash(r, "txid")
+	if errTxid != nil {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_TXID)
+		return
+	}
+	slotProof, errProof := srv.GetAttestationSlotProof(txid, position)
+	writeSlotProof(w, slotProof, errProof)
+}
+
+// Merkle Root Proof request handler
+// Return slot proof for position in the attestation of the requested merkle root
+func HandleProofMerkleRoot(w http.ResponseWriter, r *http.Request, srv *server.Server) {
+	position, errPosition := requestPosition(r)
+	if errPosition != nil {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_POSITION)
+		return
+	}
+	merkleRoot, errRoot := requestHash(r, "merkleroot")
+	if errRoot != nil {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_ROOT)
+		return
+	}
+	slotProof, errProof := srv.GetMerkleRootSlotProof(merkleRoot, position)
+	writeSlotProof(w, slotProof, errProof)
+}
+
+// Attestations request handler
+// Return page of the attestation history matching the query filters
+func HandleAttestations(w http.ResponseWriter, r *http.Request, srv *server.Server) {
+	query := r.URL.Query()
+	page := int64(1)
+	limit := int64(server.ATTESTATIONS_LIMIT_DEFAULT)
+	var filter server.AttestationFilter
+
+	errQuery := parseQueryInts(query,
+		queryInt{QUERY_PAGE, &page},
+		queryInt{QUERY_LIMIT, &limit},
+		queryInt{QUERY_FROM_TIME, &filter.FromTime},
+		queryInt{QUERY_TO_TIME, &filter.ToTime},
+		queryInt{QUERY_FROM_HEIGHT, &filter.FromHeight},
+		queryInt{QUERY_TO_HEIGHT, &filter.ToHeight})
+	if errQuery != nil {
+		writeError(w, http.StatusBadRequest, errQuery.Error())
+		return
+	}
+	if query.Get(QUERY_CONFIRMED) != "" {
+		confirmed, errParse := strconv.ParseBool(query.Get(QUERY_CONFIRMED))
+		if errParse != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s %s", ERROR_REQUEST_QUERY, QUERY_CONFIRMED))
+			return
+		}
+		filter.Confirmed = &confirmed
+	}
+	if query.Get(QUERY_MERKLE_ROOT) != "" {
+		merkleRoot, errRoot := parseHash(query.Get(QUERY_MERKLE_ROOT))
+		if errRoot != nil {
+			writeError(w, http.StatusBadRequest, ERROR_REQUEST_ROOT)
+			return
+		}
+		filter.MerkleRoot = &merkleRoot
+	}
+
+	attestations, errAttestations := srv.GetAttestations(filter, page, limit)
+	if errAttestations != nil {
+		writeError(w, pageErrorStatus(errAttestations), errAttestations.Error())
+		return
+	}
+	emptySlots := make([][]int32, len(attestations))
+	for i, attestation := range attestations {
+		var errSlots error
+		if emptySlots[i], errSlots = srv.GetAttestationEmptySlots(attestation); errSlots != nil {
+			writeError(w, http.StatusInternalServerError, errSlots.Error())
+			return
+		}
+	}
+	writeResponse(w, http.StatusOK, newAttestationsResponse(attestations, emptySlots, page, limit))
+}
+
+// Commitment History request handler
+// Return page of the commitment history of the requested client position
+func HandleCommitmentHistory(w http.ResponseWriter, r *http.Request, srv *server.Server) {
+	position, errPosition := requestPosition(r)
+	if errPosition != nil {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_POSITION)
+		return
+	}
+	page := int64(1)
+	limit := int64(server.ATTESTATIONS_LIMIT_DEFAULT)
+	errQuery := parseQueryInts(r.URL.Query(), queryInt{QUERY_PAGE, &page}, queryInt{QUERY_LIMIT, &limit})
+	if errQuery != nil {
+		writeError(w, http.StatusBadRequest, errQuery.Error())
+		return
+	}
+
+	history, errHistory := srv.GetClientCommitmentHistory(position, page, limit)
+	if errHistory != nil {
+		writeError(w, pageErrorStatus(errHistory), errHistory.Error())
+		return
+	}
+	writeResponse(w, http.StatusOK, newCommitmentHistoryResponse(history, position, page, limit))
+}
+
+// Revoked Commitment History request handler
+// Return page of the commitment history of the revoked client that
+// started at the requested time at the requested client position
+func HandleRevokedCommitmentHistory(w http.ResponseWriter, r *http.Request, srv *server.Server) {
+	position, errPosition := requestPosition(r)
+	if errPosition != nil {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_POSITION)
+		return
+	}
+	startedAt, errStartedAt := strconv.ParseInt(mux.Vars(r)["startedat"], 10, 64)
+	if errStartedAt != nil || startedAt < 0 {
+		writeError(w, http.StatusBadRequest, ERROR_REQUEST_STARTED_AT)
+		return
+	}
+	page := int64(1)
+	limit := int64(server.ATTESTATIONS_LIMIT_DEFAULT)
+	errQuery := parseQueryInts(r.URL.Query(), queryInt{QUERY_PAGE, &page}, queryInt{QUERY_LIMIT, &limit})
+	if errQuery != nil {
+		writeError(w, http.StatusBadRequest, errQuery.Error())
+		return
+	}
+
+	history, errHistory := srv.GetRevokedClientCommitmentHistory(position, startedAt, page, limit)
+	if errHistory != nil {
+		writeError(w, pageErrorStatus(errHistory), errHistory.Error())
+		return
+	}
+	writeResponse(w, http.StatusOK, newCommitmentHistoryResponse(history, position, page, limit))
+}
+
+// Events request handler
+// Stream attestation lifecycle events as server-sent events, optionally
+// filtered by client position. Streams resume after the cursor query
+// parameter or the Last-Event-ID header sent by clients on reconnecting
+// and start with new events otherwise. Each stream ends after
+// EVENT_STREAM_DURATION and clients reconnect to resume from the last id
+func HandleEvents(w http.ResponseWriter, r *http.Request, srv *server.Server) {
+	query := r.URL.Query()
+	position := int64(-1)
+	cursor := srv.Events().Cursor()
+	if r.Header.Get(HEADER_LAST_EVENT_ID) != "" {
+		query.Set(QUERY_CURSOR, r.Header.Get(HEADER_LAST_EVENT_ID))
+	}
+	errQuery := parseQueryInts(query, queryInt{QUERY_CLIENT_POSITION, &position}, queryInt{QUERY_CURSOR, &cursor})
+	if errQuery == nil && position > math.MaxInt32 {
+		errQuery = errors.New(fmt.Sprintf("%s %s", ERROR_REQUEST_QUERY, QUERY_CLIENT_POSITION))
+	}
+	if errQuery != nil {
+		writeError(w, http.StatusBadRequest, errQuery.Error())
+		return
+	}
+	flusher, isFlusher := w.(http.Flusher)
+	if !isFlusher {
+		writeError(w, http.StatusInternalServerError, ERROR_REQUEST_STREAM)
+		return
+	}
+	events, notify, errEvents := srv.Events().Since(cursor)
+	if errEvents != nil {
+		writeError(w, http.StatusGone, errEvents.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", EVENT_STREAM_RETRY)
+
+	timeout := time.After(eventStreamDuration)
+	for {
+		for i, event := range events {
+			if position < 0 || event.HasPosition(int32(position)) {
+				data, _ := json.Marshal(newEventResponse(event, int32(position)))
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+			} else if i == len(events)-1 {
+				// move client cursor past filtered events without dispatching
+				fmt.Fprintf(w, "id: %d\n\n", event.Id)
+			}
+			cursor = event.Id
+		}
+		flusher.Flush()
+
+		select {
+		case <-notify:
+		case <-timeout:
+			return
+		case <-r.Context().Done():
+			return
+		}
+		events, notify, errEvents = srv.Events().Since(cursor)
+		if errEvents != nil {
+			return // client resumes with an expired cursor and is notified
+		}
+	}
+}
+
+// Return http status for history query errors
+func pageErrorStatus(err error) int {
+	switch {
+	case strings.HasPrefix(err.Error(), server.ERROR_ATTESTATIONS_PAGE),
+		strings.HasPrefix(err.Error(), server.ERROR_ATTESTATIONS_LIMIT):
+		return http.StatusBadRequest
+	case strings.HasPrefix(err.Error(), server.ERROR_CLIENT_REVOKED_MISSING):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// queryInt for a non negative integer query parameter and its value
+type queryInt struct {
+	name  string
+	value *int64
+}
+
+// Parse non negative integer query parameters in order into their values
+// Parameters not set in the query keep their default value
+func parseQueryInts(query url.Values, params ...queryInt) error {
+	for _, param := range params {
+		if query.Get(param.name) == "" {
+			continue
+		}
+		parsed, errParse := strconv.ParseInt(query.Get(param.name), 10, 64)
+		if errParse != nil || parsed < 0 {
+			return errors.New(fmt.Sprintf("%s %s", ERROR_REQUEST_QUERY, param.name))
+		}
+		*param.value = parsed
+	}
+	return nil
+}
+
+// Parse non negative client position from request path
+func requestPosition(r *http.Request) (int32, error) {
+	position, errParse := strconv.ParseInt(mux.Vars(r)["position"], 10, 32)
+	if errParse != nil {
+		return 0, errParse
+	} else if position < 0 {
+		return 0, errors.New(ERROR_REQUEST_POSITION)
+	}
+	return int32(position), nil
+}
+
+// Parse 32 byte hex hash from request path
+func requestHash(r *http.Request, name string) (chainhash.Hash, error) {
+	return parseHash(mux.Vars(r)[name])
+}
+
+// Parse 32 byte hex hash string
+func parseHash(hashStr string) (chainhash.Hash, error) {
+	if len(hashStr) != chainhash.MaxHashStringSize {
+		return chainhash.Hash{}, chainhash.ErrHashStrSize
+	}
+	hash, errHash := chainhash.NewHashFromStr(hashStr)
+	if errHash != nil {
+		return chainhash.Hash{}, errHash
+	}
+	return *hash, nil
+}
+
+// Write slot proof response or error for slot proof requests
+func writeSlotProof(w http.ResponseWriter, slotProof models.SlotProof, err error) {
+	if err != nil {
+		writeError(w, proofErrorStatus(err), err.Error())
+		return
+	}
+	writeResponse(w, http.StatusOK, NewSlotProofResponse(slotProof))
+}
+
+// Return http status for slot proof retrieval errors
+func proofErrorStatus(err error) int {
+	switch {
+	case strings.HasPrefix(err.Error(), server.ERROR_ATTESTATION_MISSING),
+		strings.HasPrefix(err.Error(), server.ERROR_ATTESTATION_GET),
+		strings.HasPrefix(err.Error(), server.ERROR_ATTESTATION_INFO_GET),
+		strings.HasPrefix(err.Error(), server.ERROR_MERKLE_PROOF_GET):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,456 @@
+package requestapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mainstay/crypto"
+	"mainstay/models"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Send commitment request to router and return response
+func sendCommitment(router http.Handler, token string, request CommitmentSendRequest) (int, CommitmentSendResponse) {
+	body, _ := json.Marshal(request)
+	req := httptest.NewRequest(POST, ROUTE_COMMITMENT_SEND, bytes.NewReader(body))
+	req.Header.Set(HEADER_AUTH_TOKEN, token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var response CommitmentSendResponse
+	json.NewDecoder(rec.Body).Decode(&response)
+	return rec.Code, response
+}
+
+// Test Commitment Send request handler
+func TestHandleCommitmentSend(t *testing.T) {
+	dbFake := server.NewDbFake()
+	srv := server.NewServer(context.Background(), dbFake)
+	router := NewRouter(srv)
+
+	privKey, _ := btcutil.DecodeWIF("cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz")
+	dbFake.SetClientDetails([]models.ClientDetails{models.ClientDetails{ClientPosition: 0, AuthToken: "token0",
+		Pubkey: "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33"}})
+
+	commitment := "aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	sig, _ := crypto.SignMessage(privKey, commitment)
+
+	// invalid body
+	req := httptest.NewRequest(POST, ROUTE_COMMITMENT_SEND, bytes.NewReader([]byte("{")))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// invalid commitment and signature
+	code, response := sendCommitment(router, "token0", CommitmentSendRequest{0, commitment[2:], sig})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ERROR_REQUEST_COMMITMENT, response.Error)
+	code, response = sendCommitment(router, "token0", CommitmentSendRequest{0, "zz" + commitment[2:], sig})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ERROR_REQUEST_COMMITMENT, response.Error)
+	code, response = sendCommitment(router, "token0", CommitmentSendRequest{0, commitment, ""})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ERROR_REQUEST_SIGNATURE, response.Error)
+
+	// invalid client details
+	code, response = sendCommitment(router, "token0", CommitmentSendRequest{1, commitment, sig})
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, fmt.Sprintf("%s %d", server.ERROR_CLIENT_DETAILS_MISSING, 1), response.Error)
+	code, response = sendCommitment(router, "token1", CommitmentSendRequest{0, commitment, sig})
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, fmt.Sprintf("%s %d", server.ERROR_CLIENT_AUTH_TOKEN, 0), response.Error)
+	otherSig, _ := crypto.SignMessage(privKey, "bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	code, response = sendCommitment(router, "token0", CommitmentSendRequest{0, commitment, otherSig})
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// no commitment stored
+	_, errCommitment := srv.GetClientCommitment()
+	assert.Equal(t, true, errCommitment != nil)
+
+	// valid commitment
+	code, response = sendCommitment(router, "token0", CommitmentSendRequest{0, commitment, sig})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "", response.Error)
+	assert.Equal(t, int32(0), response.ClientPosition)
+	assert.Equal(t, commitment, response.Commitment)
+
+	commitmentHash, _ := chainhash.NewHashFromStr(commitment)
+	expectedCommitment, _ := models.NewCommitment([]chainhash.Hash{*commitmentHash})
+	latestCommitment, errCommitment := srv.GetClientCommitment()
+	assert.Equal(t, nil, errCommitment)
+	assert.Equal(t, expectedCommitment.GetCommitmentHash(), latestCommitment.GetCommitmentHash())
+
+	// suspended client
+	srv.SetClientStatus(0, models.CLIENT_STATUS_SUSPENDED)
+	code, response = sendCommitment(router, "token0", CommitmentSendRequest{0, commitment, sig})
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, fmt.Sprintf("%s %d: %s", server.ERROR_CLIENT_NOT_ACTIVE, 0, models.CLIENT_STATUS_SUSPENDED), response.Error)
+}
+
+// Send proof request to router and return response
+func getProof(router http.Handler, path string) (int, SlotProofResponse) {
+	req := httptest.NewRequest(GET, path, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var response SlotProofResponse
+	json.NewDecoder(rec.Body).Decode(&response)
+	return rec.Code, response
+}
+
+// Test Proof request handlers
+func TestHandleProof(t *testing.T) {
+	dbFake := server.NewDbFake()
+	srv := server.NewServer(context.Background(), dbFake)
+	router := NewRouter(srv)
+
+	// no attestations
+	code, response := getProof(router, "/api/proof/latest/0")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, server.ERROR_ATTESTATION_MISSING, response.Error)
+
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY})
+	root := commitment.GetCommitmentHash()
+	txid := "11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	blockhash := "abcdef11111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	txidHash, _ := chainhash.NewHashFromStr(txid)
+
+	// unconfirmed attestation
+	attestation := models.NewAttestation(*txidHash, commitment)
+	srv.UpdateLatestAttestation(*attestation)
+
+	expected := SlotProofResponse{Txid: txid, Confirmed: false, MerkleRoot: root.String(), ClientPosition: 1,
+		Commitment: hashY.String(), Ops: []SlotProofOpResponse{SlotProofOpResponse{false, hashX.String()}}}
+	code, response = getProof(router, fmt.Sprintf("/api/proof/txid/%s/1", txid))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, expected, response)
+	code, response = getProof(router, fmt.Sprintf("/api/proof/merkleroot/%s/1", root.String()))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, expected, response)
+	code, response = getProof(router, "/api/proof/latest/1")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// confirmed attestation
+	attestation.Confirmed = true
+	attestation.Info = models.AttestationInfo{Txid: txid, Blockhash: blockhash}
+	srv.UpdateLatestAttestation(*attestation)
+
+	expected = SlotProofResponse{Txid: txid, Blockhash: blockhash, Confirmed: true, MerkleRoot: root.String(),
+		ClientPosition: 0, Commitment: hashX.String(), Ops: []SlotProofOpResponse{SlotProofOpResponse{true, hashY.String()}}}
+	code, response = getProof(router, "/api/proof/latest/0")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, expected, response)
+
+	// invalid requests
+	code, response = getProof(router, "/api/proof/latest/-1")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ERROR_REQUEST_POSITION, response.Error)
+	code, response = getProof(router, fmt.Sprintf("/api/proof/txid/%s/0", txid[2:]))
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ERROR_REQUEST_TXID, response.Error)
+	code, response = getProof(router, fmt.Sprintf("/api/proof/merkleroot/zz%s/0", txid[2:]))
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ERROR_REQUEST_ROOT, response.Error)
+	code, response = getProof(router, "/api/proof/latest/2")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, response = getProof(router, fmt.Sprintf("/api/proof/txid/%s/0", blockhash))
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+// Send attestations request to router and return response
+func getAttestations(router http.Handler, path string) (int, AttestationsResponse) {
+	req := httptest.NewRequest(GET, path, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var response AttestationsResponse
+	json.NewDecoder(rec.Body).Decode(&response)
+	return rec.Code, response
+}
+
+// Test Attestations request handler
+func TestHandleAttestations(t *testing.T) {
+	dbFake := server.NewDbFake()
+	srv := server.NewServer(context.Background(), dbFake)
+	router := NewRouter(srv)
+
+	// no attestations
+	code, response := getAttestations(router, "/api/attestations")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, AttestationsResponse{Page: 1, Limit: server.ATTESTATIONS_LIMIT_DEFAULT,
+		Attestations: []AttestationResponse{}}, response)
+
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitmentX, _ := models.NewCommitment([]chainhash.Hash{*hashX})
+	commitmentY, _ := models.NewCommitment([]chainhash.Hash{chainhash.Hash{}, *hashY})
+	txid1 := "11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	txid2 := "22222222222d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	blockhash := "abcdef11111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	txidHash1, _ := chainhash.NewHashFromStr(txid1)
+	txidHash2, _ := chainhash.NewHashFromStr(txid2)
+
+	// confirmed attestation followed by unconfirmed attestation
+	attestation1 := models.NewAttestation(*txidHash1, commitmentX)
+	attestation1.Confirmed = true
+	attestation1.Fee = int64(100)
+	attestation1.Info = models.AttestationInfo{Txid: txid1, Blockhash: blockhash, Height: 100,
+		Amount: int64(1000), Time: int64(1542121293)}
+	srv.UpdateLatestAttestation(*attestation1)
+	attestation2 := models.NewAttestation(*txidHash2, commitmentY)
+	attestation2.Fee = int64(200)
+	srv.UpdateLatestAttestation(*attestation2)
+
+	response1 := AttestationResponse{Txid: txid1, MerkleRoot: commitmentX.GetCommitmentHash().String(),
+		TreeVersion: models.COMMITMENT_TREE_VERSION, Confirmed: true,
+		Fee: int64(100), EmptySlots: []int32{}, Info: &AttestationInfoResponse{Blockhash: blockhash, Height: 100, Amount: int64(1000), Time: int64(1542121293)}}
+	response2 := AttestationResponse{Txid: txid2, MerkleRoot: commitmentY.GetCommitmentHash().String(),
+		TreeVersion: models.COMMITMENT_TREE_VERSION, Confirmed: false,
+		Fee: int64(200), EmptySlots: []int32{0}}
+
+	code, response = getAttestations(router, "/api/attestations")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []AttestationResponse{response2, response1}, response.Attestations)
+
+	code, response = getAttestations(router, "/api/attestations?page=2&limit=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, AttestationsResponse{Page: 2, Limit: 1, Attestations: []AttestationResponse{response1}}, response)
+
+	code, response = getAttestations(router, "/api/attestations?confirmed=false")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []AttestationResponse{response2}, response.Attestations)
+
+	code, response = getAttestations(router, fmt.Sprintf("/api/attestations?merkle_root=%s",
+		commitmentX.GetCommitmentHash().String()))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []AttestationResponse{response1}, response.Attestations)
+
+	code, response = getAttestations(router, "/api/attestations?from_height=100&to_height=100&from_time=1542121293")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []AttestationResponse{response1}, response.Attestations)
+
+	code, response = getAttestations(router, "/api/attestations?to_time=1542121292")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []AttestationResponse{}, response.Attestations)
+
+	// invalid requests
+	code, response = getAttestations(router, "/api/attestations?page=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, fmt.Sprintf("%s 0", server.ERROR_ATTESTATIONS_PAGE), response.Error)
+	code, response = getAttestations(router, "/api/attestations?limit=1000")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, fmt.Sprintf("%s 1000", server.ERROR_ATTESTATIONS_LIMIT), response.Error)
+	code, response = getAttestations(router, "/api/attestations?from_height=-1")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, fmt.Sprintf("%s %s", ERROR_REQUEST_QUERY, QUERY_FROM_HEIGHT), response.Error)
+	code, response = getAttestations(router, "/api/attestations?confirmed=maybe")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, fmt.Sprintf("%s %s", ERROR_REQUEST_QUERY, QUERY_CONFIRMED), response.Error)
+	code, response = getAttestations(router, "/api/attestations?merkle_root=abc")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ERROR_REQUEST_ROOT, response.Error)
+}
+
+// Send commitment history request to router and return response
+func getCommitmentHistory(router http.Handler, path string) (int, CommitmentHistoryResponse) {
+	req := httptest.NewRequest(GET, path, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var response CommitmentHistoryResponse
+	json.NewDecoder(rec.Body).Decode(&response)
+	return rec.Code, response
+}
+
+// Test Commitment History request handler
+func TestHandleCommitmentHistory(t *testing.T) {
+	dbFake := server.NewDbFake()
+	srv := server.NewServer(context.Background(), dbFake)
+	router := NewRouter(srv)
+
+	privKey, _ := btcutil.DecodeWIF("cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz")
+	pubkey := "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33"
+	dbFake.SetClientDetails([]models.ClientDetails{models.ClientDetails{ClientPosition: 0, AuthToken: "token0",
+		Pubkey: pubkey}})
+
+	// no history
+	code, response := getCommitmentHistory(router, "/api/commitment/history/0")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, CommitmentHistoryResponse{ClientPosition: 0, Page: 1, Limit: server.ATTESTATIONS_LIMIT_DEFAULT,
+		History: []CommitmentHistoryEntryResponse{}}, response)
+
+	// two commitments sent for position and the latest attested
+	commitmentX := "aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	commitmentY := "bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	sigX, _ := crypto.SignMessage(privKey, commitmentX)
+	sigY, _ := crypto.SignMessage(privKey, commitmentY)
+	sendCommitment(router, "token0", CommitmentSendRequest{0, commitmentX, sigX})
+	sendCommitment(router, "token0", CommitmentSendRequest{0, commitmentY, sigY})
+
+	commitment, _ := srv.GetClientCommitment()
+	txid := "11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	txidHash, _ := chainhash.NewHashFromStr(txid)
+	attestation := models.NewAttestation(*txidHash, &commitment)
+	attestation.Confirmed = true
+	attestation.Info = models.AttestationInfo{Txid: txid}
+	srv.UpdateLatestAttestation(*attestation)
+
+	code, response = getCommitmentHistory(router, "/api/commitment/history/0")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, len(response.History))
+	assert.Equal(t, commitmentY, response.History[0].Commitment)
+	assert.Equal(t, pubkey, response.History[0].Submitter)
+	assert.Equal(t, sigY, response.History[0].Signature)
+	assert.Equal(t, true, response.History[0].Attested)
+	assert.Equal(t, txid, response.History[0].Txid)
+	assert.Equal(t, commitment.GetCommitmentHash().String(), response.History[0].MerkleRoot)
+	assert.Equal(t, true, response.History[0].SubmittedAt > 0)
+	assert.Equal(t, commitmentX, response.History[1].Commitment)
+	assert.Equal(t, false, response.History[1].Attested)
+	assert.Equal(t, "", response.History[1].Txid)
+
+	code, response = getCommitmentHistory(router, "/api/commitment/history/0?page=2&limit=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(2), response.Page)
+	assert.Equal(t, int64(1), response.Limit)
+	assert.Equal(t, 1, len(response.History))
+	assert.Equal(t, commitmentX, response.History[0].Commitment)
+
+	// invalid requests
+	code, response = getCommitmentHistory(router, "/api/commitment/history/-1")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ERROR_REQUEST_POSITION, response.Error)
+	code, response = getCommitmentHistory(router, "/api/commitment/history/0?limit=x")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, fmt.Sprintf("%s %s", ERROR_REQUEST_QUERY, QUERY_LIMIT), response.Error)
+	code, response = getCommitmentHistory(router, "/api/commitment/history/0?page=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, fmt.Sprintf("%s 0", server.ERROR_ATTESTATIONS_PAGE), response.Error)
+
+	// history of revoked client is kept apart from the client reusing its position
+	srv.SetClientStatus(0, models.CLIENT_STATUS_REVOKED)
+	_, errSignup := srv.SignupClient("token1", pubkey)
+	assert.Equal(t, nil, errSignup)
+	code, response = getCommitmentHistory(router, "/api/commitment/history/0")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 0, len(response.History))
+	code, response = getCommitmentHistory(router, "/api/commitment/history/0/revoked/0")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, len(response.History))
+	assert.Equal(t, commitmentY, response.History[0].Commitment)
+	assert.Equal(t, commitmentX, response.History[1].Commitment)
+	code, response = getCommitmentHistory(router, "/api/commitment/history/0/revoked/0?page=2&limit=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, len(response.History))
+	assert.Equal(t, commitmentX, response.History[0].Commitment)
+
+	code, response = getCommitmentHistory(router, "/api/commitment/history/0/revoked/1")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, fmt.Sprintf("%s 0", server.ERROR_CLIENT_REVOKED_MISSING), response.Error)
+	code, response = getCommitmentHistory(router, "/api/commitment/history/0/revoked/x")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ERROR_REQUEST_STARTED_AT, response.Error)
+}
+
+// Stream events request to router and return response code, header and body
+func getEvents(router http.Handler, path string, lastEventId string) (int, http.Header, string) {
+	req := httptest.NewRequest(GET, path, nil)
+	if lastEventId != "" {
+		req.Header.Set(HEADER_LAST_EVENT_ID, lastEventId)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code, rec.Header(), rec.Body.String()
+}
+
+// Return server-sent event lines for event response
+func eventLines(response EventResponse) string {
+	data, _ := json.Marshal(response)
+	return fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", response.Id, response.Type, data)
+}
+
+// Test Events request handler
+func TestHandleEvents(t *testing.T) {
+	srv := server.NewServer(context.Background(), server.NewDbFake())
+	router := NewRouter(srv)
+	eventStreamDuration = 50 * time.Millisecond
+	defer func() { eventStreamDuration = EVENT_STREAM_DURATION }()
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hash0, chainhash.Hash{}, *hash2})
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	cursor := srv.Events().Cursor()
+
+	round := srv.PublishEvent(models.NewAttestationEvent(models.EVENT_ROUND_STARTED, *commitment, time.Unix(1542121293, 0)))
+	broadcastEvent := models.NewAttestationEvent(models.EVENT_ATTESTATION_BROADCAST, *commitment, time.Unix(1542121393, 0))
+	broadcastEvent.Txid = *txid
+	broadcast := srv.PublishEvent(broadcastEvent)
+	merkleRoot := commitment.GetCommitmentHash().String()
+
+	// resume from cursor
+	code, header, body := getEvents(router, fmt.Sprintf("%s?cursor=%d", ROUTE_EVENTS, cursor), "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "text/event-stream", header.Get("Content-Type"))
+	assert.Equal(t, fmt.Sprintf("retry: %d\n\n", EVENT_STREAM_RETRY)+
+		eventLines(EventResponse{Id: round.Id, Type: models.EVENT_ROUND_STARTED, Time: 1542121293, MerkleRoot: merkleRoot})+
+		eventLines(EventResponse{Id: broadcast.Id, Type: models.EVENT_ATTESTATION_BROADCAST, Time: 1542121393,
+			MerkleRoot: merkleRoot, Txid: txid.String()}), body)
+
+	// Last-Event-ID of a reconnecting client takes precedence over the cursor
+	// and events published while streaming are sent
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		confirmedEvent := models.NewAttestationEvent(models.EVENT_ATTESTATION_CONFIRMED, *commitment, time.Unix(1542121493, 0))
+		confirmedEvent.Txid = *txid
+		confirmedEvent.Blockhash = *hash0
+		srv.PublishEvent(confirmedEvent)
+	}()
+	position := int32(2)
+	code, _, body = getEvents(router, fmt.Sprintf("%s?cursor=%d&client_position=2", ROUTE_EVENTS, cursor),
+		fmt.Sprintf("%d", round.Id))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, fmt.Sprintf("retry: %d\n\n", EVENT_STREAM_RETRY)+
+		eventLines(EventResponse{Id: broadcast.Id, Type: models.EVENT_ATTESTATION_BROADCAST, Time: 1542121393,
+			MerkleRoot: merkleRoot, Txid: txid.String(), ClientPosition: &position, Commitment: hash2.String()})+
+		eventLines(EventResponse{Id: broadcast.Id + 1, Type: models.EVENT_ATTESTATION_CONFIRMED, Time: 1542121493,
+			MerkleRoot: merkleRoot, Txid: txid.String(), Blockhash: hash0.String(), ClientPosition: &position,
+			Commitment: hash2.String()}), body)
+
+	// events of positions not included only move the client cursor
+	code, _, body = getEvents(router, fmt.Sprintf("%s?cursor=%d&client_position=1", ROUTE_EVENTS, cursor), "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, fmt.Sprintf("retry: %d\n\nid: %d\n\n", EVENT_STREAM_RETRY, broadcast.Id+1), body)
+
+	// no cursor streams new events only
+	code, _, body = getEvents(router, ROUTE_EVENTS, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, fmt.Sprintf("retry: %d\n\n", EVENT_STREAM_RETRY), body)
+
+	// invalid requests
+	var response BaseResponse
+	code, _, body = getEvents(router, ROUTE_EVENTS+"?client_position=x", "")
+	json.Unmarshal([]byte(body), &response)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, fmt.Sprintf("%s %s", ERROR_REQUEST_QUERY, QUERY_CLIENT_POSITION), response.Error)
+	code, _, body = getEvents(router, ROUTE_EVENTS, "x")
+	json.Unmarshal([]byte(body), &response)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, fmt.Sprintf("%s %s", ERROR_REQUEST_QUERY, QUERY_CURSOR), response.Error)
+	code, _, body = getEvents(router, fmt.Sprintf("%s?cursor=%d", ROUTE_EVENTS, broadcast.Id+2), "")
+	json.Unmarshal([]byte(body), &response)
+	assert.Equal(t, http.StatusGone, code)
+	assert.Equal(t, fmt.Sprintf("%s %d", server.ERROR_EVENT_CURSOR, broadcast.Id+2), response.Error)
+}
@@ -0,0 +1,129 @@
+package requestapi
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"mainstay/server"
+
+	"github.com/gorilla/mux"
+)
+
+const GET = "GET"
+const POST = "POST"
+
+// route names
+const (
+	ROUTE_NAME_INDEX           = "Index"
+	ROUTE_NAME_COMMITMENT_SEND = "CommitmentSend"
+	ROUTE_NAME_PROOF_LATEST    = "ProofLatest"
+	ROUTE_NAME_PROOF_TXID      = "ProofTxid"
+	ROUTE_NAME_PROOF_ROOT      = "ProofMerkleRoot"
+	ROUTE_NAME_ATTESTATIONS    = "Attestations"
+	ROUTE_NAME_COMMITMENT_HIST = "CommitmentHistory"
+	ROUTE_NAME_REVOKED_HIST    = "RevokedCommitmentHistory"
+	ROUTE_NAME_EVENTS          = "Events"
+)
+
+// route patterns
+const (
+	ROUTE_INDEX           = "/"
+	ROUTE_COMMITMENT_SEND = "/api/commitment/send/"
+	ROUTE_PROOF_LATEST    = "/api/proof/latest/{position}"
+	ROUTE_PROOF_TXID      = "/api/proof/txid/{txid}/{position}"
+	ROUTE_PROOF_ROOT      = "/api/proof/merkleroot/{merkleroot}/{position}"
+	ROUTE_ATTESTATIONS    = "/api/attestations"
+	ROUTE_COMMITMENT_HIST = "/api/commitment/history/{position}"
+	ROUTE_REVOKED_HIST    = "/api/commitment/history/{position}/revoked/{startedat}"
+	ROUTE_EVENTS          = "/api/events"
+)
+
+// Route structure
+// Routing for http requests to request service
+type Route struct {
+	name        string
+	method      string
+	pattern     string
+	handlerFunc func(http.ResponseWriter, *http.Request, *server.Server)
+}
+
+var routes = []Route{
+	Route{
+		ROUTE_NAME_INDEX,
+		GET,
+		ROUTE_INDEX,
+		HandleIndex,
+	},
+	Route{
+		ROUTE_NAME_COMMITMENT_SEND,
+		POST,
+		ROUTE_COMMITMENT_SEND,
+		HandleCommitmentSend,
+	},
+	Route{
+		ROUTE_NAME_PROOF_LATEST,
+		GET,
+		ROUTE_PROOF_LATEST,
+		HandleProofLatest,
+	},
+	Route{
+		ROUTE_NAME_PROOF_TXID,
+		GET,
+		ROUTE_PROOF_TXID,
+		HandleProofTxid,
+	},
+	Route{
+		ROUTE_NAME_PROOF_ROOT,
+		GET,
+		ROUTE_PROOF_ROOT,
+		HandleProofMerkleRoot,
+	},
+	Route{
+		ROUTE_NAME_ATTESTATIONS,
+		GET,
+		ROUTE_ATTESTATIONS,
+		HandleAttestations,
+	},
+	Route{
+		ROUTE_NAME_COMMITMENT_HIST,
+		GET,
+		ROUTE_COMMITMENT_HIST,
+		HandleCommitmentHistory,
+	},
+	Route{
+		ROUTE_NAME_REVOKED_HIST,
+		GET,
+		ROUTE_REVOKED_HIST,
+		HandleRevokedCommitmentHistory,
+	},
+	Route{
+		ROUTE_NAME_EVENTS,
+		GET,
+		ROUTE_EVENTS,
+		HandleEvents,
+	},
+}
+
+// NewRouter returns pointer to mux router instance
+func NewRouter(server *server.Server) *mux.Router {
+	router := mux.NewRouter().StrictSlash(true)
+	for _, route := range routes {
+		handlerFunc := makeHandler(route.handlerFunc, server) // pass server to request handler
+		router.
+			Methods(route.method).
+			Path(route.pattern).
+			Name(route.name).
+			Handler(handlerFunc)
+	}
+	return router
+}
+
+// make custom handler to pass server to api handlers
+func makeHandler(fn func(http.ResponseWriter, *http.Request, *server.Server), server *server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		fn(w, r, server)
+		log.Printf("%s\t%s\t%s", r.Method, r.RequestURI, time.Since(start))
+	}
+}
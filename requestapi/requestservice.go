@@ -0,0 +1,55 @@
+package requestapi
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"mainstay/server"
+
+	"github.com/gorilla/mux"
+)
+
+// RequestService struct
+// Handles setting a request router and handling api requests
+type RequestService struct {
+	ctx    context.Context
+	wg     *sync.WaitGroup
+	host   string
+	router *mux.Router
+}
+
+// NewRequestService returns a pointer to a RequestService instance
+func NewRequestService(ctx context.Context, wg *sync.WaitGroup, server *server.Server, host string) *RequestService {
+	router := NewRouter(server)
+	return &RequestService{ctx, wg, host, router}
+}
+
+// Main Run method
+func (c *RequestService) Run() {
+	defer c.wg.Done()
+
+	srv := &http.Server{
+		Addr:         c.host,
+		WriteTimeout: time.Second * 15,
+		ReadTimeout:  time.Second * 15,
+		Handler:      c.router,
+	}
+
+	c.wg.Add(1)
+	go func() { //Running server waiting for requests
+		defer c.wg.Done()
+		if err := srv.ListenAndServe(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	select { //Waiting for cancellation signal to shut down server
+	case <-c.ctx.Done():
+		log.Println("Shutting down request service...")
+		srv.Shutdown(context.Background())
+		return
+	}
+}
@@ -0,0 +1,220 @@
+package requestapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"mainstay/models"
+)
+
+// Response implementations
+// These are used by request handlers to reply to api requests
+// BaseResponse is used for errors and requests with no response data
+
+// BaseResponse - only error specified
+type BaseResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+// CommitmentSendResponse for ROUTE_COMMITMENT_SEND
+type CommitmentSendResponse struct {
+	BaseResponse
+	ClientPosition int32  `json:"position"`
+	Commitment     string `json:"commitment"`
+}
+
+// SlotProofOpResponse for a single merkle proof operation
+type SlotProofOpResponse struct {
+	Append     bool   `json:"append"`
+	Commitment string `json:"commitment"`
+}
+
+// SpvProofResponse for the Bitcoin SPV proof of an attestation transaction
+type SpvProofResponse struct {
+	Header       string   `json:"header"`
+	TxIndex      int32    `json:"tx_index"`
+	MerkleBranch []string `json:"merkle_branch"`
+}
+
+// SlotProofResponse for ROUTE_PROOF_LATEST, ROUTE_PROOF_TXID and ROUTE_PROOF_ROOT
+// Blockhash and Spv are only included for confirmed attestations
+type SlotProofResponse struct {
+	BaseResponse
+	Txid           string                `json:"txid"`
+	Blockhash      string                `json:"blockhash,omitempty"`
+	Confirmed      bool                  `json:"confirmed"`
+	MerkleRoot     string                `json:"merkle_root"`
+	ClientPosition int32                 `json:"position"`
+	Commitment     string                `json:"commitment"`
+	Ops            []SlotProofOpResponse `json:"ops"`
+	Spv            *SpvProofResponse     `json:"spv,omitempty"`
+}
+
+// Return SlotProofResponse from SlotProof model
+func NewSlotProofResponse(slotProof models.SlotProof) SlotProofResponse {
+	response := SlotProofResponse{
+		Txid:           slotProof.Txid.String(),
+		Confirmed:      slotProof.Confirmed,
+		MerkleRoot:     slotProof.Proof.MerkleRoot.String(),
+		ClientPosition: slotProof.Proof.ClientPosition,
+		Commitment:     slotProof.Proof.Commitment.String(),
+		Ops:            []SlotProofOpResponse{}}
+	if slotProof.Confirmed {
+		response.Blockhash = slotProof.Blockhash.String()
+	}
+	if slotProof.Spv != nil {
+		response.Spv = &SpvProofResponse{
+			Header:       slotProof.Spv.HeaderHex(),
+			TxIndex:      slotProof.Spv.TxIndex,
+			MerkleBranch: slotProof.Spv.MerkleBranchStrings()}
+	}
+	for _, op := range slotProof.Proof.Ops {
+		response.Ops = append(response.Ops, SlotProofOpResponse{Append: op.Append, Commitment: op.Commitment.String()})
+	}
+	return response
+}
+
+// AttestationInfoResponse for the info of a confirmed attestation
+type AttestationInfoResponse struct {
+	Blockhash string `json:"blockhash"`
+	Height    int64  `json:"height"`
+	Amount    int64  `json:"amount"`
+	Time      int64  `json:"time"`
+}
+
+// AttestationResponse for a single attestation of the attestation history
+// Info is only included for confirmed attestations
+// TreeVersion is the commitment merkle tree layout version of the attestation
+// EmptySlots are the positions attested with a zero commitment
+type AttestationResponse struct {
+	Txid        string                   `json:"txid"`
+	MerkleRoot  string                   `json:"merkle_root"`
+	TreeVersion int32                    `json:"tree_version,omitempty"`
+	Confirmed   bool                     `json:"confirmed"`
+	Fee         int64                    `json:"fee"`
+	EmptySlots  []int32                  `json:"empty_slots"`
+	Info        *AttestationInfoResponse `json:"info,omitempty"`
+}
+
+// AttestationsResponse for ROUTE_ATTESTATIONS
+type AttestationsResponse struct {
+	BaseResponse
+	Page         int64                 `json:"page"`
+	Limit        int64                 `json:"limit"`
+	Attestations []AttestationResponse `json:"attestations"`
+}
+
+// Return AttestationsResponse from Attestation models of a history page
+// along with the empty slots of each attestation
+func newAttestationsResponse(attestations []models.Attestation, emptySlots [][]int32, page int64, limit int64) AttestationsResponse {
+	response := AttestationsResponse{Page: page, Limit: limit, Attestations: []AttestationResponse{}}
+	for i, attestation := range attestations {
+		attestationResponse := AttestationResponse{
+			Txid:        attestation.Txid.String(),
+			MerkleRoot:  attestation.CommitmentHash().String(),
+			TreeVersion: attestation.TreeVersion(),
+			Confirmed:   attestation.Confirmed,
+			Fee:         attestation.Fee,
+			EmptySlots:  emptySlots[i]}
+		if attestation.Confirmed {
+			attestationResponse.Info = &AttestationInfoResponse{
+				Blockhash: attestation.Info.Blockhash,
+				Height:    attestation.Info.Height,
+				Amount:    attestation.Info.Amount,
+				Time:      attestation.Info.Time}
+		}
+		response.Attestations = append(response.Attestations, attestationResponse)
+	}
+	return response
+}
+
+// CommitmentHistoryEntryResponse for a single commitment of a slot history
+// Txid and MerkleRoot are only included for attested commitments
+type CommitmentHistoryEntryResponse struct {
+	Commitment  string `json:"commitment"`
+	SubmittedAt int64  `json:"submitted_at"`
+	Submitter   string `json:"submitter"`
+	Signature   string `json:"signature"`
+	Attested    bool   `json:"attested"`
+	Txid        string `json:"txid,omitempty"`
+	MerkleRoot  string `json:"merkle_root,omitempty"`
+}
+
+// CommitmentHistoryResponse for ROUTE_COMMITMENT_HIST
+type CommitmentHistoryResponse struct {
+	BaseResponse
+	ClientPosition int32                            `json:"position"`
+	Page           int64                            `json:"page"`
+	Limit          int64                            `json:"limit"`
+	History        []CommitmentHistoryEntryResponse `json:"history"`
+}
+
+// Return CommitmentHistoryResponse from ClientCommitmentHistory models of a history page
+func newCommitmentHistoryResponse(history []models.ClientCommitmentHistory, position int32, page int64, limit int64) CommitmentHistoryResponse {
+	response := CommitmentHistoryResponse{ClientPosition: position, Page: page, Limit: limit,
+		History: []CommitmentHistoryEntryResponse{}}
+	for _, entry := range history {
+		entryResponse := CommitmentHistoryEntryResponse{
+			Commitment:  entry.Commitment.String(),
+			SubmittedAt: entry.SubmittedAt.Unix(),
+			Submitter:   entry.Submitter,
+			Signature:   entry.Signature,
+			Attested:    entry.Attested()}
+		if entry.Attested() {
+			entryResponse.Txid = entry.Txid.String()
+			entryResponse.MerkleRoot = entry.MerkleRoot.String()
+		}
+		response.History = append(response.History, entryResponse)
+	}
+	return response
+}
+
+// EventResponse for the data of ROUTE_EVENTS stream events
+// Position and Commitment are only included in streams filtered by client position
+type EventResponse struct {
+	Id              int64  `json:"id"`
+	Type            string `json:"type"`
+	Time            int64  `json:"time"`
+	MerkleRoot      string `json:"merkle_root"`
+	Txid            string `json:"txid,omitempty"`
+	Blockhash       string `json:"blockhash,omitempty"`
+	ReplacementTxid string `json:"replacement_txid,omitempty"`
+	ClientPosition  *int32 `json:"position,omitempty"`
+	Commitment      string `json:"commitment,omitempty"`
+}
+
+// Return EventResponse from AttestationEvent model
+// Commitment of the client position is included for positions not negative
+func newEventResponse(event models.AttestationEvent, position int32) EventResponse {
+	response := EventResponse{
+		Id:         event.Id,
+		Type:       event.Type,
+		Time:       event.Time.Unix(),
+		MerkleRoot: event.MerkleRoot.String()}
+	if event.Type != models.EVENT_ROUND_STARTED {
+		response.Txid = event.Txid.String()
+	}
+	if event.Type == models.EVENT_ATTESTATION_CONFIRMED {
+		response.Blockhash = event.Blockhash.String()
+	}
+	if event.Type == models.EVENT_ATTESTATION_REPLACED {
+		response.ReplacementTxid = event.ReplacementTxid.String()
+	}
+	if position >= 0 {
+		response.ClientPosition = &position
+		response.Commitment = event.Commitments[position].String()
+	}
+	return response
+}
+
+// Write json response with the status code provided
+func writeResponse(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Write error response with the status code provided
+func writeError(w http.ResponseWriter, status int, err string) {
+	writeResponse(w, status, BaseResponse{Error: err})
+}
@@ -1,19 +1,199 @@
 package server
 
 import (
+	"context"
+	"log"
+
+	"mainstay/config"
 	"mainstay/models"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
+// AttestationStore interface
+// Storage of attestations and their confirmation info
+type AttestationStore interface {
+	SaveAttestation(context.Context, models.Attestation) error
+	SaveAttestationInfo(context.Context, models.AttestationInfo) error
+
+	GetLatestAttestationMerkleRoot(context.Context, bool) (string, error)
+	GetAttestation(context.Context, chainhash.Hash) (models.Attestation, error)
+	GetAttestationMerkleRoot(context.Context, chainhash.Hash) (string, error)
+	GetMerkleRootAttestation(context.Context, chainhash.Hash) (models.Attestation, error)
+	GetAttestationInfo(context.Context, chainhash.Hash) (models.AttestationInfo, error)
+	GetAttestations(context.Context, AttestationFilter, int64, int64) ([]models.Attestation, error)
+}
+
+// CommitmentStore interface
+// Storage of latest client commitments, their history and the
+// merkle commitments of each attestation
+type CommitmentStore interface {
+	SaveMerkleCommitments(context.Context, []models.CommitmentMerkleCommitment) error
+	SaveClientCommitment(context.Context, models.ClientCommitment) error
+	SaveClientCommitmentHistory(context.Context, models.ClientCommitmentHistory) error
+	AttestClientCommitmentHistory(context.Context, models.CommitmentMerkleCommitment, chainhash.Hash) error
+
+	GetAttestationMerkleCommitments(context.Context, chainhash.Hash) ([]models.CommitmentMerkleCommitment, error)
+	GetEmptySlots(context.Context, chainhash.Hash) ([]int32, error)
+	GetClientCommitments(context.Context) ([]models.ClientCommitment, error)
+	GetClientCommitmentHistory(context.Context, int32, int64, int64, int64, int64) ([]models.ClientCommitmentHistory, error)
+}
+
+// ProofStore interface
+// Storage of the slot merkle proofs of each attestation
+type ProofStore interface {
+	SaveMerkleProofs(context.Context, []models.CommitmentMerkleProof) error
+
+	GetMerkleProof(context.Context, chainhash.Hash, int32) (models.CommitmentMerkleProof, error)
+}
+
+// ClientStore interface
+// Storage of client details for each client position and of the
+// details of revoked clients whose positions have been reused
+type ClientStore interface {
+	SaveClientDetails(context.Context, models.ClientDetails) error
+	SaveRevokedClientDetails(context.Context, models.ClientDetails) error
+
+	GetClientDetails(context.Context) ([]models.ClientDetails, error)
+	GetRevokedClientDetails(context.Context, int32) ([]models.ClientDetails, error)
+}
+
+// WebhookStore interface
+// Storage of pending client webhook deliveries and of failed
+// deliveries for redelivery
+type WebhookStore interface {
+	SaveWebhookDelivery(context.Context, models.WebhookDelivery) error
+	AddWebhookDelivery(context.Context, models.WebhookDelivery) error
+
+	GetWebhookDeliveries(context.Context, bool) ([]models.WebhookDelivery, error)
+}
+
 // Db Interface
+// Storage backend used by Server, implemented by DbMongo, DbSqlite and DbFake
 type Db interface {
-	saveAttestation(models.Attestation) error
-	saveAttestationInfo(models.AttestationInfo) error
-	saveMerkleCommitments(commitments []models.CommitmentMerkleCommitment) error
-	saveMerkleProofs(proofs []models.CommitmentMerkleProof) error
-
-	getLatestAttestationMerkleRoot(bool) (string, error)
-	getClientCommitments() ([]models.ClientCommitment, error)
-	getAttestationMerkleCommitments(chainhash.Hash) ([]models.CommitmentMerkleCommitment, error)
+	AttestationStore
+	CommitmentStore
+	ProofStore
+	ClientStore
+	WebhookStore
+
+	// Save all writes of an attestation update atomically
+	SaveAttestationUpdate(context.Context, models.Attestation, ...models.CommitmentMerkleProof) error
+	// Delete merkle proofs and commitments of an archived merkle root
+	PruneMerkleRoot(context.Context, chainhash.Hash) error
+}
+
+// Save attestation along with its merkle commitments and proofs and, for
+// confirmed attestations, its info, the attestation of client commitment
+// history and the pending client webhook deliveries. Merkle proofs are
+// built from the commitment if not provided. Writes are not atomic and are
+// run by each backend within its transaction or journaled write. All
+// writes are safe to repeat
+func saveAttestationUpdate(ctx context.Context, db Db, attestation models.Attestation,
+	proofs []models.CommitmentMerkleProof) error {
+	commitment, errCommitment := attestation.Commitment()
+	if errCommitment != nil {
+		return errCommitment
+	}
+	if errSave := db.SaveMerkleCommitments(ctx, commitment.GetMerkleCommitments()); errSave != nil {
+		return errSave
+	}
+	return saveAttestationRecords(ctx, db, attestation, *commitment, proofs)
+}
+
+// Save attestation writes of an attestation update that follow the merkle
+// commitments, which the commitment of the attestation is stored as
+func saveAttestationRecords(ctx context.Context, db Db, attestation models.Attestation,
+	commitment models.Commitment, proofs []models.CommitmentMerkleProof) error {
+	if errSave := db.SaveAttestation(ctx, attestation); errSave != nil {
+		return errSave
+	}
+	if len(proofs) == 0 {
+		proofs = commitment.GetMerkleProofs()
+	}
+	if errSave := db.SaveMerkleProofs(ctx, proofs); errSave != nil {
+		return errSave
+	}
+
+	if attestation.Confirmed {
+		if errSave := db.SaveAttestationInfo(ctx, attestation.Info); errSave != nil {
+			return errSave
+		}
+
+		// record confirmed attestation in client commitment history
+		for _, merkleCommitment := range commitment.GetMerkleCommitments() {
+			errSave := db.AttestClientCommitmentHistory(ctx, merkleCommitment, attestation.Txid)
+			if errSave != nil {
+				return errSave
+			}
+		}
+		return addWebhookDeliveries(ctx, db, attestation.Txid, commitment)
+	}
+	return nil
+}
+
+// Add pending webhook deliveries of a confirmed attestation for the
+// clients with a webhook and a commitment in the attestation
+func addWebhookDeliveries(ctx context.Context, db Db, txid chainhash.Hash, commitment models.Commitment) error {
+	clientDetails, errDetails := db.GetClientDetails(ctx)
+	if errDetails != nil {
+		return errDetails
+	}
+	detailsByPosition := make(map[int32]models.ClientDetails)
+	for _, details := range clientDetails {
+		if details.WebhookUrl != "" {
+			detailsByPosition[details.ClientPosition] = details
+		}
+	}
+	for _, merkleCommitment := range commitment.GetMerkleCommitments() {
+		details, found := detailsByPosition[merkleCommitment.ClientPosition]
+		if !found || merkleCommitment.Commitment == (chainhash.Hash{}) {
+			continue
+		}
+		errAdd := db.AddWebhookDelivery(ctx, models.WebhookDelivery{
+			Txid:           txid,
+			ClientPosition: details.ClientPosition,
+			Pubkey:         details.Pubkey,
+			Url:            details.WebhookUrl})
+		if errAdd != nil {
+			return errAdd
+		}
+	}
+	return nil
+}
+
+// error consts
+const (
+	ERROR_DB_BACKEND = "Unknown db backend"
+)
+
+// Return new Db instance of the backend selected in dbConnectivity
+func NewDb(ctx context.Context, dbConnectivity config.DbConnectivity) Db {
+	switch dbConnectivity.Backend {
+	case config.DB_BACKEND_MONGO:
+		return NewDbMongo(ctx, dbConnectivity)
+	case config.DB_BACKEND_SQLITE:
+		return NewDbSqlite(ctx, dbConnectivity)
+	}
+	log.Fatalf("%s %s", ERROR_DB_BACKEND, dbConnectivity.Backend)
+	return nil
+}
+
+// AttestationFilter struct
+// Filters for querying the attestation history
+// Nil or zero fields are not applied. Time and height ranges are
+// inclusive and only match confirmed attestations, as these are
+// taken from the attestation info stored on confirmation
+type AttestationFilter struct {
+	Confirmed  *bool
+	MerkleRoot *chainhash.Hash
+	FromTime   int64
+	ToTime     int64
+	FromHeight int64
+	ToHeight   int64
+}
+
+// Check whether the filter requires attestation info to match
+func (f AttestationFilter) hasInfoFilter() bool {
+	return f.FromTime != 0 || f.ToTime != 0 || f.FromHeight != 0 || f.ToHeight != 0
 }
@@ -0,0 +1,480 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"mainstay/config"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Db conformance test suite
+// Each Db implementation is run against the same tests with a new empty
+// Db from newDb for every test, so that all backends behave like DbMongo
+
+// Run Db conformance tests on Db instances returned by newDb
+func testDbConformance(t *testing.T, newDb func() Db) {
+	ctx := context.Background()
+	t.Run("Attestations", func(t *testing.T) { testDbAttestations(t, ctx, newDb()) })
+	t.Run("MerkleCommitments", func(t *testing.T) { testDbMerkleCommitments(t, ctx, newDb()) })
+	t.Run("ClientCommitments", func(t *testing.T) { testDbClientCommitments(t, ctx, newDb()) })
+	t.Run("ClientDetails", func(t *testing.T) { testDbClientDetails(t, ctx, newDb()) })
+	t.Run("RevokedClientDetails", func(t *testing.T) { testDbRevokedClientDetails(t, ctx, newDb()) })
+	t.Run("ClientCommitmentHistory", func(t *testing.T) { testDbClientCommitmentHistory(t, ctx, newDb()) })
+	t.Run("AttestationHistory", func(t *testing.T) { testDbAttestationHistory(t, ctx, newDb()) })
+	t.Run("AttestationUpdate", func(t *testing.T) { testDbAttestationUpdate(t, ctx, newDb()) })
+	t.Run("WebhookDeliveries", func(t *testing.T) { testDbWebhookDeliveries(t, ctx, newDb()) })
+	t.Run("PruneMerkleRoot", func(t *testing.T) { testDbPruneMerkleRoot(t, ctx, newDb()) })
+}
+
+// Return test hash for index i
+func testDbHash(prefix string, i int) chainhash.Hash {
+	hash, _ := chainhash.NewHashFromStr(fmt.Sprintf("%s%d1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7", prefix, i))
+	return *hash
+}
+
+// Test saving and getting attestations, attestation info and latest merkle roots
+func testDbAttestations(t *testing.T, ctx context.Context, db Db) {
+	txid0 := testDbHash("aaaaaa", 0)
+	txid1 := testDbHash("aaaaaa", 1)
+
+	// no attestations
+	root, errRoot := db.GetLatestAttestationMerkleRoot(ctx, true)
+	assert.Equal(t, nil, errRoot)
+	assert.Equal(t, "", root)
+	root, errRoot = db.GetAttestationMerkleRoot(ctx, txid0)
+	assert.Equal(t, nil, errRoot)
+	assert.Equal(t, "", root)
+	_, errGet := db.GetAttestation(ctx, txid0)
+	assert.NotEqual(t, nil, errGet)
+
+	// unconfirmed attestation
+	commitment0, _ := models.NewCommitment([]chainhash.Hash{testDbHash("bbbbbb", 0), testDbHash("bbbbbb", 1)})
+	attestation0 := models.NewAttestation(txid0, commitment0)
+	attestation0.Fee = 100
+	assert.Equal(t, nil, db.SaveAttestation(ctx, *attestation0))
+
+	root, errRoot = db.GetLatestAttestationMerkleRoot(ctx, false)
+	assert.Equal(t, nil, errRoot)
+	assert.Equal(t, commitment0.GetCommitmentHash().String(), root)
+	_, errRoot = db.GetLatestAttestationMerkleRoot(ctx, true)
+	assert.NotEqual(t, nil, errRoot)
+	root, errRoot = db.GetAttestationMerkleRoot(ctx, txid0)
+	assert.Equal(t, nil, errRoot)
+	assert.Equal(t, commitment0.GetCommitmentHash().String(), root)
+	attestation, errGet := db.GetAttestation(ctx, txid0)
+	assert.Equal(t, nil, errGet)
+	assert.Equal(t, attestation0.WithoutCommitment(), attestation)
+
+	// confirm attestation with info
+	attestation0.Confirmed = true
+	info0 := models.AttestationInfo{Txid: txid0.String(), Blockhash: testDbHash("cccccc", 0).String(),
+		Height: 100, Amount: 1000, Time: 1542121293, MerkleBranch: []string{testDbHash("dddddd", 0).String()}}
+	assert.Equal(t, nil, db.SaveAttestation(ctx, *attestation0))
+	assert.Equal(t, nil, db.SaveAttestationInfo(ctx, info0))
+
+	root, errRoot = db.GetLatestAttestationMerkleRoot(ctx, true)
+	assert.Equal(t, nil, errRoot)
+	assert.Equal(t, commitment0.GetCommitmentHash().String(), root)
+	attestation, _ = db.GetAttestation(ctx, txid0)
+	assert.Equal(t, true, attestation.Confirmed)
+	info, errInfo := db.GetAttestationInfo(ctx, txid0)
+	assert.Equal(t, nil, errInfo)
+	assert.Equal(t, info0, info)
+	_, errInfo = db.GetAttestationInfo(ctx, txid1)
+	assert.NotEqual(t, nil, errInfo)
+
+	// latest unconfirmed attestation for the same merkle root
+	attestation1 := models.NewAttestation(txid1, commitment0)
+	assert.Equal(t, nil, db.SaveAttestation(ctx, *attestation1))
+	root, _ = db.GetLatestAttestationMerkleRoot(ctx, false)
+	assert.Equal(t, commitment0.GetCommitmentHash().String(), root)
+	attestation, errGet = db.GetMerkleRootAttestation(ctx, commitment0.GetCommitmentHash())
+	assert.Equal(t, nil, errGet)
+	assert.Equal(t, txid0, attestation.Txid)
+	_, errGet = db.GetMerkleRootAttestation(ctx, testDbHash("eeeeee", 0))
+	assert.NotEqual(t, nil, errGet)
+}
+
+// Test saving and getting merkle commitments and proofs of attestations
+func testDbMerkleCommitments(t *testing.T, ctx context.Context, db Db) {
+	txid := testDbHash("aaaaaa", 0)
+	commitment, _ := models.NewCommitment([]chainhash.Hash{
+		testDbHash("bbbbbb", 0), testDbHash("bbbbbb", 1), testDbHash("bbbbbb", 2)})
+	assert.Equal(t, nil, db.SaveAttestation(ctx, *models.NewAttestation(txid, commitment)))
+	assert.Equal(t, nil, db.SaveMerkleCommitments(ctx, commitment.GetMerkleCommitments()))
+	assert.Equal(t, nil, db.SaveMerkleProofs(ctx, commitment.GetMerkleProofs()))
+
+	merkleCommitments, errCommitments := db.GetAttestationMerkleCommitments(ctx, txid)
+	assert.Equal(t, nil, errCommitments)
+	assert.Equal(t, commitment.GetMerkleCommitments(), merkleCommitments)
+
+	for _, proof := range commitment.GetMerkleProofs() {
+		dbProof, errProof := db.GetMerkleProof(ctx, commitment.GetCommitmentHash(), proof.ClientPosition)
+		assert.Equal(t, nil, errProof)
+		assert.Equal(t, proof, dbProof)
+	}
+	_, errProof := db.GetMerkleProof(ctx, commitment.GetCommitmentHash(), 3)
+	assert.NotEqual(t, nil, errProof)
+	_, errProof = db.GetMerkleProof(ctx, testDbHash("eeeeee", 0), 0)
+	assert.NotEqual(t, nil, errProof)
+
+	// empty slots of zero merkle commitments
+	zeroCommitment, _ := models.NewCommitment([]chainhash.Hash{
+		chainhash.Hash{}, testDbHash("bbbbbb", 1), chainhash.Hash{}})
+	assert.Equal(t, nil, db.SaveMerkleCommitments(ctx, zeroCommitment.GetMerkleCommitments()))
+	emptySlots, errSlots := db.GetEmptySlots(ctx, zeroCommitment.GetCommitmentHash())
+	assert.Equal(t, nil, errSlots)
+	assert.Equal(t, []int32{0, 2}, emptySlots)
+	emptySlots, _ = db.GetEmptySlots(ctx, commitment.GetCommitmentHash())
+	assert.Equal(t, []int32{}, emptySlots)
+}
+
+// Test pruning merkle commitments and proofs of an attestation round
+func testDbPruneMerkleRoot(t *testing.T, ctx context.Context, db Db) {
+	var commitments []*models.Commitment
+	for i := 0; i < 2; i++ {
+		commitment, _ := models.NewCommitment([]chainhash.Hash{testDbHash("bbbbbb", i), testDbHash("cccccc", i)})
+		assert.Equal(t, nil, db.SaveAttestation(ctx, *models.NewAttestation(testDbHash("aaaaaa", i), commitment)))
+		assert.Equal(t, nil, db.SaveMerkleCommitments(ctx, commitment.GetMerkleCommitments()))
+		assert.Equal(t, nil, db.SaveMerkleProofs(ctx, commitment.GetMerkleProofs()))
+		commitments = append(commitments, commitment)
+	}
+
+	assert.Equal(t, nil, db.PruneMerkleRoot(ctx, commitments[0].GetCommitmentHash()))
+	for _, proof := range commitments[0].GetMerkleProofs() {
+		_, errProof := db.GetMerkleProof(ctx, commitments[0].GetCommitmentHash(), proof.ClientPosition)
+		assert.NotEqual(t, nil, errProof)
+	}
+	merkleCommitments, _ := db.GetAttestationMerkleCommitments(ctx, testDbHash("aaaaaa", 0))
+	assert.Equal(t, 0, len(merkleCommitments))
+
+	// other rounds and attestations are kept
+	_, errGet := db.GetAttestation(ctx, testDbHash("aaaaaa", 0))
+	assert.Equal(t, nil, errGet)
+	merkleCommitments, errCommitments := db.GetAttestationMerkleCommitments(ctx, testDbHash("aaaaaa", 1))
+	assert.Equal(t, nil, errCommitments)
+	assert.Equal(t, commitments[1].GetMerkleCommitments(), merkleCommitments)
+	proof, errProof := db.GetMerkleProof(ctx, commitments[1].GetCommitmentHash(), 1)
+	assert.Equal(t, nil, errProof)
+	assert.Equal(t, commitments[1].GetMerkleProofs()[1], proof)
+
+	// pruning again or an unknown merkle root is a no-op
+	assert.Equal(t, nil, db.PruneMerkleRoot(ctx, commitments[0].GetCommitmentHash()))
+	assert.Equal(t, nil, db.PruneMerkleRoot(ctx, testDbHash("eeeeee", 0)))
+}
+
+// Test saving and getting latest client commitments
+func testDbClientCommitments(t *testing.T, ctx context.Context, db Db) {
+	commitments, errCommitments := db.GetClientCommitments(ctx)
+	assert.Equal(t, nil, errCommitments)
+	assert.Equal(t, 0, len(commitments))
+
+	commitment1 := models.ClientCommitment{Commitment: testDbHash("bbbbbb", 1), ClientPosition: 1}
+	commitment0 := models.ClientCommitment{Commitment: testDbHash("bbbbbb", 0), ClientPosition: 0}
+	assert.Equal(t, nil, db.SaveClientCommitment(ctx, commitment0))
+	assert.Equal(t, nil, db.SaveClientCommitment(ctx, commitment1))
+
+	// update overwrites latest commitment of position
+	commitment0.Commitment = testDbHash("bbbbbb", 2)
+	assert.Equal(t, nil, db.SaveClientCommitment(ctx, commitment0))
+	commitments, errCommitments = db.GetClientCommitments(ctx)
+	assert.Equal(t, nil, errCommitments)
+	assert.Equal(t, []models.ClientCommitment{commitment0, commitment1}, commitments)
+}
+
+// Test saving and getting client details ordered by position
+func testDbClientDetails(t *testing.T, ctx context.Context, db Db) {
+	details, errDetails := db.GetClientDetails(ctx)
+	assert.Equal(t, nil, errDetails)
+	assert.Equal(t, 0, len(details))
+
+	details1 := models.ClientDetails{ClientPosition: 1, AuthToken: "token1", Pubkey: "pubkey1",
+		Status: models.CLIENT_STATUS_ACTIVE, StartedAt: 1542121293}
+	details0 := models.ClientDetails{ClientPosition: 0, AuthToken: "token0", Pubkey: "pubkey0"}
+	assert.Equal(t, nil, db.SaveClientDetails(ctx, details1))
+	assert.Equal(t, nil, db.SaveClientDetails(ctx, details0))
+
+	// update overwrites details of position
+	details1.Status = models.CLIENT_STATUS_REVOKED
+	details1.EndedAt = 1542121893
+	details1.WebhookUrl = "https://client1.example.com/webhook"
+	details1.WebhookSecret = "secret1"
+	assert.Equal(t, nil, db.SaveClientDetails(ctx, details1))
+	details, errDetails = db.GetClientDetails(ctx)
+	assert.Equal(t, nil, errDetails)
+	assert.Equal(t, []models.ClientDetails{details0, details1}, details)
+
+	// update clears fields not set in the new details
+	details1 = models.ClientDetails{ClientPosition: 1, AuthToken: "token2", Pubkey: "pubkey2",
+		Status: models.CLIENT_STATUS_ACTIVE, StartedAt: 1542122493}
+	assert.Equal(t, nil, db.SaveClientDetails(ctx, details1))
+	details, _ = db.GetClientDetails(ctx)
+	assert.Equal(t, []models.ClientDetails{details0, details1}, details)
+
+	// removing a webhook clears its url and secret
+	details0.WebhookUrl = "https://client0.example.com/webhook"
+	details0.WebhookSecret = "secret0"
+	assert.Equal(t, nil, db.SaveClientDetails(ctx, details0))
+	details0.WebhookUrl = ""
+	details0.WebhookSecret = ""
+	assert.Equal(t, nil, db.SaveClientDetails(ctx, details0))
+	details, _ = db.GetClientDetails(ctx)
+	assert.Equal(t, []models.ClientDetails{details0, details1}, details)
+}
+
+// Test saving and getting details of revoked clients by position oldest first
+func testDbRevokedClientDetails(t *testing.T, ctx context.Context, db Db) {
+	revoked, errRevoked := db.GetRevokedClientDetails(ctx, 0)
+	assert.Equal(t, nil, errRevoked)
+	assert.Equal(t, []models.ClientDetails{}, revoked)
+
+	revoked1 := models.ClientDetails{ClientPosition: 0, Pubkey: "pubkey1", Status: models.CLIENT_STATUS_REVOKED,
+		StartedAt: 1542121893, EndedAt: 1542122493}
+	revoked0 := models.ClientDetails{ClientPosition: 0, Pubkey: "pubkey0", Status: models.CLIENT_STATUS_REVOKED,
+		StartedAt: 1542121293, EndedAt: 1542121593, WebhookUrl: "https://client0.example.com"}
+	other := models.ClientDetails{ClientPosition: 1, Pubkey: "pubkey2", Status: models.CLIENT_STATUS_REVOKED,
+		StartedAt: 1542121293, EndedAt: 1542121593}
+	assert.Equal(t, nil, db.SaveRevokedClientDetails(ctx, revoked1))
+	assert.Equal(t, nil, db.SaveRevokedClientDetails(ctx, revoked0))
+	assert.Equal(t, nil, db.SaveRevokedClientDetails(ctx, other))
+
+	// revoked details do not replace current client details
+	details, _ := db.GetClientDetails(ctx)
+	assert.Equal(t, 0, len(details))
+
+	revoked, errRevoked = db.GetRevokedClientDetails(ctx, 0)
+	assert.Equal(t, nil, errRevoked)
+	assert.Equal(t, []models.ClientDetails{revoked0, revoked1}, revoked)
+	revoked, _ = db.GetRevokedClientDetails(ctx, 1)
+	assert.Equal(t, []models.ClientDetails{other}, revoked)
+}
+
+// Test saving, updating and getting webhook deliveries oldest failure first
+func testDbWebhookDeliveries(t *testing.T, ctx context.Context, db Db) {
+	deliveries, errDeliveries := db.GetWebhookDeliveries(ctx, false)
+	assert.Equal(t, nil, errDeliveries)
+	assert.Equal(t, []models.WebhookDelivery{}, deliveries)
+
+	delivery0 := models.WebhookDelivery{Txid: testDbHash("1", 0), ClientPosition: 0, Url: "https://client0.example.com",
+		Attempts: 5, LastError: "error0", FailedAt: time.Unix(1542121893, 0).UTC()}
+	delivery1 := models.WebhookDelivery{Txid: testDbHash("1", 0), ClientPosition: 1, Url: "https://client1.example.com",
+		Attempts: 5, LastError: "error1", FailedAt: time.Unix(1542121293, 0).UTC()}
+	assert.Equal(t, nil, db.SaveWebhookDelivery(ctx, delivery0))
+	assert.Equal(t, nil, db.SaveWebhookDelivery(ctx, delivery1))
+	deliveries, errDeliveries = db.GetWebhookDeliveries(ctx, false)
+	assert.Equal(t, nil, errDeliveries)
+	assert.Equal(t, []models.WebhookDelivery{delivery1, delivery0}, deliveries)
+
+	// update overwrites delivery of txid and position
+	delivery1.Attempts = 6
+	delivery1.Delivered = true
+	assert.Equal(t, nil, db.SaveWebhookDelivery(ctx, delivery1))
+	deliveries, _ = db.GetWebhookDeliveries(ctx, false)
+	assert.Equal(t, []models.WebhookDelivery{delivery0}, deliveries)
+	deliveries, errDeliveries = db.GetWebhookDeliveries(ctx, true)
+	assert.Equal(t, nil, errDeliveries)
+	assert.Equal(t, []models.WebhookDelivery{delivery1}, deliveries)
+
+	// add inserts pending delivery and keeps delivery recorded for txid and position
+	pending := models.WebhookDelivery{Txid: testDbHash("1", 0), ClientPosition: 2, Pubkey: "pubkey2",
+		Url: "https://client2.example.com"}
+	assert.Equal(t, nil, db.AddWebhookDelivery(ctx, pending))
+	assert.Equal(t, nil, db.AddWebhookDelivery(ctx, models.WebhookDelivery{Txid: delivery0.Txid,
+		ClientPosition: delivery0.ClientPosition, Url: "https://client0.example.com/webhook"}))
+	deliveries, _ = db.GetWebhookDeliveries(ctx, false)
+	assert.Equal(t, []models.WebhookDelivery{pending, delivery0}, deliveries)
+	assert.Equal(t, true, deliveries[0].Pending())
+}
+
+// Test appending, attesting and paging client commitment history
+func testDbClientCommitmentHistory(t *testing.T, ctx context.Context, db Db) {
+	history, errHistory := db.GetClientCommitmentHistory(ctx, 0, 0, 0, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, nil, errHistory)
+	assert.Equal(t, []models.ClientCommitmentHistory{}, history)
+
+	// entries submitted within the same second are ordered by sequence
+	submittedAt := time.Unix(1542121293, 0).UTC()
+	var expected []models.ClientCommitmentHistory
+	for _, hash := range []chainhash.Hash{testDbHash("bbbbbb", 0), testDbHash("bbbbbb", 1), testDbHash("bbbbbb", 0)} {
+		entry := models.ClientCommitmentHistory{Commitment: hash, ClientPosition: 0,
+			SubmittedAt: submittedAt, Submitter: "pubkey0", Signature: "sig"}
+		assert.Equal(t, nil, db.SaveClientCommitmentHistory(ctx, entry))
+		expected = append([]models.ClientCommitmentHistory{entry}, expected...)
+	}
+	other := models.ClientCommitmentHistory{Commitment: testDbHash("bbbbbb", 0), ClientPosition: 1,
+		SubmittedAt: submittedAt, Submitter: "pubkey1", Signature: "sig"}
+	assert.Equal(t, nil, db.SaveClientCommitmentHistory(ctx, other))
+
+	history, errHistory = db.GetClientCommitmentHistory(ctx, 0, 0, 0, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, nil, errHistory)
+	assert.Equal(t, len(expected), len(history))
+	for i := range history {
+		assert.Equal(t, true, i == 0 || history[i].Sequence < history[i-1].Sequence)
+		expected[i].Sequence = history[i].Sequence
+	}
+	assert.Equal(t, expected, history)
+	history, _ = db.GetClientCommitmentHistory(ctx, 0, 0, 0, 1, 1)
+	assert.Equal(t, expected[1:2], history)
+
+	// only entries with sequence after and up to the sequences provided
+	history, _ = db.GetClientCommitmentHistory(ctx, 0, expected[2].Sequence, 0, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, expected[:2], history)
+	history, _ = db.GetClientCommitmentHistory(ctx, 0, expected[2].Sequence, expected[1].Sequence, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, expected[1:2], history)
+	history, _ = db.GetClientCommitmentHistory(ctx, 0, expected[0].Sequence, 0, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, []models.ClientCommitmentHistory{}, history)
+
+	// attest latest unattested matching entry only
+	txid := testDbHash("aaaaaa", 0)
+	merkleCommitment := models.CommitmentMerkleCommitment{MerkleRoot: testDbHash("cccccc", 0),
+		ClientPosition: 0, Commitment: testDbHash("bbbbbb", 0)}
+	assert.Equal(t, nil, db.AttestClientCommitmentHistory(ctx, merkleCommitment, txid))
+	expected[0].Txid = txid
+	expected[0].MerkleRoot = merkleCommitment.MerkleRoot
+	history, _ = db.GetClientCommitmentHistory(ctx, 0, 0, 0, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, expected, history)
+	history, _ = db.GetClientCommitmentHistory(ctx, 1, 0, 0, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	other.Sequence = history[0].Sequence
+	assert.Equal(t, []models.ClientCommitmentHistory{other}, history)
+
+	// attesting again by the same txid is a no-op
+	merkleCommitment.Commitment = testDbHash("bbbbbb", 0)
+	assert.Equal(t, nil, db.AttestClientCommitmentHistory(ctx, merkleCommitment, txid))
+	history, _ = db.GetClientCommitmentHistory(ctx, 0, 0, 0, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, expected, history)
+
+	// no matching entry is not an error
+	merkleCommitment.Commitment = testDbHash("bbbbbb", 2)
+	assert.Equal(t, nil, db.AttestClientCommitmentHistory(ctx, merkleCommitment, txid))
+}
+
+// Test querying attestation history with filters and paging
+func testDbAttestationHistory(t *testing.T, ctx context.Context, db Db) {
+	attestations, errGet := db.GetAttestations(ctx, AttestationFilter{}, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, nil, errGet)
+	assert.Equal(t, []models.Attestation{}, attestations)
+
+	// three confirmed attestations and a latest unconfirmed attestation
+	var expected []models.Attestation
+	for i := 0; i < 4; i++ {
+		commitment, _ := models.NewCommitment([]chainhash.Hash{testDbHash("bbbbbb", i%3)})
+		attestation := models.NewAttestation(testDbHash("aaaaaa", i), commitment)
+		if i < 3 {
+			attestation.Confirmed = true
+			attestation.Info = models.AttestationInfo{Txid: attestation.Txid.String(),
+				Blockhash: testDbHash("cccccc", i).String(), Height: int64(100 + i), Time: int64(1542121293 + 600*i),
+				MerkleBranch: []string{testDbHash("dddddd", i).String()}}
+			assert.Equal(t, nil, db.SaveAttestationInfo(ctx, attestation.Info))
+		}
+		assert.Equal(t, nil, db.SaveAttestation(ctx, *attestation))
+		expected = append([]models.Attestation{attestation.WithoutCommitment()}, expected...)
+		// keep insertion times distinct for backends ordering by time
+		time.Sleep(time.Millisecond)
+	}
+
+	attestations, errGet = db.GetAttestations(ctx, AttestationFilter{}, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, nil, errGet)
+	assert.Equal(t, expected, attestations)
+	attestations, _ = db.GetAttestations(ctx, AttestationFilter{}, 3, 3)
+	assert.Equal(t, expected[3:], attestations)
+
+	confirmed := false
+	attestations, _ = db.GetAttestations(ctx, AttestationFilter{Confirmed: &confirmed}, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, expected[:1], attestations)
+	merkleRoot := expected[0].CommitmentHash()
+	attestations, _ = db.GetAttestations(ctx, AttestationFilter{MerkleRoot: &merkleRoot}, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, []models.Attestation{expected[0], expected[3]}, attestations)
+	attestations, _ = db.GetAttestations(ctx, AttestationFilter{FromTime: int64(1542121293 + 600)}, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, expected[1:3], attestations)
+	attestations, _ = db.GetAttestations(ctx, AttestationFilter{FromHeight: 101, ToHeight: 101}, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, expected[2:3], attestations)
+}
+
+// Test DbFake conformance
+func TestDbFakeConformance(t *testing.T) {
+	testDbConformance(t, func() Db { return NewDbFake() })
+}
+
+// Test saving attestation updates with all their writes
+func testDbAttestationUpdate(t *testing.T, ctx context.Context, db Db) {
+	txid := testDbHash("aaaaaa", 0)
+	hashX, hashY := testDbHash("bbbbbb", 0), testDbHash("bbbbbb", 1)
+	for i, hash := range []chainhash.Hash{hashX, hashY} {
+		assert.Equal(t, nil, db.SaveClientCommitmentHistory(ctx, models.ClientCommitmentHistory{Commitment: hash,
+			ClientPosition: int32(i), SubmittedAt: time.Unix(1542121293, 0).UTC()}))
+	}
+
+	// attestation without commitment saves nothing
+	errSave := db.SaveAttestationUpdate(ctx, *models.NewAttestation(txid, nil))
+	assert.Equal(t, errors.New(models.ERROR_COMMITMENT_NOT_DEFINED), errSave)
+	root, _ := db.GetLatestAttestationMerkleRoot(ctx, false)
+	assert.Equal(t, "", root)
+
+	// unconfirmed attestation saves commitments and proofs
+	commitment, _ := models.NewCommitment([]chainhash.Hash{hashX, hashY})
+	attestation := models.NewAttestation(txid, commitment)
+	assert.Equal(t, nil, db.SaveAttestationUpdate(ctx, *attestation))
+	dbAttestation, _ := db.GetAttestation(ctx, txid)
+	assert.Equal(t, attestation.WithoutCommitment(), dbAttestation)
+	merkleCommitments, _ := db.GetAttestationMerkleCommitments(ctx, txid)
+	assert.Equal(t, commitment.GetMerkleCommitments(), merkleCommitments)
+	emptySlots, _ := db.GetEmptySlots(ctx, commitment.GetCommitmentHash())
+	assert.Equal(t, []int32{}, emptySlots)
+	proof, _ := db.GetMerkleProof(ctx, commitment.GetCommitmentHash(), 1)
+	assert.Equal(t, commitment.GetMerkleProofs()[1], proof)
+	_, errInfo := db.GetAttestationInfo(ctx, txid)
+	assert.NotEqual(t, nil, errInfo)
+	history, _ := db.GetClientCommitmentHistory(ctx, 0, 0, 0, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, false, history[0].Attested())
+
+	// confirmed attestation saves info and attests history
+	attestation.Confirmed = true
+	attestation.Info = models.AttestationInfo{Txid: txid.String(), Blockhash: testDbHash("cccccc", 0).String(),
+		Height: 100, Time: 1542121293, MerkleBranch: []string{testDbHash("dddddd", 0).String()}}
+	assert.Equal(t, nil, db.SaveAttestationUpdate(ctx, *attestation))
+	info, _ := db.GetAttestationInfo(ctx, txid)
+	assert.Equal(t, attestation.Info, info)
+	for i := int32(0); i < 2; i++ {
+		history, _ = db.GetClientCommitmentHistory(ctx, i, 0, 0, 0, ATTESTATIONS_LIMIT_DEFAULT)
+		assert.Equal(t, txid, history[0].Txid)
+		assert.Equal(t, commitment.GetCommitmentHash(), history[0].MerkleRoot)
+	}
+
+	// repeating an update is safe
+	assert.Equal(t, nil, db.SaveAttestationUpdate(ctx, *attestation))
+	attestations, _ := db.GetAttestations(ctx, AttestationFilter{}, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, []models.Attestation{attestation.WithoutCommitment()}, attestations)
+}
+
+// Return test mongoDB connectivity from the MAINSTAY_TEST_DB_HOST, PORT, USER
+// and PASSWORD env, skipping the test if no test mongoDB instance is set
+func testDbMongoConnectivity(t *testing.T) config.DbConnectivity {
+	host := os.Getenv("MAINSTAY_TEST_DB_HOST")
+	if host == "" {
+		t.Skip("MAINSTAY_TEST_DB_HOST not set")
+	}
+	return config.DbConnectivity{Backend: config.DB_BACKEND_MONGO, Host: host,
+		Port: os.Getenv("MAINSTAY_TEST_DB_PORT"), User: os.Getenv("MAINSTAY_TEST_DB_USER"),
+		Password: os.Getenv("MAINSTAY_TEST_DB_PASSWORD"), Name: "mainstayConformanceTest"}
+}
+
+// Test DbMongo conformance
+// The test database is dropped before each test
+func TestDbMongoConformance(t *testing.T) {
+	dbConnectivity := testDbMongoConnectivity(t)
+	ctx := context.Background()
+	testDbConformance(t, func() Db {
+		db := NewDbMongo(ctx, dbConnectivity)
+		assert.Equal(t, nil, db.db.Drop(ctx))
+		assert.Equal(t, nil, ensureMongoSchema(ctx, db.db))
+		return db
+	})
+}
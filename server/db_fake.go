@@ -1,7 +1,10 @@
 package server
 
 import (
+	"context"
 	"errors"
+	"sort"
+	"sync"
 
 	"mainstay/models"
 
@@ -15,6 +18,12 @@ type DbFake struct {
 	merkleCommitments []models.CommitmentMerkleCommitment
 	merkleProofs      []models.CommitmentMerkleProof
 	latestCommitments []models.ClientCommitment
+	commitmentHistory []models.ClientCommitmentHistory
+	clientDetails     []models.ClientDetails
+	revokedDetails    []models.ClientDetails
+	webhookDeliveries []models.WebhookDelivery
+	webhookMu         sync.Mutex // webhook deliveries are saved concurrently
+	saveErr           error
 }
 
 // Return new DbFake instance
@@ -24,11 +33,37 @@ func NewDbFake() *DbFake {
 		[]models.AttestationInfo{},
 		[]models.CommitmentMerkleCommitment{},
 		[]models.CommitmentMerkleProof{},
-		[]models.ClientCommitment{}}
+		[]models.ClientCommitment{},
+		[]models.ClientCommitmentHistory{},
+		[]models.ClientDetails{},
+		[]models.ClientDetails{},
+		[]models.WebhookDelivery{},
+		sync.Mutex{},
+		nil}
+}
+
+// Set error returned by all save operations for testing db write failures
+func (d *DbFake) SetSaveError(err error) {
+	d.saveErr = err
+}
+
+// Return stored attestations for testing
+func (d *DbFake) Attestations() []models.Attestation {
+	return d.attestations
+}
+
+// Return stored attestations info for testing
+func (d *DbFake) AttestationsInfo() []models.AttestationInfo {
+	return d.attestationsInfo
 }
 
 // Save latest attestation to attestations
-func (d *DbFake) saveAttestation(attestation models.Attestation) error {
+// Attestations are stored without their commitment like in the db backends
+func (d *DbFake) SaveAttestation(ctx context.Context, attestation models.Attestation) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
+	attestation = attestation.WithoutCommitment()
 	for i, a := range d.attestations {
 		if a.Txid == attestation.Txid {
 			d.attestations[i] = attestation
@@ -39,8 +74,18 @@ func (d *DbFake) saveAttestation(attestation models.Attestation) error {
 	return nil
 }
 
+// Save attestation update with all its writes
+// Save errors set for testing fail the first write so nothing is saved
+func (d *DbFake) SaveAttestationUpdate(ctx context.Context, attestation models.Attestation,
+	proofs ...models.CommitmentMerkleProof) error {
+	return saveAttestationUpdate(ctx, d, attestation, proofs)
+}
+
 // Save latest attestation info to attestationsInfo
-func (d *DbFake) saveAttestationInfo(attestationInfo models.AttestationInfo) error {
+func (d *DbFake) SaveAttestationInfo(ctx context.Context, attestationInfo models.AttestationInfo) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
 	for i, a := range d.attestationsInfo {
 		if a.Txid == attestationInfo.Txid {
 			d.attestationsInfo[i] = attestationInfo
@@ -52,14 +97,17 @@ func (d *DbFake) saveAttestationInfo(attestationInfo models.AttestationInfo) err
 }
 
 // Save merkle commitments to the MerkleCommitment collection
-func (d *DbFake) saveMerkleCommitments(commitments []models.CommitmentMerkleCommitment) error {
+func (d *DbFake) SaveMerkleCommitments(ctx context.Context, commitments []models.CommitmentMerkleCommitment) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
 	var newCommitments []models.CommitmentMerkleCommitment
 	for _, commitment := range commitments {
 		found := false
-		for _, c := range d.merkleCommitments {
-			if c.MerkleRoot == commitment.MerkleRoot {
+		for i, c := range d.merkleCommitments {
+			if c.MerkleRoot == commitment.MerkleRoot && c.ClientPosition == commitment.ClientPosition {
 				found = true
-				c = commitment
+				d.merkleCommitments[i] = commitment
 				break
 			}
 		}
@@ -72,14 +120,17 @@ func (d *DbFake) saveMerkleCommitments(commitments []models.CommitmentMerkleComm
 }
 
 // Save merkle proofs to the MerkleProof collection
-func (d *DbFake) saveMerkleProofs(proofs []models.CommitmentMerkleProof) error {
+func (d *DbFake) SaveMerkleProofs(ctx context.Context, proofs []models.CommitmentMerkleProof) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
 	var newProofs []models.CommitmentMerkleProof
 	for _, proof := range proofs {
 		found := false
-		for _, p := range d.merkleProofs {
-			if p.MerkleRoot == proof.MerkleRoot {
+		for i, p := range d.merkleProofs {
+			if p.MerkleRoot == proof.MerkleRoot && p.ClientPosition == proof.ClientPosition {
 				found = true
-				p = proof
+				d.merkleProofs[i] = proof
 				break
 			}
 		}
@@ -91,8 +142,30 @@ func (d *DbFake) saveMerkleProofs(proofs []models.CommitmentMerkleProof) error {
 	return nil
 }
 
+// Delete merkle proofs and merkle commitments of the merkle root
+func (d *DbFake) PruneMerkleRoot(ctx context.Context, merkleRoot chainhash.Hash) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
+	merkleProofs := []models.CommitmentMerkleProof{}
+	for _, proof := range d.merkleProofs {
+		if proof.MerkleRoot != merkleRoot {
+			merkleProofs = append(merkleProofs, proof)
+		}
+	}
+	merkleCommitments := []models.CommitmentMerkleCommitment{}
+	for _, commitment := range d.merkleCommitments {
+		if commitment.MerkleRoot != merkleRoot {
+			merkleCommitments = append(merkleCommitments, commitment)
+		}
+	}
+	d.merkleProofs = merkleProofs
+	d.merkleCommitments = merkleCommitments
+	return nil
+}
+
 // Return latest attestation commitment hash
-func (d *DbFake) getLatestAttestationMerkleRoot(confirmed bool) (string, error) {
+func (d *DbFake) GetLatestAttestationMerkleRoot(ctx context.Context, confirmed bool) (string, error) {
 	if len(d.attestations) == 0 {
 		return "", nil
 	}
@@ -110,13 +183,186 @@ func (d *DbFake) SetClientCommitments(latestCommitments []models.ClientCommitmen
 	d.latestCommitments = latestCommitments
 }
 
+// Save client commitment to fake client commitments ordered by position
+func (d *DbFake) SaveClientCommitment(ctx context.Context, commitment models.ClientCommitment) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
+	for i, c := range d.latestCommitments {
+		if c.ClientPosition == commitment.ClientPosition {
+			d.latestCommitments[i] = commitment
+			return nil
+		} else if c.ClientPosition > commitment.ClientPosition {
+			d.latestCommitments = append(d.latestCommitments[:i],
+				append([]models.ClientCommitment{commitment}, d.latestCommitments[i:]...)...)
+			return nil
+		}
+	}
+	d.latestCommitments = append(d.latestCommitments, commitment)
+	return nil
+}
+
+// Append client commitment to fake client commitment history
+// The sequence of history entries is their position in the history
+func (d *DbFake) SaveClientCommitmentHistory(ctx context.Context, history models.ClientCommitmentHistory) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
+	history.Sequence = int64(len(d.commitmentHistory) + 1)
+	d.commitmentHistory = append(d.commitmentHistory, history)
+	return nil
+}
+
+// Set attestation of the latest unattested history entry matching
+// the client position and commitment of the merkle commitment
+// No entry is updated if one is already attested by txid
+func (d *DbFake) AttestClientCommitmentHistory(ctx context.Context, commitment models.CommitmentMerkleCommitment, txid chainhash.Hash) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
+	for _, history := range d.commitmentHistory {
+		if history.ClientPosition == commitment.ClientPosition &&
+			history.Commitment == commitment.Commitment && history.Txid == txid {
+			return nil
+		}
+	}
+	for i := len(d.commitmentHistory) - 1; i >= 0; i-- {
+		history := &d.commitmentHistory[i]
+		if history.ClientPosition == commitment.ClientPosition &&
+			history.Commitment == commitment.Commitment && !history.Attested() {
+			history.Txid = txid
+			history.MerkleRoot = commitment.MerkleRoot
+			return nil
+		}
+	}
+	return nil
+}
+
+// Return client commitment history for position with sequence after the
+// first and up to the second sequence provided, latest first
+// The upper sequence bound is not applied if zero
+func (d *DbFake) GetClientCommitmentHistory(ctx context.Context, position int32, after int64, until int64, skip int64, limit int64) ([]models.ClientCommitmentHistory, error) {
+	history := []models.ClientCommitmentHistory{}
+	for i := len(d.commitmentHistory) - 1; i >= 0 && int64(len(history)) < limit; i-- {
+		entry := d.commitmentHistory[i]
+		if entry.ClientPosition != position || entry.Sequence <= after || (until != 0 && entry.Sequence > until) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		history = append(history, entry)
+	}
+	return history, nil
+}
+
+// Set client details for testing
+func (d *DbFake) SetClientDetails(clientDetails []models.ClientDetails) {
+	d.clientDetails = clientDetails
+}
+
+// Save client details to fake client details ordered by position
+func (d *DbFake) SaveClientDetails(ctx context.Context, details models.ClientDetails) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
+	for i, c := range d.clientDetails {
+		if c.ClientPosition == details.ClientPosition {
+			d.clientDetails[i] = details
+			return nil
+		} else if c.ClientPosition > details.ClientPosition {
+			d.clientDetails = append(d.clientDetails[:i],
+				append([]models.ClientDetails{details}, d.clientDetails[i:]...)...)
+			return nil
+		}
+	}
+	d.clientDetails = append(d.clientDetails, details)
+	return nil
+}
+
+// Return client details from fake client details
+func (d *DbFake) GetClientDetails(ctx context.Context) ([]models.ClientDetails, error) {
+	return d.clientDetails, nil
+}
+
+// Append revoked client details to fake revoked client details
+func (d *DbFake) SaveRevokedClientDetails(ctx context.Context, details models.ClientDetails) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
+	d.revokedDetails = append(d.revokedDetails, details)
+	return nil
+}
+
+// Return revoked client details for position from fake revoked client details, oldest first
+func (d *DbFake) GetRevokedClientDetails(ctx context.Context, position int32) ([]models.ClientDetails, error) {
+	details := []models.ClientDetails{}
+	for _, r := range d.revokedDetails {
+		if r.ClientPosition == position {
+			details = append(details, r)
+		}
+	}
+	sort.SliceStable(details, func(i, j int) bool { return details[i].EndedAt < details[j].EndedAt })
+	return details, nil
+}
+
+// Save webhook delivery to fake webhook deliveries
+// Deliveries are upserted by attestation txid and client position
+func (d *DbFake) SaveWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
+	d.webhookMu.Lock()
+	defer d.webhookMu.Unlock()
+	for i, w := range d.webhookDeliveries {
+		if w.Txid == delivery.Txid && w.ClientPosition == delivery.ClientPosition {
+			d.webhookDeliveries[i] = delivery
+			return nil
+		}
+	}
+	d.webhookDeliveries = append(d.webhookDeliveries, delivery)
+	return nil
+}
+
+// Add webhook delivery to fake webhook deliveries
+// Deliveries already recorded for the attestation txid and client position are kept
+func (d *DbFake) AddWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	if d.saveErr != nil {
+		return d.saveErr
+	}
+	d.webhookMu.Lock()
+	defer d.webhookMu.Unlock()
+	for _, w := range d.webhookDeliveries {
+		if w.Txid == delivery.Txid && w.ClientPosition == delivery.ClientPosition {
+			return nil
+		}
+	}
+	d.webhookDeliveries = append(d.webhookDeliveries, delivery)
+	return nil
+}
+
+// Return fake webhook deliveries that have or have not been redelivered, oldest failure first
+func (d *DbFake) GetWebhookDeliveries(ctx context.Context, delivered bool) ([]models.WebhookDelivery, error) {
+	d.webhookMu.Lock()
+	defer d.webhookMu.Unlock()
+	deliveries := []models.WebhookDelivery{}
+	for _, w := range d.webhookDeliveries {
+		if w.Delivered == delivered {
+			deliveries = append(deliveries, w)
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].FailedAt.Before(deliveries[j].FailedAt) })
+	return deliveries, nil
+}
+
 // Return latest commitment from fake client commitments
-func (d *DbFake) getClientCommitments() ([]models.ClientCommitment, error) {
+func (d *DbFake) GetClientCommitments(ctx context.Context) ([]models.ClientCommitment, error) {
 	return d.latestCommitments, nil
 }
 
 // Return commitment for attestation with given txid
-func (d *DbFake) getAttestationMerkleCommitments(txid chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
+func (d *DbFake) GetAttestationMerkleCommitments(ctx context.Context, txid chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
 	if len(d.attestations) == 0 {
 		return []models.CommitmentMerkleCommitment{}, nil
 	}
@@ -138,3 +384,123 @@ func (d *DbFake) getAttestationMerkleCommitments(txid chainhash.Hash) ([]models.
 	}
 	return merkleCommitments, nil
 }
+
+// Return positions of the zero merkle commitments of a merkle root
+func (d *DbFake) GetEmptySlots(ctx context.Context, merkleRoot chainhash.Hash) ([]int32, error) {
+	emptySlots := []int32{}
+	for _, commitment := range d.merkleCommitments {
+		if commitment.MerkleRoot == merkleRoot && (commitment.Commitment == chainhash.Hash{}) {
+			emptySlots = append(emptySlots, commitment.ClientPosition)
+		}
+	}
+	sort.Slice(emptySlots, func(i, j int) bool { return emptySlots[i] < emptySlots[j] })
+	return emptySlots, nil
+}
+
+// Return attestation with given txid
+func (d *DbFake) GetAttestation(ctx context.Context, txid chainhash.Hash) (models.Attestation, error) {
+	for _, attestation := range d.attestations {
+		if attestation.Txid == txid {
+			return attestation, nil
+		}
+	}
+	return models.Attestation{}, errors.New(ERROR_ATTESTATION_GET)
+}
+
+// Return merkle root of attestation with given txid
+func (d *DbFake) GetAttestationMerkleRoot(ctx context.Context, txid chainhash.Hash) (string, error) {
+	if len(d.attestations) == 0 {
+		return "", nil
+	}
+	attestation, errAttestation := d.GetAttestation(ctx, txid)
+	if errAttestation != nil {
+		return "", errAttestation
+	}
+	return attestation.CommitmentHash().String(), nil
+}
+
+// Return latest attestation with given merkle root preferring confirmed attestations
+func (d *DbFake) GetMerkleRootAttestation(ctx context.Context, merkleRoot chainhash.Hash) (models.Attestation, error) {
+	var latest *models.Attestation
+	for i := len(d.attestations) - 1; i >= 0; i-- {
+		if d.attestations[i].CommitmentHash() == merkleRoot {
+			if d.attestations[i].Confirmed {
+				return d.attestations[i], nil
+			} else if latest == nil {
+				latest = &d.attestations[i]
+			}
+		}
+	}
+	if latest == nil {
+		return models.Attestation{}, errors.New(ERROR_ATTESTATION_GET)
+	}
+	return *latest, nil
+}
+
+// Return attestation info for attestation with given txid
+func (d *DbFake) GetAttestationInfo(ctx context.Context, txid chainhash.Hash) (models.AttestationInfo, error) {
+	for _, info := range d.attestationsInfo {
+		if info.Txid == txid.String() {
+			return info, nil
+		}
+	}
+	return models.AttestationInfo{}, errors.New(ERROR_ATTESTATION_INFO_GET)
+}
+
+// Return merkle proof for merkle root and client position
+func (d *DbFake) GetMerkleProof(ctx context.Context, merkleRoot chainhash.Hash, position int32) (models.CommitmentMerkleProof, error) {
+	for _, proof := range d.merkleProofs {
+		if proof.MerkleRoot == merkleRoot && proof.ClientPosition == position {
+			return proof, nil
+		}
+	}
+	return models.CommitmentMerkleProof{}, errors.New(ERROR_MERKLE_PROOF_GET)
+}
+
+// Return attestations matching the filter, latest first, with info set
+// for confirmed attestations, skipping and limiting the results for paging
+func (d *DbFake) GetAttestations(ctx context.Context, filter AttestationFilter, skip int64, limit int64) ([]models.Attestation, error) {
+	attestations := []models.Attestation{}
+	for i := len(d.attestations) - 1; i >= 0 && int64(len(attestations)) < limit; i-- {
+		attestation := d.attestations[i]
+		if attestation.Confirmed {
+			info, errInfo := d.GetAttestationInfo(ctx, attestation.Txid)
+			if errInfo == nil {
+				attestation.Info = info
+			}
+		}
+		if !filter.matches(attestation) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		attestations = append(attestations, attestation)
+	}
+	return attestations, nil
+}
+
+// Check whether attestation and its info match the filter
+// Info is ignored for unconfirmed attestations
+func (f AttestationFilter) matches(attestation models.Attestation) bool {
+	info := attestation.Info
+	if f.Confirmed != nil && attestation.Confirmed != *f.Confirmed {
+		return false
+	}
+	if f.MerkleRoot != nil && attestation.CommitmentHash() != *f.MerkleRoot {
+		return false
+	}
+	if f.hasInfoFilter() {
+		if !attestation.Confirmed {
+			return false
+		}
+		if (f.FromTime != 0 && info.Time < f.FromTime) || (f.ToTime != 0 && info.Time > f.ToTime) {
+			return false
+		}
+		if (f.FromHeight != 0 && info.Height < f.FromHeight) || (f.ToHeight != 0 && info.Height > f.ToHeight) {
+			return false
+		}
+	}
+	return true
+}
@@ -17,39 +17,65 @@ import (
 
 const (
 	// collection names
-	COL_NAME_ATTESTATION       = "Attestation"
-	COL_NAME_ATTESTATION_INFO  = "AttestationInfo"
-	COL_NAME_MERKLE_COMMITMENT = "MerkleCommitment"
-	COL_NAME_MERKLE_PROOF      = "MerkleProof"
-	COL_NAME_CLIENT_COMMITMENT = "ClientCommitment"
-	COL_NAME_CLIENT_DETAILS    = "ClientDetails"
+	COL_NAME_ATTESTATION               = "Attestation"
+	COL_NAME_ATTESTATION_INFO          = "AttestationInfo"
+	COL_NAME_MERKLE_COMMITMENT         = "MerkleCommitment"
+	COL_NAME_MERKLE_PROOF              = "MerkleProof"
+	COL_NAME_CLIENT_COMMITMENT         = "ClientCommitment"
+	COL_NAME_CLIENT_DETAILS            = "ClientDetails"
+	COL_NAME_REVOKED_CLIENT_DETAILS    = "RevokedClientDetails"
+	COL_NAME_CLIENT_COMMITMENT_HISTORY = "ClientCommitmentHistory"
+	COL_NAME_WEBHOOK_DELIVERY          = "WebhookDelivery"
+	COL_NAME_SEQUENCE                  = "Sequence"
+
+	// value field of sequence documents
+	SEQUENCE_VALUE_NAME = "value"
+
+	// field of attestation info joined to attestation documents
+	ATTESTATION_INFO_FIELD = "info"
 
 	// error messages
 	ERROR_MONGO_CLIENT  = "could not create mongoDB client"
 	ERROR_MONGO_CONNECT = "could not connect to mongoDB client"
 	ERROR_MONGO_PING    = "could not ping mongoDB database"
 
-	ERROR_ATTESTATION_SAVE       = "could not save attestation"
-	ERROR_ATTESTATION_INFO_SAVE  = "could not save attestation info"
-	ERROR_MERKLE_COMMITMENT_SAVE = "could not save merkle commitment"
-	ERROR_MERKLE_PROOF_SAVE      = "could not save merkle proof"
-	ERROR_CLIENT_DETAILS_SAVE    = "could not save client details"
-
-	ERROR_ATTESTATION_GET       = "could not get attestation"
-	ERROR_MERKLE_COMMITMENT_GET = "could not get merkle commitment"
-	ERROR_MERKLE_PROOF_GET      = "could not get merkle proof"
-	ERROR_CLIENT_COMMITMENT_GET = "could not get client commitment"
-	ERROR_CLIENT_DETAILS_GET    = "could not get client details"
-
-	BAD_DATA_CLIENT_COMMITMENT_COL = "bad data in client commitment collection"
-	BAD_DATA_MERKLE_COMMITMENT_COL = "bad data in merkle commitment collection"
-	BAD_DATA_CLIENT_DETAILS_COL    = "bad data in client details collection"
-
-	BAD_DATA_ATTESTATION_MODEL       = "bad data in attestation model"
-	BAD_DATA_ATTESTATION_INFO_MODEL  = "bad data in attestation info model"
-	BAD_DATA_MERKLE_COMMITMENT_MODEL = "bad data in merkle commitment model"
-	BAD_DATA_MERKLE_PROOF_MODEL      = "bad data in merkle proof model"
-	BAD_DATA_CLIENT_DETAILS_MODEL    = "bad data in client details model"
+	ERROR_ATTESTATION_SAVE               = "could not save attestation"
+	ERROR_ATTESTATION_INFO_SAVE          = "could not save attestation info"
+	ERROR_MERKLE_COMMITMENT_SAVE         = "could not save merkle commitment"
+	ERROR_MERKLE_PROOF_SAVE              = "could not save merkle proof"
+	ERROR_CLIENT_DETAILS_SAVE            = "could not save client details"
+	ERROR_CLIENT_COMMITMENT_SAVE         = "could not save client commitment"
+	ERROR_CLIENT_COMMITMENT_HISTORY_SAVE = "could not save client commitment history"
+	ERROR_WEBHOOK_DELIVERY_SAVE          = "could not save webhook delivery"
+	ERROR_MERKLE_ROOT_PRUNE              = "could not prune merkle root"
+	ERROR_SEQUENCE_NEXT                  = "could not get next sequence"
+
+	ERROR_ATTESTATION_GET               = "could not get attestation"
+	ERROR_MERKLE_COMMITMENT_GET         = "could not get merkle commitment"
+	ERROR_MERKLE_PROOF_GET              = "could not get merkle proof"
+	ERROR_CLIENT_COMMITMENT_GET         = "could not get client commitment"
+	ERROR_CLIENT_DETAILS_GET            = "could not get client details"
+	ERROR_ATTESTATION_INFO_GET          = "could not get attestation info"
+	ERROR_CLIENT_COMMITMENT_HISTORY_GET = "could not get client commitment history"
+	ERROR_WEBHOOK_DELIVERY_GET          = "could not get webhook delivery"
+
+	BAD_DATA_CLIENT_COMMITMENT_COL         = "bad data in client commitment collection"
+	BAD_DATA_MERKLE_COMMITMENT_COL         = "bad data in merkle commitment collection"
+	BAD_DATA_CLIENT_DETAILS_COL            = "bad data in client details collection"
+	BAD_DATA_ATTESTATION_COL               = "bad data in attestation collection"
+	BAD_DATA_ATTESTATION_INFO_COL          = "bad data in attestation info collection"
+	BAD_DATA_MERKLE_PROOF_COL              = "bad data in merkle proof collection"
+	BAD_DATA_CLIENT_COMMITMENT_HISTORY_COL = "bad data in client commitment history collection"
+	BAD_DATA_WEBHOOK_DELIVERY_COL          = "bad data in webhook delivery collection"
+
+	BAD_DATA_ATTESTATION_MODEL               = "bad data in attestation model"
+	BAD_DATA_ATTESTATION_INFO_MODEL          = "bad data in attestation info model"
+	BAD_DATA_MERKLE_COMMITMENT_MODEL         = "bad data in merkle commitment model"
+	BAD_DATA_MERKLE_PROOF_MODEL              = "bad data in merkle proof model"
+	BAD_DATA_CLIENT_DETAILS_MODEL            = "bad data in client details model"
+	BAD_DATA_CLIENT_COMMITMENT_MODEL         = "bad data in client commitment model"
+	BAD_DATA_CLIENT_COMMITMENT_HISTORY_MODEL = "bad data in client commitment history model"
+	BAD_DATA_WEBHOOK_DELIVERY_MODEL          = "bad data in webhook delivery model"
 )
 
 // Method to connect to mongo database through config
@@ -82,24 +108,34 @@ func dbConnect(ctx context.Context, dbConnectivity config.DbConnectivity) (*mong
 }
 
 // DbMongo struct
+// transactions is set if the deployment supports multi-document transactions
 type DbMongo struct {
-	ctx            context.Context
 	dbConnectivity config.DbConnectivity
 	db             *mongo.Database
+	transactions   bool
 }
 
 // Return new DbMongo instance
+// The database is migrated to the latest schema version with indexes ensured
+// and any incomplete journaled attestation update is repaired
 func NewDbMongo(ctx context.Context, dbConnectivity config.DbConnectivity) *DbMongo {
 	db, errConnect := dbConnect(ctx, dbConnectivity)
 	if errConnect != nil {
 		log.Fatal(errConnect)
 	}
+	if errSchema := ensureMongoSchema(ctx, db); errSchema != nil {
+		log.Fatal(errSchema)
+	}
 
-	return &DbMongo{ctx, dbConnectivity, db}
+	d := &DbMongo{dbConnectivity, db, mongoSupportsTransactions(ctx, db)}
+	if errReplay := d.replayAttestationJournal(ctx); errReplay != nil {
+		log.Fatal(errReplay)
+	}
+	return d
 }
 
 // Save latest attestation to the Attestation collection
-func (d *DbMongo) saveAttestation(attestation models.Attestation) error {
+func (d *DbMongo) SaveAttestation(ctx context.Context, attestation models.Attestation) error {
 
 	// get document representation of Attestation object
 	docAttestation, docErr := models.GetDocumentFromModel(attestation)
@@ -121,7 +157,7 @@ func (d *DbMongo) saveAttestation(attestation models.Attestation) error {
 	t := bson.NewDocument()
 	opts := &options.FindOneAndUpdateOptions{}
 	opts.SetUpsert(true)
-	res := d.db.Collection(COL_NAME_ATTESTATION).FindOneAndUpdate(d.ctx, filterAttestation, newAttestation, opts)
+	res := d.db.Collection(COL_NAME_ATTESTATION).FindOneAndUpdate(ctx, filterAttestation, newAttestation, opts)
 	resErr := res.Decode(t)
 	if resErr != nil && resErr != mongo.ErrNoDocuments {
 		return errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_SAVE, resErr))
@@ -131,7 +167,7 @@ func (d *DbMongo) saveAttestation(attestation models.Attestation) error {
 }
 
 // Save latest attestation info to the Attestation info collection
-func (d *DbMongo) saveAttestationInfo(attestationInfo models.AttestationInfo) error {
+func (d *DbMongo) SaveAttestationInfo(ctx context.Context, attestationInfo models.AttestationInfo) error {
 
 	// get document representation of AttestationInfo object
 	docAttestationInfo, docErr := models.GetDocumentFromModel(attestationInfo)
@@ -152,7 +188,7 @@ func (d *DbMongo) saveAttestationInfo(attestationInfo models.AttestationInfo) er
 	t := bson.NewDocument()
 	opts := &options.FindOneAndUpdateOptions{}
 	opts.SetUpsert(true)
-	res := d.db.Collection(COL_NAME_ATTESTATION_INFO).FindOneAndUpdate(d.ctx, filterAttestationInfo, newAttestationInfo, opts)
+	res := d.db.Collection(COL_NAME_ATTESTATION_INFO).FindOneAndUpdate(ctx, filterAttestationInfo, newAttestationInfo, opts)
 	resErr := res.Decode(t)
 	if resErr != nil && resErr != mongo.ErrNoDocuments {
 		return errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_INFO_SAVE, resErr))
@@ -162,7 +198,7 @@ func (d *DbMongo) saveAttestationInfo(attestationInfo models.AttestationInfo) er
 }
 
 // Save merkle commitments to the MerkleCommitment collection
-func (d *DbMongo) saveMerkleCommitments(commitments []models.CommitmentMerkleCommitment) error {
+func (d *DbMongo) SaveMerkleCommitments(ctx context.Context, commitments []models.CommitmentMerkleCommitment) error {
 	for pos := range commitments {
 		// get document representation of each commitment
 		// get document representation of Attestation object
@@ -187,7 +223,7 @@ func (d *DbMongo) saveMerkleCommitments(commitments []models.CommitmentMerkleCom
 		t := bson.NewDocument()
 		opts := &options.FindOneAndUpdateOptions{}
 		opts.SetUpsert(true)
-		res := d.db.Collection(COL_NAME_MERKLE_COMMITMENT).FindOneAndUpdate(d.ctx, filterMerkleCommitment, newCommitment, opts)
+		res := d.db.Collection(COL_NAME_MERKLE_COMMITMENT).FindOneAndUpdate(ctx, filterMerkleCommitment, newCommitment, opts)
 		resErr := res.Decode(t)
 		if resErr != nil && resErr != mongo.ErrNoDocuments {
 			return errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_COMMITMENT_SAVE, resErr))
@@ -197,7 +233,7 @@ func (d *DbMongo) saveMerkleCommitments(commitments []models.CommitmentMerkleCom
 }
 
 // Save merkle proofs to the MerkleProof collection
-func (d *DbMongo) saveMerkleProofs(proofs []models.CommitmentMerkleProof) error {
+func (d *DbMongo) SaveMerkleProofs(ctx context.Context, proofs []models.CommitmentMerkleProof) error {
 	for pos := range proofs {
 		// get document representation of merkle proof
 		docProof, docErr := models.GetDocumentFromModel(proofs[pos])
@@ -221,7 +257,7 @@ func (d *DbMongo) saveMerkleProofs(proofs []models.CommitmentMerkleProof) error
 		t := bson.NewDocument()
 		opts := &options.FindOneAndUpdateOptions{}
 		opts.SetUpsert(true)
-		res := d.db.Collection(COL_NAME_MERKLE_PROOF).FindOneAndUpdate(d.ctx, filterMerkleProof, newProof, opts)
+		res := d.db.Collection(COL_NAME_MERKLE_PROOF).FindOneAndUpdate(ctx, filterMerkleProof, newProof, opts)
 		resErr := res.Decode(t)
 		if resErr != nil && resErr != mongo.ErrNoDocuments {
 			return errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_PROOF_SAVE, resErr))
@@ -230,41 +266,201 @@ func (d *DbMongo) saveMerkleProofs(proofs []models.CommitmentMerkleProof) error
 	return nil
 }
 
-// Save client details to ClientDetails collection
-func (d *DbMongo) SaveClientDetails(details models.ClientDetails) error {
-	// get document representation of client details
-	docDetails, docErr := models.GetDocumentFromModel(details)
+// Delete merkle proofs and then merkle commitments of the merkle root
+// from the MerkleProof and MerkleCommitment collections
+func (d *DbMongo) PruneMerkleRoot(ctx context.Context, merkleRoot chainhash.Hash) error {
+	filterProofs := bson.NewDocument(bson.EC.String(models.PROOF_MERKLE_ROOT_NAME, merkleRoot.String()))
+	_, resErr := d.db.Collection(COL_NAME_MERKLE_PROOF).DeleteMany(ctx, filterProofs)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_ROOT_PRUNE, resErr))
+	}
+	filterCommitments := bson.NewDocument(bson.EC.String(models.COMMITMENT_MERKLE_ROOT_NAME, merkleRoot.String()))
+	_, resErr = d.db.Collection(COL_NAME_MERKLE_COMMITMENT).DeleteMany(ctx, filterCommitments)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_ROOT_PRUNE, resErr))
+	}
+	return nil
+}
+
+// Save client commitment to ClientCommitment collection
+func (d *DbMongo) SaveClientCommitment(ctx context.Context, commitment models.ClientCommitment) error {
+	// get document representation of client commitment
+	docCommitment, docErr := models.GetDocumentFromModel(commitment)
 	if docErr != nil {
-		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_DETAILS_MODEL, docErr))
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_COMMITMENT_MODEL, docErr))
 	}
 
-	newDetails := bson.NewDocument(
-		bson.EC.SubDocument("$set", docDetails),
+	newCommitment := bson.NewDocument(
+		bson.EC.SubDocument("$set", docCommitment),
 	)
 
-	// search if client details for position already exists
-	filterClientDetails := bson.NewDocument(
-		bson.EC.Int32(models.CLIENT_DETAILS_CLIENT_POSITION_NAME,
-			docDetails.Lookup(models.CLIENT_DETAILS_CLIENT_POSITION_NAME).Int32()),
+	// search if client commitment for position already exists
+	filterClientCommitment := bson.NewDocument(
+		bson.EC.Int32(models.CLIENT_COMMITMENT_CLIENT_POSITION_NAME,
+			docCommitment.Lookup(models.CLIENT_COMMITMENT_CLIENT_POSITION_NAME).Int32()),
 	)
 
-	// insert or update client details
+	// insert or update client commitment
 	t := bson.NewDocument()
 	opts := &options.FindOneAndUpdateOptions{}
 	opts.SetUpsert(true)
-	res := d.db.Collection(COL_NAME_CLIENT_DETAILS).FindOneAndUpdate(d.ctx, filterClientDetails, newDetails, opts)
+	res := d.db.Collection(COL_NAME_CLIENT_COMMITMENT).FindOneAndUpdate(ctx, filterClientCommitment, newCommitment, opts)
+	resErr := res.Decode(t)
+	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_SAVE, resErr))
+	}
+	return nil
+}
+
+// Append client commitment to ClientCommitmentHistory collection
+// History entries are saved with the next ClientCommitmentHistory sequence
+func (d *DbMongo) SaveClientCommitmentHistory(ctx context.Context, history models.ClientCommitmentHistory) error {
+	sequence, errSequence := nextMongoSequence(ctx, d.db, COL_NAME_CLIENT_COMMITMENT_HISTORY)
+	if errSequence != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_SAVE, errSequence))
+	}
+	history.Sequence = sequence
+
+	// get document representation of client commitment history
+	docHistory, docErr := models.GetDocumentFromModel(history)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_COMMITMENT_HISTORY_MODEL, docErr))
+	}
+
+	// always insert as history is append-only
+	_, resErr := d.db.Collection(COL_NAME_CLIENT_COMMITMENT_HISTORY).InsertOne(ctx, docHistory)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_SAVE, resErr))
+	}
+	return nil
+}
+
+// Return next value of the named sequence of the Sequence collection
+// Sequences start from 1 and increase by 1 on each call
+func nextMongoSequence(ctx context.Context, db *mongo.Database, name string) (int64, error) {
+	filterSequence := bson.NewDocument(bson.EC.String("_id", name))
+	incSequence := bson.NewDocument(
+		bson.EC.SubDocumentFromElements("$inc", bson.EC.Int64(SEQUENCE_VALUE_NAME, 1)))
+
+	sequenceDoc := bson.NewDocument()
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	opts.SetReturnDocument(options.After)
+	resErr := db.Collection(COL_NAME_SEQUENCE).FindOneAndUpdate(ctx, filterSequence, incSequence, opts).Decode(sequenceDoc)
+	if resErr != nil {
+		return 0, errors.New(fmt.Sprintf("%s %s: %v", ERROR_SEQUENCE_NEXT, name, resErr))
+	}
+	return sequenceDoc.Lookup(SEQUENCE_VALUE_NAME).Int64(), nil
+}
+
+// Set attestation of the latest unattested history entry matching
+// the client position and commitment of the merkle commitment
+// No entry is updated if one is already attested by txid
+func (d *DbMongo) AttestClientCommitmentHistory(ctx context.Context, commitment models.CommitmentMerkleCommitment, txid chainhash.Hash) error {
+	filterAttested := bson.NewDocument(
+		bson.EC.Int32(models.CLIENT_COMMITMENT_HISTORY_CLIENT_POSITION_NAME, commitment.ClientPosition),
+		bson.EC.String(models.CLIENT_COMMITMENT_HISTORY_COMMITMENT_NAME, commitment.Commitment.String()),
+		bson.EC.String(models.CLIENT_COMMITMENT_HISTORY_TXID_NAME, txid.String()),
+	)
+	attested, countErr := d.db.Collection(COL_NAME_CLIENT_COMMITMENT_HISTORY).Count(ctx, filterAttested)
+	if countErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_SAVE, countErr))
+	} else if attested > 0 {
+		return nil
+	}
+
+	filterHistory := bson.NewDocument(
+		bson.EC.Int32(models.CLIENT_COMMITMENT_HISTORY_CLIENT_POSITION_NAME, commitment.ClientPosition),
+		bson.EC.String(models.CLIENT_COMMITMENT_HISTORY_COMMITMENT_NAME, commitment.Commitment.String()),
+		bson.EC.String(models.CLIENT_COMMITMENT_HISTORY_TXID_NAME, ""),
+	)
+	attestHistory := bson.NewDocument(
+		bson.EC.SubDocumentFromElements("$set",
+			bson.EC.String(models.CLIENT_COMMITMENT_HISTORY_TXID_NAME, txid.String()),
+			bson.EC.String(models.CLIENT_COMMITMENT_HISTORY_MERKLE_ROOT_NAME, commitment.MerkleRoot.String())),
+	)
+
+	// update latest entry only - no entry to update is not an error
+	t := bson.NewDocument()
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetSort(bson.NewDocument(bson.EC.Int32(models.CLIENT_COMMITMENT_HISTORY_SEQUENCE_NAME, -1)))
+	res := d.db.Collection(COL_NAME_CLIENT_COMMITMENT_HISTORY).FindOneAndUpdate(ctx, filterHistory, attestHistory, opts)
 	resErr := res.Decode(t)
 	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_SAVE, resErr))
+	}
+	return nil
+}
+
+// Save client details to ClientDetails collection
+// The whole client details document is replaced so that cleared fields are removed
+func (d *DbMongo) SaveClientDetails(ctx context.Context, details models.ClientDetails) error {
+	// get document representation of client details
+	docDetails, docErr := models.GetDocumentFromModel(details)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_DETAILS_MODEL, docErr))
+	}
+
+	// search if client details for position already exists
+	filterClientDetails := bson.NewDocument(
+		bson.EC.Int32(models.CLIENT_DETAILS_CLIENT_POSITION_NAME, details.ClientPosition),
+	)
+
+	// insert or replace client details
+	opts := &options.ReplaceOptions{}
+	opts.SetUpsert(true)
+	_, resErr := d.db.Collection(COL_NAME_CLIENT_DETAILS).ReplaceOne(ctx, filterClientDetails, docDetails, opts)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_DETAILS_SAVE, resErr))
+	}
+	return nil
+}
+
+// Append revoked client details to RevokedClientDetails collection
+func (d *DbMongo) SaveRevokedClientDetails(ctx context.Context, details models.ClientDetails) error {
+	docDetails, docErr := models.GetDocumentFromModel(details)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_DETAILS_MODEL, docErr))
+	}
+	_, resErr := d.db.Collection(COL_NAME_REVOKED_CLIENT_DETAILS).InsertOne(ctx, docDetails)
+	if resErr != nil {
 		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_DETAILS_SAVE, resErr))
 	}
 	return nil
 }
 
+// Get revoked client details for position oldest first
+func (d *DbMongo) GetRevokedClientDetails(ctx context.Context, position int32) ([]models.ClientDetails, error) {
+	sortFilter := bson.NewDocument(bson.EC.Int64(models.CLIENT_DETAILS_ENDED_AT_NAME, 1), bson.EC.Int32("_id", 1))
+	filterPosition := bson.NewDocument(bson.EC.Int32(models.CLIENT_DETAILS_CLIENT_POSITION_NAME, position))
+	res, resErr := d.db.Collection(COL_NAME_REVOKED_CLIENT_DETAILS).Find(ctx, filterPosition, &options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []models.ClientDetails{}, errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_DETAILS_GET, resErr))
+	}
+
+	details := []models.ClientDetails{}
+	for res.Next(ctx) {
+		detailsDoc := bson.NewDocument()
+		if err := res.Decode(detailsDoc); err != nil {
+			return []models.ClientDetails{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_DETAILS_COL, err))
+		}
+		detailsModel := &models.ClientDetails{}
+		if modelErr := models.GetModelFromDocument(detailsDoc, detailsModel); modelErr != nil {
+			return []models.ClientDetails{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_DETAILS_COL, modelErr))
+		}
+		details = append(details, *detailsModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.ClientDetails{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_DETAILS_COL, err))
+	}
+	return details, nil
+}
+
 // Get latest ClientDetails document
-func (d *DbMongo) GetClientDetails() ([]models.ClientDetails, error) {
+func (d *DbMongo) GetClientDetails(ctx context.Context) ([]models.ClientDetails, error) {
 	// sort by client position
 	sortFilter := bson.NewDocument(bson.EC.Int32(models.CLIENT_DETAILS_CLIENT_POSITION_NAME, 1))
-	res, resErr := d.db.Collection(COL_NAME_CLIENT_DETAILS).Find(d.ctx, bson.NewDocument(), &options.FindOptions{Sort: sortFilter})
+	res, resErr := d.db.Collection(COL_NAME_CLIENT_DETAILS).Find(ctx, bson.NewDocument(), &options.FindOptions{Sort: sortFilter})
 	if resErr != nil {
 		return []models.ClientDetails{},
 			errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_DETAILS_GET, resErr))
@@ -272,7 +468,7 @@ func (d *DbMongo) GetClientDetails() ([]models.ClientDetails, error) {
 
 	// iterate through details
 	var details []models.ClientDetails
-	for res.Next(d.ctx) {
+	for res.Next(ctx) {
 		detailsDoc := bson.NewDocument()
 		if err := res.Decode(detailsDoc); err != nil {
 			return []models.ClientDetails{},
@@ -292,11 +488,11 @@ func (d *DbMongo) GetClientDetails() ([]models.ClientDetails, error) {
 }
 
 // Get Attestation collection document count
-func (d *DbMongo) getLatestAttestationCount() (int64, error) {
+func (d *DbMongo) getLatestAttestationCount(ctx context.Context) (int64, error) {
 	// find latest attestation count
 	opts := options.CountOptions{}
 	opts.SetLimit(1)
-	count, countErr := d.db.Collection(COL_NAME_ATTESTATION).Count(d.ctx, nil, &opts)
+	count, countErr := d.db.Collection(COL_NAME_ATTESTATION).Count(ctx, nil, &opts)
 	if countErr != nil {
 		return 0, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, countErr))
 	}
@@ -305,9 +501,9 @@ func (d *DbMongo) getLatestAttestationCount() (int64, error) {
 }
 
 // Get Attestation entry from collection and return merkle_root field
-func (d *DbMongo) getLatestAttestationMerkleRoot(confirmed bool) (string, error) {
+func (d *DbMongo) GetLatestAttestationMerkleRoot(ctx context.Context, confirmed bool) (string, error) {
 	// first check if attestation has any documents
-	count, countErr := d.getLatestAttestationCount()
+	count, countErr := d.getLatestAttestationCount(ctx)
 	if countErr != nil {
 		return "", countErr
 	} else if count == 0 { // no attestations yet
@@ -319,7 +515,7 @@ func (d *DbMongo) getLatestAttestationMerkleRoot(confirmed bool) (string, error)
 	confirmedFilter := bson.NewDocument(bson.EC.Boolean(models.ATTESTATION_CONFIRMED_NAME, confirmed))
 
 	attestationDoc := bson.NewDocument()
-	resErr := d.db.Collection(COL_NAME_ATTESTATION).FindOne(d.ctx,
+	resErr := d.db.Collection(COL_NAME_ATTESTATION).FindOne(ctx,
 		confirmedFilter, &options.FindOneOptions{Sort: sortFilter}).Decode(attestationDoc)
 	if resErr != nil {
 		return "", errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, resErr))
@@ -328,9 +524,9 @@ func (d *DbMongo) getLatestAttestationMerkleRoot(confirmed bool) (string, error)
 }
 
 // Return Commitment from MerkleCommitment commitments for attestation with given txid hash
-func (d *DbMongo) getAttestationMerkleRoot(txid chainhash.Hash) (string, error) {
+func (d *DbMongo) GetAttestationMerkleRoot(ctx context.Context, txid chainhash.Hash) (string, error) {
 	// first check if attestation has any documents
-	count, countErr := d.getLatestAttestationCount()
+	count, countErr := d.getLatestAttestationCount(ctx)
 	if countErr != nil {
 		return "", countErr
 	} else if count == 0 { // no attestations yet
@@ -341,7 +537,7 @@ func (d *DbMongo) getAttestationMerkleRoot(txid chainhash.Hash) (string, error)
 	filterAttestation := bson.NewDocument(bson.EC.String(models.ATTESTATION_TXID_NAME, txid.String()))
 
 	attestationDoc := bson.NewDocument()
-	resErr := d.db.Collection(COL_NAME_ATTESTATION).FindOne(d.ctx, filterAttestation).Decode(attestationDoc)
+	resErr := d.db.Collection(COL_NAME_ATTESTATION).FindOne(ctx, filterAttestation).Decode(attestationDoc)
 	if resErr != nil {
 		return "", errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, resErr))
 	}
@@ -349,19 +545,41 @@ func (d *DbMongo) getAttestationMerkleRoot(txid chainhash.Hash) (string, error)
 }
 
 // Return Commitment from MerkleCommitment commitments for attestation with given txid hash
-func (d *DbMongo) getAttestationMerkleCommitments(txid chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
+func (d *DbMongo) GetAttestationMerkleCommitments(ctx context.Context, txid chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
 	// get merkle root of attestation
-	merkleRoot, rootErr := d.getAttestationMerkleRoot(txid)
+	merkleRoot, rootErr := d.GetAttestationMerkleRoot(ctx, txid)
 	if rootErr != nil {
 		return []models.CommitmentMerkleCommitment{}, rootErr
 	} else if merkleRoot == "" {
 		return []models.CommitmentMerkleCommitment{}, nil
 	}
 
-	// filter MerkleCommitment collection by merkle_root and sort for client position
-	sortFilter := bson.NewDocument(bson.EC.Int32(models.COMMITMENT_CLIENT_POSITION_NAME, 1))
+	// filter MerkleCommitment collection by merkle_root
 	filterMerkleRoot := bson.NewDocument(bson.EC.String(models.COMMITMENT_MERKLE_ROOT_NAME, merkleRoot))
-	res, resErr := d.db.Collection(COL_NAME_MERKLE_COMMITMENT).Find(d.ctx, filterMerkleRoot, &options.FindOptions{Sort: sortFilter})
+	return d.findMerkleCommitments(ctx, filterMerkleRoot)
+}
+
+// Return positions of the zero merkle commitments of a merkle root
+func (d *DbMongo) GetEmptySlots(ctx context.Context, merkleRoot chainhash.Hash) ([]int32, error) {
+	filterEmpty := bson.NewDocument(
+		bson.EC.String(models.COMMITMENT_MERKLE_ROOT_NAME, merkleRoot.String()),
+		bson.EC.String(models.COMMITMENT_COMMITMENT_NAME, (chainhash.Hash{}).String()))
+	merkleCommitments, errCommitments := d.findMerkleCommitments(ctx, filterEmpty)
+	if errCommitments != nil {
+		return []int32{}, errCommitments
+	}
+	emptySlots := []int32{}
+	for _, c := range merkleCommitments {
+		emptySlots = append(emptySlots, c.ClientPosition)
+	}
+	return emptySlots, nil
+}
+
+// Return merkle commitments of the MerkleCommitment collection matching the filter
+func (d *DbMongo) findMerkleCommitments(ctx context.Context, filter *bson.Document) ([]models.CommitmentMerkleCommitment, error) {
+	// sort for client position
+	sortFilter := bson.NewDocument(bson.EC.Int32(models.COMMITMENT_CLIENT_POSITION_NAME, 1))
+	res, resErr := d.db.Collection(COL_NAME_MERKLE_COMMITMENT).Find(ctx, filter, &options.FindOptions{Sort: sortFilter})
 	if resErr != nil {
 		return []models.CommitmentMerkleCommitment{},
 			errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_COMMITMENT_GET, resErr))
@@ -369,7 +587,7 @@ func (d *DbMongo) getAttestationMerkleCommitments(txid chainhash.Hash) ([]models
 
 	// fetch commitments
 	var merkleCommitments []models.CommitmentMerkleCommitment
-	for res.Next(d.ctx) {
+	for res.Next(ctx) {
 		commitmentDoc := bson.NewDocument()
 		if err := res.Decode(commitmentDoc); err != nil {
 			fmt.Printf("%s\n", BAD_DATA_MERKLE_COMMITMENT_COL)
@@ -392,11 +610,11 @@ func (d *DbMongo) getAttestationMerkleCommitments(txid chainhash.Hash) ([]models
 }
 
 // Return latest commitments from MerkleCommitment collection
-func (d *DbMongo) getClientCommitments() ([]models.ClientCommitment, error) {
+func (d *DbMongo) GetClientCommitments(ctx context.Context) ([]models.ClientCommitment, error) {
 
 	// sort by client position to get correct commitment order
 	sortFilter := bson.NewDocument(bson.EC.Int32(models.CLIENT_COMMITMENT_CLIENT_POSITION_NAME, 1))
-	res, resErr := d.db.Collection(COL_NAME_CLIENT_COMMITMENT).Find(d.ctx, bson.NewDocument(), &options.FindOptions{Sort: sortFilter})
+	res, resErr := d.db.Collection(COL_NAME_CLIENT_COMMITMENT).Find(ctx, bson.NewDocument(), &options.FindOptions{Sort: sortFilter})
 	if resErr != nil {
 		return []models.ClientCommitment{},
 			errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_GET, resErr))
@@ -404,7 +622,7 @@ func (d *DbMongo) getClientCommitments() ([]models.ClientCommitment, error) {
 
 	// iterate through commitments
 	var latestCommitments []models.ClientCommitment
-	for res.Next(d.ctx) {
+	for res.Next(ctx) {
 		commitmentDoc := bson.NewDocument()
 		if err := res.Decode(commitmentDoc); err != nil {
 			return []models.ClientCommitment{},
@@ -422,3 +640,298 @@ func (d *DbMongo) getClientCommitments() ([]models.ClientCommitment, error) {
 	}
 	return latestCommitments, nil
 }
+
+// Return Attestation model for attestation with given txid hash
+func (d *DbMongo) GetAttestation(ctx context.Context, txid chainhash.Hash) (models.Attestation, error) {
+	filterAttestation := bson.NewDocument(bson.EC.String(models.ATTESTATION_TXID_NAME, txid.String()))
+
+	attestationDoc := bson.NewDocument()
+	resErr := d.db.Collection(COL_NAME_ATTESTATION).FindOne(ctx, filterAttestation).Decode(attestationDoc)
+	if resErr != nil {
+		return models.Attestation{}, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, resErr))
+	}
+	attestationModel := &models.Attestation{}
+	modelErr := models.GetModelFromDocument(attestationDoc, attestationModel)
+	if modelErr != nil {
+		return models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_COL, modelErr))
+	}
+	return *attestationModel, nil
+}
+
+// Return latest Attestation model for the merkle root given, preferring confirmed attestations
+func (d *DbMongo) GetMerkleRootAttestation(ctx context.Context, merkleRoot chainhash.Hash) (models.Attestation, error) {
+	// sort by confirmed and inserted date to get latest confirmed attestation first
+	sortFilter := bson.NewDocument(
+		bson.EC.Int32(models.ATTESTATION_CONFIRMED_NAME, -1),
+		bson.EC.Int32(models.ATTESTATION_INSERTED_AT_NAME, -1))
+	filterMerkleRoot := bson.NewDocument(bson.EC.String(models.ATTESTATION_MERKLE_ROOT_NAME, merkleRoot.String()))
+
+	attestationDoc := bson.NewDocument()
+	resErr := d.db.Collection(COL_NAME_ATTESTATION).FindOne(ctx,
+		filterMerkleRoot, &options.FindOneOptions{Sort: sortFilter}).Decode(attestationDoc)
+	if resErr != nil {
+		return models.Attestation{}, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, resErr))
+	}
+	attestationModel := &models.Attestation{}
+	modelErr := models.GetModelFromDocument(attestationDoc, attestationModel)
+	if modelErr != nil {
+		return models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_COL, modelErr))
+	}
+	return *attestationModel, nil
+}
+
+// Return AttestationInfo model for attestation with given txid hash
+func (d *DbMongo) GetAttestationInfo(ctx context.Context, txid chainhash.Hash) (models.AttestationInfo, error) {
+	filterAttestationInfo := bson.NewDocument(bson.EC.String(models.ATTESTATION_INFO_TXID_NAME, txid.String()))
+
+	infoDoc := bson.NewDocument()
+	resErr := d.db.Collection(COL_NAME_ATTESTATION_INFO).FindOne(ctx, filterAttestationInfo).Decode(infoDoc)
+	if resErr != nil {
+		return models.AttestationInfo{}, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_INFO_GET, resErr))
+	}
+	infoModel := &models.AttestationInfo{}
+	modelErr := models.GetModelFromDocument(infoDoc, infoModel)
+	if modelErr != nil {
+		return models.AttestationInfo{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_INFO_COL, modelErr))
+	}
+	return *infoModel, nil
+}
+
+// Return CommitmentMerkleProof model for merkle root and client position
+func (d *DbMongo) GetMerkleProof(ctx context.Context, merkleRoot chainhash.Hash, position int32) (models.CommitmentMerkleProof, error) {
+	filterMerkleProof := bson.NewDocument(
+		bson.EC.String(models.PROOF_MERKLE_ROOT_NAME, merkleRoot.String()),
+		bson.EC.Int32(models.PROOF_CLIENT_POSITION_NAME, position),
+	)
+
+	proofDoc := bson.NewDocument()
+	resErr := d.db.Collection(COL_NAME_MERKLE_PROOF).FindOne(ctx, filterMerkleProof).Decode(proofDoc)
+	if resErr != nil {
+		return models.CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_PROOF_GET, resErr))
+	}
+	proofModel := &models.CommitmentMerkleProof{}
+	modelErr := models.GetModelFromDocument(proofDoc, proofModel)
+	if modelErr != nil {
+		return models.CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_MERKLE_PROOF_COL, modelErr))
+	}
+	return *proofModel, nil
+}
+
+// Return Attestation models matching the filter, latest first, with info set
+// for confirmed attestations, skipping and limiting the results for paging
+// Attestation info is joined from the AttestationInfo collection by txid
+func (d *DbMongo) GetAttestations(ctx context.Context, filter AttestationFilter, skip int64, limit int64) ([]models.Attestation, error) {
+	attestationMatch := bson.NewDocument()
+	if filter.Confirmed != nil {
+		attestationMatch.Append(bson.EC.Boolean(models.ATTESTATION_CONFIRMED_NAME, *filter.Confirmed))
+	}
+	if filter.MerkleRoot != nil {
+		attestationMatch.Append(bson.EC.String(models.ATTESTATION_MERKLE_ROOT_NAME, filter.MerkleRoot.String()))
+	}
+
+	pipeline := bson.NewArray(
+		bson.VC.DocumentFromElements(bson.EC.SubDocument("$match", attestationMatch)),
+		bson.VC.DocumentFromElements(bson.EC.SubDocumentFromElements("$sort",
+			bson.EC.Int32(models.ATTESTATION_INSERTED_AT_NAME, -1))),
+		bson.VC.DocumentFromElements(bson.EC.SubDocumentFromElements("$lookup",
+			bson.EC.String("from", COL_NAME_ATTESTATION_INFO),
+			bson.EC.String("localField", models.ATTESTATION_TXID_NAME),
+			bson.EC.String("foreignField", models.ATTESTATION_INFO_TXID_NAME),
+			bson.EC.String("as", ATTESTATION_INFO_FIELD))),
+		bson.VC.DocumentFromElements(bson.EC.SubDocumentFromElements("$unwind",
+			bson.EC.String("path", "$"+ATTESTATION_INFO_FIELD),
+			bson.EC.Boolean("preserveNullAndEmptyArrays", true))),
+	)
+	if filter.hasInfoFilter() {
+		infoMatch := bson.NewDocument(bson.EC.Boolean(models.ATTESTATION_CONFIRMED_NAME, true))
+		if rangeDoc := rangeFilter(filter.FromTime, filter.ToTime); rangeDoc != nil {
+			infoMatch.Append(bson.EC.SubDocument(ATTESTATION_INFO_FIELD+"."+models.ATTESTATION_INFO_TIME_NAME, rangeDoc))
+		}
+		if rangeDoc := rangeFilter(filter.FromHeight, filter.ToHeight); rangeDoc != nil {
+			infoMatch.Append(bson.EC.SubDocument(ATTESTATION_INFO_FIELD+"."+models.ATTESTATION_INFO_HEIGHT_NAME, rangeDoc))
+		}
+		pipeline.Append(bson.VC.DocumentFromElements(bson.EC.SubDocument("$match", infoMatch)))
+	}
+	pipeline.Append(
+		bson.VC.DocumentFromElements(bson.EC.Int64("$skip", skip)),
+		bson.VC.DocumentFromElements(bson.EC.Int64("$limit", limit)))
+
+	res, resErr := d.db.Collection(COL_NAME_ATTESTATION).Aggregate(ctx, pipeline)
+	if resErr != nil {
+		return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, resErr))
+	}
+
+	// iterate through attestations and their info
+	attestations := []models.Attestation{}
+	for res.Next(ctx) {
+		attestationDoc := bson.NewDocument()
+		if err := res.Decode(attestationDoc); err != nil {
+			return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_COL, err))
+		}
+		infoElem := attestationDoc.Delete(ATTESTATION_INFO_FIELD)
+
+		attestationModel := &models.Attestation{}
+		modelErr := models.GetModelFromDocument(attestationDoc, attestationModel)
+		if modelErr != nil {
+			return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_COL, modelErr))
+		}
+		if attestationModel.Confirmed && infoElem != nil {
+			infoModel := &models.AttestationInfo{}
+			modelErr = models.GetModelFromDocument(infoElem.Value().MutableDocument(), infoModel)
+			if modelErr != nil {
+				return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_INFO_COL, modelErr))
+			}
+			attestationModel.Info = *infoModel
+		}
+		attestations = append(attestations, *attestationModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_COL, err))
+	}
+	return attestations, nil
+}
+
+// Return inclusive range filter document for non zero bounds
+// Returns nil if neither bound is set
+func rangeFilter(from int64, to int64) *bson.Document {
+	if from == 0 && to == 0 {
+		return nil
+	}
+	rangeDoc := bson.NewDocument()
+	if from != 0 {
+		rangeDoc.Append(bson.EC.Int64("$gte", from))
+	}
+	if to != 0 {
+		rangeDoc.Append(bson.EC.Int64("$lte", to))
+	}
+	return rangeDoc
+}
+
+// Return client commitment history for position with sequence after the
+// first and up to the second sequence provided, latest first
+// The upper sequence bound is not applied if zero
+func (d *DbMongo) GetClientCommitmentHistory(ctx context.Context, position int32, after int64, until int64, skip int64, limit int64) ([]models.ClientCommitmentHistory, error) {
+	sortFilter := bson.NewDocument(bson.EC.Int32(models.CLIENT_COMMITMENT_HISTORY_SEQUENCE_NAME, -1))
+	sequenceRange := bson.NewDocument(bson.EC.Int64("$gt", after))
+	if until != 0 {
+		sequenceRange.Append(bson.EC.Int64("$lte", until))
+	}
+	filterPosition := bson.NewDocument(
+		bson.EC.Int32(models.CLIENT_COMMITMENT_HISTORY_CLIENT_POSITION_NAME, position),
+		bson.EC.SubDocument(models.CLIENT_COMMITMENT_HISTORY_SEQUENCE_NAME, sequenceRange))
+	opts := &options.FindOptions{Sort: sortFilter}
+	opts.SetSkip(skip)
+	opts.SetLimit(limit)
+	res, resErr := d.db.Collection(COL_NAME_CLIENT_COMMITMENT_HISTORY).Find(ctx, filterPosition, opts)
+	if resErr != nil {
+		return []models.ClientCommitmentHistory{},
+			errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_GET, resErr))
+	}
+
+	// iterate through history entries
+	history := []models.ClientCommitmentHistory{}
+	for res.Next(ctx) {
+		historyDoc := bson.NewDocument()
+		if err := res.Decode(historyDoc); err != nil {
+			return []models.ClientCommitmentHistory{},
+				errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_COMMITMENT_HISTORY_COL, err))
+		}
+		historyModel := &models.ClientCommitmentHistory{}
+		modelErr := models.GetModelFromDocument(historyDoc, historyModel)
+		if modelErr != nil {
+			return []models.ClientCommitmentHistory{},
+				errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_COMMITMENT_HISTORY_COL, modelErr))
+		}
+		history = append(history, *historyModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.ClientCommitmentHistory{},
+			errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_COMMITMENT_HISTORY_COL, err))
+	}
+	return history, nil
+}
+
+// Save webhook delivery to WebhookDelivery collection
+// Deliveries are upserted by attestation txid and client position
+func (d *DbMongo) SaveWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	docDelivery, docErr := models.GetDocumentFromModel(delivery)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_WEBHOOK_DELIVERY_MODEL, docErr))
+	}
+
+	newDelivery := bson.NewDocument(
+		bson.EC.SubDocument("$set", docDelivery),
+	)
+	filterDelivery := bson.NewDocument(
+		bson.EC.String(models.WEBHOOK_DELIVERY_TXID_NAME, delivery.Txid.String()),
+		bson.EC.Int32(models.WEBHOOK_DELIVERY_CLIENT_POSITION_NAME, delivery.ClientPosition),
+	)
+
+	// insert or update webhook delivery
+	t := bson.NewDocument()
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	res := d.db.Collection(COL_NAME_WEBHOOK_DELIVERY).FindOneAndUpdate(ctx, filterDelivery, newDelivery, opts)
+	resErr := res.Decode(t)
+	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_WEBHOOK_DELIVERY_SAVE, resErr))
+	}
+	return nil
+}
+
+// Add webhook delivery to WebhookDelivery collection
+// Deliveries already recorded for the attestation txid and client position are kept
+func (d *DbMongo) AddWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	docDelivery, docErr := models.GetDocumentFromModel(delivery)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_WEBHOOK_DELIVERY_MODEL, docErr))
+	}
+
+	newDelivery := bson.NewDocument(
+		bson.EC.SubDocument("$setOnInsert", docDelivery),
+	)
+	filterDelivery := bson.NewDocument(
+		bson.EC.String(models.WEBHOOK_DELIVERY_TXID_NAME, delivery.Txid.String()),
+		bson.EC.Int32(models.WEBHOOK_DELIVERY_CLIENT_POSITION_NAME, delivery.ClientPosition),
+	)
+
+	// insert webhook delivery if not recorded
+	t := bson.NewDocument()
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	res := d.db.Collection(COL_NAME_WEBHOOK_DELIVERY).FindOneAndUpdate(ctx, filterDelivery, newDelivery, opts)
+	resErr := res.Decode(t)
+	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_WEBHOOK_DELIVERY_SAVE, resErr))
+	}
+	return nil
+}
+
+// Return webhook deliveries that have or have not been redelivered, oldest failure first
+func (d *DbMongo) GetWebhookDeliveries(ctx context.Context, delivered bool) ([]models.WebhookDelivery, error) {
+	sortFilter := bson.NewDocument(bson.EC.Int32(models.WEBHOOK_DELIVERY_FAILED_AT_NAME, 1))
+	filterDelivered := bson.NewDocument(bson.EC.Boolean(models.WEBHOOK_DELIVERY_DELIVERED_NAME, delivered))
+	res, resErr := d.db.Collection(COL_NAME_WEBHOOK_DELIVERY).Find(ctx, filterDelivered, &options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []models.WebhookDelivery{}, errors.New(fmt.Sprintf("%s %v", ERROR_WEBHOOK_DELIVERY_GET, resErr))
+	}
+
+	// iterate through deliveries
+	deliveries := []models.WebhookDelivery{}
+	for res.Next(ctx) {
+		deliveryDoc := bson.NewDocument()
+		if err := res.Decode(deliveryDoc); err != nil {
+			return []models.WebhookDelivery{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_WEBHOOK_DELIVERY_COL, err))
+		}
+		deliveryModel := &models.WebhookDelivery{}
+		modelErr := models.GetModelFromDocument(deliveryDoc, deliveryModel)
+		if modelErr != nil {
+			return []models.WebhookDelivery{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_WEBHOOK_DELIVERY_COL, modelErr))
+		}
+		deliveries = append(deliveries, *deliveryModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.WebhookDelivery{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_WEBHOOK_DELIVERY_COL, err))
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/mongodb/mongo-go-driver/options"
+)
+
+// Atomic attestation updates for mongoDB
+// Replica sets and sharded clusters save attestation updates in a multi-document
+// transaction. Standalone servers do not support transactions, so each update is
+// first recorded in the AttestationJournal collection and removed once all its
+// writes are done. Updates left in the journal by a crash are replayed on startup
+// and before the next update, as all writes of an update are safe to repeat
+
+const (
+	// attestation journal collection and field names
+	COL_NAME_ATTESTATION_JOURNAL          = "AttestationJournal"
+	ATTESTATION_JOURNAL_ATTESTATION_NAME  = "attestation"
+	ATTESTATION_JOURNAL_INFO_NAME         = "info"
+	ATTESTATION_JOURNAL_JOURNALED_AT_NAME = "journaled_at"
+
+	// error messages
+	ERROR_MONGO_TRANSACTION          = "could not run mongoDB transaction"
+	ERROR_ATTESTATION_JOURNAL_SAVE   = "could not save attestation journal"
+	ERROR_ATTESTATION_JOURNAL_GET    = "could not get attestation journal"
+	ERROR_ATTESTATION_JOURNAL_REPLAY = "could not replay attestation journal"
+	BAD_DATA_ATTESTATION_JOURNAL_COL = "bad data in attestation journal collection"
+)
+
+// Return whether the mongoDB deployment supports multi-document transactions,
+// i.e. whether it is a replica set member or a sharded cluster mongos
+func mongoSupportsTransactions(ctx context.Context, db *mongo.Database) bool {
+	res, errCommand := db.RunCommand(ctx, bson.NewDocument(bson.EC.Int32("isMaster", 1)))
+	if errCommand != nil {
+		return false
+	}
+	if _, errSetName := res.Lookup("setName"); errSetName == nil {
+		return true
+	}
+	msg, errMsg := res.Lookup("msg")
+	return errMsg == nil && msg.Value().StringValue() == "isdbgrid"
+}
+
+// Save attestation update with all its writes atomically
+// Uses a transaction if supported by the deployment or the attestation journal otherwise
+func (d *DbMongo) SaveAttestationUpdate(ctx context.Context, attestation models.Attestation,
+	proofs ...models.CommitmentMerkleProof) error {
+	if d.transactions {
+		return d.saveAttestationUpdateTransaction(ctx, attestation, proofs)
+	}
+	return d.saveAttestationUpdateJournaled(ctx, attestation, proofs)
+}
+
+// Save attestation update writes in a multi-document transaction
+func (d *DbMongo) saveAttestationUpdateTransaction(ctx context.Context, attestation models.Attestation,
+	proofs []models.CommitmentMerkleProof) error {
+	session, errSession := d.db.Client().StartSession()
+	if errSession != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_MONGO_TRANSACTION, errSession))
+	}
+	defer session.EndSession(ctx)
+
+	return mongo.WithSession(ctx, session, func(sc mongo.SessionContext) error {
+		if errStart := sc.StartTransaction(); errStart != nil {
+			return errors.New(fmt.Sprintf("%s %v", ERROR_MONGO_TRANSACTION, errStart))
+		}
+		if errSave := saveAttestationUpdate(sc, d, attestation, proofs); errSave != nil {
+			sc.AbortTransaction(sc)
+			return errSave
+		}
+		if errCommit := sc.CommitTransaction(sc); errCommit != nil {
+			return errors.New(fmt.Sprintf("%s %v", ERROR_MONGO_TRANSACTION, errCommit))
+		}
+		return nil
+	})
+}
+
+// Save attestation update writes after recording the update in the journal
+// Any incomplete journaled update is replayed first to keep updates in order
+// The merkle commitments are saved before the update is journaled, as the
+// journal only records the attestation and its commitment is rebuilt from
+// these on replay. Merkle proofs are not journaled and are built on replay
+func (d *DbMongo) saveAttestationUpdateJournaled(ctx context.Context, attestation models.Attestation,
+	proofs []models.CommitmentMerkleProof) error {
+	commitment, errCommitment := attestation.Commitment()
+	if errCommitment != nil {
+		return errCommitment
+	}
+	if errReplay := d.replayAttestationJournal(ctx); errReplay != nil {
+		return errReplay
+	}
+	if errSave := d.SaveMerkleCommitments(ctx, commitment.GetMerkleCommitments()); errSave != nil {
+		return errSave
+	}
+	if errJournal := d.saveAttestationJournal(ctx, attestation); errJournal != nil {
+		return errJournal
+	}
+	if errSave := saveAttestationRecords(ctx, d, attestation, *commitment, proofs); errSave != nil {
+		return errSave
+	}
+	return d.deleteAttestationJournal(ctx, attestation.Txid)
+}
+
+// Record attestation update in the AttestationJournal collection
+func (d *DbMongo) saveAttestationJournal(ctx context.Context, attestation models.Attestation) error {
+	docAttestation, docErr := models.GetDocumentFromModel(attestation)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_MODEL, docErr))
+	}
+	journalFields := []*bson.Element{
+		bson.EC.SubDocument(ATTESTATION_JOURNAL_ATTESTATION_NAME, docAttestation),
+		bson.EC.Time(ATTESTATION_JOURNAL_JOURNALED_AT_NAME, time.Now()),
+	}
+	if attestation.Confirmed {
+		docInfo, docErr := models.GetDocumentFromModel(attestation.Info)
+		if docErr != nil {
+			return errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_INFO_MODEL, docErr))
+		}
+		journalFields = append(journalFields, bson.EC.SubDocument(ATTESTATION_JOURNAL_INFO_NAME, docInfo))
+	}
+
+	filterJournal := bson.NewDocument(bson.EC.String("_id", attestation.Txid.String()))
+	newJournal := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", journalFields...))
+
+	// insert or update journal of attestation
+	t := bson.NewDocument()
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	res := d.db.Collection(COL_NAME_ATTESTATION_JOURNAL).FindOneAndUpdate(ctx, filterJournal, newJournal, opts)
+	resErr := res.Decode(t)
+	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_JOURNAL_SAVE, resErr))
+	}
+	return nil
+}
+
+// Remove completed attestation update from the AttestationJournal collection
+func (d *DbMongo) deleteAttestationJournal(ctx context.Context, txid chainhash.Hash) error {
+	filterJournal := bson.NewDocument(bson.EC.String("_id", txid.String()))
+	_, resErr := d.db.Collection(COL_NAME_ATTESTATION_JOURNAL).DeleteOne(ctx, filterJournal)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_JOURNAL_SAVE, resErr))
+	}
+	return nil
+}
+
+// Return attestation updates in the AttestationJournal collection in journal order
+func (d *DbMongo) getAttestationJournal(ctx context.Context) ([]models.Attestation, error) {
+	sortFilter := bson.NewDocument(bson.EC.Int32(ATTESTATION_JOURNAL_JOURNALED_AT_NAME, 1))
+	res, resErr := d.db.Collection(COL_NAME_ATTESTATION_JOURNAL).Find(ctx, bson.NewDocument(),
+		&options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_JOURNAL_GET, resErr))
+	}
+
+	// iterate through journaled attestations and their info
+	attestations := []models.Attestation{}
+	for res.Next(ctx) {
+		journalDoc := bson.NewDocument()
+		if err := res.Decode(journalDoc); err != nil {
+			return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_JOURNAL_COL, err))
+		}
+		attestationModel := &models.Attestation{}
+		attestationValue := journalDoc.Lookup(ATTESTATION_JOURNAL_ATTESTATION_NAME)
+		if attestationValue == nil {
+			return []models.Attestation{}, errors.New(BAD_DATA_ATTESTATION_JOURNAL_COL)
+		}
+		modelErr := models.GetModelFromDocument(attestationValue.MutableDocument(), attestationModel)
+		if modelErr != nil {
+			return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_JOURNAL_COL, modelErr))
+		}
+		if infoValue := journalDoc.Lookup(ATTESTATION_JOURNAL_INFO_NAME); infoValue != nil {
+			infoModel := &models.AttestationInfo{}
+			modelErr = models.GetModelFromDocument(infoValue.MutableDocument(), infoModel)
+			if modelErr != nil {
+				return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_JOURNAL_COL, modelErr))
+			}
+			attestationModel.Info = *infoModel
+		}
+		attestations = append(attestations, *attestationModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_JOURNAL_COL, err))
+	}
+	return attestations, nil
+}
+
+// Replay attestation updates left incomplete in the AttestationJournal collection
+// The commitment of each update is rebuilt from its saved merkle commitments
+func (d *DbMongo) replayAttestationJournal(ctx context.Context) error {
+	attestations, errJournal := d.getAttestationJournal(ctx)
+	if errJournal != nil {
+		return errJournal
+	}
+	for _, attestation := range attestations {
+		commitment, errCommitment := d.getJournalCommitment(ctx, attestation)
+		if errCommitment != nil {
+			return errors.New(fmt.Sprintf("%s %s: %v", ERROR_ATTESTATION_JOURNAL_REPLAY, attestation.Txid.String(), errCommitment))
+		}
+		attestation.SetCommitment(commitment)
+		if errSave := saveAttestationRecords(ctx, d, attestation, *commitment, nil); errSave != nil {
+			return errors.New(fmt.Sprintf("%s %s: %v", ERROR_ATTESTATION_JOURNAL_REPLAY, attestation.Txid.String(), errSave))
+		}
+		if errDelete := d.deleteAttestationJournal(ctx, attestation.Txid); errDelete != nil {
+			return errDelete
+		}
+	}
+	return nil
+}
+
+// Return commitment of a journaled attestation from the merkle commitments
+// of its merkle root, checking that these rebuild the merkle root
+func (d *DbMongo) getJournalCommitment(ctx context.Context, attestation models.Attestation) (*models.Commitment, error) {
+	filterMerkleRoot := bson.NewDocument(
+		bson.EC.String(models.COMMITMENT_MERKLE_ROOT_NAME, attestation.CommitmentHash().String()))
+	merkleCommitments, errCommitments := d.findMerkleCommitments(ctx, filterMerkleRoot)
+	if errCommitments != nil {
+		return nil, errCommitments
+	}
+	var commitmentHashes []chainhash.Hash
+	for _, c := range merkleCommitments {
+		commitmentHashes = append(commitmentHashes, c.Commitment)
+	}
+	commitment, errCommitment := models.NewCommitmentVersion(commitmentHashes, attestation.TreeVersion())
+	if errCommitment != nil {
+		return nil, errCommitment
+	}
+	if commitment.GetCommitmentHash() != attestation.CommitmentHash() {
+		return nil, errors.New(models.ERROR_COMMITMENT_MERKLE_ROOT)
+	}
+	return commitment, nil
+}
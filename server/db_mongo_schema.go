@@ -0,0 +1,290 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"mainstay/config"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/mongodb/mongo-go-driver/options"
+)
+
+const (
+	// schema version collection and document id
+	COL_NAME_SCHEMA_VERSION = "SchemaVersion"
+	SCHEMA_VERSION_ID       = "schema"
+	SCHEMA_VERSION_NAME     = "version"
+
+	// error messages
+	ERROR_MONGO_INDEXES        = "could not create mongoDB indexes"
+	ERROR_MONGO_MIGRATE        = "could not migrate mongoDB database"
+	ERROR_MONGO_SCHEMA_VERSION = "unknown mongoDB database schema version"
+)
+
+// mongoIndex struct
+// Index on the fields of a collection with ascending (1) or descending (-1) order
+type mongoIndex struct {
+	collection string
+	name       string
+	fields     []string
+	orders     []int32
+	unique     bool
+}
+
+// mongoDB indexes required by DbMongo queries
+// Unique indexes match the fields that documents are upserted by
+var mongoIndexes = []mongoIndex{
+	{COL_NAME_ATTESTATION, "txid_merkle_root",
+		[]string{models.ATTESTATION_TXID_NAME, models.ATTESTATION_MERKLE_ROOT_NAME}, []int32{1, 1}, true},
+	{COL_NAME_ATTESTATION, "confirmed_inserted_at",
+		[]string{models.ATTESTATION_CONFIRMED_NAME, models.ATTESTATION_INSERTED_AT_NAME}, []int32{1, -1}, false},
+	{COL_NAME_ATTESTATION, "merkle_root_confirmed_inserted_at",
+		[]string{models.ATTESTATION_MERKLE_ROOT_NAME, models.ATTESTATION_CONFIRMED_NAME, models.ATTESTATION_INSERTED_AT_NAME},
+		[]int32{1, -1, -1}, false},
+	{COL_NAME_ATTESTATION, "inserted_at",
+		[]string{models.ATTESTATION_INSERTED_AT_NAME}, []int32{-1}, false},
+	{COL_NAME_ATTESTATION_INFO, "txid",
+		[]string{models.ATTESTATION_INFO_TXID_NAME}, []int32{1}, true},
+	{COL_NAME_MERKLE_COMMITMENT, "merkle_root_client_position",
+		[]string{models.COMMITMENT_MERKLE_ROOT_NAME, models.COMMITMENT_CLIENT_POSITION_NAME}, []int32{1, 1}, true},
+	{COL_NAME_MERKLE_COMMITMENT, "merkle_root_commitment",
+		[]string{models.COMMITMENT_MERKLE_ROOT_NAME, models.COMMITMENT_COMMITMENT_NAME}, []int32{1, 1}, false},
+	{COL_NAME_MERKLE_PROOF, "merkle_root_client_position",
+		[]string{models.PROOF_MERKLE_ROOT_NAME, models.PROOF_CLIENT_POSITION_NAME}, []int32{1, 1}, true},
+	{COL_NAME_CLIENT_COMMITMENT, "client_position",
+		[]string{models.CLIENT_COMMITMENT_CLIENT_POSITION_NAME}, []int32{1}, true},
+	{COL_NAME_CLIENT_DETAILS, "client_position",
+		[]string{models.CLIENT_DETAILS_CLIENT_POSITION_NAME}, []int32{1}, true},
+	{COL_NAME_REVOKED_CLIENT_DETAILS, "client_position_ended_at",
+		[]string{models.CLIENT_DETAILS_CLIENT_POSITION_NAME, models.CLIENT_DETAILS_ENDED_AT_NAME}, []int32{1, 1}, false},
+	{COL_NAME_CLIENT_COMMITMENT_HISTORY, "client_position_submitted_at",
+		[]string{models.CLIENT_COMMITMENT_HISTORY_CLIENT_POSITION_NAME, models.CLIENT_COMMITMENT_HISTORY_SUBMITTED_AT_NAME},
+		[]int32{1, -1}, false},
+	{COL_NAME_CLIENT_COMMITMENT_HISTORY, "client_position_sequence",
+		[]string{models.CLIENT_COMMITMENT_HISTORY_CLIENT_POSITION_NAME, models.CLIENT_COMMITMENT_HISTORY_SEQUENCE_NAME},
+		[]int32{1, -1}, false},
+	{COL_NAME_CLIENT_COMMITMENT_HISTORY, "client_position_commitment_txid",
+		[]string{models.CLIENT_COMMITMENT_HISTORY_CLIENT_POSITION_NAME, models.CLIENT_COMMITMENT_HISTORY_COMMITMENT_NAME,
+			models.CLIENT_COMMITMENT_HISTORY_TXID_NAME}, []int32{1, 1, 1}, false},
+	{COL_NAME_WEBHOOK_DELIVERY, "txid_client_position",
+		[]string{models.WEBHOOK_DELIVERY_TXID_NAME, models.WEBHOOK_DELIVERY_CLIENT_POSITION_NAME}, []int32{1, 1}, true},
+	{COL_NAME_WEBHOOK_DELIVERY, "delivered_failed_at",
+		[]string{models.WEBHOOK_DELIVERY_DELIVERED_NAME, models.WEBHOOK_DELIVERY_FAILED_AT_NAME}, []int32{1, 1}, false},
+}
+
+// mongoDB schema migrations
+// Each migration upgrades the schema by one version and is applied once, in
+// order, with the current schema version stored in the SchemaVersion collection
+// Migrations are not atomic and must be safe to re-run if interrupted
+var mongoMigrations = []func(context.Context, *mongo.Database) error{
+	// version 1: initial collections
+	func(ctx context.Context, db *mongo.Database) error { return nil },
+	// version 2: attestations stored before raw txs, fees and tree versions
+	migrateMongoAttestationDefaults,
+	// version 3: attestations and journaled updates stored with their commitments
+	migrateMongoAttestationCommitments,
+	// version 4: client commitment history stored before sequences
+	migrateMongoHistorySequences,
+}
+
+// Set default raw tx, fee and legacy tree version fields of attestations
+// that were stored before these fields were added to the model
+func migrateMongoAttestationDefaults(ctx context.Context, db *mongo.Database) error {
+	for _, field := range []*bson.Element{
+		bson.EC.String(models.ATTESTATION_TX_NAME, ""),
+		bson.EC.Int64(models.ATTESTATION_FEE_NAME, 0),
+		bson.EC.Int32(models.ATTESTATION_TREE_VERSION_NAME, models.COMMITMENT_TREE_VERSION_LEGACY),
+	} {
+		filterMissing := bson.NewDocument(
+			bson.EC.SubDocumentFromElements(field.Key(), bson.EC.Boolean("$exists", false)))
+		setDefault := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", field))
+		_, resErr := db.Collection(COL_NAME_ATTESTATION).UpdateMany(ctx, filterMissing, setDefault)
+		if resErr != nil {
+			return resErr
+		}
+	}
+	return nil
+}
+
+// Remove commitments stored with attestations, which are also stored as
+// merkle commitments, and with journaled attestation updates, saving the
+// merkle commitments of these first as the journal is replayed from them
+func migrateMongoAttestationCommitments(ctx context.Context, db *mongo.Database) error {
+	journalCommitments := ATTESTATION_JOURNAL_ATTESTATION_NAME + "." + models.ATTESTATION_COMMITMENTS_NAME
+	filterCommitments := bson.NewDocument(
+		bson.EC.SubDocumentFromElements(journalCommitments, bson.EC.Boolean("$exists", true)))
+	res, resErr := db.Collection(COL_NAME_ATTESTATION_JOURNAL).Find(ctx, filterCommitments)
+	if resErr != nil {
+		return resErr
+	}
+	d := &DbMongo{config.DbConnectivity{}, db, false}
+	for res.Next(ctx) {
+		var journaled struct {
+			Attestation struct {
+				models.AttestationBSON `bson:",inline"`
+				Commitments            []string `bson:"commitments"`
+			} `bson:"attestation"`
+		}
+		if err := res.Decode(&journaled); err != nil {
+			return err
+		}
+		commitment, errCommitment := legacyJournalCommitment(journaled.Attestation.Commitments,
+			journaled.Attestation.TreeVersion)
+		if errCommitment != nil {
+			return errCommitment
+		}
+		if errSave := d.SaveMerkleCommitments(ctx, commitment.GetMerkleCommitments()); errSave != nil {
+			return errSave
+		}
+	}
+	if err := res.Err(); err != nil {
+		return err
+	}
+
+	for collection, field := range map[string]string{
+		COL_NAME_ATTESTATION:         models.ATTESTATION_COMMITMENTS_NAME,
+		COL_NAME_ATTESTATION_JOURNAL: journalCommitments,
+	} {
+		filterField := bson.NewDocument(
+			bson.EC.SubDocumentFromElements(field, bson.EC.Boolean("$exists", true)))
+		unsetField := bson.NewDocument(bson.EC.SubDocumentFromElements("$unset", bson.EC.String(field, "")))
+		_, resErr := db.Collection(collection).UpdateMany(ctx, filterField, unsetField)
+		if resErr != nil {
+			return resErr
+		}
+	}
+	return nil
+}
+
+// Return commitment of the commitments stored with a journaled attestation
+// Attestations stored without a tree version used the legacy layout
+func legacyJournalCommitment(commitments []string, treeVersion int32) (*models.Commitment, error) {
+	var commitmentHashes []chainhash.Hash
+	for _, commitmentStr := range commitments {
+		commitmentHash, errHash := chainhash.NewHashFromStr(commitmentStr)
+		if errHash != nil {
+			return nil, errHash
+		}
+		commitmentHashes = append(commitmentHashes, *commitmentHash)
+	}
+	if treeVersion == 0 {
+		treeVersion = models.COMMITMENT_TREE_VERSION_LEGACY
+	}
+	return models.NewCommitmentVersion(commitmentHashes, treeVersion)
+}
+
+// Set sequences of client commitment history entries stored before these
+// were added to the model, in the order the entries were submitted
+func migrateMongoHistorySequences(ctx context.Context, db *mongo.Database) error {
+	filterMissing := bson.NewDocument(bson.EC.SubDocumentFromElements(
+		models.CLIENT_COMMITMENT_HISTORY_SEQUENCE_NAME, bson.EC.Boolean("$exists", false)))
+	sortFilter := bson.NewDocument(
+		bson.EC.Int32(models.CLIENT_COMMITMENT_HISTORY_SUBMITTED_AT_NAME, 1),
+		bson.EC.Int32("_id", 1))
+	res, resErr := db.Collection(COL_NAME_CLIENT_COMMITMENT_HISTORY).Find(ctx, filterMissing,
+		&options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return resErr
+	}
+	for res.Next(ctx) {
+		historyDoc := bson.NewDocument()
+		if err := res.Decode(historyDoc); err != nil {
+			return err
+		}
+		sequence, errSequence := nextMongoSequence(ctx, db, COL_NAME_CLIENT_COMMITMENT_HISTORY)
+		if errSequence != nil {
+			return errSequence
+		}
+		filterHistory := bson.NewDocument(historyDoc.LookupElement("_id"))
+		setSequence := bson.NewDocument(bson.EC.SubDocumentFromElements("$set",
+			bson.EC.Int64(models.CLIENT_COMMITMENT_HISTORY_SEQUENCE_NAME, sequence)))
+		_, resErr := db.Collection(COL_NAME_CLIENT_COMMITMENT_HISTORY).UpdateOne(ctx, filterHistory, setSequence)
+		if resErr != nil {
+			return resErr
+		}
+	}
+	return res.Err()
+}
+
+// Return schema version of the mongoDB database, zero if not set
+func mongoSchemaVersion(ctx context.Context, db *mongo.Database) (int32, error) {
+	filterVersion := bson.NewDocument(bson.EC.String("_id", SCHEMA_VERSION_ID))
+
+	versionDoc := bson.NewDocument()
+	resErr := db.Collection(COL_NAME_SCHEMA_VERSION).FindOne(ctx, filterVersion).Decode(versionDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return 0, nil
+	} else if resErr != nil {
+		return 0, errors.New(fmt.Sprintf("%s %v", ERROR_MONGO_MIGRATE, resErr))
+	}
+	return versionDoc.Lookup(SCHEMA_VERSION_NAME).Int32(), nil
+}
+
+// Store schema version of the mongoDB database
+func setMongoSchemaVersion(ctx context.Context, db *mongo.Database, version int32) error {
+	filterVersion := bson.NewDocument(bson.EC.String("_id", SCHEMA_VERSION_ID))
+	newVersion := bson.NewDocument(
+		bson.EC.SubDocumentFromElements("$set", bson.EC.Int32(SCHEMA_VERSION_NAME, version)))
+
+	t := bson.NewDocument()
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	res := db.Collection(COL_NAME_SCHEMA_VERSION).FindOneAndUpdate(ctx, filterVersion, newVersion, opts)
+	resErr := res.Decode(t)
+	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %d: %v", ERROR_MONGO_MIGRATE, version, resErr))
+	}
+	return nil
+}
+
+// Apply pending schema migrations to the mongoDB database
+// Databases with a newer schema version than known are rejected
+func migrateMongo(ctx context.Context, db *mongo.Database) error {
+	version, errVersion := mongoSchemaVersion(ctx, db)
+	if errVersion != nil {
+		return errVersion
+	} else if int(version) > len(mongoMigrations) {
+		return errors.New(fmt.Sprintf("%s %d", ERROR_MONGO_SCHEMA_VERSION, version))
+	}
+
+	for ; int(version) < len(mongoMigrations); version++ {
+		if err := mongoMigrations[version](ctx, db); err != nil {
+			return errors.New(fmt.Sprintf("%s %d: %v", ERROR_MONGO_MIGRATE, version+1, err))
+		}
+		if err := setMongoSchemaVersion(ctx, db, version+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create any missing indexes required by DbMongo queries
+// Creating an index that already exists with the same options is a no-op
+func ensureMongoIndexes(ctx context.Context, db *mongo.Database) error {
+	for _, index := range mongoIndexes {
+		keys := bson.NewDocument()
+		for i, field := range index.fields {
+			keys.Append(bson.EC.Int32(field, index.orders[i]))
+		}
+		opts := mongo.NewIndexOptionsBuilder().Name(index.name).Unique(index.unique).Build()
+		_, errIndex := db.Collection(index.collection).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: opts})
+		if errIndex != nil {
+			return errors.New(fmt.Sprintf("%s %s.%s: %v", ERROR_MONGO_INDEXES, index.collection, index.name, errIndex))
+		}
+	}
+	return nil
+}
+
+// Migrate mongoDB database to the latest schema version and ensure indexes
+// Indexes are created after migrations so that they apply to migrated documents
+func ensureMongoSchema(ctx context.Context, db *mongo.Database) error {
+	if errMigrate := migrateMongo(ctx, db); errMigrate != nil {
+		return errMigrate
+	}
+	return ensureMongoIndexes(ctx, db)
+}
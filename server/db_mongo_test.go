@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test DbMongo schema migrations and versioning
+// Requires a test mongoDB instance, see testDbMongoConnectivity
+func TestDbMongoSchema(t *testing.T) {
+	dbConnectivity := testDbMongoConnectivity(t)
+	ctx := context.Background()
+	db, errConnect := dbConnect(ctx, dbConnectivity)
+	assert.Equal(t, nil, errConnect)
+	assert.Equal(t, nil, db.Drop(ctx))
+
+	// new database is migrated to the latest version
+	assert.Equal(t, nil, ensureMongoSchema(ctx, db))
+	version, errVersion := mongoSchemaVersion(ctx, db)
+	assert.Equal(t, nil, errVersion)
+	assert.Equal(t, int32(len(mongoMigrations)), version)
+
+	// ensuring the schema again is a no-op
+	assert.Equal(t, nil, ensureMongoSchema(ctx, db))
+	version, _ = mongoSchemaVersion(ctx, db)
+	assert.Equal(t, int32(len(mongoMigrations)), version)
+
+	// version 1 attestations get default fields
+	assert.Equal(t, nil, setMongoSchemaVersion(ctx, db, 1))
+	_, errInsert := db.Collection(COL_NAME_ATTESTATION).InsertOne(ctx, bson.NewDocument(
+		bson.EC.String(models.ATTESTATION_TXID_NAME, "txid"),
+		bson.EC.String(models.ATTESTATION_MERKLE_ROOT_NAME, "merkle_root"),
+		bson.EC.Boolean(models.ATTESTATION_CONFIRMED_NAME, true)))
+	assert.Equal(t, nil, errInsert)
+	assert.Equal(t, nil, ensureMongoSchema(ctx, db))
+	attestationDoc := bson.NewDocument()
+	errFind := db.Collection(COL_NAME_ATTESTATION).FindOne(ctx, bson.NewDocument()).Decode(attestationDoc)
+	assert.Equal(t, nil, errFind)
+	assert.Equal(t, "", attestationDoc.Lookup(models.ATTESTATION_TX_NAME).StringValue())
+	assert.Equal(t, int64(0), attestationDoc.Lookup(models.ATTESTATION_FEE_NAME).Int64())
+	assert.Equal(t, models.COMMITMENT_TREE_VERSION_LEGACY, attestationDoc.Lookup(models.ATTESTATION_TREE_VERSION_NAME).Int32())
+
+	// newer schema versions are rejected
+	newVersion := int32(len(mongoMigrations) + 1)
+	assert.Equal(t, nil, setMongoSchemaVersion(ctx, db, newVersion))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_MONGO_SCHEMA_VERSION, newVersion)), ensureMongoSchema(ctx, db))
+	assert.Equal(t, nil, db.Drop(ctx))
+}
+
+// Test DbMongo replays incomplete journaled attestation updates
+// Requires a test mongoDB instance, see testDbMongoConnectivity
+func TestDbMongoAttestationJournal(t *testing.T) {
+	dbConnectivity := testDbMongoConnectivity(t)
+	ctx := context.Background()
+	db, errConnect := dbConnect(ctx, dbConnectivity)
+	assert.Equal(t, nil, errConnect)
+	assert.Equal(t, nil, db.Drop(ctx))
+	d := &DbMongo{dbConnectivity, db, false}
+
+	// journaled update interrupted after its merkle commitments
+	txid := testDbHash("aaaaaa", 0)
+	commitment, _ := models.NewCommitment([]chainhash.Hash{testDbHash("bbbbbb", 0)})
+	attestation := models.NewAttestation(txid, commitment)
+	assert.Equal(t, nil, d.SaveMerkleCommitments(ctx, commitment.GetMerkleCommitments()))
+	assert.Equal(t, nil, d.saveAttestationJournal(ctx, *attestation))
+	journal, errJournal := d.getAttestationJournal(ctx)
+	assert.Equal(t, nil, errJournal)
+	assert.Equal(t, 1, len(journal))
+
+	// replay completes the update and clears the journal
+	assert.Equal(t, nil, d.replayAttestationJournal(ctx))
+	dbAttestation, _ := d.GetAttestation(ctx, txid)
+	assert.Equal(t, attestation.WithoutCommitment(), dbAttestation)
+	proof, _ := d.GetMerkleProof(ctx, commitment.GetCommitmentHash(), 0)
+	assert.Equal(t, commitment.GetMerkleProofs()[0], proof)
+	merkleCommitments, _ := d.GetAttestationMerkleCommitments(ctx, txid)
+	assert.Equal(t, commitment.GetMerkleCommitments(), merkleCommitments)
+	journal, _ = d.getAttestationJournal(ctx)
+	assert.Equal(t, 0, len(journal))
+	assert.Equal(t, nil, db.Drop(ctx))
+}
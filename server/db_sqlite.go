@@ -0,0 +1,714 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"mainstay/config"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mongodb/mongo-go-driver/bson"
+)
+
+const (
+	// error messages
+	ERROR_SQLITE_OPEN           = "could not open sqlite database"
+	ERROR_SQLITE_MIGRATE        = "could not migrate sqlite database"
+	ERROR_SQLITE_SCHEMA_VERSION = "unknown sqlite database schema version"
+)
+
+// sqliteQuerier interface
+// Implemented by sql.DB and sql.Tx so that DbSqlite queries can run in a transaction
+type sqliteQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// DbSqlite struct
+// Implementation of the Db interface on an embedded SQLite database file
+// Models are stored with the same BSON representation used by DbMongo
+// Queries run on q, which is the database or the transaction of an update
+type DbSqlite struct {
+	db *sql.DB
+	q  sqliteQuerier
+}
+
+// Return new DbSqlite instance for the database file path of dbConnectivity
+func NewDbSqlite(ctx context.Context, dbConnectivity config.DbConnectivity) *DbSqlite {
+	d, errOpen := openDbSqlite(ctx, dbConnectivity.Path)
+	if errOpen != nil {
+		log.Fatal(errOpen)
+	}
+	return d
+}
+
+// Open sqlite database file and apply any pending schema migrations
+func openDbSqlite(ctx context.Context, path string) (*DbSqlite, error) {
+	db, errOpen := sql.Open("sqlite3", path)
+	if errOpen != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_SQLITE_OPEN, errOpen))
+	}
+	// sqlite allows a single writer so share a single connection
+	db.SetMaxOpenConns(1)
+	if errPing := db.PingContext(ctx); errPing != nil {
+		db.Close()
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_SQLITE_OPEN, errPing))
+	}
+	if errMigrate := migrateSqlite(ctx, db); errMigrate != nil {
+		db.Close()
+		return nil, errMigrate
+	}
+	return &DbSqlite{db, db}, nil
+}
+
+// Close sqlite database
+func (d *DbSqlite) Close() error {
+	return d.db.Close()
+}
+
+// Save latest attestation to the Attestation table
+func (d *DbSqlite) SaveAttestation(ctx context.Context, attestation models.Attestation) error {
+	doc, docErr := bson.Marshal(attestation)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_MODEL, docErr))
+	}
+
+	// insert or update attestation
+	_, resErr := d.q.ExecContext(ctx, `INSERT INTO Attestation (txid, merkle_root, confirmed, inserted_at, doc)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT (txid, merkle_root) DO UPDATE SET
+		confirmed = excluded.confirmed, inserted_at = excluded.inserted_at, doc = excluded.doc`,
+		attestation.Txid.String(), attestation.CommitmentHash().String(), attestation.Confirmed, time.Now().UnixNano(), doc)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_SAVE, resErr))
+	}
+	return nil
+}
+
+// Save attestation update with all its writes in a single transaction
+func (d *DbSqlite) SaveAttestationUpdate(ctx context.Context, attestation models.Attestation,
+	proofs ...models.CommitmentMerkleProof) error {
+	tx, errTx := d.db.BeginTx(ctx, nil)
+	if errTx != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_SAVE, errTx))
+	}
+	if errSave := saveAttestationUpdate(ctx, &DbSqlite{d.db, tx}, attestation, proofs); errSave != nil {
+		tx.Rollback()
+		return errSave
+	}
+	if errCommit := tx.Commit(); errCommit != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_SAVE, errCommit))
+	}
+	return nil
+}
+
+// Save latest attestation info to the AttestationInfo table
+func (d *DbSqlite) SaveAttestationInfo(ctx context.Context, attestationInfo models.AttestationInfo) error {
+	doc, docErr := bson.Marshal(attestationInfo)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_INFO_MODEL, docErr))
+	}
+
+	// insert or update attestation info
+	_, resErr := d.q.ExecContext(ctx, `INSERT INTO AttestationInfo (txid, time, height, doc)
+		VALUES (?, ?, ?, ?) ON CONFLICT (txid) DO UPDATE SET
+		time = excluded.time, height = excluded.height, doc = excluded.doc`,
+		attestationInfo.Txid, attestationInfo.Time, attestationInfo.Height, doc)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_INFO_SAVE, resErr))
+	}
+	return nil
+}
+
+// Save merkle commitments to the MerkleCommitment table
+func (d *DbSqlite) SaveMerkleCommitments(ctx context.Context, commitments []models.CommitmentMerkleCommitment) error {
+	for pos := range commitments {
+		doc, docErr := bson.Marshal(commitments[pos])
+		if docErr != nil {
+			return errors.New(fmt.Sprintf("%s %v", BAD_DATA_MERKLE_COMMITMENT_MODEL, docErr))
+		}
+
+		// insert or update merkle commitment
+		_, resErr := d.q.ExecContext(ctx, `INSERT INTO MerkleCommitment (merkle_root, client_position, commitment, doc)
+			VALUES (?, ?, ?, ?) ON CONFLICT (merkle_root, client_position)
+			DO UPDATE SET commitment = excluded.commitment, doc = excluded.doc`,
+			commitments[pos].MerkleRoot.String(), commitments[pos].ClientPosition, commitments[pos].Commitment.String(), doc)
+		if resErr != nil {
+			return errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_COMMITMENT_SAVE, resErr))
+		}
+	}
+	return nil
+}
+
+// Save merkle proofs to the MerkleProof table
+func (d *DbSqlite) SaveMerkleProofs(ctx context.Context, proofs []models.CommitmentMerkleProof) error {
+	for pos := range proofs {
+		doc, docErr := bson.Marshal(proofs[pos])
+		if docErr != nil {
+			return errors.New(fmt.Sprintf("%s %v", BAD_DATA_MERKLE_PROOF_MODEL, docErr))
+		}
+
+		// insert or update merkle proof
+		_, resErr := d.q.ExecContext(ctx, `INSERT INTO MerkleProof (merkle_root, client_position, doc)
+			VALUES (?, ?, ?) ON CONFLICT (merkle_root, client_position) DO UPDATE SET doc = excluded.doc`,
+			proofs[pos].MerkleRoot.String(), proofs[pos].ClientPosition, doc)
+		if resErr != nil {
+			return errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_PROOF_SAVE, resErr))
+		}
+	}
+	return nil
+}
+
+// Delete merkle proofs and merkle commitments of the merkle root in a single transaction
+func (d *DbSqlite) PruneMerkleRoot(ctx context.Context, merkleRoot chainhash.Hash) error {
+	tx, errTx := d.db.BeginTx(ctx, nil)
+	if errTx != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_ROOT_PRUNE, errTx))
+	}
+	for _, query := range []string{`DELETE FROM MerkleProof WHERE merkle_root = ?`,
+		`DELETE FROM MerkleCommitment WHERE merkle_root = ?`} {
+		if _, resErr := tx.ExecContext(ctx, query, merkleRoot.String()); resErr != nil {
+			tx.Rollback()
+			return errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_ROOT_PRUNE, resErr))
+		}
+	}
+	if errCommit := tx.Commit(); errCommit != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_ROOT_PRUNE, errCommit))
+	}
+	return nil
+}
+
+// Save client commitment to ClientCommitment table
+func (d *DbSqlite) SaveClientCommitment(ctx context.Context, commitment models.ClientCommitment) error {
+	doc, docErr := bson.Marshal(commitment)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_COMMITMENT_MODEL, docErr))
+	}
+
+	// insert or update client commitment
+	_, resErr := d.q.ExecContext(ctx, `INSERT INTO ClientCommitment (client_position, doc)
+		VALUES (?, ?) ON CONFLICT (client_position) DO UPDATE SET doc = excluded.doc`,
+		commitment.ClientPosition, doc)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_SAVE, resErr))
+	}
+	return nil
+}
+
+// Append client commitment to ClientCommitmentHistory table
+// The sequence of history entries is their table id and is not stored in the doc
+func (d *DbSqlite) SaveClientCommitmentHistory(ctx context.Context, history models.ClientCommitmentHistory) error {
+	history.Sequence = 0
+	doc, docErr := bson.Marshal(history)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_COMMITMENT_HISTORY_MODEL, docErr))
+	}
+
+	// always insert as history is append-only
+	_, resErr := d.q.ExecContext(ctx, `INSERT INTO ClientCommitmentHistory
+		(client_position, commitment, submitted_at, txid, doc) VALUES (?, ?, ?, ?, ?)`,
+		history.ClientPosition, history.Commitment.String(), sqliteTime(history.SubmittedAt), sqliteHistoryTxid(history), doc)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_SAVE, resErr))
+	}
+	return nil
+}
+
+// Return submitted_at column value of a time, with times before the
+// unix epoch, including the zero time, stored as the epoch
+func sqliteTime(t time.Time) int64 {
+	if t.Before(time.Unix(0, 0)) {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// Return txid column value of client commitment history, empty until attested
+func sqliteHistoryTxid(history models.ClientCommitmentHistory) string {
+	if history.Attested() {
+		return history.Txid.String()
+	}
+	return ""
+}
+
+// Set attestation of the latest unattested history entry matching
+// the client position and commitment of the merkle commitment
+// No entry is updated if one is already attested by txid
+func (d *DbSqlite) AttestClientCommitmentHistory(ctx context.Context, commitment models.CommitmentMerkleCommitment, txid chainhash.Hash) error {
+	var attested int64
+	resErr := d.q.QueryRowContext(ctx, `SELECT COUNT(*) FROM ClientCommitmentHistory
+		WHERE client_position = ? AND commitment = ? AND txid = ?`,
+		commitment.ClientPosition, commitment.Commitment.String(), txid.String()).Scan(&attested)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_SAVE, resErr))
+	} else if attested > 0 {
+		return nil
+	}
+
+	var id int64
+	var doc []byte
+	resErr = d.q.QueryRowContext(ctx, `SELECT id, doc FROM ClientCommitmentHistory
+		WHERE client_position = ? AND commitment = ? AND txid = ''
+		ORDER BY id DESC LIMIT 1`,
+		commitment.ClientPosition, commitment.Commitment.String()).Scan(&id, &doc)
+	if resErr == sql.ErrNoRows {
+		return nil
+	} else if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_SAVE, resErr))
+	}
+
+	history := models.ClientCommitmentHistory{}
+	if err := bson.Unmarshal(doc, &history); err != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_COMMITMENT_HISTORY_COL, err))
+	}
+	history.Txid = txid
+	history.MerkleRoot = commitment.MerkleRoot
+	doc, docErr := bson.Marshal(history)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_COMMITMENT_HISTORY_MODEL, docErr))
+	}
+
+	_, resErr = d.q.ExecContext(ctx, `UPDATE ClientCommitmentHistory SET txid = ?, doc = ? WHERE id = ?`,
+		txid.String(), doc, id)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_SAVE, resErr))
+	}
+	return nil
+}
+
+// Save client details to ClientDetails table
+func (d *DbSqlite) SaveClientDetails(ctx context.Context, details models.ClientDetails) error {
+	doc, docErr := bson.Marshal(details)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_DETAILS_MODEL, docErr))
+	}
+
+	// insert or update client details
+	_, resErr := d.q.ExecContext(ctx, `INSERT INTO ClientDetails (client_position, doc)
+		VALUES (?, ?) ON CONFLICT (client_position) DO UPDATE SET doc = excluded.doc`,
+		details.ClientPosition, doc)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_DETAILS_SAVE, resErr))
+	}
+	return nil
+}
+
+// Append revoked client details to RevokedClientDetails table
+func (d *DbSqlite) SaveRevokedClientDetails(ctx context.Context, details models.ClientDetails) error {
+	doc, docErr := bson.Marshal(details)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_DETAILS_MODEL, docErr))
+	}
+	_, resErr := d.q.ExecContext(ctx, `INSERT INTO RevokedClientDetails (client_position, ended_at, doc)
+		VALUES (?, ?, ?)`, details.ClientPosition, details.EndedAt, doc)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_DETAILS_SAVE, resErr))
+	}
+	return nil
+}
+
+// Get revoked client details for position oldest first
+func (d *DbSqlite) GetRevokedClientDetails(ctx context.Context, position int32) ([]models.ClientDetails, error) {
+	docs, resErr := d.queryDocs(ctx, `SELECT doc FROM RevokedClientDetails WHERE client_position = ?
+		ORDER BY ended_at, id`, position)
+	if resErr != nil {
+		return []models.ClientDetails{}, errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_DETAILS_GET, resErr))
+	}
+
+	details := []models.ClientDetails{}
+	for _, doc := range docs {
+		detailsModel := models.ClientDetails{}
+		if err := bson.Unmarshal(doc, &detailsModel); err != nil {
+			return []models.ClientDetails{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_DETAILS_COL, err))
+		}
+		details = append(details, detailsModel)
+	}
+	return details, nil
+}
+
+// Get client details ordered by client position
+func (d *DbSqlite) GetClientDetails(ctx context.Context) ([]models.ClientDetails, error) {
+	docs, resErr := d.queryDocs(ctx, `SELECT doc FROM ClientDetails ORDER BY client_position`)
+	if resErr != nil {
+		return []models.ClientDetails{}, errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_DETAILS_GET, resErr))
+	}
+
+	var details []models.ClientDetails
+	for _, doc := range docs {
+		detailsModel := models.ClientDetails{}
+		if err := bson.Unmarshal(doc, &detailsModel); err != nil {
+			return []models.ClientDetails{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_DETAILS_COL, err))
+		}
+		details = append(details, detailsModel)
+	}
+	return details, nil
+}
+
+// Return BSON documents of the doc column of all query result rows
+func (d *DbSqlite) queryDocs(ctx context.Context, query string, args ...interface{}) ([][]byte, error) {
+	rows, errQuery := d.q.QueryContext(ctx, query, args...)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+	defer rows.Close()
+
+	var docs [][]byte
+	for rows.Next() {
+		var doc []byte
+		if err := rows.Scan(&doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// Get Attestation table row count
+func (d *DbSqlite) getLatestAttestationCount(ctx context.Context) (int64, error) {
+	var count int64
+	resErr := d.q.QueryRowContext(ctx, `SELECT COUNT(*) FROM Attestation`).Scan(&count)
+	if resErr != nil {
+		return 0, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, resErr))
+	}
+	return count, nil
+}
+
+// Get latest Attestation entry from table and return merkle_root field
+func (d *DbSqlite) GetLatestAttestationMerkleRoot(ctx context.Context, confirmed bool) (string, error) {
+	// first check if attestation has any rows
+	count, countErr := d.getLatestAttestationCount(ctx)
+	if countErr != nil {
+		return "", countErr
+	} else if count == 0 { // no attestations yet
+		return "", nil
+	}
+
+	var merkleRoot string
+	resErr := d.q.QueryRowContext(ctx, `SELECT merkle_root FROM Attestation WHERE confirmed = ?
+		ORDER BY inserted_at DESC, rowid DESC LIMIT 1`, confirmed).Scan(&merkleRoot)
+	if resErr != nil {
+		return "", errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, resErr))
+	}
+	return merkleRoot, nil
+}
+
+// Return merkle root of attestation with given txid hash
+func (d *DbSqlite) GetAttestationMerkleRoot(ctx context.Context, txid chainhash.Hash) (string, error) {
+	// first check if attestation has any rows
+	count, countErr := d.getLatestAttestationCount(ctx)
+	if countErr != nil {
+		return "", countErr
+	} else if count == 0 { // no attestations yet
+		return "", nil
+	}
+
+	var merkleRoot string
+	resErr := d.q.QueryRowContext(ctx, `SELECT merkle_root FROM Attestation WHERE txid = ?`,
+		txid.String()).Scan(&merkleRoot)
+	if resErr != nil {
+		return "", errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, resErr))
+	}
+	return merkleRoot, nil
+}
+
+// Return MerkleCommitment commitments for attestation with given txid hash
+func (d *DbSqlite) GetAttestationMerkleCommitments(ctx context.Context, txid chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
+	// get merkle root of attestation
+	merkleRoot, rootErr := d.GetAttestationMerkleRoot(ctx, txid)
+	if rootErr != nil {
+		return []models.CommitmentMerkleCommitment{}, rootErr
+	} else if merkleRoot == "" {
+		return []models.CommitmentMerkleCommitment{}, nil
+	}
+
+	return d.queryMerkleCommitments(ctx, `SELECT doc FROM MerkleCommitment WHERE merkle_root = ?
+		ORDER BY client_position`, merkleRoot)
+}
+
+// Return positions of the zero merkle commitments of a merkle root
+// Merkle commitments saved before their commitment was stored in the
+// MerkleCommitment table are matched by their document
+func (d *DbSqlite) GetEmptySlots(ctx context.Context, merkleRoot chainhash.Hash) ([]int32, error) {
+	merkleCommitments, errCommitments := d.queryMerkleCommitments(ctx, `SELECT doc FROM MerkleCommitment
+		WHERE merkle_root = ? AND (commitment = ? OR commitment IS NULL) ORDER BY client_position`,
+		merkleRoot.String(), (chainhash.Hash{}).String())
+	if errCommitments != nil {
+		return []int32{}, errCommitments
+	}
+	emptySlots := []int32{}
+	for _, c := range merkleCommitments {
+		if (c.Commitment == chainhash.Hash{}) {
+			emptySlots = append(emptySlots, c.ClientPosition)
+		}
+	}
+	return emptySlots, nil
+}
+
+// Return merkle commitments of the MerkleCommitment table query
+func (d *DbSqlite) queryMerkleCommitments(ctx context.Context, query string, args ...interface{}) ([]models.CommitmentMerkleCommitment, error) {
+	docs, resErr := d.queryDocs(ctx, query, args...)
+	if resErr != nil {
+		return []models.CommitmentMerkleCommitment{},
+			errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_COMMITMENT_GET, resErr))
+	}
+
+	var merkleCommitments []models.CommitmentMerkleCommitment
+	for _, doc := range docs {
+		commitmentModel := models.CommitmentMerkleCommitment{}
+		if err := bson.Unmarshal(doc, &commitmentModel); err != nil {
+			return []models.CommitmentMerkleCommitment{},
+				errors.New(fmt.Sprintf("%s %v", BAD_DATA_MERKLE_COMMITMENT_COL, err))
+		}
+		merkleCommitments = append(merkleCommitments, commitmentModel)
+	}
+	return merkleCommitments, nil
+}
+
+// Return latest commitments from ClientCommitment table
+func (d *DbSqlite) GetClientCommitments(ctx context.Context) ([]models.ClientCommitment, error) {
+	docs, resErr := d.queryDocs(ctx, `SELECT doc FROM ClientCommitment ORDER BY client_position`)
+	if resErr != nil {
+		return []models.ClientCommitment{}, errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_GET, resErr))
+	}
+
+	var latestCommitments []models.ClientCommitment
+	for _, doc := range docs {
+		commitmentModel := models.ClientCommitment{}
+		if err := bson.Unmarshal(doc, &commitmentModel); err != nil {
+			return []models.ClientCommitment{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_COMMITMENT_COL, err))
+		}
+		latestCommitments = append(latestCommitments, commitmentModel)
+	}
+	return latestCommitments, nil
+}
+
+// Return Attestation model for attestation with given txid hash
+func (d *DbSqlite) GetAttestation(ctx context.Context, txid chainhash.Hash) (models.Attestation, error) {
+	var doc []byte
+	resErr := d.q.QueryRowContext(ctx, `SELECT doc FROM Attestation WHERE txid = ?`, txid.String()).Scan(&doc)
+	if resErr != nil {
+		return models.Attestation{}, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, resErr))
+	}
+	attestationModel := models.Attestation{}
+	if err := bson.Unmarshal(doc, &attestationModel); err != nil {
+		return models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_COL, err))
+	}
+	return attestationModel, nil
+}
+
+// Return latest Attestation model for the merkle root given, preferring confirmed attestations
+func (d *DbSqlite) GetMerkleRootAttestation(ctx context.Context, merkleRoot chainhash.Hash) (models.Attestation, error) {
+	var doc []byte
+	resErr := d.q.QueryRowContext(ctx, `SELECT doc FROM Attestation WHERE merkle_root = ?
+		ORDER BY confirmed DESC, inserted_at DESC, rowid DESC LIMIT 1`, merkleRoot.String()).Scan(&doc)
+	if resErr != nil {
+		return models.Attestation{}, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, resErr))
+	}
+	attestationModel := models.Attestation{}
+	if err := bson.Unmarshal(doc, &attestationModel); err != nil {
+		return models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_COL, err))
+	}
+	return attestationModel, nil
+}
+
+// Return AttestationInfo model for attestation with given txid hash
+func (d *DbSqlite) GetAttestationInfo(ctx context.Context, txid chainhash.Hash) (models.AttestationInfo, error) {
+	var doc []byte
+	resErr := d.q.QueryRowContext(ctx, `SELECT doc FROM AttestationInfo WHERE txid = ?`, txid.String()).Scan(&doc)
+	if resErr != nil {
+		return models.AttestationInfo{}, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_INFO_GET, resErr))
+	}
+	infoModel := models.AttestationInfo{}
+	if err := bson.Unmarshal(doc, &infoModel); err != nil {
+		return models.AttestationInfo{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_INFO_COL, err))
+	}
+	return infoModel, nil
+}
+
+// Return CommitmentMerkleProof model for merkle root and client position
+func (d *DbSqlite) GetMerkleProof(ctx context.Context, merkleRoot chainhash.Hash, position int32) (models.CommitmentMerkleProof, error) {
+	var doc []byte
+	resErr := d.q.QueryRowContext(ctx, `SELECT doc FROM MerkleProof WHERE merkle_root = ? AND client_position = ?`,
+		merkleRoot.String(), position).Scan(&doc)
+	if resErr != nil {
+		return models.CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %v", ERROR_MERKLE_PROOF_GET, resErr))
+	}
+	proofModel := models.CommitmentMerkleProof{}
+	if err := bson.Unmarshal(doc, &proofModel); err != nil {
+		return models.CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_MERKLE_PROOF_COL, err))
+	}
+	return proofModel, nil
+}
+
+// Return Attestation models matching the filter, latest first, with info set
+// for confirmed attestations, skipping and limiting the results for paging
+// Attestation info is joined from the AttestationInfo table by txid
+func (d *DbSqlite) GetAttestations(ctx context.Context, filter AttestationFilter, skip int64, limit int64) ([]models.Attestation, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Confirmed != nil {
+		conditions = append(conditions, "a.confirmed = ?")
+		args = append(args, *filter.Confirmed)
+	}
+	if filter.MerkleRoot != nil {
+		conditions = append(conditions, "a.merkle_root = ?")
+		args = append(args, filter.MerkleRoot.String())
+	}
+	if filter.hasInfoFilter() {
+		conditions = append(conditions, "a.confirmed = 1")
+		conditions, args = sqliteRangeFilter(conditions, args, "i.time", filter.FromTime, filter.ToTime)
+		conditions, args = sqliteRangeFilter(conditions, args, "i.height", filter.FromHeight, filter.ToHeight)
+	}
+	query := `SELECT a.confirmed, a.doc, i.doc FROM Attestation a LEFT JOIN AttestationInfo i ON i.txid = a.txid`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY a.inserted_at DESC, a.rowid DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, skip)
+
+	rows, resErr := d.q.QueryContext(ctx, query, args...)
+	if resErr != nil {
+		return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", ERROR_ATTESTATION_GET, resErr))
+	}
+	defer rows.Close()
+
+	// iterate through attestations and their info
+	attestations := []models.Attestation{}
+	for rows.Next() {
+		var confirmed bool
+		var doc, infoDoc []byte
+		if err := rows.Scan(&confirmed, &doc, &infoDoc); err != nil {
+			return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_COL, err))
+		}
+		attestationModel := models.Attestation{}
+		if err := bson.Unmarshal(doc, &attestationModel); err != nil {
+			return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_COL, err))
+		}
+		if confirmed && infoDoc != nil {
+			if err := bson.Unmarshal(infoDoc, &attestationModel.Info); err != nil {
+				return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_INFO_COL, err))
+			}
+		}
+		attestations = append(attestations, attestationModel)
+	}
+	if err := rows.Err(); err != nil {
+		return []models.Attestation{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_ATTESTATION_COL, err))
+	}
+	return attestations, nil
+}
+
+// Append inclusive range conditions on column for non zero bounds
+func sqliteRangeFilter(conditions []string, args []interface{}, column string, from int64, to int64) ([]string, []interface{}) {
+	if from != 0 {
+		conditions = append(conditions, column+" >= ?")
+		args = append(args, from)
+	}
+	if to != 0 {
+		conditions = append(conditions, column+" <= ?")
+		args = append(args, to)
+	}
+	return conditions, args
+}
+
+// Return client commitment history for position with sequence after the
+// first and up to the second sequence provided, latest first
+// The upper sequence bound is not applied if zero
+// The sequence of history entries is their ClientCommitmentHistory table id
+func (d *DbSqlite) GetClientCommitmentHistory(ctx context.Context, position int32, after int64, until int64, skip int64, limit int64) ([]models.ClientCommitmentHistory, error) {
+	conditions := []string{"client_position = ?", "id > ?"}
+	args := []interface{}{position, after}
+	if until != 0 {
+		conditions = append(conditions, "id <= ?")
+		args = append(args, until)
+	}
+	rows, resErr := d.q.QueryContext(ctx, `SELECT id, doc FROM ClientCommitmentHistory WHERE `+
+		strings.Join(conditions, " AND ")+` ORDER BY id DESC LIMIT ? OFFSET ?`, append(args, limit, skip)...)
+	if resErr != nil {
+		return []models.ClientCommitmentHistory{},
+			errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_GET, resErr))
+	}
+	defer rows.Close()
+
+	history := []models.ClientCommitmentHistory{}
+	for rows.Next() {
+		var id int64
+		var doc []byte
+		if err := rows.Scan(&id, &doc); err != nil {
+			return []models.ClientCommitmentHistory{},
+				errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_GET, err))
+		}
+		historyModel := models.ClientCommitmentHistory{}
+		if err := bson.Unmarshal(doc, &historyModel); err != nil {
+			return []models.ClientCommitmentHistory{},
+				errors.New(fmt.Sprintf("%s %v", BAD_DATA_CLIENT_COMMITMENT_HISTORY_COL, err))
+		}
+		historyModel.Sequence = id
+		history = append(history, historyModel)
+	}
+	if err := rows.Err(); err != nil {
+		return []models.ClientCommitmentHistory{},
+			errors.New(fmt.Sprintf("%s %v", ERROR_CLIENT_COMMITMENT_HISTORY_GET, err))
+	}
+	return history, nil
+}
+
+// Save webhook delivery to WebhookDelivery table
+// Deliveries are upserted by attestation txid and client position
+func (d *DbSqlite) SaveWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	doc, docErr := bson.Marshal(delivery)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_WEBHOOK_DELIVERY_MODEL, docErr))
+	}
+
+	_, resErr := d.q.ExecContext(ctx, `INSERT INTO WebhookDelivery (txid, client_position, delivered, failed_at, doc)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT (txid, client_position) DO UPDATE SET
+		delivered = excluded.delivered, failed_at = excluded.failed_at, doc = excluded.doc`,
+		delivery.Txid.String(), delivery.ClientPosition, delivery.Delivered, delivery.FailedAt.UnixNano(), doc)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_WEBHOOK_DELIVERY_SAVE, resErr))
+	}
+	return nil
+}
+
+// Add webhook delivery to WebhookDelivery table
+// Deliveries already recorded for the attestation txid and client position are kept
+func (d *DbSqlite) AddWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	doc, docErr := bson.Marshal(delivery)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BAD_DATA_WEBHOOK_DELIVERY_MODEL, docErr))
+	}
+
+	_, resErr := d.q.ExecContext(ctx, `INSERT INTO WebhookDelivery (txid, client_position, delivered, failed_at, doc)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT (txid, client_position) DO NOTHING`,
+		delivery.Txid.String(), delivery.ClientPosition, delivery.Delivered, delivery.FailedAt.UnixNano(), doc)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_WEBHOOK_DELIVERY_SAVE, resErr))
+	}
+	return nil
+}
+
+// Return webhook deliveries that have or have not been redelivered, oldest failure first
+func (d *DbSqlite) GetWebhookDeliveries(ctx context.Context, delivered bool) ([]models.WebhookDelivery, error) {
+	docs, resErr := d.queryDocs(ctx, `SELECT doc FROM WebhookDelivery WHERE delivered = ?
+		ORDER BY failed_at, txid, client_position`, delivered)
+	if resErr != nil {
+		return []models.WebhookDelivery{}, errors.New(fmt.Sprintf("%s %v", ERROR_WEBHOOK_DELIVERY_GET, resErr))
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	for _, doc := range docs {
+		deliveryModel := models.WebhookDelivery{}
+		if err := bson.Unmarshal(doc, &deliveryModel); err != nil {
+			return []models.WebhookDelivery{}, errors.New(fmt.Sprintf("%s %v", BAD_DATA_WEBHOOK_DELIVERY_COL, err))
+		}
+		deliveries = append(deliveries, deliveryModel)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// sqlite schema migrations
+// Each migration upgrades the schema by one version and is applied once, in
+// order, with the current schema version stored in the database user_version
+// Tables are named after the DbMongo collections and store the BSON document
+// of each model along with the fields it is queried by
+var sqliteMigrations = []string{
+	// version 1: DbMongo collections
+	`CREATE TABLE Attestation (
+		txid        TEXT    NOT NULL,
+		merkle_root TEXT    NOT NULL,
+		confirmed   INTEGER NOT NULL,
+		inserted_at INTEGER NOT NULL,
+		doc         BLOB    NOT NULL,
+		PRIMARY KEY (txid, merkle_root)
+	);
+	CREATE INDEX Attestation_inserted_at ON Attestation (inserted_at);
+	CREATE INDEX Attestation_merkle_root ON Attestation (merkle_root);
+
+	CREATE TABLE AttestationInfo (
+		txid   TEXT    NOT NULL PRIMARY KEY,
+		time   INTEGER NOT NULL,
+		height INTEGER NOT NULL,
+		doc    BLOB    NOT NULL
+	);
+
+	CREATE TABLE MerkleCommitment (
+		merkle_root     TEXT    NOT NULL,
+		client_position INTEGER NOT NULL,
+		doc             BLOB    NOT NULL,
+		PRIMARY KEY (merkle_root, client_position)
+	);
+
+	CREATE TABLE MerkleProof (
+		merkle_root     TEXT    NOT NULL,
+		client_position INTEGER NOT NULL,
+		doc             BLOB    NOT NULL,
+		PRIMARY KEY (merkle_root, client_position)
+	);
+
+	CREATE TABLE ClientCommitment (
+		client_position INTEGER NOT NULL PRIMARY KEY,
+		doc             BLOB    NOT NULL
+	);
+
+	CREATE TABLE ClientDetails (
+		client_position INTEGER NOT NULL PRIMARY KEY,
+		doc             BLOB    NOT NULL
+	);
+
+	CREATE TABLE ClientCommitmentHistory (
+		id              INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		client_position INTEGER NOT NULL,
+		commitment      TEXT    NOT NULL,
+		submitted_at    INTEGER NOT NULL,
+		txid            TEXT    NOT NULL,
+		doc             BLOB    NOT NULL
+	);
+	CREATE INDEX ClientCommitmentHistory_position ON ClientCommitmentHistory (client_position, submitted_at);`,
+	// version 2: failed client webhook deliveries
+	`CREATE TABLE WebhookDelivery (
+		txid            TEXT    NOT NULL,
+		client_position INTEGER NOT NULL,
+		delivered       INTEGER NOT NULL,
+		failed_at       INTEGER NOT NULL,
+		doc             BLOB    NOT NULL,
+		PRIMARY KEY (txid, client_position)
+	);
+	CREATE INDEX WebhookDelivery_delivered ON WebhookDelivery (delivered, failed_at);`,
+	// version 3: details of revoked clients of reused positions
+	`CREATE TABLE RevokedClientDetails (
+		id              INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		client_position INTEGER NOT NULL,
+		ended_at        INTEGER NOT NULL,
+		doc             BLOB    NOT NULL
+	);
+	CREATE INDEX RevokedClientDetails_position ON RevokedClientDetails (client_position, ended_at);`,
+	// version 4: merkle commitments queried by commitment
+	`ALTER TABLE MerkleCommitment ADD COLUMN commitment TEXT;
+	CREATE INDEX MerkleCommitment_commitment ON MerkleCommitment (merkle_root, commitment);`,
+	// version 5: client commitment history queried by sequence
+	`CREATE INDEX ClientCommitmentHistory_position_id ON ClientCommitmentHistory (client_position, id);`,
+}
+
+// Return schema version of the sqlite database
+func sqliteSchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return 0, errors.New(fmt.Sprintf("%s %v", ERROR_SQLITE_MIGRATE, err))
+	}
+	return version, nil
+}
+
+// Apply pending schema migrations to the sqlite database
+// Each migration is applied in a transaction with its schema version update
+// Databases with a newer schema version than known are rejected
+func migrateSqlite(ctx context.Context, db *sql.DB) error {
+	version, errVersion := sqliteSchemaVersion(ctx, db)
+	if errVersion != nil {
+		return errVersion
+	} else if version > len(sqliteMigrations) {
+		return errors.New(fmt.Sprintf("%s %d", ERROR_SQLITE_SCHEMA_VERSION, version))
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		tx, errTx := db.BeginTx(ctx, nil)
+		if errTx != nil {
+			return errors.New(fmt.Sprintf("%s %v", ERROR_SQLITE_MIGRATE, errTx))
+		}
+		if _, err := tx.ExecContext(ctx, sqliteMigrations[version]); err != nil {
+			tx.Rollback()
+			return errors.New(fmt.Sprintf("%s %d: %v", ERROR_SQLITE_MIGRATE, version+1, err))
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return errors.New(fmt.Sprintf("%s %d: %v", ERROR_SQLITE_MIGRATE, version+1, err))
+		}
+		if err := tx.Commit(); err != nil {
+			return errors.New(fmt.Sprintf("%s %d: %v", ERROR_SQLITE_MIGRATE, version+1, err))
+		}
+	}
+	return nil
+}
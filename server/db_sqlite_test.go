@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mainstay/config"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test DbSqlite conformance on new database files
+func TestDbSqliteConformance(t *testing.T) {
+	dir, errDir := ioutil.TempDir("", "mainstay-sqlite")
+	assert.Equal(t, nil, errDir)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	var count int
+	testDbConformance(t, func() Db {
+		count++
+		return NewDbSqlite(ctx, config.DbConnectivity{Backend: config.DB_BACKEND_SQLITE,
+			Path: filepath.Join(dir, fmt.Sprintf("mainstay%d.db", count))})
+	})
+}
+
+// Test DbSqlite schema migrations on open
+func TestDbSqliteMigrations(t *testing.T) {
+	dir, errDir := ioutil.TempDir("", "mainstay-sqlite")
+	assert.Equal(t, nil, errDir)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	path := filepath.Join(dir, "mainstay.db")
+
+	// new database is migrated to the latest version
+	db, errOpen := openDbSqlite(ctx, path)
+	assert.Equal(t, nil, errOpen)
+	version, errVersion := sqliteSchemaVersion(ctx, db.db)
+	assert.Equal(t, nil, errVersion)
+	assert.Equal(t, len(sqliteMigrations), version)
+	assert.Equal(t, nil, db.SaveClientDetails(ctx, models.ClientDetails{ClientPosition: 0, AuthToken: "token0"}))
+	assert.Equal(t, nil, db.Close())
+
+	// reopening keeps the version and data
+	db, errOpen = openDbSqlite(ctx, path)
+	assert.Equal(t, nil, errOpen)
+	version, _ = sqliteSchemaVersion(ctx, db.db)
+	assert.Equal(t, len(sqliteMigrations), version)
+	details, _ := db.GetClientDetails(ctx)
+	assert.Equal(t, 1, len(details))
+
+	// newer schema versions are rejected
+	_, errExec := db.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(sqliteMigrations)+1))
+	assert.Equal(t, nil, errExec)
+	assert.Equal(t, nil, db.Close())
+	_, errOpen = openDbSqlite(ctx, path)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_SQLITE_SCHEMA_VERSION, len(sqliteMigrations)+1)), errOpen)
+}
+
+// Test DbSqlite attestation updates are rolled back on failure
+func TestDbSqliteAttestationUpdateRollback(t *testing.T) {
+	dir, errDir := ioutil.TempDir("", "mainstay-sqlite")
+	assert.Equal(t, nil, errDir)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	db, errOpen := openDbSqlite(ctx, filepath.Join(dir, "mainstay.db"))
+	assert.Equal(t, nil, errOpen)
+	defer db.Close()
+
+	// fail the last write of the update
+	_, errExec := db.db.Exec("DROP TABLE MerkleProof")
+	assert.Equal(t, nil, errExec)
+
+	txid := testDbHash("aaaaaa", 0)
+	commitment, _ := models.NewCommitment([]chainhash.Hash{testDbHash("bbbbbb", 0)})
+	assert.NotEqual(t, nil, db.SaveAttestationUpdate(ctx, *models.NewAttestation(txid, commitment)))
+
+	// no attestation or commitments are left behind
+	attestations, _ := db.GetAttestations(ctx, AttestationFilter{}, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, 0, len(attestations))
+	merkleCommitments, _ := db.GetAttestationMerkleCommitments(ctx, txid)
+	assert.Equal(t, 0, len(merkleCommitments))
+}
+
+// Test webhook deliveries are saved once the server context is cancelled
+func TestDbSqliteWebhookDeliveryShutdown(t *testing.T) {
+	dir, errDir := ioutil.TempDir("", "mainstay-sqlite")
+	assert.Equal(t, nil, errDir)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	db, errOpen := openDbSqlite(ctx, filepath.Join(dir, "mainstay.db"))
+	assert.Equal(t, nil, errOpen)
+	defer db.Close()
+	server := NewServer(ctx, db)
+	cancel()
+
+	delivery := models.WebhookDelivery{Txid: testDbHash("aaaaaa", 0), ClientPosition: 0, Pubkey: "pubkey0",
+		Url: "https://client0.example.com", Attempts: 1, LastError: "error0"}
+	assert.NotEqual(t, nil, db.SaveWebhookDelivery(ctx, delivery))
+	assert.Equal(t, nil, server.SaveWebhookDelivery(delivery))
+	deliveries, _ := db.GetWebhookDeliveries(context.Background(), false)
+	assert.Equal(t, []models.WebhookDelivery{delivery}, deliveries)
+}
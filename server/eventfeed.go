@@ -0,0 +1,76 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"mainstay/models"
+)
+
+// error consts
+const (
+	ERROR_EVENT_CURSOR = "Event cursor not available in the event feed"
+)
+
+// number of latest attestation events kept for resuming subscribers
+const EVENT_FEED_SIZE = 1000
+
+// EventFeed structure
+// In-memory feed of the latest attestation lifecycle events
+// Events get increasing ids starting from firstId, which subscribers
+// use as a cursor to resume from the last event received. Only the
+// latest size events are kept, so cursors of older events expire
+type EventFeed struct {
+	mtx    sync.Mutex
+	size   int
+	events []models.AttestationEvent
+	nextId int64
+	notify chan struct{}
+}
+
+// Return new EventFeed instance keeping size events with ids from firstId
+func NewEventFeed(size int, firstId int64) *EventFeed {
+	return &EventFeed{size: size, nextId: firstId, notify: make(chan struct{})}
+}
+
+// Publish event to the feed and notify waiting subscribers
+// Returns the event with the id assigned by the feed
+func (f *EventFeed) Publish(event models.AttestationEvent) models.AttestationEvent {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	event.Id = f.nextId
+	f.nextId++
+	f.events = append(f.events, event)
+	if len(f.events) > f.size {
+		f.events = f.events[len(f.events)-f.size:]
+	}
+
+	close(f.notify)
+	f.notify = make(chan struct{})
+	return event
+}
+
+// Return the cursor of the latest event published
+func (f *EventFeed) Cursor() int64 {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.nextId - 1
+}
+
+// Return events published after the cursor and a channel that
+// is closed when the next event is published
+// Cursors of expired events or events not yet published are invalid
+func (f *EventFeed) Since(cursor int64) ([]models.AttestationEvent, <-chan struct{}, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	oldestCursor := f.nextId - 1 - int64(len(f.events))
+	if cursor < oldestCursor || cursor >= f.nextId {
+		return nil, nil, errors.New(fmt.Sprintf("%s %d", ERROR_EVENT_CURSOR, cursor))
+	}
+	events := make([]models.AttestationEvent, f.nextId-1-cursor)
+	copy(events, f.events[len(f.events)-len(events):])
+	return events, f.notify, nil
+}
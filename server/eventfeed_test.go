@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test EventFeed publishing, resuming from cursors and cursor expiry
+func TestEventFeed(t *testing.T) {
+	feed := NewEventFeed(3, 10)
+	assert.Equal(t, int64(9), feed.Cursor())
+
+	events, notify, errSince := feed.Since(9)
+	assert.Equal(t, nil, errSince)
+	assert.Equal(t, []models.AttestationEvent{}, events)
+
+	// publishing assigns ids and notifies subscribers
+	txid := chainhash.HashH([]byte{0x01})
+	event := feed.Publish(models.AttestationEvent{Type: models.EVENT_ATTESTATION_BROADCAST, Txid: txid})
+	assert.Equal(t, int64(10), event.Id)
+	select {
+	case <-notify:
+	default:
+		t.Error("subscriber not notified")
+	}
+	events, notify, _ = feed.Since(9)
+	assert.Equal(t, []models.AttestationEvent{event}, events)
+	select {
+	case <-notify:
+		t.Error("subscriber notified without new event")
+	default:
+	}
+
+	// replaced event followed by the broadcast of the replacement
+	replacementTxid := chainhash.HashH([]byte{0x02})
+	replaced := feed.Publish(models.AttestationEvent{Type: models.EVENT_ATTESTATION_REPLACED, Txid: txid,
+		ReplacementTxid: replacementTxid})
+	broadcast := feed.Publish(models.AttestationEvent{Type: models.EVENT_ATTESTATION_BROADCAST, Txid: replacementTxid})
+	events, _, _ = feed.Since(10)
+	assert.Equal(t, []models.AttestationEvent{replaced, broadcast}, events)
+	assert.Equal(t, int64(12), feed.Cursor())
+
+	// only the latest events are kept
+	confirmed := feed.Publish(models.AttestationEvent{Type: models.EVENT_ATTESTATION_CONFIRMED, Txid: replacementTxid})
+	events, _, errSince = feed.Since(10)
+	assert.Equal(t, nil, errSince)
+	assert.Equal(t, []models.AttestationEvent{replaced, broadcast, confirmed}, events)
+	_, _, errSince = feed.Since(9)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_EVENT_CURSOR, 9)), errSince)
+	_, _, errSince = feed.Since(14)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_EVENT_CURSOR, 14)), errSince)
+}
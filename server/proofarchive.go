@@ -0,0 +1,450 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// proof archive file names
+const (
+	PROOF_ARCHIVE_PREFIX       = "proofs-"
+	PROOF_ARCHIVE_SUFFIX       = ".jsonl.gz"
+	PROOF_ARCHIVE_INDEX_SUFFIX = ".idx"
+	PROOF_ARCHIVE_DAY_LAYOUT   = "2006-01-02"
+	PROOF_ARCHIVE_UNTIL        = "archived-until"
+)
+
+// number of decoded rounds kept in memory by the proof archive
+// Each round holds the merkle tree of all its slots
+const PROOF_ARCHIVE_CACHE_SIZE = 4
+
+// error consts
+const (
+	ERROR_PROOF_ARCHIVE_READ  = "could not read proof archive bundle"
+	ERROR_PROOF_ARCHIVE_WRITE = "could not write proof archive bundle"
+	ERROR_PROOF_ARCHIVE_INDEX = "invalid proof archive index"
+	ERROR_PROOF_ARCHIVE_UNTIL = "invalid proof archive high-water mark"
+)
+
+// proofArchiveEntry of the record of a merkle root in a bundle
+// Offset and length of the gzip member of the record in the bundle
+type proofArchiveEntry struct {
+	merkleRoot chainhash.Hash
+	offset     int64
+	length     int64
+}
+
+// proofArchiveIndex of the records of a bundle in bundle order
+// Size is the length of the index file up to its last complete entry
+// and end the length of the bundle up to the end of the last entry
+type proofArchiveIndex struct {
+	entries map[chainhash.Hash]proofArchiveEntry
+	order   []chainhash.Hash
+	size    int64
+	end     int64
+}
+
+// Add entries to the index, along with the length of their index lines
+func (i *proofArchiveIndex) add(entries []proofArchiveEntry, size int64) {
+	for _, entry := range entries {
+		i.entries[entry.merkleRoot] = entry
+		i.order = append(i.order, entry.merkleRoot)
+		i.end = entry.offset + entry.length
+	}
+	i.size += size
+}
+
+// proofArchiveRound decoded from the archive with the merkle tree of its slots
+type proofArchiveRound struct {
+	merkleRoot chainhash.Hash
+	tree       *models.IncrementalMerkleTree
+}
+
+// ProofArchive struct
+// Archive of old attestation rounds in compressed per-day bundles on disk
+// Each bundle is a gzip file of commitment archive json lines of the rounds
+// confirmed on a UTC day, from which the merkle commitments and proofs of
+// each round are rebuilt. Records are appended to the bundle as separate
+// gzip members and the offset of each merkle root in the bundle is kept in
+// an index file next to the bundle, which is only extended once the records
+// are written, so records of interrupted appends are discarded. Bundles
+// without an index are rewritten with an index on first access. Recently
+// used rounds are kept decoded, and the confirmation time up to which all
+// rounds have been archived is kept in the archive directory
+type ProofArchive struct {
+	dir     string
+	mtx     sync.Mutex
+	indexes map[string]*proofArchiveIndex
+	rounds  []proofArchiveRound // latest used last
+}
+
+// Return new ProofArchive instance for the archive directory
+func NewProofArchive(dir string) *ProofArchive {
+	return &ProofArchive{dir: dir, indexes: make(map[string]*proofArchiveIndex)}
+}
+
+// Return bundle day name for the UTC day of the time provided
+func bundleDay(day time.Time) string {
+	return day.UTC().Format(PROOF_ARCHIVE_DAY_LAYOUT)
+}
+
+// Return path of the bundle for the UTC day of the time provided
+func (a *ProofArchive) bundlePath(day time.Time) string {
+	return filepath.Join(a.dir, PROOF_ARCHIVE_PREFIX+bundleDay(day)+PROOF_ARCHIVE_SUFFIX)
+}
+
+// Return path of the bundle index for the UTC day of the time provided
+func (a *ProofArchive) indexPath(day time.Time) string {
+	return filepath.Join(a.dir, PROOF_ARCHIVE_PREFIX+bundleDay(day)+PROOF_ARCHIVE_INDEX_SUFFIX)
+}
+
+// Return all records of the bundle for the day, or none if there is no bundle
+func (a *ProofArchive) Read(day time.Time) ([]models.CommitmentArchiveRecord, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	index, errIndex := a.index(day)
+	if errIndex != nil || len(index.order) == 0 {
+		return nil, errIndex
+	}
+	bundle, errOpen := os.Open(a.bundlePath(day))
+	if errOpen != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_READ, errOpen))
+	}
+	defer bundle.Close()
+
+	var records []models.CommitmentArchiveRecord
+	for _, merkleRoot := range index.order {
+		record, errRecord := readArchiveRecord(bundle, index.entries[merkleRoot])
+		if errRecord != nil {
+			return nil, errRecord
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Find record of the merkle root in the bundle for the day
+// Only the record of the merkle root is read from the bundle
+// Returns nil if the merkle root has not been archived
+func (a *ProofArchive) Find(day time.Time, merkleRoot chainhash.Hash) (*models.CommitmentArchiveRecord, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.find(day, merkleRoot)
+}
+
+// Find record of the merkle root in the bundle for the day through the bundle index
+func (a *ProofArchive) find(day time.Time, merkleRoot chainhash.Hash) (*models.CommitmentArchiveRecord, error) {
+	index, errIndex := a.index(day)
+	if errIndex != nil {
+		return nil, errIndex
+	}
+	entry, found := index.entries[merkleRoot]
+	if !found {
+		return nil, nil
+	}
+	bundle, errOpen := os.Open(a.bundlePath(day))
+	if errOpen != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_READ, errOpen))
+	}
+	defer bundle.Close()
+
+	record, errRecord := readArchiveRecord(bundle, entry)
+	if errRecord != nil {
+		return nil, errRecord
+	}
+	return &record, nil
+}
+
+// Find round of the merkle root in the bundle for the day and return the
+// merkle tree of its slots, from which its commitment and proofs are built
+// Rounds are decoded once and the latest PROOF_ARCHIVE_CACHE_SIZE rounds
+// used are kept. Returns nil if the merkle root has not been archived
+func (a *ProofArchive) FindRound(day time.Time, merkleRoot chainhash.Hash) (*models.IncrementalMerkleTree, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for i, round := range a.rounds {
+		if round.merkleRoot == merkleRoot {
+			a.rounds = append(append(a.rounds[:i:i], a.rounds[i+1:]...), round)
+			return round.tree, nil
+		}
+	}
+	record, errFind := a.find(day, merkleRoot)
+	if errFind != nil || record == nil {
+		return nil, errFind
+	}
+	tree, errTree := models.NewIncrementalMerkleTree(record.Commitments, record.TreeVersion)
+	if errTree != nil {
+		return nil, errTree
+	}
+	if tree.Root() != record.MerkleRoot {
+		return nil, errors.New(fmt.Sprintf("%s %s", models.ERROR_COMMITMENT_MERKLE_ROOT, record.MerkleRoot.String()))
+	}
+	if len(a.rounds) == PROOF_ARCHIVE_CACHE_SIZE {
+		a.rounds = a.rounds[1:]
+	}
+	a.rounds = append(a.rounds, proofArchiveRound{merkleRoot, tree})
+	return tree, nil
+}
+
+// Add records to the bundle for the day, skipping merkle roots already archived
+// Records are appended to the bundle and then their entries to the index,
+// after discarding any records and entries of an interrupted append
+func (a *ProofArchive) Append(day time.Time, records []models.CommitmentArchiveRecord) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	index, errIndex := a.index(day)
+	if errIndex != nil {
+		return errIndex
+	}
+	var members bytes.Buffer
+	var entries []proofArchiveEntry
+	added := make(map[chainhash.Hash]bool)
+	for _, record := range records {
+		if _, found := index.entries[record.MerkleRoot]; found || added[record.MerkleRoot] {
+			continue
+		}
+		added[record.MerkleRoot] = true
+		offset := int64(members.Len())
+		if errWrite := writeArchiveMember(&members, record); errWrite != nil {
+			return errWrite
+		}
+		entries = append(entries, proofArchiveEntry{record.MerkleRoot, index.end + offset,
+			int64(members.Len()) - offset})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	var lines bytes.Buffer
+	for _, entry := range entries {
+		fmt.Fprintf(&lines, "%s %d %d\n", entry.merkleRoot.String(), entry.offset, entry.length)
+	}
+
+	// the index is created before the bundle, so that bundles without an
+	// index are only bundles that were written whole
+	if errDir := os.MkdirAll(a.dir, 0755); errDir != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errDir))
+	}
+	if errWrite := appendArchiveFile(a.indexPath(day), index.size, nil); errWrite != nil {
+		return errWrite
+	}
+	if errWrite := appendArchiveFile(a.bundlePath(day), index.end, members.Bytes()); errWrite != nil {
+		return errWrite
+	}
+	if errWrite := appendArchiveFile(a.indexPath(day), index.size, lines.Bytes()); errWrite != nil {
+		return errWrite
+	}
+	index.add(entries, int64(lines.Len()))
+	return nil
+}
+
+// Return the confirmation time up to which all rounds have been archived
+// Returns the zero time if no rounds have been archived
+func (a *ProofArchive) ArchivedUntil() (time.Time, error) {
+	until, errRead := ioutil.ReadFile(filepath.Join(a.dir, PROOF_ARCHIVE_UNTIL))
+	if os.IsNotExist(errRead) {
+		return time.Time{}, nil
+	} else if errRead != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_READ, errRead))
+	}
+	untilUnix, errParse := strconv.ParseInt(string(bytes.TrimSpace(until)), 10, 64)
+	if errParse != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_UNTIL, errParse))
+	}
+	return time.Unix(untilUnix, 0), nil
+}
+
+// Set the confirmation time up to which all rounds have been archived
+func (a *ProofArchive) SetArchivedUntil(until time.Time) error {
+	if errDir := os.MkdirAll(a.dir, 0755); errDir != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errDir))
+	}
+	return replaceArchiveFile(filepath.Join(a.dir, PROOF_ARCHIVE_UNTIL),
+		[]byte(strconv.FormatInt(until.Unix(), 10)+"\n"))
+}
+
+// Return index of the bundle for the day, loaded on first access
+// A bundle without an index is rewritten with an index
+func (a *ProofArchive) index(day time.Time) (*proofArchiveIndex, error) {
+	if index, found := a.indexes[bundleDay(day)]; found {
+		return index, nil
+	}
+	index, errIndex := a.readIndex(day)
+	if errIndex == nil && index == nil {
+		index, errIndex = a.reindex(day)
+	}
+	if errIndex != nil {
+		return nil, errIndex
+	}
+	a.indexes[bundleDay(day)] = index
+	return index, nil
+}
+
+// Read index of the bundle for the day, or nil if there is no index
+// An incomplete last entry of an interrupted append is ignored
+func (a *ProofArchive) readIndex(day time.Time) (*proofArchiveIndex, error) {
+	indexFile, errOpen := os.Open(a.indexPath(day))
+	if os.IsNotExist(errOpen) {
+		return nil, nil
+	} else if errOpen != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_READ, errOpen))
+	}
+	defer indexFile.Close()
+
+	index := &proofArchiveIndex{entries: make(map[chainhash.Hash]proofArchiveEntry)}
+	reader := bufio.NewReader(indexFile)
+	for {
+		line, errLine := reader.ReadString('\n')
+		if errLine == io.EOF {
+			return index, nil
+		} else if errLine != nil {
+			return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_READ, errLine))
+		}
+		var merkleRootStr string
+		var entry proofArchiveEntry
+		if _, errScan := fmt.Sscanf(line, "%s %d %d\n", &merkleRootStr, &entry.offset, &entry.length); errScan != nil {
+			return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_INDEX, errScan))
+		}
+		merkleRoot, errHash := chainhash.NewHashFromStr(merkleRootStr)
+		if errHash != nil {
+			return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_INDEX, errHash))
+		}
+		entry.merkleRoot = *merkleRoot
+		index.add([]proofArchiveEntry{entry}, int64(len(line)))
+	}
+}
+
+// Rewrite bundle for the day with a gzip member for each record and write its index
+// Returns an empty index if there is no bundle
+func (a *ProofArchive) reindex(day time.Time) (*proofArchiveIndex, error) {
+	index := &proofArchiveIndex{entries: make(map[chainhash.Hash]proofArchiveEntry)}
+	bundle, errOpen := os.Open(a.bundlePath(day))
+	if os.IsNotExist(errOpen) {
+		return index, nil
+	} else if errOpen != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_READ, errOpen))
+	}
+	defer bundle.Close()
+	reader, errGzip := gzip.NewReader(bundle)
+	if errGzip != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_READ, errGzip))
+	}
+	defer reader.Close()
+	records, errRead := models.ReadCommitmentArchive(reader)
+	if errRead != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_READ, errRead))
+	}
+
+	var members, lines bytes.Buffer
+	for _, record := range records {
+		if _, found := index.entries[record.MerkleRoot]; found {
+			continue
+		}
+		offset := int64(members.Len())
+		if errWrite := writeArchiveMember(&members, record); errWrite != nil {
+			return nil, errWrite
+		}
+		entry := proofArchiveEntry{record.MerkleRoot, offset, int64(members.Len()) - offset}
+		fmt.Fprintf(&lines, "%s %d %d\n", entry.merkleRoot.String(), entry.offset, entry.length)
+		index.add([]proofArchiveEntry{entry}, 0)
+	}
+	if errWrite := replaceArchiveFile(a.bundlePath(day), members.Bytes()); errWrite != nil {
+		return nil, errWrite
+	}
+	if errWrite := replaceArchiveFile(a.indexPath(day), lines.Bytes()); errWrite != nil {
+		return nil, errWrite
+	}
+	index.size = int64(lines.Len())
+	return index, nil
+}
+
+// Write record as a single gzip member
+func writeArchiveMember(w io.Writer, record models.CommitmentArchiveRecord) error {
+	writer := gzip.NewWriter(w)
+	if errWrite := models.WriteCommitmentArchiveRecord(writer, record); errWrite != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errWrite))
+	}
+	if errClose := writer.Close(); errClose != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errClose))
+	}
+	return nil
+}
+
+// Read record of the index entry from its gzip member in the bundle
+func readArchiveRecord(bundle io.ReaderAt, entry proofArchiveEntry) (models.CommitmentArchiveRecord, error) {
+	reader, errGzip := gzip.NewReader(io.NewSectionReader(bundle, entry.offset, entry.length))
+	if errGzip != nil {
+		return models.CommitmentArchiveRecord{}, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_READ, errGzip))
+	}
+	defer reader.Close()
+	records, errRead := models.ReadCommitmentArchive(reader)
+	if errRead != nil {
+		return models.CommitmentArchiveRecord{}, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_READ, errRead))
+	}
+	if len(records) != 1 || records[0].MerkleRoot != entry.merkleRoot {
+		return models.CommitmentArchiveRecord{}, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_ARCHIVE_INDEX,
+			entry.merkleRoot.String()))
+	}
+	return records[0], nil
+}
+
+// Write data to the archive file at the offset provided, creating the file
+// if needed, and truncate the file to the end of the data written
+func appendArchiveFile(path string, offset int64, data []byte) error {
+	file, errOpen := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if errOpen != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errOpen))
+	}
+	defer file.Close()
+	if errTruncate := file.Truncate(offset); errTruncate != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errTruncate))
+	}
+	if _, errWrite := file.WriteAt(data, offset); errWrite != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errWrite))
+	}
+	if errSync := file.Sync(); errSync != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errSync))
+	}
+	return file.Close()
+}
+
+// Replace archive file with the data provided
+// The data is written to a temporary file and renamed over the file
+func replaceArchiveFile(path string, data []byte) error {
+	tmp, errTmp := ioutil.TempFile(filepath.Dir(path), PROOF_ARCHIVE_PREFIX)
+	if errTmp != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errTmp))
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, errWrite := tmp.Write(data); errWrite != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errWrite))
+	}
+	if errSync := tmp.Sync(); errSync != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errSync))
+	}
+	if errClose := tmp.Close(); errClose != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errClose))
+	}
+	if errRename := os.Rename(tmp.Name(), path); errRename != nil {
+		return errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_ARCHIVE_WRITE, errRename))
+	}
+	return nil
+}
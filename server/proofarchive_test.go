@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test ProofArchive appending and finding records of per-day bundles
+func TestProofArchive(t *testing.T) {
+	dir, errDir := ioutil.TempDir("", "mainstay-proofarchive")
+	assert.Equal(t, nil, errDir)
+	defer os.RemoveAll(dir)
+	archive := NewProofArchive(filepath.Join(dir, "archive"))
+
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment0, _ := models.NewCommitment([]chainhash.Hash{*hashX})
+	commitment1, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY})
+	archivedAt := time.Unix(1542121293, 0)
+	record0 := models.NewCommitmentArchiveRecord(*commitment0, archivedAt)
+	record1 := models.NewCommitmentArchiveRecord(*commitment1, archivedAt)
+	commitment2, _ := models.NewCommitment([]chainhash.Hash{*hashY, *hashX, *hashY})
+	record2 := models.NewCommitmentArchiveRecord(*commitment2, archivedAt)
+	day := time.Date(2018, 11, 13, 15, 0, 0, 0, time.UTC)
+
+	// no bundle for the day
+	records, errRead := archive.Read(day)
+	assert.Equal(t, nil, errRead)
+	assert.Equal(t, 0, len(records))
+	record, errFind := archive.Find(day, record0.MerkleRoot)
+	assert.Equal(t, nil, errFind)
+	assert.Equal(t, (*models.CommitmentArchiveRecord)(nil), record)
+
+	// records of the day are kept in a single bundle without duplicates
+	assert.Equal(t, nil, archive.Append(day, []models.CommitmentArchiveRecord{record0}))
+	assert.Equal(t, nil, archive.Append(day.Add(time.Hour), []models.CommitmentArchiveRecord{record0, record1}))
+	records, errRead = archive.Read(day)
+	assert.Equal(t, nil, errRead)
+	assert.Equal(t, []models.CommitmentArchiveRecord{record0, record1}, records)
+	record, errFind = archive.Find(day, record1.MerkleRoot)
+	assert.Equal(t, nil, errFind)
+	assert.Equal(t, record1, *record)
+	rebuilt, errCommitment := record.Commitment()
+	assert.Equal(t, nil, errCommitment)
+	assert.Equal(t, commitment1.GetMerkleProofs(), rebuilt.GetMerkleProofs())
+
+	// bundles of other days are separate
+	record, errFind = archive.Find(day.Add(24*time.Hour), record1.MerkleRoot)
+	assert.Equal(t, nil, errFind)
+	assert.Equal(t, (*models.CommitmentArchiveRecord)(nil), record)
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "archive"))
+	assert.Equal(t, 2, len(files))
+	assert.Equal(t, "proofs-2018-11-13.idx", files[0].Name())
+	assert.Equal(t, "proofs-2018-11-13.jsonl.gz", files[1].Name())
+	bundlePath := filepath.Join(dir, "archive", files[1].Name())
+	indexPath := filepath.Join(dir, "archive", files[0].Name())
+
+	// bundle of gzip members is read as a single gzip file
+	bundle, _ := os.Open(bundlePath)
+	reader, errGzip := gzip.NewReader(bundle)
+	assert.Equal(t, nil, errGzip)
+	records, errRead = models.ReadCommitmentArchive(reader)
+	assert.Equal(t, nil, errRead)
+	assert.Equal(t, []models.CommitmentArchiveRecord{record0, record1}, records)
+	bundle.Close()
+
+	// records are found through the index of the bundle
+	record, errFind = NewProofArchive(filepath.Join(dir, "archive")).Find(day, record1.MerkleRoot)
+	assert.Equal(t, nil, errFind)
+	assert.Equal(t, record1, *record)
+
+	// records and index entries of an interrupted append are discarded
+	bundleFile, _ := os.OpenFile(bundlePath, os.O_APPEND|os.O_WRONLY, 0644)
+	bundleFile.Write([]byte("proofs"))
+	bundleFile.Close()
+	indexFile, _ := os.OpenFile(indexPath, os.O_APPEND|os.O_WRONLY, 0644)
+	indexFile.Write([]byte(record2.MerkleRoot.String()))
+	indexFile.Close()
+	archive = NewProofArchive(filepath.Join(dir, "archive"))
+	records, errRead = archive.Read(day)
+	assert.Equal(t, nil, errRead)
+	assert.Equal(t, []models.CommitmentArchiveRecord{record0, record1}, records)
+	assert.Equal(t, nil, archive.Append(day, []models.CommitmentArchiveRecord{record2}))
+	records, errRead = NewProofArchive(filepath.Join(dir, "archive")).Read(day)
+	assert.Equal(t, nil, errRead)
+	assert.Equal(t, []models.CommitmentArchiveRecord{record0, record1, record2}, records)
+
+	// rounds are decoded once into the merkle tree of their slots
+	tree, errRound := archive.FindRound(day, record1.MerkleRoot)
+	assert.Equal(t, nil, errRound)
+	assert.Equal(t, record1.MerkleRoot, tree.Root())
+	proof, _ := tree.MerkleProof(1)
+	assert.Equal(t, commitment1.GetMerkleProofs()[1], proof)
+	cached, _ := archive.FindRound(day, record1.MerkleRoot)
+	assert.Equal(t, true, tree == cached)
+	tree, errRound = archive.FindRound(day, testDbHash("cccccc", 0))
+	assert.Equal(t, nil, errRound)
+	assert.Equal(t, (*models.IncrementalMerkleTree)(nil), tree)
+
+	// bundles without an index are indexed on first access
+	legacyDay := day.Add(24 * time.Hour)
+	var legacyBundle bytes.Buffer
+	writer := gzip.NewWriter(&legacyBundle)
+	models.WriteCommitmentArchiveRecord(writer, record1)
+	models.WriteCommitmentArchiveRecord(writer, record2)
+	writer.Close()
+	ioutil.WriteFile(filepath.Join(dir, "archive", "proofs-2018-11-14.jsonl.gz"), legacyBundle.Bytes(), 0644)
+	record, errFind = archive.Find(legacyDay, record2.MerkleRoot)
+	assert.Equal(t, nil, errFind)
+	assert.Equal(t, record2, *record)
+	_, errIndex := os.Stat(filepath.Join(dir, "archive", "proofs-2018-11-14.idx"))
+	assert.Equal(t, nil, errIndex)
+	assert.Equal(t, nil, archive.Append(legacyDay, []models.CommitmentArchiveRecord{record0}))
+	records, errRead = NewProofArchive(filepath.Join(dir, "archive")).Read(legacyDay)
+	assert.Equal(t, nil, errRead)
+	assert.Equal(t, []models.CommitmentArchiveRecord{record1, record2, record0}, records)
+
+	// corrupt bundle and index
+	ioutil.WriteFile(bundlePath, []byte("proofs"), 0644)
+	_, errRead = NewProofArchive(filepath.Join(dir, "archive")).Read(day)
+	assert.NotEqual(t, nil, errRead)
+	_, errFind = NewProofArchive(filepath.Join(dir, "archive")).Find(day, record0.MerkleRoot)
+	assert.NotEqual(t, nil, errFind)
+	ioutil.WriteFile(indexPath, []byte("proofs\n"), 0644)
+	assert.NotEqual(t, nil, NewProofArchive(filepath.Join(dir, "archive")).Append(day,
+		[]models.CommitmentArchiveRecord{record0}))
+}
+
+// Test ProofArchive high-water mark of archived rounds
+func TestProofArchiveArchivedUntil(t *testing.T) {
+	dir, errDir := ioutil.TempDir("", "mainstay-proofarchive")
+	assert.Equal(t, nil, errDir)
+	defer os.RemoveAll(dir)
+	archive := NewProofArchive(filepath.Join(dir, "archive"))
+
+	until, errUntil := archive.ArchivedUntil()
+	assert.Equal(t, nil, errUntil)
+	assert.Equal(t, true, until.IsZero())
+
+	assert.Equal(t, nil, archive.SetArchivedUntil(time.Unix(1542121293, 0)))
+	until, errUntil = NewProofArchive(filepath.Join(dir, "archive")).ArchivedUntil()
+	assert.Equal(t, nil, errUntil)
+	assert.Equal(t, time.Unix(1542121293, 0), until)
+
+	ioutil.WriteFile(filepath.Join(dir, "archive", PROOF_ARCHIVE_UNTIL), []byte("proofs"), 0644)
+	_, errUntil = archive.ArchivedUntil()
+	assert.NotEqual(t, nil, errUntil)
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// error consts
+const (
+	ERROR_PROOF_ARCHIVE_NOT_SET = "Proof archive not set"
+)
+
+// interval between archival runs of the retention service
+const RETENTION_INTERVAL = 6 * time.Hour
+
+// Return UTC day of the proof archive bundle of a confirmed attestation
+func archiveDay(info models.AttestationInfo) time.Time {
+	return time.Unix(info.Time, 0).UTC().Truncate(24 * time.Hour)
+}
+
+// Return merkle tree of a confirmed attestation round from the proof archive
+// Returns nil if there is no proof archive or the round is not archived
+func (s *Server) getArchivedRound(attestation models.Attestation, merkleRoot chainhash.Hash) (*models.IncrementalMerkleTree, error) {
+	if s.archive == nil || !attestation.Confirmed {
+		return nil, nil
+	}
+	info := attestation.Info
+	if info.Txid == "" {
+		var errInfo error
+		if info, errInfo = s.dbInterface.GetAttestationInfo(s.ctx, attestation.Txid); errInfo != nil {
+			return nil, errInfo
+		}
+	}
+	return s.archive.FindRound(archiveDay(info), merkleRoot)
+}
+
+// Return commitment of a confirmed attestation round from the proof archive
+// Returns nil if there is no proof archive or the round is not archived
+func (s *Server) getArchivedCommitment(attestation models.Attestation, merkleRoot chainhash.Hash) (*models.Commitment, error) {
+	tree, errRound := s.getArchivedRound(attestation, merkleRoot)
+	if errRound != nil || tree == nil {
+		return nil, errRound
+	}
+	return tree.Commitment(), nil
+}
+
+// Return merkle proof of a client position from the proof archive
+// Only the proof of the position is built from the archived round
+// Returns nil if the round or the position is not archived
+func (s *Server) getArchivedProof(attestation models.Attestation, merkleRoot chainhash.Hash, position int32) (*models.CommitmentMerkleProof, error) {
+	tree, errRound := s.getArchivedRound(attestation, merkleRoot)
+	if errRound != nil || tree == nil {
+		return nil, errRound
+	}
+	proof, errProof := tree.MerkleProof(position)
+	if errProof != nil {
+		return nil, nil
+	}
+	return &proof, nil
+}
+
+// Archive confirmed attestation rounds before the time provided into the
+// proof archive bundle of the day each round was confirmed on, and prune
+// their merkle commitments and proofs from the db once archived
+// Merkle commitments and proofs are keyed by merkle root, so a round is
+// archived for all attestations with the same merkle root and is only
+// pruned once all of these are confirmed before the time provided
+// Rounds without merkle commitments in the db are already pruned and skipped
+// Each run resumes from the time provided to the last completed run, kept as
+// the archive high-water mark, and rounds confirmed before it are not
+// scanned again. Rounds kept for attestations of the same merkle root are
+// archived once these are scanned after the mark
+// Returns the merkle roots of the rounds archived and pruned
+func (s *Server) ArchiveProofs(before time.Time) ([]chainhash.Hash, error) {
+	if s.archive == nil {
+		return nil, errors.New(ERROR_PROOF_ARCHIVE_NOT_SET)
+	}
+	archivedUntil, errUntil := s.archive.ArchivedUntil()
+	if errUntil != nil {
+		return nil, errUntil
+	}
+
+	// attestations are paged latest first and are not removed by pruning
+	confirmed := true
+	filter := AttestationFilter{Confirmed: &confirmed, ToTime: before.Unix() - 1}
+	if !archivedUntil.IsZero() {
+		filter.FromTime = archivedUntil.Unix()
+	}
+	var pruned []chainhash.Hash
+	for skip := int64(0); ; skip += ATTESTATIONS_LIMIT_MAX {
+		attestations, errAttestations := s.dbInterface.GetAttestations(s.ctx, filter, skip, ATTESTATIONS_LIMIT_MAX)
+		if errAttestations != nil {
+			return pruned, errAttestations
+		}
+
+		// group rounds of the page by archive bundle day
+		days := make(map[time.Time][]models.CommitmentArchiveRecord)
+		archived := make(map[chainhash.Hash]bool)
+		for _, attestation := range attestations {
+			commitment, errCommitment := s.getDbAttestationCommitment(attestation.Txid)
+			merkleRoot := commitment.GetCommitmentHash()
+			if errCommitment != nil || (merkleRoot == chainhash.Hash{}) || archived[merkleRoot] {
+				continue
+			}
+			rootDays, errDays := s.merkleRootArchiveDays(merkleRoot, before)
+			if errDays != nil {
+				return pruned, errDays
+			}
+			if len(rootDays) == 0 {
+				continue
+			}
+			archived[merkleRoot] = true
+			record := models.NewCommitmentArchiveRecord(commitment, time.Now())
+			for _, day := range rootDays {
+				days[day] = append(days[day], record)
+			}
+		}
+		var sortedDays []time.Time
+		for day := range days {
+			sortedDays = append(sortedDays, day)
+		}
+		sort.Slice(sortedDays, func(i, j int) bool { return sortedDays[i].Before(sortedDays[j]) })
+
+		for _, day := range sortedDays {
+			if errAppend := s.archive.Append(day, days[day]); errAppend != nil {
+				return pruned, errAppend
+			}
+		}
+		for _, day := range sortedDays {
+			for _, record := range days[day] {
+				if !archived[record.MerkleRoot] {
+					continue
+				}
+				if errPrune := s.dbInterface.PruneMerkleRoot(s.ctx, record.MerkleRoot); errPrune != nil {
+					return pruned, errPrune
+				}
+				archived[record.MerkleRoot] = false
+				pruned = append(pruned, record.MerkleRoot)
+			}
+		}
+		if len(attestations) < ATTESTATIONS_LIMIT_MAX {
+			break
+		}
+	}
+	if before.After(archivedUntil) {
+		if errUntil := s.archive.SetArchivedUntil(before); errUntil != nil {
+			return pruned, errUntil
+		}
+	}
+	return pruned, nil
+}
+
+// Return archive bundle days of all attestations with the merkle root
+// Returns no days if any of these is unconfirmed or confirmed at or after
+// the time provided, in which case the round cannot be pruned yet
+func (s *Server) merkleRootArchiveDays(merkleRoot chainhash.Hash, before time.Time) ([]time.Time, error) {
+	var days []time.Time
+	seen := make(map[time.Time]bool)
+	filter := AttestationFilter{MerkleRoot: &merkleRoot}
+	for skip := int64(0); ; skip += ATTESTATIONS_LIMIT_MAX {
+		attestations, errAttestations := s.dbInterface.GetAttestations(s.ctx, filter, skip, ATTESTATIONS_LIMIT_MAX)
+		if errAttestations != nil {
+			return nil, errAttestations
+		}
+		for _, attestation := range attestations {
+			if !attestation.Confirmed || attestation.Info.Time >= before.Unix() {
+				return nil, nil
+			}
+			if day := archiveDay(attestation.Info); !seen[day] {
+				seen[day] = true
+				days = append(days, day)
+			}
+		}
+		if len(attestations) < ATTESTATIONS_LIMIT_MAX {
+			return days, nil
+		}
+	}
+}
+
+// RetentionService struct
+// Periodically archives and prunes attestation rounds older than the
+// retention period from the db into the server proof archive
+type RetentionService struct {
+	ctx       context.Context
+	wg        *sync.WaitGroup
+	server    *Server
+	retention time.Duration
+}
+
+// NewRetentionService returns a pointer to a RetentionService instance
+func NewRetentionService(ctx context.Context, wg *sync.WaitGroup, server *Server, retention time.Duration) *RetentionService {
+	return &RetentionService{ctx, wg, server, retention}
+}
+
+// Main Run method
+func (r *RetentionService) Run() {
+	defer r.wg.Done()
+
+	for {
+		pruned, errArchive := r.server.ArchiveProofs(time.Now().Add(-r.retention))
+		if errArchive != nil {
+			log.Printf("*RetentionService* %v\n", errArchive)
+		}
+		if len(pruned) > 0 {
+			log.Printf("*RetentionService* archived and pruned %d rounds\n", len(pruned))
+		}
+
+		timer := time.NewTimer(RETENTION_INTERVAL)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			log.Println("Shutting down retention service...")
+			return
+		case <-timer.C:
+		}
+	}
+}
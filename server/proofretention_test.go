@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Server archival and pruning of old rounds and retrieval of archived proofs
+func TestServerArchiveProofs(t *testing.T) {
+	dir, errDir := ioutil.TempDir("", "mainstay-proofarchive")
+	assert.Equal(t, nil, errDir)
+	defer os.RemoveAll(dir)
+	dbFake := NewDbFake()
+	server := NewServer(context.Background(), dbFake)
+
+	_, errArchive := server.ArchiveProofs(time.Now())
+	assert.Equal(t, errors.New(ERROR_PROOF_ARCHIVE_NOT_SET), errArchive)
+	server.SetProofArchive(NewProofArchive(dir))
+
+	// confirmed rounds on two days and an unconfirmed round
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	times := []time.Time{
+		time.Date(2018, 11, 13, 10, 0, 0, 0, time.UTC),
+		time.Date(2018, 11, 13, 23, 0, 0, 0, time.UTC),
+		time.Date(2018, 11, 14, 1, 0, 0, 0, time.UTC),
+		time.Date(2018, 11, 15, 1, 0, 0, 0, time.UTC)}
+	var attestations []models.Attestation
+	for i, attestedAt := range times {
+		commitment, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY, testDbHash("cccccc", i)})
+		attestation := models.NewAttestation(testDbHash("111111", i), commitment)
+		attestation.Confirmed = true
+		attestation.Info = models.AttestationInfo{Txid: attestation.Txid.String(),
+			Blockhash: testDbHash("222222", i).String(), Time: attestedAt.Unix()}
+		assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+		attestations = append(attestations, *attestation)
+	}
+	unconfirmedCommitment, _ := models.NewCommitment([]chainhash.Hash{*hashX})
+	unconfirmed := models.NewAttestation(testDbHash("111111", 9), unconfirmedCommitment)
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*unconfirmed))
+
+	var slotProofs []models.SlotProof
+	for _, attestation := range attestations[:3] {
+		slotProof, errProof := server.GetAttestationSlotProof(attestation.Txid, 2)
+		assert.Equal(t, nil, errProof)
+		slotProofs = append(slotProofs, slotProof)
+	}
+
+	// rounds confirmed before the cutoff are archived and pruned
+	pruned, errArchive := server.ArchiveProofs(times[3])
+	assert.Equal(t, nil, errArchive)
+	assert.Equal(t, []chainhash.Hash{attestations[1].CommitmentHash(), attestations[0].CommitmentHash(),
+		attestations[2].CommitmentHash()}, pruned)
+	for i, attestation := range attestations {
+		_, errProof := dbFake.GetMerkleProof(context.Background(), attestation.CommitmentHash(), 0)
+		assert.Equal(t, i < 3, errProof != nil)
+	}
+	_, errProof := dbFake.GetMerkleProof(context.Background(), unconfirmed.CommitmentHash(), 0)
+	assert.Equal(t, nil, errProof)
+
+	// archived proofs and commitments are retrieved through the same api
+	for i, attestation := range attestations[:3] {
+		slotProof, errProof := server.GetAttestationSlotProof(attestation.Txid, 2)
+		assert.Equal(t, nil, errProof)
+		assert.Equal(t, slotProofs[i], slotProof)
+		slotProof, errProof = server.GetMerkleRootSlotProof(attestation.CommitmentHash(), 2)
+		assert.Equal(t, nil, errProof)
+		assert.Equal(t, slotProofs[i], slotProof)
+		commitment, errCommitment := server.GetAttestationCommitment(attestation.Txid)
+		assert.Equal(t, nil, errCommitment)
+		assert.Equal(t, attestation.CommitmentHash(), commitment.GetCommitmentHash())
+	}
+	_, errProof = server.GetAttestationSlotProof(attestations[0].Txid, 3)
+	assert.Equal(t, errors.New(ERROR_MERKLE_PROOF_GET), errProof)
+
+	// runs resume from the cutoff of the last run
+	archivedUntil, _ := NewProofArchive(dir).ArchivedUntil()
+	assert.Equal(t, times[3].Unix(), archivedUntil.Unix())
+	pruned, errArchive = server.ArchiveProofs(times[2])
+	assert.Equal(t, nil, errArchive)
+	assert.Equal(t, 0, len(pruned))
+	archivedUntil, _ = NewProofArchive(dir).ArchivedUntil()
+	assert.Equal(t, times[3].Unix(), archivedUntil.Unix())
+
+	// archived rounds are not archived again
+	pruned, errArchive = server.ArchiveProofs(time.Now())
+	assert.Equal(t, nil, errArchive)
+	assert.Equal(t, []chainhash.Hash{attestations[3].CommitmentHash()}, pruned)
+	pruned, errArchive = server.ArchiveProofs(time.Now())
+	assert.Equal(t, nil, errArchive)
+	assert.Equal(t, 0, len(pruned))
+	records, _ := NewProofArchive(dir).Read(times[0])
+	assert.Equal(t, 2, len(records))
+
+	// archive write failure leaves rounds in the db
+	dbFake2 := NewDbFake()
+	server2 := NewServer(context.Background(), dbFake2)
+	file, _ := ioutil.TempFile(dir, "file")
+	server2.SetProofArchive(NewProofArchive(file.Name()))
+	assert.Equal(t, nil, server2.UpdateLatestAttestation(attestations[0]))
+	_, errArchive = server2.ArchiveProofs(time.Now())
+	assert.NotEqual(t, nil, errArchive)
+	_, errProof = dbFake2.GetMerkleProof(context.Background(), attestations[0].CommitmentHash(), 0)
+	assert.Equal(t, nil, errProof)
+}
+
+// Test rounds are only pruned once all attestations with the merkle root can be archived
+func TestServerArchiveProofsSharedMerkleRoot(t *testing.T) {
+	dir, errDir := ioutil.TempDir("", "mainstay-proofarchive")
+	assert.Equal(t, nil, errDir)
+	defer os.RemoveAll(dir)
+	dbFake := NewDbFake()
+	server := NewServer(context.Background(), dbFake)
+	server.SetProofArchive(NewProofArchive(dir))
+
+	// commitment A attested again after commitment B
+	commitmentA, _ := models.NewCommitment([]chainhash.Hash{testDbHash("aaaaaa", 0), testDbHash("aaaaaa", 1)})
+	commitmentB, _ := models.NewCommitment([]chainhash.Hash{testDbHash("bbbbbb", 0), testDbHash("bbbbbb", 1)})
+	times := []time.Time{
+		time.Date(2018, 11, 13, 10, 0, 0, 0, time.UTC),
+		time.Date(2018, 11, 13, 11, 0, 0, 0, time.UTC),
+		time.Date(2018, 11, 15, 10, 0, 0, 0, time.UTC),
+		time.Date(2018, 11, 16, 10, 0, 0, 0, time.UTC)}
+	var attestations []models.Attestation
+	for i, commitment := range []*models.Commitment{commitmentA, commitmentB, commitmentA, commitmentA} {
+		attestation := models.NewAttestation(testDbHash("111111", i), commitment)
+		attestation.Confirmed = i < 3
+		attestation.Info = models.AttestationInfo{Txid: attestation.Txid.String(),
+			Blockhash: testDbHash("222222", i).String(), Time: times[i].Unix()}
+		assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+		attestations = append(attestations, *attestation)
+	}
+
+	// round A is kept while attested again after the cutoff
+	pruned, errArchive := server.ArchiveProofs(times[2])
+	assert.Equal(t, nil, errArchive)
+	assert.Equal(t, []chainhash.Hash{commitmentB.GetCommitmentHash()}, pruned)
+	_, errProof := dbFake.GetMerkleProof(context.Background(), commitmentA.GetCommitmentHash(), 0)
+	assert.Equal(t, nil, errProof)
+
+	// round A is kept while attested again by an unconfirmed attestation
+	pruned, errArchive = server.ArchiveProofs(times[3])
+	assert.Equal(t, nil, errArchive)
+	assert.Equal(t, 0, len(pruned))
+
+	// round A is archived for the days of all its attestations once confirmed
+	attestations[3].Confirmed = true
+	assert.Equal(t, nil, server.UpdateLatestAttestation(attestations[3]))
+	pruned, errArchive = server.ArchiveProofs(time.Now())
+	assert.Equal(t, nil, errArchive)
+	assert.Equal(t, []chainhash.Hash{commitmentA.GetCommitmentHash()}, pruned)
+	_, errProof = dbFake.GetMerkleProof(context.Background(), commitmentA.GetCommitmentHash(), 0)
+	assert.NotEqual(t, nil, errProof)
+	for _, attestation := range attestations {
+		commitment, _ := attestation.Commitment()
+		slotProof, errProof := server.GetAttestationSlotProof(attestation.Txid, 1)
+		assert.Equal(t, nil, errProof)
+		assert.Equal(t, commitment.GetMerkleProofs()[1], slotProof.Proof)
+	}
+}
+
+// Test empty slots of attestations are retrieved from the db or the proof archive
+func TestServerGetAttestationEmptySlots(t *testing.T) {
+	dir, errDir := ioutil.TempDir("", "mainstay-proofarchive")
+	assert.Equal(t, nil, errDir)
+	defer os.RemoveAll(dir)
+	dbFake := NewDbFake()
+	server := NewServer(context.Background(), dbFake)
+	server.SetProofArchive(NewProofArchive(dir))
+
+	commitment, _ := models.NewCommitment([]chainhash.Hash{chainhash.Hash{}, testDbHash("aaaaaa", 1), chainhash.Hash{}})
+	fullCommitment, _ := models.NewCommitment([]chainhash.Hash{testDbHash("aaaaaa", 0)})
+	for i, c := range []*models.Commitment{commitment, fullCommitment} {
+		attestation := models.NewAttestation(testDbHash("111111", i), c)
+		attestation.Confirmed = true
+		attestation.Info = models.AttestationInfo{Txid: attestation.Txid.String(),
+			Blockhash: testDbHash("222222", i).String(), Time: time.Date(2018, 11, 13, 10, i, 0, 0, time.UTC).Unix()}
+		assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+	}
+
+	for _, pruned := range []bool{false, true} {
+		if pruned {
+			_, errArchive := server.ArchiveProofs(time.Now())
+			assert.Equal(t, nil, errArchive)
+		}
+		attestation, _ := server.GetAttestation(testDbHash("111111", 0))
+		emptySlots, errSlots := server.GetAttestationEmptySlots(attestation)
+		assert.Equal(t, nil, errSlots)
+		assert.Equal(t, []int32{0, 2}, emptySlots)
+		attestation, _ = server.GetAttestation(testDbHash("111111", 1))
+		emptySlots, errSlots = server.GetAttestationEmptySlots(attestation)
+		assert.Equal(t, nil, errSlots)
+		assert.Equal(t, []int32{}, emptySlots)
+	}
+}
@@ -1,9 +1,16 @@
 package server
 
 import (
+	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
 
+	"mainstay/crypto"
 	"mainstay/models"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -11,64 +18,89 @@ import (
 
 // error consts
 const (
-	ERROR_LATEST_COMMITMENT_MISSING = "Client commitment missing for position"
+	ERROR_CLIENT_DETAILS_MISSING = "Client details missing for position"
+	ERROR_CLIENT_AUTH_TOKEN      = "Invalid auth token for position"
+	ERROR_CLIENT_SIGNATURE       = "Invalid commitment signature for position"
+	ERROR_CLIENT_NOT_ACTIVE      = "Client slot not active for position"
+	ERROR_CLIENT_STATUS          = "Invalid client slot status"
+	ERROR_CLIENT_REVOKED         = "Client slot revoked for position"
+	ERROR_CLIENT_REVOKED_MISSING = "No revoked client for position"
+	ERROR_CLIENT_WEBHOOK_URL     = "Invalid client webhook url"
+	ERROR_CLIENT_POSITION        = "Invalid client position"
+	ERROR_ATTESTATION_MISSING    = "No attestation found"
+	ERROR_ATTESTATIONS_PAGE      = "Invalid page"
+	ERROR_ATTESTATIONS_LIMIT     = "Invalid page limit"
 )
 
+// history query paging limits
+const (
+	ATTESTATIONS_LIMIT_DEFAULT = 20
+	ATTESTATIONS_LIMIT_MAX     = 100
+)
+
+// timeout of saving a webhook delivery record
+const WEBHOOK_DELIVERY_SAVE_TIMEOUT = 10 * time.Second
+
 // Server structure
 // Stores information on the latest attestation and commitment
 // Methods to get latest state by attestation service
+// Commitments and proofs of rounds pruned from the db are
+// retrieved from the proof archive if set
+// The commitment tree of the latest client commitments is kept across
+// rounds so that only the slots that changed are rehashed
 type Server struct {
+	ctx         context.Context
 	dbInterface Db
+	events      *EventFeed
+	archive     *ProofArchive
+	tree        *models.IncrementalMerkleTree
+	treeMu      sync.Mutex
 }
 
 // NewServer returns a pointer to an Server instance
-func NewServer(dbInterface Db) *Server {
-	return &Server{dbInterface}
+// ctx is passed to all db operations of the server
+// Event ids start from the server start time so that cursors
+// of a previous server instance are never resumed from
+func NewServer(ctx context.Context, dbInterface Db) *Server {
+	return &Server{ctx: ctx, dbInterface: dbInterface, events: NewEventFeed(EVENT_FEED_SIZE, time.Now().UnixNano())}
 }
 
-// Handle saving Commitment underlying components to the database
-func (s *Server) updateAttestationCommitment(commitment models.Commitment) error {
-	// store merkle commitments
-	merkleCommitments := commitment.GetMerkleCommitments()
-	errSave := s.dbInterface.saveMerkleCommitments(merkleCommitments)
-	if errSave != nil {
-		return errSave
-	}
+// Set proof archive of rounds pruned from the db
+// Must be set before the server is used by any service
+func (s *Server) SetProofArchive(archive *ProofArchive) {
+	s.archive = archive
+}
 
-	// store merkle proofs
-	merkleProofs := commitment.GetMerkleProofs()
-	errSave = s.dbInterface.saveMerkleProofs(merkleProofs)
-	if errSave != nil {
-		return errSave
-	}
+// Publish attestation lifecycle event to the server event feed
+func (s *Server) PublishEvent(event models.AttestationEvent) models.AttestationEvent {
+	return s.events.Publish(event)
+}
 
-	return nil
+// Return the server event feed of attestation lifecycle events
+func (s *Server) Events() *EventFeed {
+	return s.events
 }
 
 // Update latest Attestation in the server
+// The attestation, its commitment components and info are saved atomically
 func (s *Server) UpdateLatestAttestation(attestation models.Attestation) error {
-
-	errSave := s.dbInterface.saveAttestation(attestation)
-	if errSave != nil {
-		return errSave
-	}
 	commitment, errCommitment := attestation.Commitment()
 	if errCommitment != nil {
 		return errCommitment
 	}
-	errSave = s.updateAttestationCommitment(*commitment)
-	if errSave != nil {
-		return errSave
-	}
+	return s.dbInterface.SaveAttestationUpdate(s.ctx, attestation, s.GetCommitmentMerkleProofs(*commitment)...)
+}
 
-	if attestation.Confirmed {
-		errSave = s.dbInterface.saveAttestationInfo(attestation.Info)
-		if errSave != nil {
-			return errSave
-		}
+// Return merkle proofs of all slots of a commitment
+// Proofs of the latest client commitment are built from the commitment
+// tree kept across rounds without rebuilding the tree of the commitment
+func (s *Server) GetCommitmentMerkleProofs(commitment models.Commitment) []models.CommitmentMerkleProof {
+	s.treeMu.Lock()
+	defer s.treeMu.Unlock()
+	if s.tree == nil {
+		return commitment.GetMerkleProofs()
 	}
-
-	return nil
+	return s.tree.CommitmentMerkleProofs(commitment)
 }
 
 // Return Commitment hash of latest Attestation stored in the server
@@ -80,7 +112,7 @@ func (s *Server) GetLatestAttestationCommitmentHash(confirmed ...bool) (chainhas
 	}
 
 	// get attestation merkle root from db
-	merkleRoot, rootErr := s.dbInterface.getLatestAttestationMerkleRoot(confirmedParam)
+	merkleRoot, rootErr := s.dbInterface.GetLatestAttestationMerkleRoot(s.ctx, confirmedParam)
 	if rootErr != nil {
 		return chainhash.Hash{}, rootErr
 	} else if merkleRoot == "" { // no attestations yet
@@ -94,38 +126,387 @@ func (s *Server) GetLatestAttestationCommitmentHash(confirmed ...bool) (chainhas
 }
 
 // Return latest commitment stored in the server
+// The commitment covers the full slot range up to the highest position with
+// client details or a commitment. Positions without a commitment or with
+// suspended or revoked client details are inactive slots and are included
+// as zero commitments so they do not block attestation
 func (s *Server) GetClientCommitment() (models.Commitment, error) {
 
 	// get latest commitments from db
-	latestCommitments, errLatest := s.dbInterface.getClientCommitments()
+	latestCommitments, errLatest := s.dbInterface.GetClientCommitments(s.ctx)
 	if errLatest != nil {
 		return models.Commitment{}, errLatest
+	} else if len(latestCommitments) == 0 {
+		return models.Commitment{}, errors.New(models.ERROR_COMMITMENT_LIST_EMPTY)
+	}
+	clientDetails, errDetails := s.dbInterface.GetClientDetails(s.ctx)
+	if errDetails != nil {
+		return models.Commitment{}, errDetails
 	}
 
-	var commitmentHashes []chainhash.Hash
-	// assume latest commitments ordered by position
-	for pos, c := range latestCommitments {
-		if int32(pos) == c.ClientPosition {
-			commitmentHashes = append(commitmentHashes, c.Commitment)
+	// find slot range from client details and commitment positions
+	// invalid negative positions are skipped
+	numSlots := int32(0)
+	for _, d := range clientDetails {
+		if d.ClientPosition < 0 {
+			log.Printf("%s %d\n", ERROR_CLIENT_POSITION, d.ClientPosition)
+		} else if d.ClientPosition >= numSlots {
+			numSlots = d.ClientPosition + 1
+		}
+	}
+	for _, c := range latestCommitments {
+		if c.ClientPosition < 0 {
+			log.Printf("%s %d\n", ERROR_CLIENT_POSITION, c.ClientPosition)
+		} else if c.ClientPosition >= numSlots {
+			numSlots = c.ClientPosition + 1
+		}
+	}
+
+	// zero commitments for inactive slots
+	commitmentHashes := make([]chainhash.Hash, numSlots)
+	for _, c := range latestCommitments {
+		if c.ClientPosition >= 0 {
+			commitmentHashes[c.ClientPosition] = c.Commitment
+		}
+	}
+	for _, d := range clientDetails {
+		if d.ClientPosition >= 0 && !d.IsActive() {
+			commitmentHashes[d.ClientPosition] = chainhash.Hash{}
+		}
+	}
+
+	// update commitment tree of the previous round with the slot commitments
+	s.treeMu.Lock()
+	defer s.treeMu.Unlock()
+	if s.tree == nil {
+		tree, errTree := models.NewIncrementalMerkleTree(commitmentHashes, models.COMMITMENT_TREE_VERSION)
+		if errTree != nil {
+			return models.Commitment{}, errTree
+		}
+		s.tree = tree
+	} else if _, errUpdate := s.tree.Update(commitmentHashes); errUpdate != nil {
+		return models.Commitment{}, errUpdate
+	}
+	return *s.tree.Commitment(), nil
+}
+
+// Verify and store a new client commitment for a client position
+// The auth token and signature of the commitment hash string are
+// verified against the client details for the position
+func (s *Server) UpdateClientCommitment(commitment models.ClientCommitment, authToken string, signature string) error {
+
+	// get client details for position
+	clientDetails, errDetails := s.dbInterface.GetClientDetails(s.ctx)
+	if errDetails != nil {
+		return errDetails
+	}
+	var details *models.ClientDetails
+	for i := range clientDetails {
+		if clientDetails[i].ClientPosition == commitment.ClientPosition {
+			details = &clientDetails[i]
+			break
+		}
+	}
+	if details == nil {
+		return errors.New(fmt.Sprintf("%s %d", ERROR_CLIENT_DETAILS_MISSING, commitment.ClientPosition))
+	}
+
+	// verify auth token and commitment signature
+	if subtle.ConstantTimeCompare([]byte(authToken), []byte(details.AuthToken)) != 1 {
+		return errors.New(fmt.Sprintf("%s %d", ERROR_CLIENT_AUTH_TOKEN, commitment.ClientPosition))
+	}
+	if !details.IsActive() {
+		return errors.New(fmt.Sprintf("%s %d: %s", ERROR_CLIENT_NOT_ACTIVE, commitment.ClientPosition, details.SlotStatus()))
+	}
+	errVerify := crypto.VerifyMessage(details.Pubkey, commitment.Commitment.String(), signature)
+	if errVerify != nil {
+		return errors.New(fmt.Sprintf("%s %d: %v", ERROR_CLIENT_SIGNATURE, commitment.ClientPosition, errVerify))
+	}
+
+	// append to slot history before replacing the latest commitment
+	errSave := s.dbInterface.SaveClientCommitmentHistory(s.ctx, models.ClientCommitmentHistory{
+		Commitment:     commitment.Commitment,
+		ClientPosition: commitment.ClientPosition,
+		SubmittedAt:    time.Now().UTC(),
+		Submitter:      details.Pubkey,
+		Signature:      signature})
+	if errSave != nil {
+		return errSave
+	}
+	return s.dbInterface.SaveClientCommitment(s.ctx, commitment)
+}
+
+// Return details of all client positions ordered by position
+func (s *Server) GetClientDetails() ([]models.ClientDetails, error) {
+	return s.dbInterface.GetClientDetails(s.ctx)
+}
+
+// Return lowest client position not held by an active or suspended client
+// Positions of revoked clients are free and are reused before new positions
+func (s *Server) NextClientPosition() (int32, error) {
+	position, _, errPosition := s.nextClientPosition()
+	return position, errPosition
+}
+
+// Return lowest free client position along with the details of the
+// revoked client holding it, or nil for a new position
+func (s *Server) nextClientPosition() (int32, *models.ClientDetails, error) {
+	clientDetails, errDetails := s.dbInterface.GetClientDetails(s.ctx)
+	if errDetails != nil {
+		return 0, nil, errDetails
+	}
+	held := make(map[int32]bool)
+	revoked := make(map[int32]models.ClientDetails)
+	for _, d := range clientDetails {
+		if d.SlotStatus() != models.CLIENT_STATUS_REVOKED {
+			held[d.ClientPosition] = true
 		} else {
-			return models.Commitment{}, errors.New(fmt.Sprintf("%s %d", ERROR_LATEST_COMMITMENT_MISSING, pos))
+			revoked[d.ClientPosition] = d
 		}
 	}
-	// construct Commitment from MerkleCommitment commitments
-	commitment, errCommitment := models.NewCommitment(commitmentHashes)
-	if errCommitment != nil {
-		return models.Commitment{}, errCommitment
+	var position int32
+	for held[position] {
+		position++
+	}
+	if details, ok := revoked[position]; ok {
+		return position, &details, nil
 	}
+	return position, nil, nil
+}
 
-	// db interface
-	return *commitment, nil
+// Sign up new client with auth token and pubkey to the lowest free position
+// A reused position has its latest commitment reset to a zero commitment
+// and the details of the revoked client are kept as revoked client details,
+// without its auth token and webhook secret, along with its commitment
+// history and proofs. The history sequence of the new client separates
+// its commitment history from the history of the revoked client
+func (s *Server) SignupClient(authToken string, pubkey string) (models.ClientDetails, error) {
+	position, revoked, errPosition := s.nextClientPosition()
+	if errPosition != nil {
+		return models.ClientDetails{}, errPosition
+	}
+	details := models.ClientDetails{
+		ClientPosition: position,
+		AuthToken:      authToken,
+		Pubkey:         pubkey,
+		Status:         models.CLIENT_STATUS_ACTIVE,
+		StartedAt:      time.Now().Unix()}
+
+	if revoked != nil {
+		revoked.AuthToken = ""
+		revoked.WebhookSecret = ""
+		if errSave := s.dbInterface.SaveRevokedClientDetails(s.ctx, *revoked); errSave != nil {
+			return models.ClientDetails{}, errSave
+		}
+
+		// history of the client follows the history of the revoked client
+		latestHistory, errHistory := s.dbInterface.GetClientCommitmentHistory(s.ctx, position, 0, 0, 0, 1)
+		if errHistory != nil {
+			return models.ClientDetails{}, errHistory
+		} else if len(latestHistory) > 0 {
+			details.HistorySequence = latestHistory[0].Sequence
+		}
+	}
+	latestCommitments, errLatest := s.dbInterface.GetClientCommitments(s.ctx)
+	if errLatest != nil {
+		return models.ClientDetails{}, errLatest
+	}
+	for _, c := range latestCommitments {
+		if c.ClientPosition == position {
+			errSave := s.dbInterface.SaveClientCommitment(s.ctx, models.ClientCommitment{ClientPosition: position})
+			if errSave != nil {
+				return models.ClientDetails{}, errSave
+			}
+			break
+		}
+	}
+	if errSave := s.dbInterface.SaveClientDetails(s.ctx, details); errSave != nil {
+		return models.ClientDetails{}, errSave
+	}
+	return details, nil
+}
+
+// Return details of the revoked clients that held a reused position, oldest first
+func (s *Server) GetRevokedClientDetails(position int32) ([]models.ClientDetails, error) {
+	return s.dbInterface.GetRevokedClientDetails(s.ctx, position)
+}
+
+// Set slot status of the client at position
+// Active and suspended slots can switch between each other or be revoked.
+// Revocation is final and records the slot end time
+func (s *Server) SetClientStatus(position int32, status string) (models.ClientDetails, error) {
+	if status != models.CLIENT_STATUS_ACTIVE && status != models.CLIENT_STATUS_SUSPENDED &&
+		status != models.CLIENT_STATUS_REVOKED {
+		return models.ClientDetails{}, errors.New(fmt.Sprintf("%s %s", ERROR_CLIENT_STATUS, status))
+	}
+	clientDetails, errDetails := s.dbInterface.GetClientDetails(s.ctx)
+	if errDetails != nil {
+		return models.ClientDetails{}, errDetails
+	}
+	for _, details := range clientDetails {
+		if details.ClientPosition != position {
+			continue
+		}
+		if details.SlotStatus() == models.CLIENT_STATUS_REVOKED {
+			return models.ClientDetails{}, errors.New(fmt.Sprintf("%s %d", ERROR_CLIENT_REVOKED, position))
+		}
+		details.Status = status
+		if status == models.CLIENT_STATUS_REVOKED {
+			details.EndedAt = time.Now().Unix()
+		}
+		if errSave := s.dbInterface.SaveClientDetails(s.ctx, details); errSave != nil {
+			return models.ClientDetails{}, errSave
+		}
+		return details, nil
+	}
+	return models.ClientDetails{}, errors.New(fmt.Sprintf("%s %d", ERROR_CLIENT_DETAILS_MISSING, position))
+}
+
+// Set webhook url and secret of the client at position
+// Webhook urls are absolute http or https urls and an empty url
+// removes the webhook of the client along with its secret
+func (s *Server) SetClientWebhook(position int32, webhookUrl string, secret string) (models.ClientDetails, error) {
+	if webhookUrl != "" {
+		parsedUrl, errUrl := url.Parse(webhookUrl)
+		if errUrl != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+			return models.ClientDetails{}, errors.New(fmt.Sprintf("%s %s", ERROR_CLIENT_WEBHOOK_URL, webhookUrl))
+		}
+	} else {
+		secret = ""
+	}
+	clientDetails, errDetails := s.dbInterface.GetClientDetails(s.ctx)
+	if errDetails != nil {
+		return models.ClientDetails{}, errDetails
+	}
+	for _, details := range clientDetails {
+		if details.ClientPosition != position {
+			continue
+		}
+		details.WebhookUrl = webhookUrl
+		details.WebhookSecret = secret
+		if errSave := s.dbInterface.SaveClientDetails(s.ctx, details); errSave != nil {
+			return models.ClientDetails{}, errSave
+		}
+		return details, nil
+	}
+	return models.ClientDetails{}, errors.New(fmt.Sprintf("%s %d", ERROR_CLIENT_DETAILS_MISSING, position))
+}
+
+// Save client webhook delivery record
+// Records are saved with a context that is not cancelled on shutdown, so
+// that deliveries interrupted by shutdown are still recorded for redelivery
+func (s *Server) SaveWebhookDelivery(delivery models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), WEBHOOK_DELIVERY_SAVE_TIMEOUT)
+	defer cancel()
+	return s.dbInterface.SaveWebhookDelivery(ctx, delivery)
+}
+
+// Return client webhook deliveries that have or have not been delivered
+func (s *Server) GetWebhookDeliveries(delivered bool) ([]models.WebhookDelivery, error) {
+	return s.dbInterface.GetWebhookDeliveries(s.ctx, delivered)
+}
+
+// Return pending client webhook deliveries, which are neither delivered nor failed
+func (s *Server) GetPendingWebhookDeliveries() ([]models.WebhookDelivery, error) {
+	deliveries, errDeliveries := s.dbInterface.GetWebhookDeliveries(s.ctx, false)
+	if errDeliveries != nil {
+		return []models.WebhookDelivery{}, errDeliveries
+	}
+	pending := []models.WebhookDelivery{}
+	for _, delivery := range deliveries {
+		if delivery.Pending() {
+			pending = append(pending, delivery)
+		}
+	}
+	return pending, nil
+}
+
+// Return page of the commitment history of a client position, latest first
+// Pages start from 1 and hold up to limit history entries each
+// Only entries saved after the history sequence of the current client of a
+// reused position are returned, so history of revoked clients is not included
+func (s *Server) GetClientCommitmentHistory(position int32, page int64, limit int64) ([]models.ClientCommitmentHistory, error) {
+	skip, errPage := pageSkip(page, limit)
+	if errPage != nil {
+		return []models.ClientCommitmentHistory{}, errPage
+	}
+	clientDetails, errDetails := s.dbInterface.GetClientDetails(s.ctx)
+	if errDetails != nil {
+		return []models.ClientCommitmentHistory{}, errDetails
+	}
+	var after int64
+	for _, details := range clientDetails {
+		if details.ClientPosition == position {
+			after = details.HistorySequence
+		}
+	}
+	return s.dbInterface.GetClientCommitmentHistory(s.ctx, position, after, 0, skip, limit)
+}
+
+// Return page of the commitment history of the revoked client of a reused
+// position that started at the unix time provided, latest first
+// Only entries up to the history sequence of the next client of the
+// position are returned, so history of other clients is not included
+func (s *Server) GetRevokedClientCommitmentHistory(position int32, startedAt int64, page int64, limit int64) ([]models.ClientCommitmentHistory, error) {
+	skip, errPage := pageSkip(page, limit)
+	if errPage != nil {
+		return []models.ClientCommitmentHistory{}, errPage
+	}
+	revoked, errRevoked := s.dbInterface.GetRevokedClientDetails(s.ctx, position)
+	if errRevoked != nil {
+		return []models.ClientCommitmentHistory{}, errRevoked
+	}
+	clientDetails, errDetails := s.dbInterface.GetClientDetails(s.ctx)
+	if errDetails != nil {
+		return []models.ClientCommitmentHistory{}, errDetails
+	}
+
+	// clients of the position in the order they held it
+	clients := revoked
+	for _, details := range clientDetails {
+		if details.ClientPosition == position {
+			clients = append(clients, details)
+		}
+	}
+	for i := 0; i+1 < len(clients); i++ {
+		if clients[i].StartedAt != startedAt {
+			continue
+		}
+		after, until := clients[i].HistorySequence, clients[i+1].HistorySequence
+		if until <= after {
+			return []models.ClientCommitmentHistory{}, nil
+		}
+		return s.dbInterface.GetClientCommitmentHistory(s.ctx, position, after, until, skip, limit)
+	}
+	return []models.ClientCommitmentHistory{}, errors.New(fmt.Sprintf("%s %d", ERROR_CLIENT_REVOKED_MISSING, position))
 }
 
 // Return Commitment for a particular Attestation transaction id
+// The commitment is rebuilt with the tree layout version of the attestation
+// Commitments of rounds pruned from the db are rebuilt from the proof archive
 func (s *Server) GetAttestationCommitment(attestationTxid chainhash.Hash) (models.Commitment, error) {
+	commitment, errCommitment := s.getDbAttestationCommitment(attestationTxid)
+	if (errCommitment != nil || commitment.GetCommitmentHash() == chainhash.Hash{}) && s.archive != nil {
+		attestation, errAttestation := s.GetAttestation(attestationTxid)
+		merkleRoot, errRoot := s.dbInterface.GetAttestationMerkleRoot(s.ctx, attestationTxid)
+		merkleRootHash, errHash := chainhash.NewHashFromStr(merkleRoot)
+		if errAttestation == nil && errRoot == nil && errHash == nil {
+			archived, errArchive := s.getArchivedCommitment(attestation, *merkleRootHash)
+			if errArchive != nil {
+				return models.Commitment{}, errArchive
+			} else if archived != nil {
+				return *archived, nil
+			}
+		}
+	}
+	return commitment, errCommitment
+}
+
+// Return Commitment for a particular Attestation transaction id from the db merkle commitments
+func (s *Server) getDbAttestationCommitment(attestationTxid chainhash.Hash) (models.Commitment, error) {
 
 	// get merkle commitments from db
-	merkleCommitments, merkleCommitmentsErr := s.dbInterface.getAttestationMerkleCommitments(attestationTxid)
+	merkleCommitments, merkleCommitmentsErr := s.dbInterface.GetAttestationMerkleCommitments(s.ctx, attestationTxid)
 
 	if merkleCommitmentsErr != nil {
 		return models.Commitment{}, merkleCommitmentsErr
@@ -139,10 +520,153 @@ func (s *Server) GetAttestationCommitment(attestationTxid chainhash.Hash) (model
 		commitmentHashes = append(commitmentHashes, c.Commitment)
 	}
 
-	commitment, errCommitment := models.NewCommitment(commitmentHashes)
+	// use the tree version recorded with the attestation
+	// attestations stored without a tree version used the legacy layout
+	treeVersion := models.COMMITMENT_TREE_VERSION_LEGACY
+	attestation, errAttestation := s.dbInterface.GetAttestation(s.ctx, attestationTxid)
+	if errAttestation == nil && attestation.TreeVersion() != 0 {
+		treeVersion = attestation.TreeVersion()
+	}
+
+	commitment, errCommitment := models.NewCommitmentVersion(commitmentHashes, treeVersion)
 	if errCommitment != nil {
 		return models.Commitment{}, nil
 	}
 
 	return *commitment, nil
 }
+
+// Return positions of the slots attested with a zero commitment by an attestation
+// Empty slots of rounds pruned from the db are taken from the proof archive
+func (s *Server) GetAttestationEmptySlots(attestation models.Attestation) ([]int32, error) {
+	merkleRoot := attestation.CommitmentHash()
+	emptySlots, errSlots := s.dbInterface.GetEmptySlots(s.ctx, merkleRoot)
+	if errSlots != nil || len(emptySlots) > 0 || s.archive == nil {
+		return emptySlots, errSlots
+	}
+
+	// merkle proofs are only missing for rounds pruned from the db
+	if _, errProof := s.dbInterface.GetMerkleProof(s.ctx, merkleRoot, 0); errProof == nil {
+		return emptySlots, nil
+	}
+	archived, errArchive := s.getArchivedCommitment(attestation, merkleRoot)
+	if errArchive != nil {
+		return []int32{}, errArchive
+	} else if archived == nil {
+		return emptySlots, nil
+	}
+	return archived.GetEmptySlots(), nil
+}
+
+// Return Attestation for a particular Attestation transaction id
+// Info is only set for confirmed attestations
+func (s *Server) GetAttestation(txid chainhash.Hash) (models.Attestation, error) {
+	attestation, errAttestation := s.dbInterface.GetAttestation(s.ctx, txid)
+	if errAttestation != nil {
+		return models.Attestation{}, errAttestation
+	}
+	if attestation.Confirmed {
+		info, errInfo := s.dbInterface.GetAttestationInfo(s.ctx, txid)
+		if errInfo != nil {
+			return models.Attestation{}, errInfo
+		}
+		attestation.Info = info
+	}
+	return attestation, nil
+}
+
+// Return page of the attestation history matching the filter, latest first
+// Pages start from 1 and hold up to limit attestations each
+// Info is only set for confirmed attestations
+func (s *Server) GetAttestations(filter AttestationFilter, page int64, limit int64) ([]models.Attestation, error) {
+	skip, errPage := pageSkip(page, limit)
+	if errPage != nil {
+		return []models.Attestation{}, errPage
+	}
+	return s.dbInterface.GetAttestations(s.ctx, filter, skip, limit)
+}
+
+// Return number of results to skip for a page of history query results
+// Pages start from 1 and hold between 1 and ATTESTATIONS_LIMIT_MAX results
+func pageSkip(page int64, limit int64) (int64, error) {
+	if page < 1 {
+		return 0, errors.New(fmt.Sprintf("%s %d", ERROR_ATTESTATIONS_PAGE, page))
+	}
+	if limit < 1 || limit > ATTESTATIONS_LIMIT_MAX {
+		return 0, errors.New(fmt.Sprintf("%s %d", ERROR_ATTESTATIONS_LIMIT, limit))
+	}
+	return (page - 1) * limit, nil
+}
+
+// Return slot proof for a client position in the attestation with the given txid
+func (s *Server) GetAttestationSlotProof(txid chainhash.Hash, position int32) (models.SlotProof, error) {
+	attestation, errAttestation := s.dbInterface.GetAttestation(s.ctx, txid)
+	if errAttestation != nil {
+		return models.SlotProof{}, errAttestation
+	}
+	merkleRoot, rootErr := s.dbInterface.GetAttestationMerkleRoot(s.ctx, txid)
+	if rootErr != nil {
+		return models.SlotProof{}, rootErr
+	} else if merkleRoot == "" {
+		return models.SlotProof{}, errors.New(ERROR_ATTESTATION_MISSING)
+	}
+	merkleRootHash, errHash := chainhash.NewHashFromStr(merkleRoot)
+	if errHash != nil {
+		return models.SlotProof{}, errHash
+	}
+	return s.getSlotProof(attestation, *merkleRootHash, position)
+}
+
+// Return slot proof for a client position in the attestation of the given merkle root
+func (s *Server) GetMerkleRootSlotProof(merkleRoot chainhash.Hash, position int32) (models.SlotProof, error) {
+	attestation, errAttestation := s.dbInterface.GetMerkleRootAttestation(s.ctx, merkleRoot)
+	if errAttestation != nil {
+		return models.SlotProof{}, errAttestation
+	}
+	return s.getSlotProof(attestation, merkleRoot, position)
+}
+
+// Return slot proof for a client position in the latest confirmed attestation
+func (s *Server) GetLatestSlotProof(position int32) (models.SlotProof, error) {
+	merkleRoot, rootErr := s.GetLatestAttestationCommitmentHash()
+	if rootErr != nil {
+		return models.SlotProof{}, rootErr
+	} else if (merkleRoot == chainhash.Hash{}) {
+		return models.SlotProof{}, errors.New(ERROR_ATTESTATION_MISSING)
+	}
+	return s.GetMerkleRootSlotProof(merkleRoot, position)
+}
+
+// Build slot proof from stored merkle proof and attestation details
+// Proofs of rounds pruned from the db are rebuilt from the proof archive
+func (s *Server) getSlotProof(attestation models.Attestation, merkleRoot chainhash.Hash, position int32) (models.SlotProof, error) {
+	proof, errProof := s.dbInterface.GetMerkleProof(s.ctx, merkleRoot, position)
+	if errProof != nil {
+		archived, errArchive := s.getArchivedProof(attestation, merkleRoot, position)
+		if errArchive != nil {
+			return models.SlotProof{}, errArchive
+		} else if archived == nil {
+			return models.SlotProof{}, errProof
+		}
+		proof = *archived
+	}
+
+	slotProof := models.SlotProof{Txid: attestation.Txid, Confirmed: attestation.Confirmed, Proof: proof}
+	if attestation.Confirmed {
+		info, errInfo := s.dbInterface.GetAttestationInfo(s.ctx, attestation.Txid)
+		if errInfo != nil {
+			return models.SlotProof{}, errInfo
+		}
+		blockhash, errHash := chainhash.NewHashFromStr(info.Blockhash)
+		if errHash != nil {
+			return models.SlotProof{}, errHash
+		}
+		slotProof.Blockhash = *blockhash
+		spvProof, errSpv := info.SpvProof()
+		if errSpv != nil {
+			return models.SlotProof{}, errSpv
+		}
+		slotProof.Spv = spvProof
+	}
+	return slotProof, nil
+}
@@ -1,13 +1,17 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
 
+	"mainstay/crypto"
 	"mainstay/models"
 
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,7 +19,7 @@ import (
 func TestServerUpdateLatestAttestation_NoClientCommitments(t *testing.T) {
 	// TEST INIT
 	dbFake := NewDbFake()
-	server := NewServer(dbFake)
+	server := NewServer(context.Background(), dbFake)
 
 	respClientCommitment := (*models.Commitment)(nil)
 	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
@@ -31,7 +35,7 @@ func TestServerUpdateLatestAttestation_NoClientCommitments(t *testing.T) {
 func TestServerUpdateLatestAttestation_1ClientCommitments(t *testing.T) {
 	// TEST INIT
 	dbFake := NewDbFake()
-	server := NewServer(dbFake)
+	server := NewServer(context.Background(), dbFake)
 
 	// set db latest commitment
 	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
@@ -99,19 +103,19 @@ func TestServerUpdateLatestAttestation_1ClientCommitments(t *testing.T) {
 	assert.Equal(t, int32(0), dbFake.merkleProofs[0].ClientPosition)
 	assert.Equal(t, *hash0, dbFake.merkleProofs[0].Commitment)
 	assert.Equal(t, true, dbFake.merkleProofs[0].Ops[0].Append)
-	assert.Equal(t, *hash0, dbFake.merkleProofs[0].Ops[0].Commitment)
+	assert.Equal(t, chainhash.Hash{}, dbFake.merkleProofs[0].Ops[0].Commitment)
 }
 
 // Test Server UpdateLatestAttestation with 3 latest commitment
 func TestServerUpdateLatestAttestation_3ClientCommitments(t *testing.T) {
 	// TEST INIT
 	dbFake := NewDbFake()
-	server := NewServer(dbFake)
+	server := NewServer(context.Background(), dbFake)
 
 	// set db latest commitment
 	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 	hash1, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
-	hash01, _ := chainhash.NewHashFromStr("f6dab9f1bfb9ba3f33178e040ff78ff79bc875bfb383ce6db28f46b8226ca073")
+	hash01, _ := chainhash.NewHashFromStr("13c101f32f4558c3f946f7302340a12d0999415ecd1e264c18062f76fae243ef")
 	hash2, _ := chainhash.NewHashFromStr("caaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 	hash22, _ := chainhash.NewHashFromStr("e0ae56a5a7eec5de827346ea45dd3d834c006d12e333d0d949aa974dda4928ed")
 	latestCommitments := []models.ClientCommitment{
@@ -205,7 +209,7 @@ func TestServerUpdateLatestAttestation_3ClientCommitments(t *testing.T) {
 	assert.Equal(t, int32(2), dbFake.merkleProofs[2].ClientPosition)
 	assert.Equal(t, *hash2, dbFake.merkleProofs[2].Commitment)
 	assert.Equal(t, true, dbFake.merkleProofs[2].Ops[0].Append)
-	assert.Equal(t, *hash2, dbFake.merkleProofs[2].Ops[0].Commitment)
+	assert.Equal(t, chainhash.Hash{}, dbFake.merkleProofs[2].Ops[0].Commitment)
 	assert.Equal(t, false, dbFake.merkleProofs[2].Ops[1].Append)
 	assert.Equal(t, *hash22, dbFake.merkleProofs[2].Ops[1].Commitment)
 }
@@ -214,7 +218,7 @@ func TestServerUpdateLatestAttestation_3ClientCommitments(t *testing.T) {
 func TestServerGetClientCommitment(t *testing.T) {
 	// TEST INIT
 	dbFake := NewDbFake()
-	server := NewServer(dbFake)
+	server := NewServer(context.Background(), dbFake)
 
 	// check empty latest commitment first
 	respClientCommitment, err := server.GetClientCommitment()
@@ -225,31 +229,49 @@ func TestServerGetClientCommitment(t *testing.T) {
 	hash1, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 	hash2, _ := chainhash.NewHashFromStr("caaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 
-	// update server with incorrect latest commitment and test server
+	// missing positions are included as zero commitments
 	latestCommitments := []models.ClientCommitment{
 		models.ClientCommitment{*hash0, 0}, models.ClientCommitment{*hash2, 2}}
 	dbFake.SetClientCommitments(latestCommitments)
 
 	respClientCommitment, err = server.GetClientCommitment()
-	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_LATEST_COMMITMENT_MISSING, 1)), err)
-	assert.Equal(t, chainhash.Hash{}, respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, nil, err)
+	expectedCommitment, _ := models.NewCommitment([]chainhash.Hash{*hash0, chainhash.Hash{}, *hash2})
+	assert.Equal(t, expectedCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, []int32{1}, respClientCommitment.GetEmptySlots())
 
-	// update server with incorrect latest commitment and test server
-	latestCommitments = []models.ClientCommitment{
-		models.ClientCommitment{*hash0, 1}, models.ClientCommitment{*hash2, 2}}
+	latestCommitments = []models.ClientCommitment{models.ClientCommitment{*hash2, 2}}
 	dbFake.SetClientCommitments(latestCommitments)
 
 	respClientCommitment, err = server.GetClientCommitment()
-	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_LATEST_COMMITMENT_MISSING, 0)), err)
-	assert.Equal(t, chainhash.Hash{}, respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, nil, err)
+	expectedCommitment, _ = models.NewCommitment([]chainhash.Hash{chainhash.Hash{}, chainhash.Hash{}, *hash2})
+	assert.Equal(t, expectedCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, []int32{0, 1}, respClientCommitment.GetEmptySlots())
 
-	// update server with incorrect latest commitment and test server
-	latestCommitments = []models.ClientCommitment{models.ClientCommitment{*hash2, 2}}
-	dbFake.SetClientCommitments(latestCommitments)
+	// slots with client details and no commitment are empty
+	dbFake.SetClientDetails([]models.ClientDetails{
+		models.ClientDetails{ClientPosition: 2}, models.ClientDetails{ClientPosition: 4}})
 
 	respClientCommitment, err = server.GetClientCommitment()
-	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_LATEST_COMMITMENT_MISSING, 0)), err)
-	assert.Equal(t, chainhash.Hash{}, respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, nil, err)
+	expectedCommitment, _ = models.NewCommitment([]chainhash.Hash{
+		chainhash.Hash{}, chainhash.Hash{}, *hash2, chainhash.Hash{}, chainhash.Hash{}})
+	assert.Equal(t, expectedCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, []int32{0, 1, 3, 4}, respClientCommitment.GetEmptySlots())
+
+	// negative positions of commitments and client details are skipped
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash0, -1}, models.ClientCommitment{*hash2, 2}})
+	dbFake.SetClientDetails([]models.ClientDetails{
+		models.ClientDetails{ClientPosition: -2}, models.ClientDetails{ClientPosition: 2}})
+
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	expectedCommitment, _ = models.NewCommitment([]chainhash.Hash{chainhash.Hash{}, chainhash.Hash{}, *hash2})
+	assert.Equal(t, expectedCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, []int32{0, 1}, respClientCommitment.GetEmptySlots())
+	dbFake.SetClientDetails([]models.ClientDetails{})
 
 	// update server with correct latest commitment and test server
 	latestCommitments = []models.ClientCommitment{
@@ -263,13 +285,26 @@ func TestServerGetClientCommitment(t *testing.T) {
 	respClientCommitment, err = server.GetClientCommitment()
 	assert.Equal(t, nil, err)
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, latestCommitment.GetMerkleProofs(), respClientCommitment.GetMerkleProofs())
+
+	// proofs of the latest commitment are built from the server commitment tree
+	// and saved with the attestation, proofs of other commitments are rebuilt
+	assert.Equal(t, latestCommitment.GetMerkleProofs(), server.GetCommitmentMerkleProofs(respClientCommitment))
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*models.NewAttestation(*txid, &respClientCommitment)))
+	for _, proof := range latestCommitment.GetMerkleProofs() {
+		dbProof, errProof := dbFake.GetMerkleProof(context.Background(), proof.MerkleRoot, proof.ClientPosition)
+		assert.Equal(t, nil, errProof)
+		assert.Equal(t, proof, dbProof)
+	}
+	assert.Equal(t, expectedCommitment.GetMerkleProofs(), server.GetCommitmentMerkleProofs(*expectedCommitment))
 }
 
 // Test Server GetAttestationCommitment
 func TestServerGetAttestationCommitment(t *testing.T) {
 	//TEST INIT
 	dbFake := NewDbFake()
-	server := NewServer(dbFake)
+	server := NewServer(context.Background(), dbFake)
 
 	// set db latest commitment
 	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
@@ -326,4 +361,563 @@ func TestServerGetAttestationCommitment(t *testing.T) {
 	// check commitment for invalid attestation
 	commitment, err = server.GetAttestationCommitment(chainhash.Hash{})
 	assert.Equal(t, errors.New(ERROR_MERKLE_COMMITMENT_GET), err)
+
+	// check commitment for attestation with legacy tree layout
+	legacyCommitment, _ := models.NewCommitmentVersion([]chainhash.Hash{*hashX, *hashY, *hashZ}, models.COMMITMENT_TREE_VERSION_LEGACY)
+	txid2, _ := chainhash.NewHashFromStr("31111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	latest2 := models.NewAttestation(*txid2, legacyCommitment)
+	latest2.Confirmed = true
+	errUpdate = server.UpdateLatestAttestation(*latest2)
+	assert.Equal(t, nil, errUpdate)
+	commitment, err = server.GetAttestationCommitment(*txid2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, models.COMMITMENT_TREE_VERSION_LEGACY, commitment.GetTreeVersion())
+	assert.Equal(t, legacyCommitment.GetCommitmentHash(), commitment.GetCommitmentHash())
+	assert.Equal(t, false, latestCommitment0.GetCommitmentHash() == commitment.GetCommitmentHash())
+}
+
+// Test Server UpdateClientCommitment with auth token and signature verification
+func TestServerUpdateClientCommitment(t *testing.T) {
+	// TEST INIT
+	dbFake := NewDbFake()
+	server := NewServer(context.Background(), dbFake)
+
+	// client details with pubkey and address signers
+	privKey0, _ := btcutil.DecodeWIF("cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz")
+	privKey1, _ := btcutil.DecodeWIF("cSS9R4XPpajhqy28hcfHEzEzAbyWDqBaGZR4xtV7Jg8TixSWee1x")
+	addr1, _ := crypto.GetAddressFromPrivKey(privKey1, &chaincfg.RegressionNetParams)
+	dbFake.SetClientDetails([]models.ClientDetails{
+		models.ClientDetails{ClientPosition: 0, AuthToken: "token0",
+			Pubkey: "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33"},
+		models.ClientDetails{ClientPosition: 1, AuthToken: "token1", Pubkey: addr1.String()}})
+
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	sigX0, _ := crypto.SignMessage(privKey0, hashX.String())
+	sigY1, _ := crypto.SignMessage(privKey1, hashY.String())
+
+	// test missing client details
+	errUpdate := server.UpdateClientCommitment(models.ClientCommitment{Commitment: *hashX, ClientPosition: 2}, "token0", sigX0)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_CLIENT_DETAILS_MISSING, 2)), errUpdate)
+
+	// test invalid auth token
+	errUpdate = server.UpdateClientCommitment(models.ClientCommitment{Commitment: *hashX, ClientPosition: 0}, "token1", sigX0)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_CLIENT_AUTH_TOKEN, 0)), errUpdate)
+
+	// test invalid signatures
+	errUpdate = server.UpdateClientCommitment(models.ClientCommitment{Commitment: *hashY, ClientPosition: 0}, "token0", sigX0)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d: %s", ERROR_CLIENT_SIGNATURE, 0, crypto.ERROR_MESSAGE_SIGNATURE_INVALID)), errUpdate)
+	errUpdate = server.UpdateClientCommitment(models.ClientCommitment{Commitment: *hashX, ClientPosition: 0}, "token0", sigY1)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d: %s", ERROR_CLIENT_SIGNATURE, 0, crypto.ERROR_MESSAGE_SIGNATURE_INVALID)), errUpdate)
+
+	_, errCommitment := server.GetClientCommitment()
+	assert.Equal(t, errors.New(models.ERROR_COMMITMENT_LIST_EMPTY), errCommitment)
+
+	// test valid commitments out of position order
+	errUpdate = server.UpdateClientCommitment(models.ClientCommitment{Commitment: *hashY, ClientPosition: 1}, "token1", sigY1)
+	assert.Equal(t, nil, errUpdate)
+	commitment, errCommitment := server.GetClientCommitment()
+	assert.Equal(t, nil, errCommitment)
+	assert.Equal(t, []int32{0}, commitment.GetEmptySlots())
+
+	errUpdate = server.UpdateClientCommitment(models.ClientCommitment{Commitment: *hashX, ClientPosition: 0}, "token0", sigX0)
+	assert.Equal(t, nil, errUpdate)
+	commitment, errCommitment = server.GetClientCommitment()
+	assert.Equal(t, nil, errCommitment)
+	expectedCommitment, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY})
+	assert.Equal(t, expectedCommitment.GetCommitmentHash(), commitment.GetCommitmentHash())
+
+	// test commitment overwrite
+	sigY0, _ := crypto.SignMessage(privKey0, hashY.String())
+	errUpdate = server.UpdateClientCommitment(models.ClientCommitment{Commitment: *hashY, ClientPosition: 0}, "token0", sigY0)
+	assert.Equal(t, nil, errUpdate)
+	commitment, errCommitment = server.GetClientCommitment()
+	assert.Equal(t, nil, errCommitment)
+	expectedCommitment, _ = models.NewCommitment([]chainhash.Hash{*hashY, *hashY})
+	assert.Equal(t, expectedCommitment.GetCommitmentHash(), commitment.GetCommitmentHash())
+
+	// test suspended slot is rejected and zeroed
+	_, errStatus := server.SetClientStatus(1, models.CLIENT_STATUS_SUSPENDED)
+	assert.Equal(t, nil, errStatus)
+	errUpdate = server.UpdateClientCommitment(models.ClientCommitment{Commitment: *hashY, ClientPosition: 1}, "token1", sigY1)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d: %s", ERROR_CLIENT_NOT_ACTIVE, 1, models.CLIENT_STATUS_SUSPENDED)), errUpdate)
+	commitment, errCommitment = server.GetClientCommitment()
+	assert.Equal(t, nil, errCommitment)
+	assert.Equal(t, []int32{1}, commitment.GetEmptySlots())
+
+	// test reactivated slot
+	_, errStatus = server.SetClientStatus(1, models.CLIENT_STATUS_ACTIVE)
+	assert.Equal(t, nil, errStatus)
+	errUpdate = server.UpdateClientCommitment(models.ClientCommitment{Commitment: *hashY, ClientPosition: 1}, "token1", sigY1)
+	assert.Equal(t, nil, errUpdate)
+	commitment, errCommitment = server.GetClientCommitment()
+	assert.Equal(t, nil, errCommitment)
+	assert.Equal(t, expectedCommitment.GetCommitmentHash(), commitment.GetCommitmentHash())
+}
+
+// Test Server client slot signup, status changes and slot reuse
+func TestServerClientSlotLifecycle(t *testing.T) {
+	// TEST INIT
+	dbFake := NewDbFake()
+	server := NewServer(context.Background(), dbFake)
+
+	// test signup to lowest free positions
+	position, errPosition := server.NextClientPosition()
+	assert.Equal(t, nil, errPosition)
+	assert.Equal(t, int32(0), position)
+	for i := int32(0); i < 3; i++ {
+		details, errSignup := server.SignupClient(fmt.Sprintf("token%d", i), fmt.Sprintf("pubkey%d", i))
+		assert.Equal(t, nil, errSignup)
+		assert.Equal(t, i, details.ClientPosition)
+		assert.Equal(t, models.CLIENT_STATUS_ACTIVE, details.Status)
+		assert.Equal(t, true, details.StartedAt > 0)
+		assert.Equal(t, int64(0), details.EndedAt)
+	}
+
+	// test invalid status changes
+	_, errStatus := server.SetClientStatus(1, "paused")
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_CLIENT_STATUS, "paused")), errStatus)
+	_, errStatus = server.SetClientStatus(3, models.CLIENT_STATUS_SUSPENDED)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_CLIENT_DETAILS_MISSING, 3)), errStatus)
+
+	// test suspended slots are not reused
+	details, errStatus := server.SetClientStatus(1, models.CLIENT_STATUS_SUSPENDED)
+	assert.Equal(t, nil, errStatus)
+	assert.Equal(t, models.CLIENT_STATUS_SUSPENDED, details.Status)
+	assert.Equal(t, int64(0), details.EndedAt)
+	position, _ = server.NextClientPosition()
+	assert.Equal(t, int32(3), position)
+
+	// test revocation is final
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hashX, 0}, models.ClientCommitment{*hashX, 1}, models.ClientCommitment{*hashX, 2}})
+	details, errStatus = server.SetClientStatus(1, models.CLIENT_STATUS_REVOKED)
+	assert.Equal(t, nil, errStatus)
+	assert.Equal(t, models.CLIENT_STATUS_REVOKED, details.Status)
+	assert.Equal(t, true, details.EndedAt >= details.StartedAt)
+	_, errStatus = server.SetClientStatus(1, models.CLIENT_STATUS_ACTIVE)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_CLIENT_REVOKED, 1)), errStatus)
+	commitment, _ := server.GetClientCommitment()
+	assert.Equal(t, []int32{1}, commitment.GetEmptySlots())
+
+	// test revoked slot is reused with zero latest commitment
+	position, _ = server.NextClientPosition()
+	assert.Equal(t, int32(1), position)
+	details, errSignup := server.SignupClient("token3", "pubkey3")
+	assert.Equal(t, nil, errSignup)
+	assert.Equal(t, int32(1), details.ClientPosition)
+	assert.Equal(t, "token3", details.AuthToken)
+	assert.Equal(t, models.CLIENT_STATUS_ACTIVE, details.Status)
+	clientDetails, _ := dbFake.GetClientDetails(context.Background())
+	assert.Equal(t, 3, len(clientDetails))
+	assert.Equal(t, details, clientDetails[1])
+	revoked, errRevoked := server.GetRevokedClientDetails(1)
+	assert.Equal(t, nil, errRevoked)
+	assert.Equal(t, 1, len(revoked))
+	assert.Equal(t, "pubkey1", revoked[0].Pubkey)
+	assert.Equal(t, models.CLIENT_STATUS_REVOKED, revoked[0].Status)
+	assert.Equal(t, "", revoked[0].AuthToken)
+	revoked, _ = server.GetRevokedClientDetails(0)
+	assert.Equal(t, 0, len(revoked))
+	latestCommitments, _ := dbFake.GetClientCommitments(context.Background())
+	assert.Equal(t, models.ClientCommitment{chainhash.Hash{}, 1}, latestCommitments[1])
+	position, _ = server.NextClientPosition()
+	assert.Equal(t, int32(3), position)
+}
+
+// Test Server client webhook settings
+func TestServerClientWebhook(t *testing.T) {
+	// TEST INIT
+	dbFake := NewDbFake()
+	server := NewServer(context.Background(), dbFake)
+	server.SignupClient("token0", "pubkey0")
+
+	// test invalid webhook urls and positions
+	for _, webhookUrl := range []string{"client.example.com/webhook", "ftp://client.example.com", "https://", "::"} {
+		_, errWebhook := server.SetClientWebhook(0, webhookUrl, "secret0")
+		assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_CLIENT_WEBHOOK_URL, webhookUrl)), errWebhook)
+	}
+	_, errWebhook := server.SetClientWebhook(1, "https://client.example.com/webhook", "secret0")
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_CLIENT_DETAILS_MISSING, 1)), errWebhook)
+
+	// test setting and removing webhook
+	details, errWebhook := server.SetClientWebhook(0, "https://client.example.com/webhook", "secret0")
+	assert.Equal(t, nil, errWebhook)
+	assert.Equal(t, "https://client.example.com/webhook", details.WebhookUrl)
+	assert.Equal(t, "secret0", details.WebhookSecret)
+	clientDetails, _ := server.GetClientDetails()
+	assert.Equal(t, []models.ClientDetails{details}, clientDetails)
+	details, errWebhook = server.SetClientWebhook(0, "", "secret0")
+	assert.Equal(t, nil, errWebhook)
+	assert.Equal(t, "", details.WebhookUrl)
+	assert.Equal(t, "", details.WebhookSecret)
+	assert.Equal(t, "token0", details.AuthToken)
+	clientDetails, _ = dbFake.GetClientDetails(context.Background())
+	assert.Equal(t, []models.ClientDetails{details}, clientDetails)
+
+	// confirmed attestations add pending deliveries for clients with a webhook and a commitment
+	server.SignupClient("token1", "pubkey1")
+	server.SignupClient("token2", "pubkey2")
+	server.SetClientWebhook(0, "https://client0.example.com/webhook", "secret0")
+	server.SetClientWebhook(1, "https://client1.example.com/webhook", "secret1")
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hashX, chainhash.Hash{}, *hashX})
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, commitment)
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+	deliveries, _ := server.GetPendingWebhookDeliveries()
+	assert.Equal(t, []models.WebhookDelivery{}, deliveries)
+	attestation.Confirmed = true
+	attestation.Info = models.AttestationInfo{Txid: txid.String()}
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+	deliveries, _ = server.GetPendingWebhookDeliveries()
+	assert.Equal(t, []models.WebhookDelivery{models.WebhookDelivery{Txid: *txid, ClientPosition: 0,
+		Pubkey: "pubkey0", Url: "https://client0.example.com/webhook"}}, deliveries)
+}
+
+// Test Server slot proof retrieval by txid, merkle root and latest confirmed
+func TestServerSlotProof(t *testing.T) {
+	// TEST INIT
+	dbFake := NewDbFake()
+	server := NewServer(context.Background(), dbFake)
+
+	// no attestations
+	_, errProof := server.GetLatestSlotProof(0)
+	assert.Equal(t, errors.New(ERROR_ATTESTATION_MISSING), errProof)
+
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY})
+	merkleProofs := commitment.GetMerkleProofs()
+
+	// unconfirmed attestation
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	latest := models.NewAttestation(*txid, commitment)
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest))
+
+	_, errProof = server.GetLatestSlotProof(0)
+	assert.Equal(t, errors.New(ERROR_ATTESTATION_GET), errProof)
+
+	slotProof, errProof := server.GetAttestationSlotProof(*txid, 1)
+	assert.Equal(t, nil, errProof)
+	assert.Equal(t, models.SlotProof{Txid: *txid, Confirmed: false, Proof: merkleProofs[1]}, slotProof)
+
+	slotProof, errProof = server.GetMerkleRootSlotProof(commitment.GetCommitmentHash(), 0)
+	assert.Equal(t, nil, errProof)
+	assert.Equal(t, models.SlotProof{Txid: *txid, Confirmed: false, Proof: merkleProofs[0]}, slotProof)
+
+	// confirmed attestation
+	blockhash, _ := chainhash.NewHashFromStr("abcdef11111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	latest.Confirmed = true
+	latest.Info = models.AttestationInfo{Txid: txid.String(), Blockhash: blockhash.String()}
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest))
+
+	slotProof, errProof = server.GetLatestSlotProof(0)
+	assert.Equal(t, nil, errProof)
+	assert.Equal(t, models.SlotProof{Txid: *txid, Blockhash: *blockhash, Confirmed: true, Proof: merkleProofs[0]}, slotProof)
+
+	slotProof, errProof = server.GetAttestationSlotProof(*txid, 1)
+	assert.Equal(t, nil, errProof)
+	assert.Equal(t, models.SlotProof{Txid: *txid, Blockhash: *blockhash, Confirmed: true, Proof: merkleProofs[1]}, slotProof)
+
+	slotProof, errProof = server.GetMerkleRootSlotProof(commitment.GetCommitmentHash(), 1)
+	assert.Equal(t, nil, errProof)
+	assert.Equal(t, models.SlotProof{Txid: *txid, Blockhash: *blockhash, Confirmed: true, Proof: merkleProofs[1]}, slotProof)
+
+	// invalid txid, merkle root and position
+	_, errProof = server.GetAttestationSlotProof(*hashX, 0)
+	assert.Equal(t, errors.New(ERROR_ATTESTATION_GET), errProof)
+	_, errProof = server.GetMerkleRootSlotProof(*hashX, 0)
+	assert.Equal(t, errors.New(ERROR_ATTESTATION_GET), errProof)
+	_, errProof = server.GetLatestSlotProof(2)
+	assert.Equal(t, errors.New(ERROR_MERKLE_PROOF_GET), errProof)
+}
+
+// Test Server attestation retrieval by txid
+func TestServerGetAttestation(t *testing.T) {
+	// TEST INIT
+	dbFake := NewDbFake()
+	server := NewServer(context.Background(), dbFake)
+
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hashX})
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	_, errGet := server.GetAttestation(*txid)
+	assert.Equal(t, errors.New(ERROR_ATTESTATION_GET), errGet)
+
+	// unconfirmed attestation
+	latest := models.NewAttestation(*txid, commitment)
+	latest.Fee = int64(100)
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest))
+	attestation, errGet := server.GetAttestation(*txid)
+	assert.Equal(t, nil, errGet)
+	assert.Equal(t, latest.WithoutCommitment(), attestation)
+
+	// confirmed attestation with info
+	latest.Confirmed = true
+	latest.Info = models.AttestationInfo{Txid: txid.String(), Blockhash: hashX.String(), Amount: int64(1000)}
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest))
+	attestation, errGet = server.GetAttestation(*txid)
+	assert.Equal(t, nil, errGet)
+	assert.Equal(t, latest.WithoutCommitment(), attestation)
+}
+
+// Test Server GetAttestations history paging and filters
+func TestServerGetAttestations(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(context.Background(), dbFake)
+
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashZ, _ := chainhash.NewHashFromStr("ccccccc1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// no attestations
+	attestations, errGet := server.GetAttestations(AttestationFilter{}, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, nil, errGet)
+	assert.Equal(t, []models.Attestation{}, attestations)
+
+	// three confirmed attestations and a latest unconfirmed attestation
+	var expected []models.Attestation
+	for i, hash := range []*chainhash.Hash{hashX, hashY, hashZ, hashX} {
+		commitment, _ := models.NewCommitment([]chainhash.Hash{*hash})
+		txid, _ := chainhash.NewHashFromStr(fmt.Sprintf("%d111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7", i))
+		attestation := models.NewAttestation(*txid, commitment)
+		if i < 3 {
+			attestation.Confirmed = true
+			attestation.Info = models.AttestationInfo{Txid: txid.String(), Blockhash: hash.String(),
+				Height: int64(100 + i), Time: int64(1542121293 + 600*i)}
+		}
+		assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+		expected = append([]models.Attestation{attestation.WithoutCommitment()}, expected...)
+	}
+
+	// all attestations latest first
+	attestations, errGet = server.GetAttestations(AttestationFilter{}, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, nil, errGet)
+	assert.Equal(t, expected, attestations)
+
+	// paging
+	attestations, errGet = server.GetAttestations(AttestationFilter{}, 1, 3)
+	assert.Equal(t, nil, errGet)
+	assert.Equal(t, expected[:3], attestations)
+	attestations, errGet = server.GetAttestations(AttestationFilter{}, 2, 3)
+	assert.Equal(t, nil, errGet)
+	assert.Equal(t, expected[3:], attestations)
+	attestations, errGet = server.GetAttestations(AttestationFilter{}, 3, 3)
+	assert.Equal(t, nil, errGet)
+	assert.Equal(t, []models.Attestation{}, attestations)
+
+	// confirmed filter
+	confirmed := false
+	attestations, _ = server.GetAttestations(AttestationFilter{Confirmed: &confirmed}, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, expected[:1], attestations)
+	confirmed = true
+	attestations, _ = server.GetAttestations(AttestationFilter{Confirmed: &confirmed}, 2, 2)
+	assert.Equal(t, expected[3:], attestations)
+
+	// merkle root filter
+	merkleRoot := expected[0].CommitmentHash()
+	attestations, _ = server.GetAttestations(AttestationFilter{MerkleRoot: &merkleRoot}, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, []models.Attestation{expected[0], expected[3]}, attestations)
+
+	// time and height ranges only match confirmed attestations
+	attestations, _ = server.GetAttestations(AttestationFilter{FromTime: int64(1542121293 + 600)}, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, expected[1:3], attestations)
+	attestations, _ = server.GetAttestations(AttestationFilter{ToTime: int64(1542121293)}, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, expected[3:], attestations)
+	attestations, _ = server.GetAttestations(AttestationFilter{FromHeight: 101, ToHeight: 101}, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, expected[2:3], attestations)
+	attestations, _ = server.GetAttestations(AttestationFilter{FromHeight: 103}, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, []models.Attestation{}, attestations)
+
+	// invalid paging
+	_, errGet = server.GetAttestations(AttestationFilter{}, 0, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s 0", ERROR_ATTESTATIONS_PAGE)), errGet)
+	_, errGet = server.GetAttestations(AttestationFilter{}, 1, 0)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s 0", ERROR_ATTESTATIONS_LIMIT)), errGet)
+	_, errGet = server.GetAttestations(AttestationFilter{}, 1, ATTESTATIONS_LIMIT_MAX+1)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_ATTESTATIONS_LIMIT, ATTESTATIONS_LIMIT_MAX+1)), errGet)
+}
+
+// Test Server client commitment history retention and attestation
+func TestServerClientCommitmentHistory(t *testing.T) {
+	// TEST INIT
+	dbFake := NewDbFake()
+	server := NewServer(context.Background(), dbFake)
+
+	privKey0, _ := btcutil.DecodeWIF("cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz")
+	privKey1, _ := btcutil.DecodeWIF("cSS9R4XPpajhqy28hcfHEzEzAbyWDqBaGZR4xtV7Jg8TixSWee1x")
+	pubkey0 := "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33"
+	addr1, _ := crypto.GetAddressFromPrivKey(privKey1, &chaincfg.RegressionNetParams)
+	dbFake.SetClientDetails([]models.ClientDetails{
+		models.ClientDetails{ClientPosition: 0, AuthToken: "token0", Pubkey: pubkey0},
+		models.ClientDetails{ClientPosition: 1, AuthToken: "token1", Pubkey: addr1.String()}})
+
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	sigX0, _ := crypto.SignMessage(privKey0, hashX.String())
+	sigY0, _ := crypto.SignMessage(privKey0, hashY.String())
+	sigY1, _ := crypto.SignMessage(privKey1, hashY.String())
+
+	history, errHistory := server.GetClientCommitmentHistory(0, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, nil, errHistory)
+	assert.Equal(t, []models.ClientCommitmentHistory{}, history)
+
+	// rejected commitments are not recorded
+	server.UpdateClientCommitment(models.ClientCommitment{Commitment: *hashY, ClientPosition: 0}, "token0", sigX0)
+	history, _ = server.GetClientCommitmentHistory(0, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, 0, len(history))
+
+	// overwritten commitments are kept in history
+	for _, update := range []struct {
+		hash      *chainhash.Hash
+		position  int32
+		token     string
+		signature string
+	}{
+		{hashX, 0, "token0", sigX0},
+		{hashY, 0, "token0", sigY0},
+		{hashY, 1, "token1", sigY1},
+		{hashX, 0, "token0", sigX0},
+	} {
+		errUpdate := server.UpdateClientCommitment(models.ClientCommitment{Commitment: *update.hash,
+			ClientPosition: update.position}, update.token, update.signature)
+		assert.Equal(t, nil, errUpdate)
+	}
+	history, _ = server.GetClientCommitmentHistory(0, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, 3, len(history))
+	for i, expected := range []struct {
+		hash      *chainhash.Hash
+		signature string
+	}{{hashX, sigX0}, {hashY, sigY0}, {hashX, sigX0}} {
+		assert.Equal(t, *expected.hash, history[i].Commitment)
+		assert.Equal(t, int32(0), history[i].ClientPosition)
+		assert.Equal(t, pubkey0, history[i].Submitter)
+		assert.Equal(t, expected.signature, history[i].Signature)
+		assert.Equal(t, false, history[i].SubmittedAt.IsZero())
+		assert.Equal(t, false, history[i].Attested())
+	}
+	history, _ = server.GetClientCommitmentHistory(1, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, addr1.String(), history[0].Submitter)
+
+	// paging
+	page, _ := server.GetClientCommitmentHistory(0, 2, 2)
+	allHistory, _ := server.GetClientCommitmentHistory(0, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, allHistory[2:], page)
+	_, errHistory = server.GetClientCommitmentHistory(0, 0, 2)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s 0", ERROR_ATTESTATIONS_PAGE)), errHistory)
+
+	// unconfirmed attestation does not attest history
+	commitment, _ := server.GetClientCommitment()
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, &commitment)
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+	history, _ = server.GetClientCommitmentHistory(0, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, false, history[0].Attested())
+
+	// confirmed attestation attests latest matching history entries only
+	attestation.Confirmed = true
+	attestation.Info = models.AttestationInfo{Txid: txid.String()}
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+	history, _ = server.GetClientCommitmentHistory(0, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, *txid, history[0].Txid)
+	assert.Equal(t, commitment.GetCommitmentHash(), history[0].MerkleRoot)
+	assert.Equal(t, false, history[1].Attested())
+	assert.Equal(t, false, history[2].Attested())
+	history, _ = server.GetClientCommitmentHistory(1, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, *txid, history[0].Txid)
+	assert.Equal(t, commitment.GetCommitmentHash(), history[0].MerkleRoot)
+
+	// history of a revoked client is not returned for the client reusing its position,
+	// even when the position is reused within the same second
+	server.SetClientStatus(0, models.CLIENT_STATUS_REVOKED)
+	details, errSignup := server.SignupClient("token2", pubkey0)
+	assert.Equal(t, nil, errSignup)
+	assert.Equal(t, int32(0), details.ClientPosition)
+	history, errHistory = server.GetClientCommitmentHistory(0, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, nil, errHistory)
+	assert.Equal(t, []models.ClientCommitmentHistory{}, history)
+	assert.Equal(t, nil, server.UpdateClientCommitment(models.ClientCommitment{Commitment: *hashY,
+		ClientPosition: 0}, "token2", sigY0))
+	history, _ = server.GetClientCommitmentHistory(0, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, *hashY, history[0].Commitment)
+	history, _ = server.GetClientCommitmentHistory(1, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, 1, len(history))
+
+	// history of the revoked client is returned by its start time
+	revokedHistory, errRevoked := server.GetRevokedClientCommitmentHistory(0, 0, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, nil, errRevoked)
+	assert.Equal(t, 3, len(revokedHistory))
+	for i, hash := range []*chainhash.Hash{hashX, hashY, hashX} {
+		assert.Equal(t, *hash, revokedHistory[i].Commitment)
+	}
+	page, _ = server.GetRevokedClientCommitmentHistory(0, 0, 2, 2)
+	assert.Equal(t, revokedHistory[2:], page)
+	_, errRevoked = server.GetRevokedClientCommitmentHistory(0, details.StartedAt, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s 0", ERROR_CLIENT_REVOKED_MISSING)), errRevoked)
+	_, errRevoked = server.GetRevokedClientCommitmentHistory(1, 0, 1, ATTESTATIONS_LIMIT_DEFAULT)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s 1", ERROR_CLIENT_REVOKED_MISSING)), errRevoked)
+}
+
+// Db discarding attestation update writes so that benchmarks measure the
+// server work of building the commitment and proofs of a round only
+type benchmarkDb struct {
+	*DbFake
+}
+
+func (d benchmarkDb) SaveAttestationUpdate(ctx context.Context, attestation models.Attestation,
+	proofs ...models.CommitmentMerkleProof) error {
+	return saveAttestationUpdate(ctx, d, attestation, proofs)
+}
+
+func (d benchmarkDb) SaveAttestation(ctx context.Context, attestation models.Attestation) error {
+	return nil
+}
+
+func (d benchmarkDb) SaveMerkleCommitments(ctx context.Context, commitments []models.CommitmentMerkleCommitment) error {
+	return nil
+}
+
+func (d benchmarkDb) SaveMerkleProofs(ctx context.Context, proofs []models.CommitmentMerkleProof) error {
+	return nil
+}
+
+// Return latest client commitments of n slots with commitments of round
+// changed for 100 slots spread over the slot range
+func benchmarkClientCommitments(n int, round byte) []models.ClientCommitment {
+	commitments := make([]models.ClientCommitment, n)
+	for i := range commitments {
+		commitments[i] = models.ClientCommitment{Commitment: chainhash.HashH([]byte(fmt.Sprintf("%d", i))),
+			ClientPosition: int32(i)}
+	}
+	for i := 0; i < n; i += n / 100 {
+		commitments[i].Commitment = chainhash.HashH([]byte(fmt.Sprintf("%d %d", i, round)))
+	}
+	return commitments
+}
+
+// Benchmark getting the client commitment and saving the attestation of a round
+func BenchmarkServerAttestationRound(b *testing.B) {
+	for _, n := range []int{10000, 100000, 1000000} {
+		dbFake := NewDbFake()
+		server := NewServer(context.Background(), benchmarkDb{dbFake})
+		rounds := [][]models.ClientCommitment{benchmarkClientCommitments(n, 1), benchmarkClientCommitments(n, 2)}
+		b.Run(fmt.Sprintf("slots=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				dbFake.SetClientCommitments(rounds[i%2])
+				commitment, errCommitment := server.GetClientCommitment()
+				if errCommitment != nil {
+					b.Fatal(errCommitment)
+				}
+				txid := chainhash.HashH([]byte(fmt.Sprintf("%d", i)))
+				if errUpdate := server.UpdateLatestAttestation(*models.NewAttestation(txid, &commitment)); errUpdate != nil {
+					b.Fatal(errUpdate)
+				}
+			}
+		})
+	}
 }
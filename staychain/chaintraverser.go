@@ -0,0 +1,70 @@
+package staychain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// error consts
+const (
+	ERROR_TRAVERSE_TX_FETCH = "Could not fetch staychain transaction"
+	ERROR_TRAVERSE_OUTPUTS  = "Staychain transaction does not have a single output"
+	ERROR_TRAVERSE_INPUTS   = "Staychain transaction does not have a single input"
+	ERROR_TRAVERSE_SPEND    = "Staychain transaction does not spend the previous staychain output"
+)
+
+// ChainTraverser struct
+// Struct that walks the staychain backwards from any attestation
+// transaction following the first input of each transaction until
+// the configured start point transaction txidsp is reached
+type ChainTraverser struct {
+	client RawTxClient
+	txidsp chainhash.Hash
+}
+
+// Return new ChainTraverser instance for the start point txidsp
+func NewChainTraverser(client RawTxClient, txidsp chainhash.Hash) ChainTraverser {
+	return ChainTraverser{client, txidsp}
+}
+
+// Traverse the staychain backwards from txid to the start point
+// Each transaction up to the start point has to have a single output
+// and a single input spending the single output of the previous one
+// Returns the staychain height of txid with the start point at height 0
+func (t *ChainTraverser) Traverse(txid chainhash.Hash) (int64, error) {
+	height, _, errTraverse := t.TraverseTo(txid, t.txidsp)
+	return height, errTraverse
+}
+
+// Traverse the staychain backwards from txid to the ancestor txid
+// The traversal stops at the start point if the ancestor is not reached
+// Returns the number of hops to the ancestor and whether it was reached
+func (t *ChainTraverser) TraverseTo(txid chainhash.Hash, ancestor chainhash.Hash) (int64, bool, error) {
+	var height int64
+	for {
+		tx, errTx := t.client.GetRawTransaction(&txid)
+		if errTx != nil {
+			return 0, false, errors.New(fmt.Sprintf("%s %s: %v", ERROR_TRAVERSE_TX_FETCH, txid.String(), errTx))
+		}
+		msgTx := tx.MsgTx()
+		if len(msgTx.TxOut) != 1 {
+			return 0, false, errors.New(fmt.Sprintf("%s %s", ERROR_TRAVERSE_OUTPUTS, txid.String()))
+		}
+		if txid == ancestor {
+			return height, true, nil
+		} else if txid == t.txidsp { // start point reached
+			return 0, false, nil
+		}
+
+		if len(msgTx.TxIn) != 1 {
+			return 0, false, errors.New(fmt.Sprintf("%s %s", ERROR_TRAVERSE_INPUTS, txid.String()))
+		}
+		if msgTx.TxIn[0].PreviousOutPoint.Index != 0 {
+			return 0, false, errors.New(fmt.Sprintf("%s %s", ERROR_TRAVERSE_SPEND, txid.String()))
+		}
+		txid = msgTx.TxIn[0].PreviousOutPoint.Hash
+		height++
+	}
+}
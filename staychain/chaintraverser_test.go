@@ -0,0 +1,76 @@
+package staychain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// Build test staychain tx spending the first output of prevTxid
+func buildTraverseTx(prevTxid chainhash.Hash, value int64) *wire.MsgTx {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevTxid, 0), []byte{0x00}, nil))
+	tx.AddTxOut(wire.NewTxOut(value, []byte{0x51}))
+	return tx
+}
+
+// Test ChainTraverser backward staychain traversal
+func TestChainTraverser(t *testing.T) {
+	client := rawTxClientFake{make(map[chainhash.Hash]*wire.MsgTx)}
+
+	// funding tx with multiple outputs and staychain of four txs
+	funding := wire.NewMsgTx(wire.TxVersion)
+	funding.AddTxOut(wire.NewTxOut(1, []byte{0x51}))
+	funding.AddTxOut(wire.NewTxOut(2, []byte{0x51}))
+	client.txs[funding.TxHash()] = funding
+	var txs []*wire.MsgTx
+	prevTxid := funding.TxHash()
+	for i := 0; i < 4; i++ {
+		tx := buildTraverseTx(prevTxid, int64(1000-i))
+		client.txs[tx.TxHash()] = tx
+		txs = append(txs, tx)
+		prevTxid = tx.TxHash()
+	}
+
+	// heights from start point
+	traverser := NewChainTraverser(client, txs[0].TxHash())
+	for i, tx := range txs {
+		height, errTraverse := traverser.Traverse(tx.TxHash())
+		assert.Equal(t, nil, errTraverse)
+		assert.Equal(t, int64(i), height)
+	}
+	traverser = NewChainTraverser(client, txs[2].TxHash())
+	height, errTraverse := traverser.Traverse(txs[3].TxHash())
+	assert.Equal(t, nil, errTraverse)
+	assert.Equal(t, int64(1), height)
+
+	// start point not an ancestor
+	_, errTraverse = traverser.Traverse(txs[1].TxHash())
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_TRAVERSE_OUTPUTS, funding.TxHash().String())), errTraverse)
+
+	// missing tx
+	traverser = NewChainTraverser(client, txs[0].TxHash())
+	delete(client.txs, txs[1].TxHash())
+	_, errTraverse = traverser.Traverse(txs[3].TxHash())
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s: %s", ERROR_TRAVERSE_TX_FETCH, txs[1].TxHash().String(),
+		"No such mempool or blockchain transaction")), errTraverse)
+	client.txs[txs[1].TxHash()] = txs[1]
+
+	// tx with multiple inputs
+	txs[2].AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil, nil))
+	client.txs[txs[2].TxHash()] = txs[2]
+	_, errTraverse = traverser.Traverse(txs[2].TxHash())
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_TRAVERSE_INPUTS, txs[2].TxHash().String())), errTraverse)
+
+	// tx not spending the first output of the previous
+	spendTx := wire.NewMsgTx(wire.TxVersion)
+	spendTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevTxid, 1), nil, nil))
+	spendTx.AddTxOut(wire.NewTxOut(1, []byte{0x51}))
+	client.txs[spendTx.TxHash()] = spendTx
+	_, errTraverse = traverser.Traverse(spendTx.TxHash())
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_TRAVERSE_SPEND, spendTx.TxHash().String())), errTraverse)
+}
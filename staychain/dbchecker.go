@@ -0,0 +1,393 @@
+package staychain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"mainstay/models"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// error consts
+const (
+	ERROR_CHECK_TX_FETCH     = "Could not fetch staychain transaction"
+	ERROR_CHECK_BLOCK_FETCH  = "Could not fetch main chain block"
+	ERROR_CHECK_UNCONFIRMED  = "Staychain start point transaction not confirmed"
+	ERROR_CHECK_ATTESTATIONS = "Could not get db attestations"
+	ERROR_CHECK_REPAIR       = "Could not repair db attestation"
+)
+
+// db consistency issues
+const (
+	ISSUE_ATTESTATION_MISSING  = "Staychain transaction missing from db attestations"
+	ISSUE_ATTESTATION_ORPHANED = "Db attestation not in staychain"
+	ISSUE_TWEAK_MISMATCH       = "Staychain transaction output not tweaked with db merkle root"
+	ISSUE_COMMITMENT_UNKNOWN   = "Db attestation commitment unknown"
+	ISSUE_CONFIRMATION         = "Db attestation not confirmed"
+	ISSUE_INFO_MISSING         = "Db attestation info missing"
+	ISSUE_INFO_MISMATCH        = "Db attestation info does not match staychain block"
+	ISSUE_COMMITMENTS_MISMATCH = "Db merkle commitments missing or mismatched"
+	ISSUE_PROOFS_MISMATCH      = "Db merkle proofs missing or invalid"
+)
+
+// DbCheckerClient interface
+// Client required to walk the staychain through the main chain blocks
+// The btcd rpcclient.Client satisfies this interface directly
+type DbCheckerClient interface {
+	GetRawTransactionVerbose(*chainhash.Hash) (*btcjson.TxRawResult, error)
+	GetBlockHeaderVerbose(*chainhash.Hash) (*btcjson.GetBlockHeaderVerboseResult, error)
+	GetBlockCount() (int64, error)
+	GetBlockHash(int64) (*chainhash.Hash, error)
+	GetBlock(*chainhash.Hash) (*wire.MsgBlock, error)
+}
+
+// DbCheckerIssue struct
+// Inconsistency found between the db and the staychain for txid
+// Repairable issues are set to Repaired once fixed in repair mode
+type DbCheckerIssue struct {
+	Txid       chainhash.Hash
+	Issue      string
+	Detail     string
+	Repairable bool
+	Repaired   bool
+}
+
+// Implement Stringer interface method
+func (i DbCheckerIssue) String() string {
+	status := "not repairable"
+	if i.Repaired {
+		status = "repaired"
+	} else if i.Repairable {
+		status = "repairable"
+	}
+	if i.Detail == "" {
+		return fmt.Sprintf("%s %s (%s)", i.Issue, i.Txid.String(), status)
+	}
+	return fmt.Sprintf("%s %s: %s (%s)", i.Issue, i.Txid.String(), i.Detail, status)
+}
+
+// staychain transaction with the main chain block including it
+// and the value of the previous staychain output it spends
+type staychainTx struct {
+	tx       *wire.MsgTx
+	block    *wire.MsgBlock
+	height   int64
+	amountIn int64
+}
+
+// DbChecker struct
+// Walks the staychain forward from the start point txid0 and cross-checks
+// each staychain transaction against the db attestations, their info,
+// merkle commitments and merkle proofs, flagging mismatched, missing and
+// orphaned records. The output of each transaction is checked against
+// the base pubkey, or base multisig script if set, tweaked with the db
+// merkle root. In repair mode confirmation status, info, commitments
+// and proofs are restored from the staychain and the db commitment
+// Rounds pruned into the proof archive, if set, are checked against the
+// archived commitment and are not restored to the db
+type DbChecker struct {
+	client  DbCheckerClient
+	db      server.Db
+	txid0   chainhash.Hash
+	pubkey  []byte
+	script  []byte
+	archive *server.ProofArchive
+}
+
+// Return new DbChecker instance for the staychain starting at txid0
+func NewDbChecker(client DbCheckerClient, db server.Db, txid0 chainhash.Hash, pubkey []byte, script []byte) DbChecker {
+	return DbChecker{client, db, txid0, pubkey, script, nil}
+}
+
+// Set proof archive of rounds pruned from the db
+func (c *DbChecker) SetProofArchive(archive *server.ProofArchive) {
+	c.archive = archive
+}
+
+// Check db against the staychain and repair issues if repair is set
+// Returns all issues found, with repaired issues marked as such
+func (c *DbChecker) Check(ctx context.Context, repair bool) ([]DbCheckerIssue, error) {
+	staychain, errWalk := walkStaychain(c.client, c.txid0)
+	if errWalk != nil {
+		return nil, errWalk
+	}
+
+	issues := []DbCheckerIssue{}
+	inStaychain := make(map[chainhash.Hash]bool)
+	for _, stayTx := range staychain {
+		inStaychain[stayTx.tx.TxHash()] = true
+		txIssues, errCheck := c.checkAttestation(ctx, stayTx, repair)
+		if errCheck != nil {
+			return nil, errCheck
+		}
+		issues = append(issues, txIssues...)
+	}
+
+	orphanIssues, errOrphans := c.checkOrphans(ctx, inStaychain, repair)
+	if errOrphans != nil {
+		return nil, errOrphans
+	}
+	return append(issues, orphanIssues...), nil
+}
+
+// Walk the staychain forward from txid0 to the main chain tip, searching
+// each block for the transaction spending the previous staychain output
+func walkStaychain(client DbCheckerClient, txid0 chainhash.Hash) ([]staychainTx, error) {
+	tx0, errTx := client.GetRawTransactionVerbose(&txid0)
+	if errTx != nil {
+		return nil, errors.New(fmt.Sprintf("%s %s: %v", ERROR_CHECK_TX_FETCH, txid0.String(), errTx))
+	}
+	if tx0.BlockHash == "" {
+		return nil, errors.New(fmt.Sprintf("%s %s", ERROR_CHECK_UNCONFIRMED, txid0.String()))
+	}
+	blockhash, errHash := chainhash.NewHashFromStr(tx0.BlockHash)
+	if errHash != nil {
+		return nil, errHash
+	}
+	header, errHeader := client.GetBlockHeaderVerbose(blockhash)
+	if errHeader != nil {
+		return nil, errors.New(fmt.Sprintf("%s %s: %v", ERROR_CHECK_BLOCK_FETCH, blockhash.String(), errHeader))
+	}
+	blockcount, errCount := client.GetBlockCount()
+	if errCount != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_CHECK_BLOCK_FETCH, errCount))
+	}
+
+	var staychain []staychainTx
+	var amountIn int64
+	prevOut := wire.OutPoint{Hash: txid0, Index: 0}
+	for height := int64(header.Height); height <= blockcount; height++ {
+		if height%1000 == 0 { // log if walking takes too long
+			log.Printf("Latest walking block height: %d\n", height)
+		}
+		blockhash, errHash := client.GetBlockHash(height)
+		if errHash != nil {
+			return nil, errors.New(fmt.Sprintf("%s %d: %v", ERROR_CHECK_BLOCK_FETCH, height, errHash))
+		}
+		block, errBlock := client.GetBlock(blockhash)
+		if errBlock != nil {
+			return nil, errors.New(fmt.Sprintf("%s %d: %v", ERROR_CHECK_BLOCK_FETCH, height, errBlock))
+		}
+		// next staychain tx can be included in the same block as the previous
+		for _, tx := range block.Transactions {
+			if tx.TxHash() == txid0 && len(tx.TxOut) > 0 {
+				amountIn = tx.TxOut[0].Value
+			} else if len(tx.TxIn) > 0 && tx.TxIn[0].PreviousOutPoint == prevOut {
+				staychain = append(staychain, staychainTx{tx, block, height, amountIn})
+				prevOut = wire.OutPoint{Hash: tx.TxHash(), Index: 0}
+				if len(tx.TxOut) > 0 {
+					amountIn = tx.TxOut[0].Value
+				}
+			}
+		}
+	}
+	return staychain, nil
+}
+
+// Check db records of the attestation of a staychain transaction
+func (c *DbChecker) checkAttestation(ctx context.Context, stayTx staychainTx, repair bool) ([]DbCheckerIssue, error) {
+	txid := stayTx.tx.TxHash()
+	attestation, errAttestation := c.db.GetAttestation(ctx, txid)
+	if errAttestation != nil {
+		return []DbCheckerIssue{{txid, ISSUE_ATTESTATION_MISSING, errAttestation.Error(), false, false}}, nil
+	}
+	merkleRootStr, errRoot := c.db.GetAttestationMerkleRoot(ctx, txid)
+	if errRoot != nil {
+		return []DbCheckerIssue{{txid, ISSUE_ATTESTATION_MISSING, errRoot.Error(), false, false}}, nil
+	}
+	merkleRoot, errHash := chainhash.NewHashFromStr(merkleRootStr)
+	if errHash != nil {
+		return []DbCheckerIssue{{txid, ISSUE_TWEAK_MISMATCH, errHash.Error(), false, false}}, nil
+	}
+	if errTweak := verifyTweak(c.pubkey, c.script, *merkleRoot, *stayTx.tx); errTweak != nil {
+		return []DbCheckerIssue{{txid, ISSUE_TWEAK_MISMATCH, errTweak.Error(), false, false}}, nil
+	}
+
+	var issues []DbCheckerIssue
+	blockhash := stayTx.block.BlockHash()
+	if !attestation.Confirmed {
+		issues = append(issues, DbCheckerIssue{txid, ISSUE_CONFIRMATION, "", true, false})
+	}
+	info, errInfo := c.db.GetAttestationInfo(ctx, txid)
+	if errInfo != nil {
+		issues = append(issues, DbCheckerIssue{txid, ISSUE_INFO_MISSING, "", true, false})
+	} else if info.Blockhash != blockhash.String() || info.Height != stayTx.height {
+		issues = append(issues, DbCheckerIssue{txid, ISSUE_INFO_MISMATCH,
+			fmt.Sprintf("blockhash %s height %d", info.Blockhash, info.Height), true, false})
+	}
+
+	// attestations stored without commitments are rebuilt from merkle commitments
+	dbMerkleCommitments, _ := c.db.GetAttestationMerkleCommitments(ctx, txid)
+	archived := c.archivedCommitment(info, errInfo, *merkleRoot, dbMerkleCommitments)
+	commitment, errCommitment := attestation.Commitment()
+	if errCommitment != nil && archived != nil {
+		commitment, errCommitment = archived, nil
+	} else if errCommitment != nil {
+		commitment, errCommitment = commitmentFromMerkleCommitments(dbMerkleCommitments, *merkleRoot)
+	}
+	if errCommitment != nil {
+		// nothing can be repaired without the commitment
+		for i := range issues {
+			issues[i].Repairable = false
+		}
+		return append(issues, DbCheckerIssue{txid, ISSUE_COMMITMENT_UNKNOWN, errCommitment.Error(), false, false}), nil
+	}
+	if archived != nil && archived.GetCommitmentHash() != commitment.GetCommitmentHash() {
+		issues = append(issues, DbCheckerIssue{txid, ISSUE_COMMITMENTS_MISMATCH, "archived", false, false})
+	} else if archived == nil {
+		if !matchMerkleCommitments(dbMerkleCommitments, commitment.GetMerkleCommitments()) {
+			issues = append(issues, DbCheckerIssue{txid, ISSUE_COMMITMENTS_MISMATCH, "", true, false})
+		}
+		for _, proof := range commitment.GetMerkleProofs() {
+			dbProof, errProof := c.db.GetMerkleProof(ctx, *merkleRoot, proof.ClientPosition)
+			if errProof != nil || dbProof.Commitment != proof.Commitment || !models.ProveMerkleProof(dbProof) {
+				issues = append(issues, DbCheckerIssue{txid, ISSUE_PROOFS_MISMATCH, "", true, false})
+				break
+			}
+		}
+	}
+
+	if !repair || len(issues) == 0 {
+		return issues, nil
+	}
+
+	// restore confirmed attestation with info of the staychain block
+	if errInfo != nil || info.Blockhash != blockhash.String() {
+		info = models.AttestationInfo{Time: stayTx.block.Header.Timestamp.Unix()}
+	}
+	info.Txid = txid.String()
+	info.Blockhash = blockhash.String()
+	info.Height = stayTx.height
+	info.Amount = stayTx.tx.TxOut[0].Value
+	attestation.Info = info
+	attestation.Tx = *stayTx.tx
+	attestation.Confirmed = true
+	attestation.SetCommitment(commitment)
+	if errSpv := attestation.UpdateSpvInfo(stayTx.block); errSpv != nil {
+		return nil, errors.New(fmt.Sprintf("%s %s: %v", ERROR_CHECK_REPAIR, txid.String(), errSpv))
+	}
+	if errSave := c.db.SaveAttestationUpdate(ctx, attestation); errSave != nil {
+		return nil, errors.New(fmt.Sprintf("%s %s: %v", ERROR_CHECK_REPAIR, txid.String(), errSave))
+	}
+	if archived != nil { // keep archived rounds pruned
+		if errPrune := c.db.PruneMerkleRoot(ctx, *merkleRoot); errPrune != nil {
+			return nil, errors.New(fmt.Sprintf("%s %s: %v", ERROR_CHECK_REPAIR, txid.String(), errPrune))
+		}
+	}
+	for i := range issues {
+		issues[i].Repaired = true
+	}
+	return issues, nil
+}
+
+// Return archived commitment of a round pruned from the db
+// Returns nil if the round has db merkle commitments or is not archived
+func (c *DbChecker) archivedCommitment(info models.AttestationInfo, errInfo error, merkleRoot chainhash.Hash,
+	dbMerkleCommitments []models.CommitmentMerkleCommitment) *models.Commitment {
+	if c.archive == nil || errInfo != nil || len(dbMerkleCommitments) > 0 {
+		return nil
+	}
+	record, errFind := c.archive.Find(time.Unix(info.Time, 0), merkleRoot)
+	if errFind != nil || record == nil {
+		return nil
+	}
+	commitment, errCommitment := record.Commitment()
+	if errCommitment != nil {
+		return nil
+	}
+	return commitment
+}
+
+// Check db attestations that are not part of the staychain
+// Unconfirmed attestations pending in the mempool are not orphaned
+// Orphaned confirmed attestations are repaired by marking them unconfirmed
+func (c *DbChecker) checkOrphans(ctx context.Context, inStaychain map[chainhash.Hash]bool, repair bool) ([]DbCheckerIssue, error) {
+	var orphans []models.Attestation
+	for skip := int64(0); ; skip += server.ATTESTATIONS_LIMIT_MAX {
+		attestations, errGet := c.db.GetAttestations(ctx, server.AttestationFilter{}, skip, server.ATTESTATIONS_LIMIT_MAX)
+		if errGet != nil {
+			return nil, errors.New(fmt.Sprintf("%s %v", ERROR_CHECK_ATTESTATIONS, errGet))
+		}
+		for _, attestation := range attestations {
+			if !inStaychain[attestation.Txid] {
+				orphans = append(orphans, attestation)
+			}
+		}
+		if len(attestations) < server.ATTESTATIONS_LIMIT_MAX {
+			break
+		}
+	}
+
+	var issues []DbCheckerIssue
+	for _, attestation := range orphans {
+		if !attestation.Confirmed {
+			tx, errTx := c.client.GetRawTransactionVerbose(&attestation.Txid)
+			if errTx == nil && tx.BlockHash == "" {
+				continue
+			}
+			issues = append(issues, DbCheckerIssue{attestation.Txid, ISSUE_ATTESTATION_ORPHANED, "unconfirmed", false, false})
+			continue
+		}
+
+		repairable := attestation.CommitmentHash() != chainhash.Hash{}
+		issue := DbCheckerIssue{attestation.Txid, ISSUE_ATTESTATION_ORPHANED, "confirmed", repairable, false}
+		if repair && issue.Repairable {
+			attestation.Confirmed = false
+			if errSave := c.db.SaveAttestation(ctx, attestation); errSave != nil {
+				return nil, errors.New(fmt.Sprintf("%s %s: %v", ERROR_CHECK_REPAIR, attestation.Txid.String(), errSave))
+			}
+			issue.Repaired = true
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// Rebuild commitment from merkle commitments of merkle root
+// Each tree version is tried until the merkle root is matched
+func commitmentFromMerkleCommitments(merkleCommitments []models.CommitmentMerkleCommitment,
+	merkleRoot chainhash.Hash) (*models.Commitment, error) {
+	sorted := append([]models.CommitmentMerkleCommitment{}, merkleCommitments...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ClientPosition < sorted[j].ClientPosition })
+
+	var commitmentHashes []chainhash.Hash
+	for i, merkleCommitment := range sorted {
+		if merkleCommitment.ClientPosition != int32(i) {
+			return nil, errors.New(fmt.Sprintf("%s %s", ISSUE_COMMITMENTS_MISMATCH, merkleRoot.String()))
+		}
+		commitmentHashes = append(commitmentHashes, merkleCommitment.Commitment)
+	}
+	for _, version := range []int32{models.COMMITMENT_TREE_VERSION_LEGACY, models.COMMITMENT_TREE_VERSION_ZERO_PAD} {
+		commitment, errCommitment := models.NewCommitmentVersion(commitmentHashes, version)
+		if errCommitment == nil && commitment.GetCommitmentHash() == merkleRoot {
+			return commitment, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("%s %s", ISSUE_COMMITMENTS_MISMATCH, merkleRoot.String()))
+}
+
+// Check db merkle commitments match the commitment merkle commitments
+func matchMerkleCommitments(dbMerkleCommitments []models.CommitmentMerkleCommitment,
+	merkleCommitments []models.CommitmentMerkleCommitment) bool {
+	if len(dbMerkleCommitments) != len(merkleCommitments) {
+		return false
+	}
+	byPosition := make(map[int32]models.CommitmentMerkleCommitment)
+	for _, merkleCommitment := range dbMerkleCommitments {
+		byPosition[merkleCommitment.ClientPosition] = merkleCommitment
+	}
+	for _, merkleCommitment := range merkleCommitments {
+		if byPosition[merkleCommitment.ClientPosition] != merkleCommitment {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package staychain
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"mainstay/clients"
+	"mainstay/crypto"
+	"mainstay/models"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// Send test staychain tx spending prevTxid to the base pubkey tweaked with commitment
+func sendCheckerTx(t *testing.T, client *clients.MainChainClientFake, prevTxid chainhash.Hash,
+	pubkey *btcec.PublicKey, commitment *models.Commitment) chainhash.Hash {
+	merkleRoot := commitment.GetCommitmentHash()
+	addr, _ := crypto.GetAddressFromPubKey(crypto.TweakPubKey(pubkey, merkleRoot.CloneBytes()), &chaincfg.RegressionNetParams)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevTxid, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+	txid, errSend := client.SendRawTransaction(tx, false)
+	assert.Equal(t, nil, errSend)
+	return *txid
+}
+
+// Return issue names of db checker issues found and repaired
+func checkerIssues(issues []DbCheckerIssue) ([]string, []string) {
+	found, repaired := []string{}, []string{}
+	for _, issue := range issues {
+		found = append(found, issue.Issue)
+		if issue.Repaired {
+			repaired = append(repaired, issue.Issue)
+		}
+	}
+	return found, repaired
+}
+
+// Test DbChecker issues and repairs
+func TestDbChecker(t *testing.T) {
+	ctx := context.Background()
+	client := clients.NewMainChainClientFake(&chaincfg.RegressionNetParams)
+	db := server.NewDbFake()
+
+	key, _ := btcec.NewPrivateKey(btcec.S256())
+	pubkey := key.PubKey()
+	addr0, _ := crypto.GetAddressFromPubKey(pubkey, &chaincfg.RegressionNetParams)
+	txid0, _ := client.SendToAddress(addr0, 100000)
+	client.Generate(1)
+
+	// staychain of three attestations with the last two in the same block
+	var attestations []*models.Attestation
+	prevTxid := *txid0
+	for i := 0; i < 3; i++ {
+		commitment, _ := models.NewCommitment([]chainhash.Hash{chainhash.HashH([]byte{byte(i)})})
+		prevTxid = sendCheckerTx(t, client, prevTxid, pubkey, commitment)
+		attestations = append(attestations, models.NewAttestation(prevTxid, commitment))
+		if i < 2 {
+			client.Generate(1)
+		}
+	}
+	client.Generate(1)
+
+	// unconfirmed attestations without info
+	assert.Equal(t, nil, db.SaveAttestationUpdate(ctx, *attestations[0]))
+	assert.Equal(t, nil, db.SaveAttestationUpdate(ctx, *attestations[1]))
+	// attestation stored without proofs
+	commitment2, _ := attestations[2].Commitment()
+	assert.Equal(t, nil, db.SaveAttestation(ctx, *attestations[2]))
+	assert.Equal(t, nil, db.SaveMerkleCommitments(ctx, commitment2.GetMerkleCommitments()))
+
+	checker := NewDbChecker(client, db, *txid0, pubkey.SerializeCompressed(), nil)
+	issues, errCheck := checker.Check(ctx, false)
+	assert.Equal(t, nil, errCheck)
+	found, repaired := checkerIssues(issues)
+	assert.Equal(t, []string{ISSUE_CONFIRMATION, ISSUE_INFO_MISSING, ISSUE_CONFIRMATION, ISSUE_INFO_MISSING,
+		ISSUE_CONFIRMATION, ISSUE_INFO_MISSING, ISSUE_PROOFS_MISMATCH}, found)
+	assert.Equal(t, []string{}, repaired)
+
+	// repair confirms attestations with staychain block info
+	issues, errCheck = checker.Check(ctx, true)
+	assert.Equal(t, nil, errCheck)
+	found, repaired = checkerIssues(issues)
+	assert.Equal(t, found, repaired)
+	for _, attestation := range attestations {
+		dbAttestation, _ := db.GetAttestation(ctx, attestation.Txid)
+		assert.Equal(t, true, dbAttestation.Confirmed)
+		info, _ := db.GetAttestationInfo(ctx, attestation.Txid)
+		assert.Equal(t, attestation.Txid.String(), info.Txid)
+		spv, _ := info.SpvProof()
+		assert.Equal(t, true, spv.Prove(attestation.Txid))
+		proof, _ := db.GetMerkleProof(ctx, attestation.CommitmentHash(), 0)
+		assert.Equal(t, true, models.ProveMerkleProof(proof))
+	}
+	issues, _ = checker.Check(ctx, false)
+	assert.Equal(t, []DbCheckerIssue{}, issues)
+
+	// info of a different block is repaired
+	info, _ := db.GetAttestationInfo(ctx, attestations[1].Txid)
+	blockhash := info.Blockhash
+	info.Blockhash = chainhash.Hash{}.String()
+	assert.Equal(t, nil, db.SaveAttestationInfo(ctx, info))
+	issues, _ = checker.Check(ctx, true)
+	found, repaired = checkerIssues(issues)
+	assert.Equal(t, []string{ISSUE_INFO_MISMATCH}, repaired)
+	info, _ = db.GetAttestationInfo(ctx, attestations[1].Txid)
+	assert.Equal(t, blockhash, info.Blockhash)
+
+	// orphaned confirmed attestation is marked unconfirmed
+	orphanCommitment, _ := models.NewCommitment([]chainhash.Hash{chainhash.HashH([]byte{0xff})})
+	orphan := models.NewAttestation(chainhash.HashH([]byte{0xfe}), orphanCommitment)
+	orphan.Confirmed = true
+	assert.Equal(t, nil, db.SaveAttestation(ctx, *orphan))
+	issues, _ = checker.Check(ctx, true)
+	assert.Equal(t, []DbCheckerIssue{{orphan.Txid, ISSUE_ATTESTATION_ORPHANED, "confirmed", true, true}}, issues)
+	dbOrphan, _ := db.GetAttestation(ctx, orphan.Txid)
+	assert.Equal(t, false, dbOrphan.Confirmed)
+	issues, _ = checker.Check(ctx, true)
+	assert.Equal(t, []DbCheckerIssue{{orphan.Txid, ISSUE_ATTESTATION_ORPHANED, "unconfirmed", false, false}}, issues)
+
+	// pending attestation in the mempool is not orphaned
+	pendingCommitment, _ := models.NewCommitment([]chainhash.Hash{chainhash.HashH([]byte{0x03})})
+	pendingTxid := sendCheckerTx(t, client, prevTxid, pubkey, pendingCommitment)
+	assert.Equal(t, nil, db.SaveAttestationUpdate(ctx, *models.NewAttestation(pendingTxid, pendingCommitment)))
+	issues, _ = checker.Check(ctx, false)
+	assert.Equal(t, 1, len(issues))
+
+	// missing attestation and attestation with another merkle root cannot be repaired
+	client.Generate(1)
+	otherTxid := sendCheckerTx(t, client, pendingTxid, pubkey, orphanCommitment)
+	client.Generate(1)
+	assert.Equal(t, nil, db.SaveAttestationUpdate(ctx, *models.NewAttestation(otherTxid, pendingCommitment)))
+	missingTxid := sendCheckerTx(t, client, otherTxid, pubkey, pendingCommitment)
+	client.Generate(1)
+	issues, _ = checker.Check(ctx, true)
+	found, repaired = checkerIssues(issues)
+	assert.Equal(t, []string{ISSUE_CONFIRMATION, ISSUE_INFO_MISSING, ISSUE_TWEAK_MISMATCH, ISSUE_ATTESTATION_MISSING,
+		ISSUE_ATTESTATION_ORPHANED}, found)
+	assert.Equal(t, []string{ISSUE_CONFIRMATION, ISSUE_INFO_MISSING}, repaired)
+	assert.Equal(t, otherTxid, issues[2].Txid)
+	assert.Equal(t, missingTxid, issues[3].Txid)
+}
+
+// Test DbChecker with rounds pruned into the proof archive
+func TestDbCheckerProofArchive(t *testing.T) {
+	ctx := context.Background()
+	client := clients.NewMainChainClientFake(&chaincfg.RegressionNetParams)
+	db := server.NewDbFake()
+	dir, errDir := ioutil.TempDir("", "mainstay-proofarchive")
+	assert.Equal(t, nil, errDir)
+	defer os.RemoveAll(dir)
+
+	key, _ := btcec.NewPrivateKey(btcec.S256())
+	pubkey := key.PubKey()
+	addr0, _ := crypto.GetAddressFromPubKey(pubkey, &chaincfg.RegressionNetParams)
+	txid0, _ := client.SendToAddress(addr0, 100000)
+	client.Generate(1)
+
+	var attestations []*models.Attestation
+	prevTxid := *txid0
+	for i := 0; i < 2; i++ {
+		commitment, _ := models.NewCommitment([]chainhash.Hash{chainhash.HashH([]byte{byte(i)})})
+		prevTxid = sendCheckerTx(t, client, prevTxid, pubkey, commitment)
+		attestations = append(attestations, models.NewAttestation(prevTxid, commitment))
+		assert.Equal(t, nil, db.SaveAttestationUpdate(ctx, *attestations[i]))
+		client.Generate(1)
+	}
+	checker := NewDbChecker(client, db, *txid0, pubkey.SerializeCompressed(), nil)
+	_, errCheck := checker.Check(ctx, true)
+	assert.Equal(t, nil, errCheck)
+
+	// archive and prune all rounds
+	archive := server.NewProofArchive(dir)
+	dbServer := server.NewServer(ctx, db)
+	dbServer.SetProofArchive(archive)
+	pruned, errArchive := dbServer.ArchiveProofs(time.Now().Add(24 * time.Hour))
+	assert.Equal(t, nil, errArchive)
+	assert.Equal(t, 2, len(pruned))
+
+	// commitments of pruned rounds are unknown without the archive
+	issues, _ := checker.Check(ctx, false)
+	found, _ := checkerIssues(issues)
+	assert.Equal(t, []string{ISSUE_COMMITMENT_UNKNOWN, ISSUE_COMMITMENT_UNKNOWN}, found)
+
+	checker.SetProofArchive(archive)
+	issues, _ = checker.Check(ctx, false)
+	assert.Equal(t, []DbCheckerIssue{}, issues)
+
+	// repair of archived rounds keeps them pruned
+	info, _ := db.GetAttestationInfo(ctx, attestations[1].Txid)
+	info.Height = 0
+	assert.Equal(t, nil, db.SaveAttestationInfo(ctx, info))
+	issues, _ = checker.Check(ctx, true)
+	found, repaired := checkerIssues(issues)
+	assert.Equal(t, []string{ISSUE_INFO_MISMATCH}, found)
+	assert.Equal(t, found, repaired)
+	_, errProof := db.GetMerkleProof(ctx, attestations[1].CommitmentHash(), 0)
+	assert.NotEqual(t, nil, errProof)
+	issues, _ = checker.Check(ctx, false)
+	assert.Equal(t, []DbCheckerIssue{}, issues)
+}
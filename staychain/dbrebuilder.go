@@ -0,0 +1,137 @@
+package staychain
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"mainstay/models"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// error consts
+const (
+	ERROR_REBUILD_ARCHIVE_EMPTY = "Commitment archive has no records"
+	ERROR_REBUILD_SAVE          = "Could not save rebuilt db attestation"
+)
+
+// DbRebuilderResult struct
+// Staychain transactions rebuilt into the db and those without
+// a commitment archive record matching their tweaked output
+type DbRebuilderResult struct {
+	Rebuilt   []chainhash.Hash
+	Unmatched []chainhash.Hash
+}
+
+// DbRebuilder struct
+// Rebuilds the db of a lost service from the staychain and the commitment
+// archive. Walks the staychain forward from the start point txid0 and
+// matches each staychain transaction output to the archive record whose
+// merkle root tweaks the base pubkey, or base multisig script if set, into
+// that output. Confirmed attestations with their info, merkle commitments
+// and merkle proofs are saved for matched transactions, and the latest
+// client commitments are restored from the latest archive record
+// Client details and commitment history are not part of the archive
+type DbRebuilder struct {
+	client DbCheckerClient
+	db     server.Db
+	txid0  chainhash.Hash
+	pubkey []byte
+	script []byte
+}
+
+// Return new DbRebuilder instance for the staychain starting at txid0
+func NewDbRebuilder(client DbCheckerClient, db server.Db, txid0 chainhash.Hash, pubkey []byte, script []byte) DbRebuilder {
+	return DbRebuilder{client, db, txid0, pubkey, script}
+}
+
+// Rebuild db from the staychain and commitment archive records
+func (r *DbRebuilder) Rebuild(ctx context.Context, records []models.CommitmentArchiveRecord) (DbRebuilderResult, error) {
+	if len(records) == 0 {
+		return DbRebuilderResult{}, errors.New(ERROR_REBUILD_ARCHIVE_EMPTY)
+	}
+
+	// index archive records by tweaked output script
+	// records archived more than once for the same round are indexed once
+	recordsByScript := make(map[string]models.CommitmentArchiveRecord)
+	for _, record := range records {
+		pkScript, errScript := tweakedPkScript(r.pubkey, r.script, record.MerkleRoot)
+		if errScript != nil {
+			return DbRebuilderResult{}, errScript
+		}
+		recordsByScript[hex.EncodeToString(pkScript)] = record
+	}
+
+	staychain, errWalk := walkStaychain(r.client, r.txid0)
+	if errWalk != nil {
+		return DbRebuilderResult{}, errWalk
+	}
+	var result DbRebuilderResult
+	for _, stayTx := range staychain {
+		txid := stayTx.tx.TxHash()
+		if len(stayTx.tx.TxOut) != 1 {
+			result.Unmatched = append(result.Unmatched, txid)
+			continue
+		}
+		record, found := recordsByScript[hex.EncodeToString(stayTx.tx.TxOut[0].PkScript)]
+		if !found {
+			result.Unmatched = append(result.Unmatched, txid)
+			continue
+		}
+		commitment, errCommitment := record.Commitment()
+		if errCommitment != nil {
+			return DbRebuilderResult{}, errCommitment
+		}
+		if errSave := r.saveAttestation(ctx, stayTx, commitment); errSave != nil {
+			return DbRebuilderResult{}, errSave
+		}
+		result.Rebuilt = append(result.Rebuilt, txid)
+	}
+
+	if errSave := r.saveClientCommitments(ctx, records); errSave != nil {
+		return DbRebuilderResult{}, errSave
+	}
+	return result, nil
+}
+
+// Save confirmed attestation of staychain tx with its commitment
+func (r *DbRebuilder) saveAttestation(ctx context.Context, stayTx staychainTx, commitment *models.Commitment) error {
+	txid := stayTx.tx.TxHash()
+	attestation := models.NewAttestation(txid, commitment)
+	attestation.Tx = *stayTx.tx
+	attestation.Confirmed = true
+	attestation.Fee = stayTx.amountIn - stayTx.tx.TxOut[0].Value
+	attestation.Info = models.AttestationInfo{
+		Txid:      txid.String(),
+		Blockhash: stayTx.block.BlockHash().String(),
+		Height:    stayTx.height,
+		Amount:    stayTx.tx.TxOut[0].Value,
+		Time:      stayTx.block.Header.Timestamp.Unix(),
+	}
+	if errSpv := attestation.UpdateSpvInfo(stayTx.block); errSpv != nil {
+		return errors.New(fmt.Sprintf("%s %s: %v", ERROR_REBUILD_SAVE, txid.String(), errSpv))
+	}
+	if errSave := r.db.SaveAttestationUpdate(ctx, *attestation); errSave != nil {
+		return errors.New(fmt.Sprintf("%s %s: %v", ERROR_REBUILD_SAVE, txid.String(), errSave))
+	}
+	return nil
+}
+
+// Save latest client commitments from the latest archive record
+// Zero commitments of inactive slots are not saved
+func (r *DbRebuilder) saveClientCommitments(ctx context.Context, records []models.CommitmentArchiveRecord) error {
+	latest := records[len(records)-1]
+	for position, commitment := range latest.Commitments {
+		if commitment == (chainhash.Hash{}) {
+			continue
+		}
+		errSave := r.db.SaveClientCommitment(ctx, models.ClientCommitment{Commitment: commitment, ClientPosition: int32(position)})
+		if errSave != nil {
+			return errSave
+		}
+	}
+	return nil
+}
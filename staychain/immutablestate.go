@@ -0,0 +1,88 @@
+package staychain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+)
+
+// error consts
+const (
+	ERROR_STATE_POSITION   = "Proofs are for different slot positions"
+	ERROR_STATE_SLOT_PATH  = "Proof ops do not follow the slot path of position"
+	ERROR_STATE_BASE       = "Proofs are for staychains with different base script or pubkey"
+	ERROR_STATE_NOT_LINKED = "Staychain transactions are not linked by single input and output spends"
+)
+
+// RawTxClient interface
+// Client required to fetch staychain transactions
+// The btcd rpcclient.Client satisfies this interface directly
+type RawTxClient interface {
+	GetRawTransaction(*chainhash.Hash) (*btcutil.Tx, error)
+}
+
+// Verify Proof of Immutable State for two proof bundles
+// Both proofs need to be valid and for the same slot of the same staychain
+// and the staychain tx of one proof has to descend from the staychain tx of
+// the other through single input and single output spends, proving that
+// both commitments belong to a single non-branching history of the slot
+// The staychain is traversed backwards no further than the start point
+// of the traverser
+// Returns the number of staychain hops between the two proofs
+func VerifyImmutableState(bundleA models.ProofBundle, bundleB models.ProofBundle, traverser ChainTraverser) (int64, error) {
+	for _, bundle := range []models.ProofBundle{bundleA, bundleB} {
+		if errVerify := VerifyProofBundle(bundle); errVerify != nil {
+			return 0, errVerify
+		}
+		if errPath := verifySlotPath(bundle.Proof); errPath != nil {
+			return 0, errPath
+		}
+	}
+	if bundleA.Proof.ClientPosition != bundleB.Proof.ClientPosition {
+		return 0, errors.New(fmt.Sprintf("%s %d %d", ERROR_STATE_POSITION,
+			bundleA.Proof.ClientPosition, bundleB.Proof.ClientPosition))
+	}
+	if !bytes.Equal(bundleA.Script, bundleB.Script) || !bytes.Equal(bundleA.Pubkey, bundleB.Pubkey) {
+		return 0, errors.New(ERROR_STATE_BASE)
+	}
+
+	// try both directions as the order of the proofs is not known
+	// traversal failures are reported if neither direction is linked
+	hops, linked, errLink := traverser.TraverseTo(bundleB.Txid, bundleA.Txid)
+	if linked {
+		return hops, nil
+	}
+	hops, linked, errLinkReverse := traverser.TraverseTo(bundleA.Txid, bundleB.Txid)
+	if linked {
+		return hops, nil
+	} else if errLink != nil && errLinkReverse != nil {
+		return 0, errors.New(fmt.Sprintf("%v; %v", errLink, errLinkReverse))
+	} else if errLink != nil {
+		return 0, errLink
+	} else if errLinkReverse != nil {
+		return 0, errLinkReverse
+	}
+	return 0, errors.New(fmt.Sprintf("%s %s %s", ERROR_STATE_NOT_LINKED, bundleA.Txid.String(), bundleB.Txid.String()))
+}
+
+// Verify commitment merkle proof ops follow the path of the slot position
+// Each op appends when the position bit at that tree height is not set
+// and any tree height above the position bits always appends
+func verifySlotPath(proof models.CommitmentMerkleProof) error {
+	position := proof.ClientPosition
+	for _, op := range proof.Ops {
+		if op.Append != (position%2 == 0) {
+			return errors.New(fmt.Sprintf("%s %d", ERROR_STATE_SLOT_PATH, proof.ClientPosition))
+		}
+		position /= 2
+	}
+	if position != 0 {
+		return errors.New(fmt.Sprintf("%s %d", ERROR_STATE_SLOT_PATH, proof.ClientPosition))
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package staychain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// RawTxClient fake returning known transactions
+type rawTxClientFake struct {
+	txs map[chainhash.Hash]*wire.MsgTx
+}
+
+// Return known transaction
+func (c rawTxClientFake) GetRawTransaction(hash *chainhash.Hash) (*btcutil.Tx, error) {
+	tx, ok := c.txs[*hash]
+	if !ok {
+		return nil, errors.New("No such mempool or blockchain transaction")
+	}
+	return btcutil.NewTx(tx), nil
+}
+
+// Test Proof of Immutable State verification
+func TestVerifyImmutableState(t *testing.T) {
+	client := rawTxClientFake{make(map[chainhash.Hash]*wire.MsgTx)}
+
+	// staychain of three attestations with slot 1 updated in each
+	commitment0, _ := models.NewCommitment([]chainhash.Hash{*testHashX, *testHashY})
+	commitment1, _ := models.NewCommitment([]chainhash.Hash{*testHashX, *testHashZ})
+	commitment2, _ := models.NewCommitment([]chainhash.Hash{*testHashX, *testHashX, *testHashY})
+	tx0, script, _ := buildTestStaychainTx(*testHashZ, commitment0, true)
+	tx1, _, _ := buildTestStaychainTx(tx0.TxHash(), commitment1, true)
+	tx2, _, _ := buildTestStaychainTx(tx1.TxHash(), commitment2, true)
+	for _, tx := range []*wire.MsgTx{tx0, tx1, tx2} {
+		client.txs[tx.TxHash()] = tx
+	}
+	bundle0 := buildTestBundleForTx(t, tx0, commitment0, 1, script, nil)
+	bundle2 := buildTestBundleForTx(t, tx2, commitment2, 1, script, nil)
+	traverser := NewChainTraverser(client, tx0.TxHash())
+
+	// linked in either order and with itself
+	hops, errState := VerifyImmutableState(bundle0, bundle2, traverser)
+	assert.Equal(t, nil, errState)
+	assert.Equal(t, int64(2), hops)
+	hops, errState = VerifyImmutableState(bundle2, bundle0, traverser)
+	assert.Equal(t, nil, errState)
+	assert.Equal(t, int64(2), hops)
+	hops, errState = VerifyImmutableState(bundle2, bundle2, traverser)
+	assert.Equal(t, nil, errState)
+	assert.Equal(t, int64(0), hops)
+
+	// different slot positions
+	bundle2Pos0 := buildTestBundleForTx(t, tx2, commitment2, 0, script, nil)
+	_, errState = VerifyImmutableState(bundle0, bundle2Pos0, traverser)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d %d", ERROR_STATE_POSITION, 1, 0)), errState)
+
+	// different base pubkey
+	tx2Single, _, pubkey := buildTestStaychainTx(tx1.TxHash(), commitment2, false)
+	bundle2Single := buildTestBundleForTx(t, tx2Single, commitment2, 1, nil, pubkey)
+	_, errState = VerifyImmutableState(bundle0, bundle2Single, traverser)
+	assert.Equal(t, errors.New(ERROR_STATE_BASE), errState)
+
+	// intermediate tx with multiple outputs
+	txid1 := tx1.TxHash()
+	tx1.AddTxOut(wire.NewTxOut(1, []byte{}))
+	_, errState = VerifyImmutableState(bundle0, bundle2, traverser)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_TRAVERSE_OUTPUTS, txid1.String())), errState)
+	tx1.TxOut = tx1.TxOut[:1]
+
+	// intermediate tx with multiple inputs
+	tx1.AddTxIn(wire.NewTxIn(wire.NewOutPoint(testHashY, 0), []byte{0x00}, nil))
+	_, errState = VerifyImmutableState(bundle0, bundle2, traverser)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_TRAVERSE_INPUTS, txid1.String())), errState)
+	tx1.TxIn = tx1.TxIn[:1]
+
+	// intermediate tx missing
+	delete(client.txs, tx1.TxHash())
+	_, errState = VerifyImmutableState(bundle0, bundle2, traverser)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s: %s",
+		ERROR_TRAVERSE_TX_FETCH, tx1.TxHash().String(), "No such mempool or blockchain transaction")), errState)
+	client.txs[tx1.TxHash()] = tx1
+
+	// branching staychain spending the same parent is not traversed past the start point
+	tx2Branch, _, _ := buildTestStaychainTx(tx0.TxHash(), commitment2, true)
+	client.txs[tx2Branch.TxHash()] = tx2Branch
+	bundle2Branch := buildTestBundleForTx(t, tx2Branch, commitment2, 1, script, nil)
+	_, errState = VerifyImmutableState(bundle2, bundle2Branch, traverser)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s %s", ERROR_STATE_NOT_LINKED,
+		tx2.TxHash().String(), tx2Branch.TxHash().String())), errState)
+
+	// invalid slot path
+	invalid := bundle2
+	invalid.Proof.Ops = append([]models.CommitmentMerkleProofOp{}, invalid.Proof.Ops...)
+	invalid.Proof.Ops[0].Append = !invalid.Proof.Ops[0].Append
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_STATE_SLOT_PATH, 1)), verifySlotPath(invalid.Proof))
+}
@@ -0,0 +1,168 @@
+package staychain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"mainstay/crypto"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// error consts
+const (
+	ERROR_PROOF_MERKLE        = "Commitment merkle proof does not match merkle root"
+	ERROR_PROOF_TX_OUTPUT     = "Staychain transaction does not have a single output"
+	ERROR_PROOF_TWEAK         = "Staychain transaction output does not pay to base tweaked with merkle root"
+	ERROR_PROOF_BASE_SCRIPT   = "Invalid base multisig script"
+	ERROR_PROOF_SPV           = "Staychain transaction not included in block header merkle root"
+	ERROR_PROOF_HEADER_POW    = "Block header hash above header difficulty target"
+	ERROR_PROOF_HEADER_FETCH  = "Could not fetch block header"
+	ERROR_PROOF_HEADER_ORPHAN = "Block header not in best chain"
+)
+
+// format byte of uncompressed pubkeys, other 65 byte pubkeys are hybrid
+const PUBKEY_UNCOMPRESSED_FORMAT = 0x04
+
+// BlockHeaderClient interface
+// Client required to confirm proof headers against the best chain
+// The btcd rpcclient.Client satisfies this interface directly
+type BlockHeaderClient interface {
+	GetBlockHeaderVerbose(*chainhash.Hash) (*btcjson.GetBlockHeaderVerboseResult, error)
+}
+
+// Verify proof bundle end to end without any service or db access
+// - commitment merkle proof leads to the merkle root
+// - merkle root tweaks base script or pubkey into staychain tx output
+// - staychain tx is included in the block header via the SPV proof
+// - block header hash satisfies the header difficulty target
+func VerifyProofBundle(bundle models.ProofBundle) error {
+	if errValidate := bundle.Validate(); errValidate != nil {
+		return errValidate
+	}
+	if !models.ProveMerkleProof(bundle.Proof) {
+		return errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_MERKLE, bundle.Proof.MerkleRoot.String()))
+	}
+	if errTweak := verifyProofBundleTweak(bundle); errTweak != nil {
+		return errTweak
+	}
+	if !bundle.Spv.Prove(bundle.Txid) {
+		return errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_SPV, bundle.Spv.Blockhash().String()))
+	}
+	blockhash := bundle.Spv.Blockhash()
+	if blockchain.HashToBig(&blockhash).Cmp(blockchain.CompactToBig(bundle.Spv.Header.Bits)) > 0 {
+		return errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_HEADER_POW, blockhash.String()))
+	}
+	return nil
+}
+
+// Verify proof bundle header is part of the best chain of the client
+// Returns the height of the block header in the best chain
+func VerifyProofBundleBestChain(bundle models.ProofBundle, client BlockHeaderClient) (int32, error) {
+	blockhash := bundle.Spv.Blockhash()
+	header, errHeader := client.GetBlockHeaderVerbose(&blockhash)
+	if errHeader != nil {
+		return 0, errors.New(fmt.Sprintf("%s %s: %v", ERROR_PROOF_HEADER_FETCH, blockhash.String(), errHeader))
+	}
+	if header.Confirmations < 1 {
+		return 0, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_HEADER_ORPHAN, blockhash.String()))
+	}
+	return header.Height, nil
+}
+
+// Verify staychain tx output pays to the base script or pubkey
+// tweaked with the merkle root of the commitment merkle proof
+func verifyProofBundleTweak(bundle models.ProofBundle) error {
+	return verifyTweak(bundle.Pubkey, bundle.Script, bundle.Proof.MerkleRoot, bundle.Tx)
+}
+
+// Verify staychain tx output pays to the base multisig script, or
+// base pubkey if no script is provided, tweaked with the merkle root
+func verifyTweak(pubkey []byte, script []byte, merkleRoot chainhash.Hash, tx wire.MsgTx) error {
+	if len(tx.TxOut) != 1 {
+		return errors.New(ERROR_PROOF_TX_OUTPUT)
+	}
+	pkScript, errScript := tweakedPkScript(pubkey, script, merkleRoot)
+	if errScript != nil {
+		return errScript
+	}
+	if !bytes.Equal(pkScript, tx.TxOut[0].PkScript) {
+		return errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_TWEAK, merkleRoot.String()))
+	}
+	return nil
+}
+
+// Return output script paying to the base multisig script, or base
+// pubkey if no script is provided, tweaked with the merkle root
+// The output script is network independent so main net params are used
+func tweakedPkScript(pubkey []byte, script []byte, merkleRoot chainhash.Hash) ([]byte, error) {
+	tweak := merkleRoot.CloneBytes()
+
+	var addr btcutil.Address
+	if len(script) == 0 {
+		basePub, errPub := btcec.ParsePubKey(pubkey, btcec.S256())
+		if errPub != nil {
+			return nil, errPub
+		}
+		tweakedAddr, errAddr := crypto.GetAddressFromPubKey(crypto.TweakPubKey(basePub, tweak), &chaincfg.MainNetParams)
+		if errAddr != nil {
+			return nil, errAddr
+		}
+		addr = tweakedAddr
+	} else {
+		redeemScript, errScript := tweakedMultisigScript(script, tweak)
+		if errScript != nil {
+			return nil, errScript
+		}
+		scriptAddr, errAddr := btcutil.NewAddressScriptHash(redeemScript, &chaincfg.MainNetParams)
+		if errAddr != nil {
+			return nil, errAddr
+		}
+		addr = scriptAddr
+	}
+	return txscript.PayToAddrScript(addr)
+}
+
+// Return base multisig script with each pubkey tweaked with the tweak
+// Tweaked pubkeys keep the serialization format of the base pubkeys
+func tweakedMultisigScript(script []byte, tweak []byte) ([]byte, error) {
+	if txscript.GetScriptClass(script) != txscript.MultiSigTy {
+		return nil, errors.New(ERROR_PROOF_BASE_SCRIPT)
+	}
+	numPubKeys, numSigs, errStats := txscript.CalcMultiSigStats(script)
+	if errStats != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_BASE_SCRIPT, errStats))
+	}
+	pushes, errPush := txscript.PushedData(script)
+	if errPush != nil || len(pushes) != numPubKeys {
+		return nil, errors.New(ERROR_PROOF_BASE_SCRIPT)
+	}
+
+	builder := txscript.NewScriptBuilder().AddInt64(int64(numSigs))
+	for _, push := range pushes {
+		basePub, errPub := btcec.ParsePubKey(push, btcec.S256())
+		if errPub != nil {
+			return nil, errors.New(fmt.Sprintf("%s %v", ERROR_PROOF_BASE_SCRIPT, errPub))
+		}
+		tweakedPub := crypto.TweakPubKey(basePub, tweak)
+		switch {
+		case len(push) == btcec.PubKeyBytesLenCompressed:
+			builder.AddData(tweakedPub.SerializeCompressed())
+		case push[0] == PUBKEY_UNCOMPRESSED_FORMAT:
+			builder.AddData(tweakedPub.SerializeUncompressed())
+		default:
+			builder.AddData(tweakedPub.SerializeHybrid())
+		}
+	}
+	builder.AddInt64(int64(numPubKeys)).AddOp(txscript.OP_CHECKMULTISIG)
+	return builder.Script()
+}
@@ -0,0 +1,206 @@
+package staychain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"mainstay/crypto"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// BlockHeaderClient fake returning confirmations for known headers
+type blockHeaderClientFake struct {
+	headers map[chainhash.Hash]int64
+}
+
+// Return verbose header result for known headers
+func (c blockHeaderClientFake) GetBlockHeaderVerbose(hash *chainhash.Hash) (*btcjson.GetBlockHeaderVerboseResult, error) {
+	confirmations, ok := c.headers[*hash]
+	if !ok {
+		return nil, errors.New("Block not found")
+	}
+	return &btcjson.GetBlockHeaderVerboseResult{Hash: hash.String(), Confirmations: confirmations, Height: 100}, nil
+}
+
+// Test commitment hashes
+var testHashX, _ = chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+var testHashY, _ = chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+var testHashZ, _ = chainhash.NewHashFromStr("ccccccc1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+// Build test staychain tx spending prevTxid and paying to the base
+// script or pubkey tweaked with the merkle root of the commitment
+// Returns the tx along with the base script or pubkey
+func buildTestStaychainTx(prevTxid chainhash.Hash, commitment *models.Commitment, multisig bool) (*wire.MsgTx, []byte, []byte) {
+	root := commitment.GetCommitmentHash()
+	_, pub0 := btcec.PrivKeyFromBytes(btcec.S256(), bytes.Repeat([]byte{1}, 32))
+	_, pub1 := btcec.PrivKeyFromBytes(btcec.S256(), bytes.Repeat([]byte{2}, 32))
+	var script, pubkey []byte
+	var addr btcutil.Address
+	if multisig {
+		_, scriptHex := crypto.CreateMultisig([]*btcec.PublicKey{pub0, pub1}, 1, &chaincfg.RegressionNetParams)
+		script, _ = hex.DecodeString(scriptHex)
+		addr, _ = crypto.CreateMultisig([]*btcec.PublicKey{crypto.TweakPubKey(pub0, root.CloneBytes()),
+			crypto.TweakPubKey(pub1, root.CloneBytes())}, 1, &chaincfg.RegressionNetParams)
+	} else {
+		pubkey = pub0.SerializeCompressed()
+		addr, _ = crypto.GetAddressFromPubKey(crypto.TweakPubKey(pub0, root.CloneBytes()), &chaincfg.RegressionNetParams)
+	}
+	pkScript, _ := txscript.PayToAddrScript(addr)
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevTxid, 0), []byte{0x00}, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+	return tx, script, pubkey
+}
+
+// Build test proof bundle for the commitment at position in the staychain tx
+// The tx is included in a block along with a coinbase tx
+func buildTestBundleForTx(t *testing.T, tx *wire.MsgTx, commitment *models.Commitment, position int,
+	script []byte, pubkey []byte) models.ProofBundle {
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_TRUE}))
+	txs := []*btcutil.Tx{btcutil.NewTx(coinbase), btcutil.NewTx(tx)}
+	store := blockchain.BuildMerkleTreeStore(txs, false)
+
+	// find nonce satisfying regtest difficulty target
+	header := wire.NewBlockHeader(1, &chainhash.Hash{}, store[len(store)-1], 0x207fffff, 0)
+	for {
+		blockhash := header.BlockHash()
+		if blockchain.HashToBig(&blockhash).Cmp(blockchain.CompactToBig(header.Bits)) <= 0 {
+			break
+		}
+		header.Nonce++
+	}
+	block := wire.NewMsgBlock(header)
+	block.AddTransaction(coinbase)
+	block.AddTransaction(tx)
+	spvProof, _ := models.NewSpvProof(block, tx.TxHash())
+
+	slotProof := models.SlotProof{Txid: tx.TxHash(), Confirmed: true, Proof: commitment.GetMerkleProofs()[position], Spv: spvProof}
+	bundle, errBundle := models.NewProofBundle(slotProof, *tx, script, pubkey)
+	assert.Equal(t, nil, errBundle)
+	return *bundle
+}
+
+// Build test proof bundle for a staychain tx paying to the base script or pubkey tweaked with the merkle root
+func buildTestBundle(t *testing.T, multisig bool) models.ProofBundle {
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*testHashX, *testHashY, *testHashZ})
+	tx, script, pubkey := buildTestStaychainTx(*testHashX, commitment, multisig)
+	return buildTestBundleForTx(t, tx, commitment, 1, script, pubkey)
+}
+
+// Test proof bundle verification
+func TestVerifyProofBundle(t *testing.T) {
+	for _, multisig := range []bool{true, false} {
+		bundle := buildTestBundle(t, multisig)
+		assert.Equal(t, nil, VerifyProofBundle(bundle))
+
+		// invalid commitment merkle proof
+		invalid := bundle
+		invalid.Proof.Commitment = chainhash.Hash{}
+		assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_MERKLE, bundle.Proof.MerkleRoot.String())),
+			VerifyProofBundle(invalid))
+
+		// proof for a different merkle root
+		invalid = bundle
+		invalid.Proof = models.CommitmentMerkleProof{MerkleRoot: bundle.Proof.Commitment, Commitment: bundle.Proof.Commitment}
+		assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_TWEAK, bundle.Proof.Commitment.String())),
+			VerifyProofBundle(invalid))
+
+		// invalid spv proof
+		invalid = bundle
+		invalid.Spv.MerkleBranch = []chainhash.Hash{chainhash.Hash{}}
+		assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_SPV, bundle.Spv.Blockhash().String())),
+			VerifyProofBundle(invalid))
+
+		// header hash above target
+		invalid = bundle
+		invalid.Spv.Header.Bits = 0x1d00ffff
+		assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_HEADER_POW, invalid.Spv.Blockhash().String())),
+			VerifyProofBundle(invalid))
+	}
+
+	// staychain tx with multiple outputs
+	bundle := buildTestBundle(t, true)
+	bundle.Tx = *bundle.Tx.Copy()
+	bundle.Tx.AddTxOut(wire.NewTxOut(1, []byte{txscript.OP_TRUE}))
+	bundle.Txid = bundle.Tx.TxHash()
+	assert.Equal(t, errors.New(ERROR_PROOF_TX_OUTPUT), VerifyProofBundle(bundle))
+}
+
+// Build test multisig script of the pubkeys with each pubkey serialized in the given format
+func buildTestMultisigScript(pubs []*btcec.PublicKey, numSigs int, compressed bool) []byte {
+	var addrs []*btcutil.AddressPubKey
+	for _, pub := range pubs {
+		serialized := pub.SerializeCompressed()
+		if !compressed {
+			serialized = pub.SerializeUncompressed()
+		}
+		addr, _ := btcutil.NewAddressPubKey(serialized, &chaincfg.RegressionNetParams)
+		addrs = append(addrs, addr)
+	}
+	script, _ := txscript.MultiSigScript(addrs, numSigs)
+	return script
+}
+
+// Test proof bundle verification of multisig scripts with more than 9 keys and uncompressed keys
+func TestVerifyProofBundleMultisigScript(t *testing.T) {
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*testHashX, *testHashY, *testHashZ})
+	root := commitment.GetCommitmentHash()
+
+	var pubs, tweakedPubs []*btcec.PublicKey
+	for i := 1; i <= 11; i++ {
+		_, pub := btcec.PrivKeyFromBytes(btcec.S256(), bytes.Repeat([]byte{byte(i)}, 32))
+		pubs = append(pubs, pub)
+		tweakedPubs = append(tweakedPubs, crypto.TweakPubKey(pub, root.CloneBytes()))
+	}
+
+	for _, compressed := range []bool{true, false} {
+		script := buildTestMultisigScript(pubs, 10, compressed)
+		addr, _ := btcutil.NewAddressScriptHash(buildTestMultisigScript(tweakedPubs, 10, compressed),
+			&chaincfg.RegressionNetParams)
+		pkScript, _ := txscript.PayToAddrScript(addr)
+
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(testHashX, 0), []byte{0x00}, nil))
+		tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+		bundle := buildTestBundleForTx(t, tx, commitment, 1, script, nil)
+		assert.Equal(t, nil, VerifyProofBundle(bundle))
+	}
+
+	// base script that is not a multisig script
+	_, errScript := tweakedPkScript(nil, []byte{txscript.OP_1, txscript.OP_CHECKMULTISIG}, root)
+	assert.Equal(t, errors.New(ERROR_PROOF_BASE_SCRIPT), errScript)
+}
+
+// Test proof bundle header best chain verification
+func TestVerifyProofBundleBestChain(t *testing.T) {
+	bundle := buildTestBundle(t, true)
+	blockhash := bundle.Spv.Blockhash()
+
+	client := blockHeaderClientFake{map[chainhash.Hash]int64{blockhash: 6}}
+	height, errChain := VerifyProofBundleBestChain(bundle, client)
+	assert.Equal(t, nil, errChain)
+	assert.Equal(t, int32(100), height)
+
+	client.headers[blockhash] = -1
+	_, errChain = VerifyProofBundleBestChain(bundle, client)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s", ERROR_PROOF_HEADER_ORPHAN, blockhash.String())), errChain)
+
+	delete(client.headers, blockhash)
+	_, errChain = VerifyProofBundleBestChain(bundle, client)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %s: %s", ERROR_PROOF_HEADER_FETCH, blockhash.String(), "Block not found")), errChain)
+}
@@ -0,0 +1,12 @@
+/*
+Package webhook implements client webhook notifications of confirmed attestations.
+
+Pending deliveries are recorded in the db along with each confirmed
+attestation. A webhook service reads pending deliveries from the db and
+posts the slot proof of each client in the confirmed attestation to the
+client webhook url, signed along with a timestamp with the client webhook
+secret. Failed deliveries are retried with backoff and kept in the db for
+redelivery. Deliveries are only delivered and redelivered to the client
+that was notified, identified by its pubkey.
+*/
+package webhook
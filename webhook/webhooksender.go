@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mainstay/models"
+	"mainstay/requestapi"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// request headers
+const (
+	HEADER_WEBHOOK_SIGNATURE = "X-MAINSTAY-SIGNATURE"
+	HEADER_WEBHOOK_TIMESTAMP = "X-MAINSTAY-TIMESTAMP"
+)
+
+// error consts
+const (
+	ERROR_WEBHOOK_NOT_SET        = "No webhook set for position"
+	ERROR_WEBHOOK_STATUS         = "Webhook response status"
+	ERROR_WEBHOOK_CLIENT_CHANGED = "Webhook client changed for position"
+)
+
+// timeout of a single webhook request
+const WEBHOOK_TIMEOUT = 10 * time.Second
+
+// maximum age of a webhook signature timestamp accepted by VerifySignature
+const WEBHOOK_SIGNATURE_TOLERANCE = 5 * time.Minute
+
+// Return hex HMAC-SHA256 signature of the webhook timestamp and payload with
+// the client webhook secret. The signed message is the unix timestamp in
+// decimal, followed by a '.' and the payload
+func Signature(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify webhook signature of the timestamp and payload with the client
+// webhook secret. Timestamps further than WEBHOOK_SIGNATURE_TOLERANCE from
+// now are rejected, so that recorded notifications cannot be replayed
+func VerifySignature(secret string, timestamp int64, payload []byte, signature string, now time.Time) bool {
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > WEBHOOK_SIGNATURE_TOLERANCE || age < -WEBHOOK_SIGNATURE_TOLERANCE {
+		return false
+	}
+	return hmac.Equal([]byte(Signature(secret, timestamp, payload)), []byte(signature))
+}
+
+// WebhookSender struct
+// Posts the slot proof of a client in an attestation to the client webhook
+// The payload is the slot proof response of the request api and is signed
+// along with the HEADER_WEBHOOK_TIMESTAMP header of the request in the
+// HEADER_WEBHOOK_SIGNATURE header with the client webhook secret
+type WebhookSender struct {
+	server *server.Server
+	client *http.Client
+}
+
+// Return new WebhookSender instance
+func NewWebhookSender(server *server.Server) *WebhookSender {
+	return &WebhookSender{server, &http.Client{Timeout: WEBHOOK_TIMEOUT}}
+}
+
+// Send slot proof of the client in the attestation with txid to the client webhook
+// Any response status other than 2xx is a failed delivery
+func (s *WebhookSender) Send(details models.ClientDetails, txid chainhash.Hash) error {
+	if details.WebhookUrl == "" {
+		return errors.New(fmt.Sprintf("%s %d", ERROR_WEBHOOK_NOT_SET, details.ClientPosition))
+	}
+	slotProof, errProof := s.server.GetAttestationSlotProof(txid, details.ClientPosition)
+	if errProof != nil {
+		return errProof
+	}
+	payload, errJson := json.Marshal(requestapi.NewSlotProofResponse(slotProof))
+	if errJson != nil {
+		return errJson
+	}
+
+	req, errReq := http.NewRequest(http.MethodPost, details.WebhookUrl, bytes.NewReader(payload))
+	if errReq != nil {
+		return errReq
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HEADER_WEBHOOK_TIMESTAMP, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HEADER_WEBHOOK_SIGNATURE, Signature(details.WebhookSecret, timestamp, payload))
+	resp, errResp := s.client.Do(req)
+	if errResp != nil {
+		return errResp
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New(fmt.Sprintf("%s %d", ERROR_WEBHOOK_STATUS, resp.StatusCode))
+	}
+	return nil
+}
+
+// Redeliver failed webhook deliveries of the client position, or of all
+// positions for a negative position, to the current client webhooks
+// Pending deliveries are left to the webhook service. Deliveries of a client whose position is now held by a client with a
+// different pubkey are skipped, so that a reused position is not sent the
+// notifications of a revoked client. Redelivered records are marked
+// delivered and records failing again are updated with the attempt.
+// Returns the updated records
+func (s *WebhookSender) Redeliver(position int32) ([]models.WebhookDelivery, error) {
+	deliveries, errDeliveries := s.server.GetWebhookDeliveries(false)
+	if errDeliveries != nil {
+		return nil, errDeliveries
+	}
+	clientDetails, errDetails := s.server.GetClientDetails()
+	if errDetails != nil {
+		return nil, errDetails
+	}
+	detailsByPosition := make(map[int32]models.ClientDetails)
+	for _, details := range clientDetails {
+		detailsByPosition[details.ClientPosition] = details
+	}
+
+	redelivered := []models.WebhookDelivery{}
+	for _, delivery := range deliveries {
+		if delivery.Pending() || position >= 0 && delivery.ClientPosition != position {
+			continue
+		}
+		details, found := detailsByPosition[delivery.ClientPosition]
+		if !found {
+			details = models.ClientDetails{ClientPosition: delivery.ClientPosition}
+		}
+		if details.Pubkey != delivery.Pubkey {
+			continue
+		}
+		if errSend := s.Send(details, delivery.Txid); errSend != nil {
+			delivery.Attempts++
+			delivery.LastError = errSend.Error()
+			delivery.FailedAt = time.Now().UTC()
+		} else {
+			delivery.Delivered = true
+		}
+		if details.WebhookUrl != "" {
+			delivery.Url = details.WebhookUrl
+		}
+		if errSave := s.server.SaveWebhookDelivery(delivery); errSave != nil {
+			return redelivered, errSave
+		}
+		redelivered = append(redelivered, delivery)
+	}
+	return redelivered, nil
+}
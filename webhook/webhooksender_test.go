@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"mainstay/models"
+	"mainstay/requestapi"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookRequest received by a test webhook
+type webhookRequest struct {
+	signature string
+	timestamp int64
+	payload   []byte
+}
+
+// Return test webhook replying with the status and sending received requests to the channel
+func newTestWebhook(status int, requests chan webhookRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := ioutil.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HEADER_WEBHOOK_TIMESTAMP), 10, 64)
+		requests <- webhookRequest{r.Header.Get(HEADER_WEBHOOK_SIGNATURE), timestamp, payload}
+		w.WriteHeader(status)
+	}))
+}
+
+// Return new server with an attestation of two client commitments
+func newTestWebhookServer() (*server.Server, chainhash.Hash) {
+	srv := server.NewServer(context.Background(), server.NewDbFake())
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY})
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	srv.UpdateLatestAttestation(*models.NewAttestation(*txid, commitment))
+	srv.SignupClient("token0", "pubkey0")
+	srv.SignupClient("token1", "pubkey1")
+	return srv, *txid
+}
+
+// Test WebhookSender sending signed slot proofs
+func TestWebhookSenderSend(t *testing.T) {
+	srv, txid := newTestWebhookServer()
+	sender := NewWebhookSender(srv)
+	requests := make(chan webhookRequest, 1)
+	webhook := newTestWebhook(http.StatusOK, requests)
+	defer webhook.Close()
+
+	// no webhook set
+	details := models.ClientDetails{ClientPosition: 1}
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_WEBHOOK_NOT_SET, 1)), sender.Send(details, txid))
+
+	// slot proof payload signed with webhook secret along with the request timestamp
+	details, _ = srv.SetClientWebhook(1, webhook.URL, "secret1")
+	assert.Equal(t, nil, sender.Send(details, txid))
+	request := <-requests
+	assert.Equal(t, Signature("secret1", request.timestamp, request.payload), request.signature)
+	assert.Equal(t, 64, len(request.signature))
+	assert.Equal(t, true, VerifySignature("secret1", request.timestamp, request.payload, request.signature, time.Now()))
+	var response requestapi.SlotProofResponse
+	assert.Equal(t, nil, json.Unmarshal(request.payload, &response))
+	slotProof, _ := srv.GetAttestationSlotProof(txid, 1)
+	assert.Equal(t, requestapi.NewSlotProofResponse(slotProof), response)
+
+	// missing attestation and failed response status
+	_, errProof := srv.GetAttestationSlotProof(chainhash.Hash{}, 1)
+	assert.Equal(t, errProof, sender.Send(details, chainhash.Hash{}))
+	failingWebhook := newTestWebhook(http.StatusInternalServerError, requests)
+	defer failingWebhook.Close()
+	details.WebhookUrl = failingWebhook.URL
+	assert.Equal(t, errors.New(fmt.Sprintf("%s %d", ERROR_WEBHOOK_STATUS, 500)), sender.Send(details, txid))
+	<-requests
+}
+
+// Test webhook signatures of the timestamp and payload
+func TestWebhookSignature(t *testing.T) {
+	payload := []byte(`{"txid":"1111"}`)
+	timestamp := int64(1542121293)
+	now := time.Unix(timestamp, 0)
+	signature := Signature("secret", timestamp, payload)
+	assert.Equal(t, true, VerifySignature("secret", timestamp, payload, signature, now))
+	assert.Equal(t, true, VerifySignature("secret", timestamp, payload, signature, now.Add(WEBHOOK_SIGNATURE_TOLERANCE)))
+
+	// signatures of other secrets, payloads or timestamps are rejected
+	assert.Equal(t, false, VerifySignature("secret0", timestamp, payload, signature, now))
+	assert.Equal(t, false, VerifySignature("secret", timestamp, []byte(`{"txid":"2222"}`), signature, now))
+	assert.Equal(t, false, VerifySignature("secret", timestamp+1, payload, signature, now))
+	assert.NotEqual(t, signature, Signature("secret", timestamp+1, payload))
+
+	// replayed notifications with an expired timestamp are rejected
+	assert.Equal(t, false, VerifySignature("secret", timestamp, payload, signature,
+		now.Add(WEBHOOK_SIGNATURE_TOLERANCE+time.Second)))
+	assert.Equal(t, false, VerifySignature("secret", timestamp, payload, signature,
+		now.Add(-WEBHOOK_SIGNATURE_TOLERANCE-time.Second)))
+}
+
+// Test WebhookSender redelivery of failed deliveries
+func TestWebhookSenderRedeliver(t *testing.T) {
+	srv, txid := newTestWebhookServer()
+	sender := NewWebhookSender(srv)
+	requests := make(chan webhookRequest, 2)
+	failingWebhook := newTestWebhook(http.StatusInternalServerError, requests)
+	defer failingWebhook.Close()
+	webhook := newTestWebhook(http.StatusOK, requests)
+	defer webhook.Close()
+
+	failedAt := time.Unix(1542121293, 0).UTC()
+	delivery0 := models.WebhookDelivery{Txid: txid, ClientPosition: 0, Pubkey: "pubkey0", Url: failingWebhook.URL,
+		Attempts: 5, LastError: "error0", FailedAt: failedAt}
+	delivery1 := models.WebhookDelivery{Txid: txid, ClientPosition: 1, Pubkey: "pubkey1", Url: failingWebhook.URL,
+		Attempts: 5, LastError: "error1", FailedAt: failedAt}
+	srv.SaveWebhookDelivery(delivery0)
+	srv.SaveWebhookDelivery(delivery1)
+	pendingDelivery := models.WebhookDelivery{Txid: chainhash.Hash{}, ClientPosition: 1, Pubkey: "pubkey1",
+		Url: failingWebhook.URL}
+	srv.SaveWebhookDelivery(pendingDelivery)
+
+	// redelivery to failing webhook of position 1 only
+	srv.SetClientWebhook(1, failingWebhook.URL, "secret1")
+	redelivered, errRedeliver := sender.Redeliver(1)
+	assert.Equal(t, nil, errRedeliver)
+	<-requests
+	assert.Equal(t, 1, len(redelivered))
+	assert.Equal(t, int32(6), redelivered[0].Attempts)
+	assert.Equal(t, fmt.Sprintf("%s %d", ERROR_WEBHOOK_STATUS, 500), redelivered[0].LastError)
+	assert.Equal(t, true, redelivered[0].FailedAt.After(failedAt))
+	assert.Equal(t, false, redelivered[0].Delivered)
+
+	// redelivery of all positions to the current webhooks
+	srv.SetClientWebhook(1, webhook.URL, "secret1")
+	redelivered, errRedeliver = sender.Redeliver(-1)
+	assert.Equal(t, nil, errRedeliver)
+	<-requests
+	assert.Equal(t, 2, len(redelivered))
+	assert.Equal(t, int32(0), redelivered[0].ClientPosition)
+	assert.Equal(t, fmt.Sprintf("%s %d", ERROR_WEBHOOK_NOT_SET, 0), redelivered[0].LastError)
+	assert.Equal(t, failingWebhook.URL, redelivered[0].Url)
+	assert.Equal(t, int32(1), redelivered[1].ClientPosition)
+	assert.Equal(t, true, redelivered[1].Delivered)
+	assert.Equal(t, webhook.URL, redelivered[1].Url)
+
+	// pending deliveries are left to the webhook service
+	pending, _ := srv.GetWebhookDeliveries(false)
+	assert.Equal(t, []models.WebhookDelivery{pendingDelivery, redelivered[0]}, pending)
+	delivered, _ := srv.GetWebhookDeliveries(true)
+	assert.Equal(t, []models.WebhookDelivery{redelivered[1]}, delivered)
+
+	// deliveries of a revoked client are not sent to the client reusing its position
+	srv.SetClientStatus(0, models.CLIENT_STATUS_REVOKED)
+	srv.SignupClient("token2", "pubkey2")
+	srv.SetClientWebhook(0, webhook.URL, "secret2")
+	redelivered, errRedeliver = sender.Redeliver(0)
+	assert.Equal(t, nil, errRedeliver)
+	assert.Equal(t, []models.WebhookDelivery{}, redelivered)
+	assert.Equal(t, 0, len(requests))
+	skipped, _ := srv.GetWebhookDeliveries(false)
+	assert.Equal(t, pending, skipped)
+}
@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"mainstay/models"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// webhook delivery schedule
+const (
+	// delivery attempts before a notification is recorded as failed
+	WEBHOOK_ATTEMPTS = 5
+
+	// waiting time before the second attempt, doubled after each failed attempt
+	WEBHOOK_BACKOFF = 10 * time.Second
+
+	// interval between checks for pending deliveries when no event is published
+	WEBHOOK_POLL_INTERVAL = time.Minute
+)
+
+// backoff before the second attempt - can be replaced for testing
+var webhookBackoff = WEBHOOK_BACKOFF
+
+// webhookDeliveryKey of a delivery by attestation txid and client position
+type webhookDeliveryKey struct {
+	txid     chainhash.Hash
+	position int32
+}
+
+// WebhookService struct
+// Notifies client webhooks of confirmed attestations. Pending deliveries
+// are saved in the db along with each confirmed attestation and are read
+// from the db whenever an event is published in the server event feed and
+// every WEBHOOK_POLL_INTERVAL, so that confirmations are notified even if
+// events are missed or the service was not running. Clients are notified
+// concurrently, with WEBHOOK_ATTEMPTS attempts each, and deliveries are
+// recorded delivered or kept for redelivery if failed
+type WebhookService struct {
+	ctx      context.Context
+	wg       *sync.WaitGroup
+	server   *server.Server
+	sender   *WebhookSender
+	mtx      sync.Mutex
+	inFlight map[webhookDeliveryKey]bool
+}
+
+// NewWebhookService returns a pointer to a WebhookService instance
+func NewWebhookService(ctx context.Context, wg *sync.WaitGroup, server *server.Server) *WebhookService {
+	return &WebhookService{ctx: ctx, wg: wg, server: server, sender: NewWebhookSender(server),
+		inFlight: make(map[webhookDeliveryKey]bool)}
+}
+
+// Main Run method
+func (w *WebhookService) Run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(WEBHOOK_POLL_INTERVAL)
+	defer ticker.Stop()
+	cursor := w.server.Events().Cursor()
+	for {
+		// events only wake the service, as pending deliveries are read from the db
+		events, notify, errEvents := w.server.Events().Since(cursor)
+		if errEvents != nil {
+			cursor = w.server.Events().Cursor()
+			continue
+		}
+		if len(events) > 0 {
+			cursor = events[len(events)-1].Id
+		}
+		w.deliverPending()
+
+		select {
+		case <-notify:
+		case <-ticker.C:
+		case <-w.ctx.Done():
+			log.Println("Shutting down webhook service...")
+			return
+		}
+	}
+}
+
+// Start delivering pending deliveries from the db that are not already being delivered
+func (w *WebhookService) deliverPending() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	deliveries, errDeliveries := w.server.GetPendingWebhookDeliveries()
+	if errDeliveries != nil {
+		log.Printf("*WebhookService* %v\n", errDeliveries)
+		return
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	clientDetails, errDetails := w.server.GetClientDetails()
+	if errDetails != nil {
+		log.Printf("*WebhookService* %v\n", errDetails)
+		return
+	}
+	detailsByPosition := make(map[int32]models.ClientDetails)
+	for _, details := range clientDetails {
+		detailsByPosition[details.ClientPosition] = details
+	}
+
+	for _, delivery := range deliveries {
+		key := webhookDeliveryKey{delivery.Txid, delivery.ClientPosition}
+		if w.inFlight[key] {
+			continue
+		}
+		w.inFlight[key] = true
+		w.wg.Add(1)
+		go w.deliver(delivery, detailsByPosition[delivery.ClientPosition])
+	}
+}
+
+// Deliver slot proof to the client webhook with retries and backoff
+// The delivery record is updated once delivered or once all attempts fail.
+// Deliveries of a client no longer holding the position or without a
+// webhook fail without any attempt. Deliveries interrupted by shutdown
+// are kept pending with their attempts and resumed by the next run
+func (w *WebhookService) deliver(delivery models.WebhookDelivery, details models.ClientDetails) {
+	defer w.wg.Done()
+
+	var errSend error
+	if details.Pubkey != delivery.Pubkey {
+		errSend = errors.New(fmt.Sprintf("%s %d", ERROR_WEBHOOK_CLIENT_CHANGED, delivery.ClientPosition))
+	} else if details.WebhookUrl == "" {
+		errSend = errors.New(fmt.Sprintf("%s %d", ERROR_WEBHOOK_NOT_SET, delivery.ClientPosition))
+	} else {
+		delivery.Url = details.WebhookUrl
+		backoff := webhookBackoff
+		for {
+			if errSend = w.sender.Send(details, delivery.Txid); errSend == nil {
+				delivery.Delivered = true
+				w.completeDelivery(delivery)
+				return
+			}
+			delivery.Attempts++
+			log.Printf("*WebhookService* webhook of position %d attempt %d failed: %v\n",
+				delivery.ClientPosition, delivery.Attempts, errSend)
+			if delivery.Attempts >= WEBHOOK_ATTEMPTS {
+				break
+			}
+			select {
+			case <-time.After(backoff):
+			case <-w.ctx.Done():
+				w.completeDelivery(delivery)
+				return
+			}
+			backoff *= 2
+		}
+	}
+
+	delivery.LastError = errSend.Error()
+	delivery.FailedAt = time.Now().UTC()
+	w.completeDelivery(delivery)
+}
+
+// Save webhook delivery record logging any error and release the delivery
+// Records failing to save are still pending and are delivered again
+func (w *WebhookService) completeDelivery(delivery models.WebhookDelivery) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if errSave := w.server.SaveWebhookDelivery(delivery); errSave != nil {
+		log.Printf("*WebhookService* %v\n", errSave)
+	}
+	delete(w.inFlight, webhookDeliveryKey{delivery.Txid, delivery.ClientPosition})
+}
@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"mainstay/models"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Save attestation of txid as confirmed, adding its pending webhook deliveries
+func confirmTestAttestation(srv *server.Server, txid chainhash.Hash) {
+	commitment, _ := srv.GetAttestationCommitment(txid)
+	attestation := models.NewAttestation(txid, &commitment)
+	attestation.Confirmed = true
+	attestation.Info = models.AttestationInfo{Txid: txid.String()}
+	srv.UpdateLatestAttestation(*attestation)
+}
+
+// Start webhook service and return function stopping the service
+func startTestWebhookService(srv *server.Server) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	service := NewWebhookService(ctx, wg, srv)
+	wg.Add(1)
+	go service.Run()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// Test WebhookService notifications of confirmed attestations
+func TestWebhookService(t *testing.T) {
+	srv, txid := newTestWebhookServer()
+	webhookBackoff = time.Millisecond
+	defer func() { webhookBackoff = WEBHOOK_BACKOFF }()
+
+	requests := make(chan webhookRequest, WEBHOOK_ATTEMPTS)
+	webhook := newTestWebhook(http.StatusOK, requests)
+	defer webhook.Close()
+	failingRequests := make(chan webhookRequest, WEBHOOK_ATTEMPTS)
+	failingWebhook := newTestWebhook(http.StatusInternalServerError, failingRequests)
+	defer failingWebhook.Close()
+	srv.SetClientWebhook(0, webhook.URL, "secret0")
+	srv.SetClientWebhook(1, failingWebhook.URL, "secret1")
+
+	// deliveries are recorded pending with the confirmed attestation,
+	// and confirmations while the service is not running are notified
+	deliveries, _ := srv.GetPendingWebhookDeliveries()
+	assert.Equal(t, 0, len(deliveries))
+	confirmTestAttestation(srv, txid)
+	deliveries, _ = srv.GetPendingWebhookDeliveries()
+	assert.Equal(t, []models.WebhookDelivery{
+		models.WebhookDelivery{Txid: txid, ClientPosition: 0, Pubkey: "pubkey0", Url: webhook.URL},
+		models.WebhookDelivery{Txid: txid, ClientPosition: 1, Pubkey: "pubkey1", Url: failingWebhook.URL}},
+		deliveries)
+
+	stop := startTestWebhookService(srv)
+	request := <-requests
+	assert.Equal(t, Signature("secret0", request.timestamp, request.payload), request.signature)
+	for i := 0; i < WEBHOOK_ATTEMPTS; i++ {
+		<-failingRequests
+	}
+	stop()
+	assert.Equal(t, 0, len(requests))
+	assert.Equal(t, 0, len(failingRequests))
+
+	// delivery failing all attempts is recorded
+	deliveries, _ = srv.GetWebhookDeliveries(false)
+	assert.Equal(t, 1, len(deliveries))
+	assert.Equal(t, txid, deliveries[0].Txid)
+	assert.Equal(t, int32(1), deliveries[0].ClientPosition)
+	assert.Equal(t, "pubkey1", deliveries[0].Pubkey)
+	assert.Equal(t, failingWebhook.URL, deliveries[0].Url)
+	assert.Equal(t, int32(WEBHOOK_ATTEMPTS), deliveries[0].Attempts)
+	assert.Equal(t, fmt.Sprintf("%s %d", ERROR_WEBHOOK_STATUS, 500), deliveries[0].LastError)
+	assert.Equal(t, false, deliveries[0].Pending())
+
+	// successful delivery is recorded delivered
+	deliveries, _ = srv.GetWebhookDeliveries(true)
+	assert.Equal(t, 1, len(deliveries))
+	assert.Equal(t, int32(0), deliveries[0].ClientPosition)
+	assert.Equal(t, "pubkey0", deliveries[0].Pubkey)
+	assert.Equal(t, webhook.URL, deliveries[0].Url)
+	assert.Equal(t, int32(0), deliveries[0].Attempts)
+
+	// repeated attestation updates do not deliver again
+	confirmTestAttestation(srv, txid)
+	deliveries, _ = srv.GetPendingWebhookDeliveries()
+	assert.Equal(t, 0, len(deliveries))
+}
+
+// Test WebhookService resumes deliveries interrupted by shutdown
+func TestWebhookServiceShutdown(t *testing.T) {
+	srv, txid := newTestWebhookServer()
+	webhookBackoff = time.Hour
+	defer func() { webhookBackoff = WEBHOOK_BACKOFF }()
+
+	requests := make(chan webhookRequest, WEBHOOK_ATTEMPTS)
+	failingWebhook := newTestWebhook(http.StatusInternalServerError, requests)
+	defer failingWebhook.Close()
+	webhook := newTestWebhook(http.StatusOK, requests)
+	defer webhook.Close()
+	srv.SetClientWebhook(1, failingWebhook.URL, "secret1")
+
+	// delivery interrupted during backoff is kept pending with its attempts
+	stop := startTestWebhookService(srv)
+	confirmTestAttestation(srv, txid)
+	srv.PublishEvent(models.AttestationEvent{Type: models.EVENT_ATTESTATION_CONFIRMED, Txid: txid})
+	<-requests
+	stop()
+	deliveries, _ := srv.GetPendingWebhookDeliveries()
+	assert.Equal(t, 1, len(deliveries))
+	assert.Equal(t, int32(1), deliveries[0].ClientPosition)
+	assert.Equal(t, int32(1), deliveries[0].Attempts)
+
+	// next run resumes the delivery to the current webhook of the client
+	webhookBackoff = time.Millisecond
+	srv.SetClientWebhook(1, webhook.URL, "secret1")
+	stop = startTestWebhookService(srv)
+	request := <-requests
+	assert.Equal(t, Signature("secret1", request.timestamp, request.payload), request.signature)
+	stop()
+	deliveries, _ = srv.GetWebhookDeliveries(true)
+	assert.Equal(t, 1, len(deliveries))
+	assert.Equal(t, webhook.URL, deliveries[0].Url)
+	assert.Equal(t, int32(1), deliveries[0].Attempts)
+
+	// pending deliveries of a client no longer holding the position fail without attempts
+	txidY, _ := chainhash.NewHashFromStr("22222222222d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := srv.GetAttestationCommitment(txid)
+	srv.UpdateLatestAttestation(*models.NewAttestation(*txidY, &commitment))
+	confirmTestAttestation(srv, *txidY)
+	srv.SetClientStatus(1, models.CLIENT_STATUS_REVOKED)
+	srv.SignupClient("token2", "pubkey2")
+	stop = startTestWebhookService(srv)
+	stop()
+	assert.Equal(t, 0, len(requests))
+	deliveries, _ = srv.GetWebhookDeliveries(false)
+	assert.Equal(t, 1, len(deliveries))
+	assert.Equal(t, *txidY, deliveries[0].Txid)
+	assert.Equal(t, int32(0), deliveries[0].Attempts)
+	assert.Equal(t, fmt.Sprintf("%s %d", ERROR_WEBHOOK_CLIENT_CHANGED, 1), deliveries[0].LastError)
+}